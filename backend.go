@@ -0,0 +1,107 @@
+package gui
+
+import (
+	"os"
+	"strings"
+)
+
+// BackendEnv is the environment variable with a comma separated list of
+// backends to try in order, e.g. GUI_BACKEND=headless.
+const BackendEnv = "GUI_BACKEND"
+
+// Capability is a set of features which a backend may support.
+type Capability uint32
+
+// Capabilities
+const (
+	CapMultiWindow Capability = 1 << iota // Loop may be called more than once
+	CapClipboard
+	CapIME
+	CapDPI // the scale of the display is known
+	CapTransparency
+	CapMenuBar   // Window.SetMenu shows a menu bar
+	CapPopupMenu // Window.PopupMenu
+	CapKeyboard
+	CapPointer
+	CapOpenGL // Window.CreateGLContext
+)
+
+var capabilityNames = []string{
+	"MultiWindow",
+	"Clipboard",
+	"IME",
+	"DPI",
+	"Transparency",
+	"MenuBar",
+	"PopupMenu",
+	"Keyboard",
+	"Pointer",
+	"OpenGL",
+}
+
+func (c Capability) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if c&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "None"
+	}
+	return strings.Join(names, "|")
+}
+
+// Option is an option of NewApplication.
+type Option func(o *options)
+
+type options struct {
+	backends []string
+
+	errorPolicy  ErrorPolicy
+	errorHandler func(err *RendererError) error
+
+	tracer          Tracer
+	traceCategories TraceCategory
+
+	clock     *VirtualClock
+	observers []DispatchObserver
+
+	apartment  Apartment
+	threadInit func(window string) (func(), error)
+
+	restoreOnSignal bool
+}
+
+// WithBackend sets the backends to try in order. It takes precedence over GUI_BACKEND.
+func WithBackend(names ...string) Option {
+	return func(o *options) {
+		o.backends = append([]string(nil), names...)
+	}
+}
+
+// WithRestoreOnSignal makes the fbdev backend give the virtual terminal
+// back to the console when SIGINT or SIGTERM arrives and then raise the
+// signal again for its default action. Without it, the signals are left to
+// the application, which must Quit or Deinit to restore the terminal.
+func WithRestoreOnSignal() Option {
+	return func(o *options) {
+		o.restoreOnSignal = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if len(o.backends) == 0 {
+		for _, name := range strings.Split(os.Getenv(BackendEnv), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				o.backends = append(o.backends, strings.ToLower(name))
+			}
+		}
+	}
+	return o
+}
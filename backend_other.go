@@ -0,0 +1,103 @@
+// +build !windows
+
+package gui
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// backend is a display backend which creates a driver for each window.
+type backend struct {
+	name     string
+	priority int  // lower is tried first
+	explicit bool // used only if named, e.g. servers
+	caps     Capability
+
+	// probe reports whether the backend can be used, e.g. a display server is running.
+	probe     func() error
+	newDriver func() (driver, error)
+}
+
+var backends = make(map[string]*backend)
+
+// registerBackend adds b to the backends. It is called from init functions.
+func registerBackend(b *backend) {
+	backends[b.name] = b
+}
+
+// defaultBackends returns the names of the registered backends by priority.
+func defaultBackends() []string {
+	list := make([]*backend, 0, len(backends))
+	for _, b := range backends {
+		if !b.explicit {
+			list = append(list, b)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].priority < list[j].priority
+	})
+
+	names := make([]string, len(list))
+	for i, b := range list {
+		names[i] = b.name
+	}
+	return names
+}
+
+// selectBackend returns the first usable backend of names, or of all backends if names is empty.
+func selectBackend(names []string) (*backend, error) {
+	if len(names) == 0 {
+		names = defaultBackends()
+	}
+
+	var errs []string
+	for _, name := range names {
+		b, ok := backends[name]
+		if !ok {
+			errs = append(errs, name+": unknown backend")
+			continue
+		}
+		// probe errors are prefixed with the backend name
+		if err := b.probe(); err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoBackend, strings.Join(errs, ", "))
+}
+
+// listenFrom listens on the TCP addr, or on one of the next ports if it is in use.
+// Server backends use it to give each window its own port.
+func listenFrom(addr string) (net.Listener, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", addr, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", addr, err)
+	}
+
+	for i := 0; ; i++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(p+i)))
+		if err == nil {
+			return ln, nil
+		}
+		if p == 0 || i == 99 {
+			return nil, err
+		}
+	}
+}
+
+// clientMessage is the native name of a message of a client of a server
+// backend. The client goroutines send it before the events of the message
+// if the driver traces, and poll traces it on the loop thread.
+type clientMessage struct {
+	category     TraceCategory
+	name, detail string
+}
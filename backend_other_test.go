@@ -0,0 +1,142 @@
+// +build !windows
+
+package gui
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// registerTestBackend registers b for the test.
+func registerTestBackend(t *testing.T, b *backend) {
+	registerBackend(b)
+	t.Cleanup(func() { delete(backends, b.name) })
+}
+
+func TestBackendPrecedence(t *testing.T) {
+	setenv(t, BackendEnv, "nope")
+
+	app := NewApplication(WithBackend("headless"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	if app.Backend() != "headless" {
+		t.Errorf("backend %q, want headless of WithBackend", app.Backend())
+	}
+
+	// names are trimmed and lowered, and the unknown one is skipped
+	setenv(t, BackendEnv, " nope , Headless")
+	app = NewApplication()
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	if app.Backend() != "headless" {
+		t.Errorf("backend %q, want headless of %s", app.Backend(), BackendEnv)
+	}
+}
+
+func TestBackendUnknown(t *testing.T) {
+	app := NewApplication(WithBackend("nope"))
+	err := app.Init()
+	if !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("Init: %v, want ErrNoBackend", err)
+	}
+	if !strings.Contains(err.Error(), "nope: unknown backend") {
+		t.Errorf("Init: %v, want the unknown name", err)
+	}
+	if app.Backend() != "" {
+		t.Errorf("backend %q without Init", app.Backend())
+	}
+	app.Deinit()
+}
+
+func TestNoBackend(t *testing.T) {
+	probeErr := errors.New("failing: no display")
+	registerTestBackend(t, &backend{
+		name:     "failing",
+		explicit: true,
+		probe: func() error {
+			return probeErr
+		},
+	})
+
+	app := NewApplication(WithBackend("failing", "nope"))
+	err := app.Init()
+	if !errors.Is(err, ErrNoBackend) {
+		t.Fatalf("Init: %v, want ErrNoBackend", err)
+	}
+	want := "gui: no backend available: failing: no display, nope: unknown backend"
+	if err.Error() != want {
+		t.Errorf("Init: %q, want %q", err, want)
+	}
+
+	// the next usable backend is selected
+	app = NewApplication(WithBackend("failing", "headless"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	if app.Backend() != "headless" {
+		t.Errorf("backend %q, want headless", app.Backend())
+	}
+}
+
+func TestDefaultBackends(t *testing.T) {
+	names := defaultBackends()
+	for i, name := range names {
+		b := backends[name]
+		if b.explicit {
+			t.Errorf("explicit backend %q is a default", name)
+		}
+		if i > 0 && backends[names[i-1]].priority > b.priority {
+			t.Errorf("backends %v are not in priority order", names)
+		}
+	}
+	if len(names) == 0 || names[len(names)-1] != "headless" {
+		t.Errorf("backends %v, want headless last", names)
+	}
+}
+
+func TestSupports(t *testing.T) {
+	app := NewApplication(WithBackend("headless"))
+	if app.Supports(0) {
+		t.Error("Supports without a backend")
+	}
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+
+	for _, c := range []struct {
+		caps Capability
+		want bool
+	}{
+		{0, true},
+		{CapMultiWindow, true},
+		{CapMultiWindow | CapMenuBar | CapPopupMenu, true},
+		{CapClipboard, false},
+		{CapMultiWindow | CapIME, false},
+	} {
+		if got := app.Supports(c.caps); got != c.want {
+			t.Errorf("Supports(%v) = %v, want %v", c.caps, got, c.want)
+		}
+	}
+}
+
+func TestCapabilityString(t *testing.T) {
+	for _, c := range []struct {
+		caps Capability
+		want string
+	}{
+		{0, "None"},
+		{CapDPI, "DPI"},
+		{CapMultiWindow | CapClipboard | CapOpenGL, "MultiWindow|Clipboard|OpenGL"},
+	} {
+		if got := c.caps.String(); got != c.want {
+			t.Errorf("%d: %q, want %q", uint32(c.caps), got, c.want)
+		}
+	}
+}
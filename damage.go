@@ -0,0 +1,75 @@
+package gui
+
+import (
+	"image"
+)
+
+// maxDamageRects limits the rectangles of a damage. More are merged into their bounds.
+const maxDamageRects = 16
+
+// damage is a region to redraw as a list of rectangles.
+// Overlapping and adjacent rectangles are merged when they are added.
+type damage struct {
+	rects []image.Rectangle
+}
+
+// add adds r clipped to bounds.
+func (d *damage) add(r, bounds image.Rectangle) {
+	r = r.Intersect(bounds)
+	if r.Empty() {
+		return
+	}
+
+	// merging can make the result touch the others, so start over
+	for i := 0; i < len(d.rects); {
+		if touches(d.rects[i], r) {
+			r = r.Union(d.rects[i])
+			d.rects = append(d.rects[:i], d.rects[i+1:]...)
+			i = 0
+			continue
+		}
+		i++
+	}
+	d.rects = append(d.rects, r)
+
+	if len(d.rects) > maxDamageRects {
+		d.rects = []image.Rectangle{d.bounds()}
+	}
+}
+
+// addAll adds each of rects clipped to bounds.
+func (d *damage) addAll(rects []image.Rectangle, bounds image.Rectangle) {
+	for _, r := range rects {
+		d.add(r, bounds)
+	}
+}
+
+// touches reports whether a and b overlap or share a part of an edge.
+// Rectangles meeting only at a corner do not touch.
+func touches(a, b image.Rectangle) bool {
+	overlapX := a.Min.X < b.Max.X && b.Min.X < a.Max.X
+	overlapY := a.Min.Y < b.Max.Y && b.Min.Y < a.Max.Y
+	meetX := a.Min.X <= b.Max.X && b.Min.X <= a.Max.X
+	meetY := a.Min.Y <= b.Max.Y && b.Min.Y <= a.Max.Y
+	return overlapX && meetY || overlapY && meetX
+}
+
+func (d *damage) empty() bool {
+	return len(d.rects) == 0
+}
+
+// bounds returns the smallest rectangle containing the damage.
+func (d *damage) bounds() image.Rectangle {
+	var b image.Rectangle
+	for _, r := range d.rects {
+		b = b.Union(r)
+	}
+	return b
+}
+
+// take returns the rectangles and clears the damage.
+func (d *damage) take() []image.Rectangle {
+	rects := d.rects
+	d.rects = nil
+	return rects
+}
@@ -0,0 +1,87 @@
+package gui
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestDamage(t *testing.T) {
+	bounds := image.Rect(0, 0, 100, 100)
+	for _, test := range []struct {
+		name string
+		add  []image.Rectangle
+		want []image.Rectangle
+	}{
+		{
+			"overlapping",
+			[]image.Rectangle{image.Rect(0, 0, 20, 20), image.Rect(10, 10, 30, 30)},
+			[]image.Rectangle{image.Rect(0, 0, 30, 30)},
+		},
+		{
+			"contained",
+			[]image.Rectangle{image.Rect(0, 0, 50, 50), image.Rect(10, 10, 20, 20)},
+			[]image.Rectangle{image.Rect(0, 0, 50, 50)},
+		},
+		{
+			"shared vertical edge",
+			[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(10, 5, 20, 15)},
+			[]image.Rectangle{image.Rect(0, 0, 20, 15)},
+		},
+		{
+			"shared horizontal edge",
+			[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(0, 10, 10, 20)},
+			[]image.Rectangle{image.Rect(0, 0, 10, 20)},
+		},
+		{
+			"corner",
+			[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(10, 10, 20, 20)},
+			[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(10, 10, 20, 20)},
+		},
+		{
+			"apart",
+			[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(30, 0, 40, 10)},
+			[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(30, 0, 40, 10)},
+		},
+		{
+			// the union of the first two touches the third
+			"chained",
+			[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(30, 0, 40, 10), image.Rect(5, 5, 35, 8)},
+			[]image.Rectangle{image.Rect(0, 0, 40, 10)},
+		},
+		{
+			"clipped",
+			[]image.Rectangle{image.Rect(-10, -10, 10, 10), image.Rect(90, 50, 200, 60)},
+			[]image.Rectangle{image.Rect(0, 0, 10, 10), image.Rect(90, 50, 100, 60)},
+		},
+		{
+			"outside",
+			[]image.Rectangle{image.Rect(100, 0, 110, 10), image.Rect(-5, -5, 0, 0), image.Rect(5, 5, 5, 10)},
+			nil,
+		},
+	} {
+		var d damage
+		d.addAll(test.add, bounds)
+		if got := d.take(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+		if !d.empty() {
+			t.Errorf("%s: not empty after take", test.name)
+		}
+	}
+}
+
+func TestDamageLimit(t *testing.T) {
+	bounds := image.Rect(0, 0, 1000, 1000)
+	var d damage
+	for i := 0; i < maxDamageRects; i++ {
+		d.add(image.Rect(i*20, 0, i*20+10, 10), bounds)
+	}
+	if len(d.rects) != maxDamageRects {
+		t.Fatalf("%d rectangles, want %d", len(d.rects), maxDamageRects)
+	}
+	d.add(image.Rect(0, 500, 10, 510), bounds)
+	if want := []image.Rectangle{image.Rect(0, 0, (maxDamageRects-1)*20+10, 510)}; !reflect.DeepEqual(d.rects, want) {
+		t.Errorf("%v, want %v", d.rects, want)
+	}
+}
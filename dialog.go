@@ -0,0 +1,145 @@
+package gui
+
+import (
+	"errors"
+	"image/color"
+	"sync"
+)
+
+// ErrCanceled is returned when the user dismisses a dialog.
+var ErrCanceled = errors.New("gui: dialog canceled")
+
+// DialogButtons selects the buttons of a message box.
+type DialogButtons int
+
+// Message box buttons
+const (
+	ButtonsOK DialogButtons = iota
+	ButtonsOKCancel
+	ButtonsYesNo
+	ButtonsYesNoCancel
+	ButtonsRetryCancel
+)
+
+// DialogIcon selects the icon of a message box.
+type DialogIcon int
+
+// Message box icons
+const (
+	IconNone DialogIcon = iota
+	IconInfo
+	IconWarning
+	IconError
+	IconQuestion
+)
+
+// DialogResult is the button pressed to close a message box.
+type DialogResult int
+
+// Message box results
+const (
+	ResultNone DialogResult = iota
+	ResultOK
+	ResultCancel
+	ResultYes
+	ResultNo
+	ResultRetry
+)
+
+// FileFilter is a named set of glob patterns like "*.png".
+type FileFilter struct {
+	Name     string
+	Patterns []string
+}
+
+// FileDialogOptions are the parameters of the open and save file dialogs.
+type FileDialogOptions struct {
+	Title     string
+	Directory string // initial directory
+	FileName  string // initial file name
+	Filters   []FileFilter
+	Multiple  bool // open only
+}
+
+// DialogProvider shows modal dialogs.
+// Owner is a native window handle as passed to Renderer.Draw, or 0.
+type DialogProvider interface {
+	MessageBox(owner uintptr, title, text string, buttons DialogButtons, icon DialogIcon) (DialogResult, error)
+	OpenFile(owner uintptr, opts *FileDialogOptions) ([]string, error)
+	SaveFile(owner uintptr, opts *FileDialogOptions) (string, error)
+	SelectFolder(owner uintptr, title string, directory string) (string, error)
+	PickColor(owner uintptr, initial color.Color) (color.RGBA, error)
+}
+
+var (
+	dialogMu       sync.Mutex
+	dialogProvider DialogProvider = newNativeDialogs()
+)
+
+// SetDialogProvider replaces the dialog implementation, e.g. with a fake for tests.
+// It returns the previous provider.
+func SetDialogProvider(p DialogProvider) DialogProvider {
+	dialogMu.Lock()
+	defer dialogMu.Unlock()
+
+	prev := dialogProvider
+	if p == nil {
+		p = newNativeDialogs()
+	}
+	dialogProvider = p
+	return prev
+}
+
+func dialogs() DialogProvider {
+	dialogMu.Lock()
+	defer dialogMu.Unlock()
+
+	return dialogProvider
+}
+
+// MessageBox shows a message box and returns the pressed button.
+//
+// On Windows it is a modal MessageBoxEx. The desktop portal has no message
+// box, so the other platforms show a desktop notification with the buttons
+// as actions and wait for one of them. It is not modal, and servers without
+// actions show no buttons, so only closing the notification answers it.
+// There is no fallback: an error is returned without a session bus or a
+// notification server.
+func MessageBox(owner uintptr, title, text string, buttons DialogButtons, icon DialogIcon) (DialogResult, error) {
+	return dialogs().MessageBox(owner, title, text, buttons, icon)
+}
+
+// OpenFile asks the user for existing files.
+// It returns ErrCanceled if the dialog is dismissed.
+func OpenFile(owner uintptr, opts *FileDialogOptions) ([]string, error) {
+	if opts == nil {
+		opts = &FileDialogOptions{}
+	}
+	return dialogs().OpenFile(owner, opts)
+}
+
+// SaveFile asks the user for a file name to save to.
+// It returns ErrCanceled if the dialog is dismissed.
+func SaveFile(owner uintptr, opts *FileDialogOptions) (string, error) {
+	if opts == nil {
+		opts = &FileDialogOptions{}
+	}
+	return dialogs().SaveFile(owner, opts)
+}
+
+// SelectFolder asks the user for a directory.
+// It returns ErrCanceled if the dialog is dismissed.
+func SelectFolder(owner uintptr, title string, directory string) (string, error) {
+	return dialogs().SelectFolder(owner, title, directory)
+}
+
+// PickColor asks the user for a color, starting at initial on Windows.
+// On the other platforms the color is picked from the screen by
+// xdg-desktop-portal and initial is ignored.
+// It returns ErrCanceled if the dialog is dismissed.
+func PickColor(owner uintptr, initial color.Color) (color.RGBA, error) {
+	if initial == nil {
+		initial = color.White
+	}
+	return dialogs().PickColor(owner, initial)
+}
@@ -0,0 +1,209 @@
+// +build !windows
+
+package gui
+
+import (
+	"fmt"
+	"image/color"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ysh86/gui/internal/dbus"
+)
+
+const (
+	portalName = "org.freedesktop.portal.Desktop"
+	portalPath = dbus.ObjectPath("/org/freedesktop/portal/desktop")
+)
+
+// nativeDialogs uses xdg-desktop-portal over the session bus.
+// The portal has no message box, so MessageBox shows a notification of
+// org.freedesktop.Notifications with the buttons as actions. It fails if no
+// notification server is running, see MessageBox.
+type nativeDialogs struct{}
+
+var portalToken uint32
+
+func newNativeDialogs() DialogProvider {
+	return &nativeDialogs{}
+}
+
+func (d *nativeDialogs) MessageBox(owner uintptr, title, text string, buttons DialogButtons, icon DialogIcon) (DialogResult, error) {
+	n, err := sessionNotifier()
+	if err != nil {
+		return ResultNone, fmt.Errorf("MessageBox: %v", err)
+	}
+	return n.messageBox(title, text, buttons, icon)
+}
+
+func (d *nativeDialogs) OpenFile(owner uintptr, opts *FileDialogOptions) ([]string, error) {
+	options := fileChooserOptions(opts)
+	options["multiple"] = dbus.MakeVariant(opts.Multiple)
+
+	results, err := portalRequest("org.freedesktop.portal.FileChooser", "OpenFile", opts.Title, options)
+	if err != nil {
+		return nil, err
+	}
+	return portalURIs(results)
+}
+
+func (d *nativeDialogs) SaveFile(owner uintptr, opts *FileDialogOptions) (string, error) {
+	options := fileChooserOptions(opts)
+	if opts.FileName != "" {
+		options["current_name"] = dbus.MakeVariant(opts.FileName)
+	}
+
+	results, err := portalRequest("org.freedesktop.portal.FileChooser", "SaveFile", opts.Title, options)
+	if err != nil {
+		return "", err
+	}
+	files, err := portalURIs(results)
+	if err != nil {
+		return "", err
+	}
+	return files[0], nil
+}
+
+func (d *nativeDialogs) SelectFolder(owner uintptr, title string, directory string) (string, error) {
+	options := fileChooserOptions(&FileDialogOptions{Directory: directory})
+	options["directory"] = dbus.MakeVariant(true)
+
+	results, err := portalRequest("org.freedesktop.portal.FileChooser", "OpenFile", title, options)
+	if err != nil {
+		return "", err
+	}
+	files, err := portalURIs(results)
+	if err != nil {
+		return "", err
+	}
+	return files[0], nil
+}
+
+// PickColor picks a color from the screen with the portal, which has no
+// initial color, so initial is ignored.
+func (d *nativeDialogs) PickColor(owner uintptr, initial color.Color) (color.RGBA, error) {
+	results, err := portalRequest("org.freedesktop.portal.Screenshot", "PickColor", "", map[string]dbus.Variant{})
+	if err != nil {
+		return color.RGBA{}, err
+	}
+
+	v, ok := results["color"].(dbus.Variant)
+	if !ok {
+		return color.RGBA{}, fmt.Errorf("PickColor: no color in %v", results)
+	}
+	rgb, ok := v.Value.([]interface{})
+	if !ok || len(rgb) != 3 {
+		return color.RGBA{}, fmt.Errorf("PickColor: invalid color %v", v.Value)
+	}
+	c := color.RGBA{A: 0xff}
+	for i, p := range []*uint8{&c.R, &c.G, &c.B} {
+		f, _ := rgb[i].(float64)
+		*p = uint8(f*255 + 0.5)
+	}
+	return c, nil
+}
+
+func fileChooserOptions(opts *FileDialogOptions) map[string]dbus.Variant {
+	options := map[string]dbus.Variant{
+		"modal": dbus.MakeVariant(true),
+	}
+	if opts.Directory != "" {
+		// a NUL terminated byte string
+		options["current_folder"] = dbus.MakeVariant(append([]byte(opts.Directory), 0))
+	}
+	if len(opts.Filters) > 0 {
+		// a(sa(us)), 0 is a glob pattern
+		var filters []interface{}
+		for _, f := range opts.Filters {
+			var patterns []interface{}
+			for _, p := range f.Patterns {
+				patterns = append(patterns, []interface{}{uint32(0), p})
+			}
+			filters = append(filters, []interface{}{f.Name, patterns})
+		}
+		options["filters"] = dbus.Variant{Sig: "a(sa(us))", Value: filters}
+	}
+	return options
+}
+
+// portalRequest calls a portal method and waits for the Response signal of its request object.
+func portalRequest(iface, method, title string, options map[string]dbus.Variant) (map[interface{}]interface{}, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", method, err)
+	}
+	defer conn.Close()
+
+	// subscribe before calling, the request path is predictable from the token
+	token := "gui" + strconv.Itoa(os.Getpid()) + "_" + strconv.FormatUint(uint64(atomic.AddUint32(&portalToken, 1)), 10)
+	sender := strings.Replace(strings.TrimPrefix(conn.Name(), ":"), ".", "_", -1)
+	path := dbus.ObjectPath("/org/freedesktop/portal/desktop/request/" + sender + "/" + token)
+	options["handle_token"] = dbus.MakeVariant(token)
+
+	signals := make(chan *dbus.Message, 8)
+	conn.Signal(signals)
+	rule := "type='signal',interface='org.freedesktop.portal.Request',member='Response',path='" + string(path) + "'"
+	if err := conn.AddMatch(rule); err != nil {
+		return nil, fmt.Errorf("%s: %v", method, err)
+	}
+
+	var reply *dbus.Message
+	if method == "PickColor" {
+		reply, err = conn.Call(portalName, portalPath, iface, method, "sa{sv}", "", options)
+	} else {
+		reply, err = conn.Call(portalName, portalPath, iface, method, "ssa{sv}", "", title, options)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", method, err)
+	}
+	if len(reply.Body) > 0 {
+		if p, ok := reply.Body[0].(dbus.ObjectPath); ok {
+			path = p
+		}
+	}
+
+	for m := range signals {
+		if m.Path != path || m.Member != "Response" || len(m.Body) < 2 {
+			continue
+		}
+		switch code, _ := m.Body[0].(uint32); code {
+		case 0:
+			results, _ := m.Body[1].(map[interface{}]interface{})
+			return results, nil
+		case 1:
+			return nil, ErrCanceled
+		default:
+			return nil, fmt.Errorf("%s: response %d", method, code)
+		}
+	}
+	return nil, fmt.Errorf("%s: %v", method, dbus.ErrClosed)
+}
+
+func portalURIs(results map[interface{}]interface{}) ([]string, error) {
+	v, ok := results["uris"].(dbus.Variant)
+	if !ok {
+		return nil, ErrCanceled
+	}
+	uris, _ := v.Value.([]interface{})
+
+	var files []string
+	for _, u := range uris {
+		s, _ := u.(string)
+		parsed, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("url.Parse %s: %v", s, err)
+		}
+		if parsed.Scheme == "file" {
+			files = append(files, parsed.Path)
+		} else {
+			files = append(files, s)
+		}
+	}
+	if len(files) == 0 {
+		return nil, ErrCanceled
+	}
+	return files, nil
+}
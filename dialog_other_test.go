@@ -0,0 +1,111 @@
+// +build !windows
+
+package gui
+
+import (
+	"testing"
+)
+
+func TestMessageBoxNotification(t *testing.T) {
+	addr := startBus(t)
+	server := newFakeNotifications(t, addr)
+
+	tests := []struct {
+		buttons DialogButtons
+		icon    DialogIcon
+		action  string // key to invoke, or "" to dismiss
+		labels  []string
+		want    DialogResult
+		err     error
+	}{
+		{ButtonsOK, IconInfo, "0", []string{"OK"}, ResultOK, nil},
+		{ButtonsYesNoCancel, IconQuestion, "1", []string{"Yes", "No", "Cancel"}, ResultNo, nil},
+		{ButtonsOKCancel, IconWarning, "", []string{"OK", "Cancel"}, ResultCancel, nil},
+		{ButtonsOK, IconError, "", []string{"OK"}, ResultOK, nil},
+		{ButtonsYesNo, IconNone, "", []string{"Yes", "No"}, ResultNone, ErrCanceled},
+	}
+	for i, tt := range tests {
+		type result struct {
+			r   DialogResult
+			err error
+		}
+		done := make(chan result, 1)
+		go func() {
+			r, err := MessageBox(0, "title", "text", tt.buttons, tt.icon)
+			done <- result{r, err}
+		}()
+
+		c := server.nextCall(t)
+		if c.summary != "title" || c.body != "text" || c.timeout != 0 {
+			t.Errorf("%d: Notify(%q, %q, timeout %d)", i, c.summary, c.body, c.timeout)
+		}
+		if c.appIcon != dialogIconNames[tt.icon] {
+			t.Errorf("%d: icon = %q, want %q", i, c.appIcon, dialogIconNames[tt.icon])
+		}
+		var labels []string
+		for j := 1; j < len(c.actions); j += 2 {
+			labels = append(labels, c.actions[j])
+		}
+		if len(labels) != len(tt.labels) {
+			t.Fatalf("%d: actions = %q, want labels %q", i, c.actions, tt.labels)
+		}
+		for j := range labels {
+			if labels[j] != tt.labels[j] {
+				t.Errorf("%d: label %d = %q, want %q", i, j, labels[j], tt.labels[j])
+			}
+		}
+
+		if tt.action != "" {
+			server.invoke(t, c.id, tt.action)
+		}
+		// 2 is dismissed by the user
+		server.close(t, c.id, 2)
+		select {
+		case res := <-done:
+			if res.r != tt.want || res.err != tt.err {
+				t.Errorf("%d: MessageBox = %v, %v, want %v, %v", i, res.r, res.err, tt.want, tt.err)
+			}
+		case <-testTimeout():
+			t.Fatalf("%d: MessageBox did not return", i)
+		}
+	}
+}
+
+func TestMessageBoxLostBus(t *testing.T) {
+	addr := startBus(t)
+	server := newFakeNotifications(t, addr)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := MessageBox(0, "title", "text", ButtonsOK, IconNone)
+		done <- err
+	}()
+	server.nextCall(t)
+	resetNotifier()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("MessageBox succeeded after the connection was lost")
+		}
+	case <-testTimeout():
+		t.Fatal("MessageBox did not return")
+	}
+}
+
+func TestMessageBoxWithoutServer(t *testing.T) {
+	startBus(t)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := MessageBox(0, "title", "text", ButtonsOK, IconNone)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("MessageBox succeeded without a notification server")
+		}
+	case <-testTimeout():
+		t.Fatal("MessageBox waits without a notification server")
+	}
+}
@@ -0,0 +1,93 @@
+package gui
+
+import (
+	"image/color"
+	"testing"
+)
+
+// fakeDialogs records the calls and answers with fixed results.
+type fakeDialogs struct {
+	calls []string
+	opts  *FileDialogOptions
+	color color.Color
+}
+
+func (d *fakeDialogs) MessageBox(owner uintptr, title, text string, buttons DialogButtons, icon DialogIcon) (DialogResult, error) {
+	d.calls = append(d.calls, "MessageBox "+title+": "+text)
+	if buttons == ButtonsYesNo {
+		return ResultNo, nil
+	}
+	return ResultOK, nil
+}
+
+func (d *fakeDialogs) OpenFile(owner uintptr, opts *FileDialogOptions) ([]string, error) {
+	d.calls = append(d.calls, "OpenFile")
+	d.opts = opts
+	return []string{"a.png", "b.png"}, nil
+}
+
+func (d *fakeDialogs) SaveFile(owner uintptr, opts *FileDialogOptions) (string, error) {
+	d.calls = append(d.calls, "SaveFile")
+	d.opts = opts
+	return "", ErrCanceled
+}
+
+func (d *fakeDialogs) SelectFolder(owner uintptr, title string, directory string) (string, error) {
+	d.calls = append(d.calls, "SelectFolder "+directory)
+	return directory, nil
+}
+
+func (d *fakeDialogs) PickColor(owner uintptr, initial color.Color) (color.RGBA, error) {
+	d.calls = append(d.calls, "PickColor")
+	d.color = initial
+	return color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}, nil
+}
+
+func TestSetDialogProvider(t *testing.T) {
+	fake := &fakeDialogs{}
+	prev := SetDialogProvider(fake)
+	defer SetDialogProvider(prev)
+
+	if r, err := MessageBox(0, "title", "text", ButtonsYesNo, IconQuestion); r != ResultNo || err != nil {
+		t.Errorf("MessageBox = %v, %v, want ResultNo", r, err)
+	}
+	if files, err := OpenFile(0, nil); len(files) != 2 || err != nil {
+		t.Errorf("OpenFile = %v, %v", files, err)
+	}
+	if fake.opts == nil {
+		t.Error("OpenFile passed nil options to the provider")
+	}
+	if _, err := SaveFile(0, &FileDialogOptions{FileName: "x.png"}); err != ErrCanceled {
+		t.Errorf("SaveFile error = %v, want ErrCanceled", err)
+	}
+	if fake.opts.FileName != "x.png" {
+		t.Errorf("SaveFile options = %+v", fake.opts)
+	}
+	if dir, err := SelectFolder(0, "", "/tmp"); dir != "/tmp" || err != nil {
+		t.Errorf("SelectFolder = %q, %v", dir, err)
+	}
+	if c, err := PickColor(0, nil); c != (color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}) || err != nil {
+		t.Errorf("PickColor = %v, %v", c, err)
+	}
+	if fake.color != color.White {
+		t.Errorf("PickColor initial = %v, want white", fake.color)
+	}
+
+	want := []string{"MessageBox title: text", "OpenFile", "SaveFile", "SelectFolder /tmp", "PickColor"}
+	if len(fake.calls) != len(want) {
+		t.Fatalf("calls = %q, want %q", fake.calls, want)
+	}
+	for i := range want {
+		if fake.calls[i] != want[i] {
+			t.Errorf("call %d = %q, want %q", i, fake.calls[i], want[i])
+		}
+	}
+
+	// nil restores the native dialogs
+	if p := SetDialogProvider(nil); p != fake {
+		t.Errorf("SetDialogProvider returned %T, want the fake", p)
+	}
+	if _, ok := dialogs().(*fakeDialogs); ok {
+		t.Error("SetDialogProvider(nil) kept the fake")
+	}
+}
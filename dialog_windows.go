@@ -0,0 +1,252 @@
+package gui
+
+import (
+	"fmt"
+	"image/color"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// nativeDialogs uses the Windows message box and common dialogs.
+type nativeDialogs struct {
+	custColors [16]uint32
+}
+
+func newNativeDialogs() DialogProvider {
+	return &nativeDialogs{}
+}
+
+func (d *nativeDialogs) MessageBox(owner uintptr, title, text string, buttons DialogButtons, icon DialogIcon) (DialogResult, error) {
+	titleUTF16, err := windows.UTF16PtrFromString(title)
+	if err != nil {
+		return ResultNone, fmt.Errorf("UTF16PtrFromString %s: %v", title, err)
+	}
+	textUTF16, err := windows.UTF16PtrFromString(text)
+	if err != nil {
+		return ResultNone, fmt.Errorf("UTF16PtrFromString %s: %v", text, err)
+	}
+
+	var style uint32
+	switch buttons {
+	case ButtonsOKCancel:
+		style = MB_OKCANCEL
+	case ButtonsYesNo:
+		style = MB_YESNO
+	case ButtonsYesNoCancel:
+		style = MB_YESNOCANCEL
+	case ButtonsRetryCancel:
+		style = MB_RETRYCANCEL
+	default:
+		style = MB_OK
+	}
+	switch icon {
+	case IconInfo:
+		style |= MB_ICONINFORMATION
+	case IconWarning:
+		style |= MB_ICONWARNING
+	case IconError:
+		style |= MB_ICONERROR
+	case IconQuestion:
+		style |= MB_ICONQUESTION
+	}
+	if owner == 0 {
+		style |= MB_TASKMODAL
+	}
+
+	id, err := MessageBoxEx(windows.Handle(owner), textUTF16, titleUTF16, style, 0)
+	if id == 0 {
+		return ResultNone, fmt.Errorf("MessageBoxEx: %v", err)
+	}
+
+	switch id {
+	case IDOK:
+		return ResultOK, nil
+	case IDCANCEL:
+		return ResultCancel, nil
+	case IDYES:
+		return ResultYes, nil
+	case IDNO:
+		return ResultNo, nil
+	case IDRETRY:
+		return ResultRetry, nil
+	}
+	return ResultNone, nil
+}
+
+func (d *nativeDialogs) OpenFile(owner uintptr, opts *FileDialogOptions) ([]string, error) {
+	flags := uint32(OFN_EXPLORER | OFN_FILEMUSTEXIST | OFN_PATHMUSTEXIST | OFN_HIDEREADONLY | OFN_NOCHANGEDIR)
+	if opts.Multiple {
+		flags |= OFN_ALLOWMULTISELECT
+	}
+
+	buf, err := d.fileDialog(owner, opts, flags, GetOpenFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	// "dir\0file1\0file2\0\0" for multiple files, "path\0\0" for one
+	var parts []string
+	for len(buf) > 0 && buf[0] != 0 {
+		n := 0
+		for buf[n] != 0 {
+			n++
+		}
+		parts = append(parts, windows.UTF16ToString(buf[:n]))
+		buf = buf[n+1:]
+	}
+	if len(parts) <= 1 {
+		return parts, nil
+	}
+	dir := strings.TrimSuffix(parts[0], `\`)
+	files := make([]string, 0, len(parts)-1)
+	for _, f := range parts[1:] {
+		files = append(files, dir+`\`+f)
+	}
+	return files, nil
+}
+
+func (d *nativeDialogs) SaveFile(owner uintptr, opts *FileDialogOptions) (string, error) {
+	flags := uint32(OFN_EXPLORER | OFN_OVERWRITEPROMPT | OFN_PATHMUSTEXIST | OFN_HIDEREADONLY | OFN_NOCHANGEDIR)
+
+	buf, err := d.fileDialog(owner, opts, flags, GetSaveFileName)
+	if err != nil {
+		return "", err
+	}
+	return windows.UTF16ToString(buf), nil
+}
+
+func (d *nativeDialogs) fileDialog(owner uintptr, opts *FileDialogOptions, flags uint32, show func(*OpenFileName) bool) ([]uint16, error) {
+	// the dialog runs a modal message loop on this thread
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// "Name\0*.a;*.b\0...\0\0"
+	var filter []uint16
+	for _, f := range opts.Filters {
+		patterns := strings.Join(f.Patterns, ";")
+		filter = append(filter, utf16z(f.Name)...)
+		filter = append(filter, utf16z(patterns)...)
+	}
+	var filterPtr *uint16
+	if len(filter) > 0 {
+		filter = append(filter, 0)
+		filterPtr = &filter[0]
+	}
+
+	size := uint32(MAX_PATH)
+	if opts.Multiple {
+		size = 32 * 1024
+	}
+	for {
+		buf := make([]uint16, size)
+		copy(buf[:size-1], utf16z(opts.FileName))
+
+		ofn := &OpenFileName{
+			Owner:       windows.Handle(owner),
+			Filter:      filterPtr,
+			FilterIndex: 1,
+			File:        &buf[0],
+			MaxFile:     size,
+			InitialDir:  utf16PtrOrNil(opts.Directory),
+			Title:       utf16PtrOrNil(opts.Title),
+			Flags:       flags,
+		}
+		ofn.StructSize = uint32(unsafe.Sizeof(*ofn))
+
+		if show(ofn) {
+			return buf, nil
+		}
+		code := CommDlgExtendedError()
+		switch code {
+		case 0:
+			return nil, ErrCanceled
+		case FNERR_BUFFERTOOSMALL:
+			// The first character holds the required size, which is
+			// truncated if it does not fit in 16 bits, so grow at
+			// least twice.
+			size = uint32(buf[0]) + 1
+			if n := 2 * uint32(len(buf)); size < n {
+				size = n
+			}
+			continue
+		}
+		return nil, fmt.Errorf("CommDlgExtendedError: 0x%04x", code)
+	}
+}
+
+func (d *nativeDialogs) SelectFolder(owner uintptr, title string, directory string) (string, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// BIF_NEWDIALOGSTYLE needs an apartment on this thread
+	if err := CoInitializeEx(0, COINIT_APARTMENTTHREADED); err == nil || err == syscall.Errno(S_FALSE) {
+		defer CoUninitialize()
+	}
+
+	name := make([]uint16, MAX_PATH)
+	bi := &BrowseInfo{
+		Owner:       windows.Handle(owner),
+		DisplayName: &name[0],
+		Title:       utf16PtrOrNil(title),
+		Flags:       BIF_RETURNONLYFSDIRS | BIF_USENEWUI,
+	}
+	idl := SHBrowseForFolder(bi)
+	if idl == 0 {
+		return "", ErrCanceled
+	}
+	defer CoTaskMemFree(idl)
+
+	path := make([]uint16, MAX_PATH)
+	if !SHGetPathFromIDList(idl, &path[0]) {
+		return "", fmt.Errorf("SHGetPathFromIDList: not a file system folder")
+	}
+	return windows.UTF16ToString(path), nil
+}
+
+func (d *nativeDialogs) PickColor(owner uintptr, initial color.Color) (color.RGBA, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	c := color.RGBAModel.Convert(initial).(color.RGBA)
+	cc := &ChooseColorInfo{
+		Owner:      windows.Handle(owner),
+		Result:     uint32(c.R) | uint32(c.G)<<8 | uint32(c.B)<<16,
+		CustColors: &d.custColors,
+		Flags:      CC_RGBINIT | CC_FULLOPEN | CC_ANYCOLOR,
+	}
+	cc.StructSize = uint32(unsafe.Sizeof(*cc))
+
+	if !ChooseColor(cc) {
+		if code := CommDlgExtendedError(); code != 0 {
+			return color.RGBA{}, fmt.Errorf("CommDlgExtendedError: 0x%04x", code)
+		}
+		return color.RGBA{}, ErrCanceled
+	}
+
+	return color.RGBA{
+		R: uint8(cc.Result),
+		G: uint8(cc.Result >> 8),
+		B: uint8(cc.Result >> 16),
+		A: 0xff,
+	}, nil
+}
+
+// utf16z converts s to UTF-16 including the terminating NUL.
+func utf16z(s string) []uint16 {
+	u, err := windows.UTF16FromString(s)
+	if err != nil {
+		return []uint16{0}
+	}
+	return u
+}
+
+func utf16PtrOrNil(s string) *uint16 {
+	if s == "" {
+		return nil
+	}
+	return &utf16z(s)[0]
+}
@@ -0,0 +1,218 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// Errors of Init and Loop, to be tested with errors.Is.
+var (
+	// ErrNoBackend is returned by Init if no backend is available.
+	ErrNoBackend = errors.New("gui: no backend available")
+	// ErrNotInitialized is sent by Loop before Init.
+	ErrNotInitialized = errors.New("gui: Loop before Init")
+	// ErrRendererInit matches a *RendererError of Renderer.Init.
+	ErrRendererInit = errors.New("gui: renderer init failed")
+	// ErrWindowCreate matches a *WindowError.
+	ErrWindowCreate = errors.New("gui: window creation failed")
+	// ErrThreadInit matches a *ThreadInitError.
+	ErrThreadInit = errors.New("gui: loop thread init failed")
+)
+
+// QuitError is sent by Loop when the application quits with a non-zero code.
+type QuitError struct {
+	Code int
+}
+
+func (e QuitError) Error() string {
+	return fmt.Sprintf("gui: quit with code %d", e.Code)
+}
+
+// quitError returns the error of Loop for the exit code.
+func quitError(code int) error {
+	if code == 0 {
+		return nil
+	}
+	return QuitError{Code: code}
+}
+
+// WindowError is sent by Loop when the window could not be created.
+type WindowError struct {
+	Window string // name of the window
+	Err    error
+}
+
+func (e *WindowError) Error() string {
+	return fmt.Sprintf("gui: create window %q: %v", e.Window, e.Err)
+}
+
+func (e *WindowError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrWindowCreate.
+func (e *WindowError) Is(target error) bool {
+	return target == ErrWindowCreate
+}
+
+// ThreadInitError is sent by Loop when COM or the hook of WithThreadInit
+// failed on the loop thread.
+type ThreadInitError struct {
+	Window string // name of the window
+	Err    error
+}
+
+func (e *ThreadInitError) Error() string {
+	return fmt.Sprintf("gui: init thread of %q: %v", e.Window, e.Err)
+}
+
+func (e *ThreadInitError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrThreadInit.
+func (e *ThreadInitError) Is(target error) bool {
+	return target == ErrThreadInit
+}
+
+// Phase is the call of a Renderer which failed.
+type Phase int
+
+// Phases
+const (
+	PhaseInit   Phase = iota // Renderer.Init
+	PhaseUpdate              // Renderer.Update
+	PhaseDraw                // Renderer.Draw and its variants
+	PhaseEvent               // EventHandler.HandleEvent
+	PhaseNative              // NativeHandler.HandleNative
+	PhaseTimer               // the function of a Timer
+	PhaseWatch               // the function of a Watcher
+	PhaseIdle                // IdleHandler.Idle
+	PhaseObserver            // the methods of a DispatchObserver
+)
+
+var phaseNames = []string{
+	"Init",
+	"Update",
+	"Draw",
+	"HandleEvent",
+	"HandleNative",
+	"Timer",
+	"Watch",
+	"Idle",
+	"Observer",
+}
+
+func (p Phase) String() string {
+	if p < 0 || int(p) >= len(phaseNames) {
+		return fmt.Sprintf("Phase(%d)", int(p))
+	}
+	return phaseNames[p]
+}
+
+// RendererError is an error returned by a Renderer or a panic recovered on
+// the loop thread.
+type RendererError struct {
+	Window string // name of the window
+	Phase  Phase
+	Err    error
+	// Stack is the stack trace of a panic, or nil.
+	Stack []byte
+}
+
+func (e *RendererError) Error() string {
+	return fmt.Sprintf("gui: %s of %q: %v", e.Phase, e.Window, e.Err)
+}
+
+func (e *RendererError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrRendererInit for an error of Renderer.Init.
+func (e *RendererError) Is(target error) bool {
+	return target == ErrRendererInit && e.Phase == PhaseInit
+}
+
+// ErrorPolicy decides what the loop does when a Renderer fails.
+type ErrorPolicy int
+
+// Error policies
+const (
+	// StopOnError closes the window and sends the *RendererError to the
+	// channel of Loop. The renderer is not called again.
+	StopOnError ErrorPolicy = iota
+	// LogErrors logs the *RendererError and keeps running.
+	LogErrors
+)
+
+// WithErrorPolicy sets the policy for errors and panics of renderers.
+// The default is StopOnError. Errors of Init always stop the loop.
+func WithErrorPolicy(policy ErrorPolicy) Option {
+	return func(o *options) {
+		o.errorPolicy = policy
+	}
+}
+
+// WithErrorHandler sets a function called on the loop thread instead of the
+// error policy. It returns nil to keep running or the error to stop with.
+func WithErrorHandler(handler func(err *RendererError) error) Option {
+	return func(o *options) {
+		o.errorHandler = handler
+	}
+}
+
+// callRenderer calls f and returns its error or panic as *RendererError.
+func callRenderer(window string, phase Phase, f func() error) (rerr *RendererError) {
+	defer func() {
+		if r := recover(); r != nil {
+			rerr = &RendererError{
+				Window: window,
+				Phase:  phase,
+				Err:    fmt.Errorf("panic: %v", r),
+				Stack:  debug.Stack(),
+			}
+		}
+	}()
+	if err := f(); err != nil {
+		return &RendererError{Window: window, Phase: phase, Err: err}
+	}
+	return nil
+}
+
+// handleError applies the error policy and returns the error to stop with, or nil.
+func (o *options) handleError(logger *log.Logger, err *RendererError) error {
+	if o.errorHandler != nil {
+		return o.errorHandler(err)
+	}
+	if o.errorPolicy == StopOnError {
+		return err
+	}
+
+	if logger == nil {
+		logger = log.New(log.Writer(), "", log.LstdFlags)
+	}
+	if err.Stack != nil {
+		logger.Printf("%v\n%s", err, err.Stack)
+	} else {
+		logger.Print(err)
+	}
+	return nil
+}
+
+// guard calls f of the renderer of w. An error to stop with is kept in
+// w.err, the window is closed and the renderer is not called again.
+func (w *window) guard(phase Phase, f func() error) {
+	if w.err != nil {
+		return
+	}
+	rerr := callRenderer(w.name, phase, f)
+	if rerr == nil {
+		return
+	}
+	if err := w.app.opts.handleError(w.app.logger, rerr); err != nil {
+		w.err = err
+		w.stop()
+	}
+}
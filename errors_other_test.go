@@ -0,0 +1,378 @@
+// +build !windows
+
+package gui
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failRenderer fails in Update and Draw with an error or a panic.
+type failRenderer struct {
+	initErr   error
+	updateErr error
+	drawErr   error
+	drawPanic interface{}
+
+	mu      sync.Mutex
+	updates int
+	draws   int
+	drawn   chan struct{}
+	window  chan Window
+}
+
+func newFailRenderer() *failRenderer {
+	return &failRenderer{
+		drawn:  make(chan struct{}, 16),
+		window: make(chan Window, 1),
+	}
+}
+
+func (r *failRenderer) Init() error             { return r.initErr }
+func (r *failRenderer) Deinit()                 {}
+func (r *failRenderer) Dpi() (float32, float32) { return 96, 96 }
+
+func (r *failRenderer) Update(width, height uint32) error {
+	r.mu.Lock()
+	r.updates++
+	r.mu.Unlock()
+	return r.updateErr
+}
+
+func (r *failRenderer) Draw(nativeWindow uintptr) error {
+	r.mu.Lock()
+	r.draws++
+	r.mu.Unlock()
+	select {
+	case r.drawn <- struct{}{}:
+	default:
+	}
+	if r.drawPanic != nil {
+		panic(r.drawPanic)
+	}
+	return r.drawErr
+}
+
+func (r *failRenderer) HandleEvent(e Event) {
+	if c, ok := e.(*CreateEvent); ok {
+		r.window <- c.Window
+	}
+}
+
+func (r *failRenderer) counts() (updates, draws int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.updates, r.draws
+}
+
+// loopError runs a headless window of r and returns the error of Loop.
+func loopError(t *testing.T, r Renderer, opts ...Option) error {
+	app := NewApplication(append([]Option{WithBackend("headless")}, opts...)...)
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	select {
+	case err := <-app.Loop("errors", 8, 8, r):
+		return err
+	case <-testTimeout():
+		t.Fatal("Loop did not stop")
+		return nil
+	}
+}
+
+func TestStopOnError(t *testing.T) {
+	failed := errors.New("draw failed")
+	r := newFailRenderer()
+	r.drawErr = failed
+	err := loopError(t, r)
+
+	var rerr *RendererError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Loop: %v, want a *RendererError", err)
+	}
+	if rerr.Phase != PhaseDraw || rerr.Window != "errors" || rerr.Err != failed || rerr.Stack != nil {
+		t.Errorf("error %+v", rerr)
+	}
+	if !errors.Is(err, failed) || errors.Is(err, ErrRendererInit) {
+		t.Errorf("errors.Is of %v", err)
+	}
+	if err.Error() != `gui: Draw of "errors": draw failed` {
+		t.Errorf("message %q", err)
+	}
+	if _, draws := r.counts(); draws != 1 {
+		t.Errorf("%d draws after the error, want 1", draws)
+	}
+}
+
+func TestStopOnUpdateError(t *testing.T) {
+	r := newFailRenderer()
+	r.updateErr = errors.New("update failed")
+	err := loopError(t, r, WithErrorPolicy(StopOnError))
+
+	var rerr *RendererError
+	if !errors.As(err, &rerr) || rerr.Phase != PhaseUpdate {
+		t.Fatalf("Loop: %v, want an error of Update", err)
+	}
+	// the renderer is not called after the error
+	if _, draws := r.counts(); draws != 0 {
+		t.Errorf("%d draws after the error of Update", draws)
+	}
+}
+
+func TestPanicStack(t *testing.T) {
+	r := newFailRenderer()
+	r.drawPanic = "boom"
+	err := loopError(t, r)
+
+	var rerr *RendererError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("Loop: %v, want a *RendererError", err)
+	}
+	if rerr.Phase != PhaseDraw || rerr.Err.Error() != "panic: boom" {
+		t.Errorf("error %v", rerr)
+	}
+	if !bytes.Contains(rerr.Stack, []byte("(*failRenderer).Draw")) {
+		t.Errorf("the stack has no Draw:\n%s", rerr.Stack)
+	}
+}
+
+// syncBuffer is the output of the standard logger during a test.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogErrors(t *testing.T) {
+	var out syncBuffer
+	prev := log.Writer()
+	log.SetOutput(&out)
+	defer log.SetOutput(prev)
+
+	r := newFailRenderer()
+	r.updateErr = errors.New("update failed")
+	r.drawPanic = "boom"
+	app := NewApplication(WithBackend("headless"), WithErrorPolicy(LogErrors))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	errc := app.Loop("errors", 8, 8, r)
+	w := (<-r.window).(*window)
+
+	// the loop keeps running and drawing
+	for i := 0; i < 2; i++ {
+		select {
+		case <-r.drawn:
+		case <-testTimeout():
+			t.Fatal("no draw")
+		}
+		onLoop(t, w, func() { w.damage.add(w.size, w.size) })
+	}
+	if updates, draws := r.counts(); updates != 1 || draws < 2 {
+		t.Errorf("%d updates and %d draws", updates, draws)
+	}
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+	logged := out.String()
+	for _, want := range []string{
+		`gui: Update of "errors": update failed`,
+		`gui: Draw of "errors": panic: boom`,
+		"(*failRenderer).Draw",
+	} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("no %q in the log:\n%s", want, logged)
+		}
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	stop := errors.New("stop")
+	r := newFailRenderer()
+	r.updateErr = errors.New("update failed")
+	r.drawErr = errors.New("draw failed")
+
+	var phases []Phase
+	// the handler replaces the policy
+	err := loopError(t, r, WithErrorPolicy(LogErrors), WithErrorHandler(func(err *RendererError) error {
+		phases = append(phases, err.Phase)
+		if err.Phase == PhaseUpdate {
+			return nil
+		}
+		return stop
+	}))
+	if err != stop {
+		t.Errorf("Loop: %v, want the error of the handler", err)
+	}
+	if len(phases) != 2 || phases[0] != PhaseUpdate || phases[1] != PhaseDraw {
+		t.Errorf("handled %v, want Update and Draw", phases)
+	}
+}
+
+// TestTimerPanic checks the phase of a panic of a timer, which stops the
+// loop like a panic of the renderer.
+func TestTimerPanic(t *testing.T) {
+	r := newTestRenderer(color.RGBA{})
+	app := NewApplication(WithBackend("headless"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	errc := app.Loop("errors", 8, 8, r)
+	w := (<-r.window).(*window)
+	onLoop(t, w, func() {
+		w.AfterFunc(time.Millisecond, func() { panic("timer") })
+	})
+
+	var rerr *RendererError
+	select {
+	case err := <-errc:
+		if !errors.As(err, &rerr) || rerr.Phase != PhaseTimer || rerr.Stack == nil {
+			t.Errorf("Loop: %v, want a panic of a timer", err)
+		}
+	case <-testTimeout():
+		t.Fatal("Loop did not stop")
+	}
+}
+
+func TestPhaseString(t *testing.T) {
+	for p, want := range map[Phase]string{
+		PhaseInit:     "Init",
+		PhaseDraw:     "Draw",
+		PhaseEvent:    "HandleEvent",
+		PhaseObserver: "Observer",
+		Phase(-1):     "Phase(-1)",
+		Phase(42):     "Phase(42)",
+	} {
+		if s := p.String(); s != want {
+			t.Errorf("%d: %q, want %q", int(p), s, want)
+		}
+	}
+}
+
+func TestRendererInitError(t *testing.T) {
+	failed := errors.New("no device")
+	r := newFailRenderer()
+	r.initErr = failed
+	err := loopError(t, r)
+
+	if !errors.Is(err, ErrRendererInit) || !errors.Is(err, failed) {
+		t.Errorf("Loop: %v, want ErrRendererInit", err)
+	}
+	if errors.Is(err, ErrWindowCreate) {
+		t.Errorf("Loop: %v is ErrWindowCreate", err)
+	}
+	var rerr *RendererError
+	if !errors.As(err, &rerr) || rerr.Phase != PhaseInit {
+		t.Errorf("Loop: %v, want an error of Init", err)
+	}
+}
+
+func TestWindowCreateError(t *testing.T) {
+	failed := errors.New("no window")
+	registerTestBackend(t, &backend{
+		name:     "nowindow",
+		explicit: true,
+		probe:    func() error { return nil },
+		newDriver: func() (driver, error) {
+			return nil, failed
+		},
+	})
+	err := loopError(t, newFailRenderer(), WithBackend("nowindow"))
+
+	if !errors.Is(err, ErrWindowCreate) || !errors.Is(err, failed) {
+		t.Errorf("Loop: %v, want ErrWindowCreate", err)
+	}
+	if errors.Is(err, ErrRendererInit) {
+		t.Errorf("Loop: %v is ErrRendererInit", err)
+	}
+	var werr *WindowError
+	if !errors.As(err, &werr) || werr.Window != "errors" {
+		t.Errorf("Loop: %v, want a *WindowError", err)
+	}
+	if err.Error() != `gui: create window "errors": no window` {
+		t.Errorf("message %q", err)
+	}
+}
+
+func TestQuitError(t *testing.T) {
+	app := NewApplication(WithBackend("headless"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("quit", 8, 8, r)
+	<-r.window
+	app.Quit(3)
+	// the first code is kept
+	app.Quit(4)
+
+	var q QuitError
+	select {
+	case err := <-errc:
+		if !errors.As(err, &q) || q.Code != 3 {
+			t.Errorf("Loop: %v, want code 3", err)
+		}
+		if err.Error() != "gui: quit with code 3" {
+			t.Errorf("message %q", err)
+		}
+	case <-testTimeout():
+		t.Fatal("Loop did not quit")
+	}
+}
+
+// TestQuitBeforeLoop quits before the loop is started, which quits at once.
+func TestQuitBeforeLoop(t *testing.T) {
+	app := NewApplication(WithBackend("headless"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	app.Quit(0)
+	select {
+	case err := <-app.Loop("quit", 8, 8, newTestRenderer(color.RGBA{})):
+		if err != nil {
+			t.Errorf("Loop: %v, want nil for code 0", err)
+		}
+	case <-testTimeout():
+		t.Fatal("Loop did not quit")
+	}
+
+	app = NewApplication(WithBackend("headless"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	app.Quit(2)
+	select {
+	case err := <-app.Loop("quit", 8, 8, newTestRenderer(color.RGBA{})):
+		var q QuitError
+		if !errors.As(err, &q) || q.Code != 2 {
+			t.Errorf("Loop: %v, want code 2", err)
+		}
+	case <-testTimeout():
+		t.Fatal("Loop did not quit")
+	}
+}
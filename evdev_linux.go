@@ -0,0 +1,312 @@
+package gui
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// evdev event types and codes (linux/input-event-codes.h)
+const (
+	evSyn = 0x00
+	evKey = 0x01
+	evRel = 0x02
+	evAbs = 0x03
+
+	synReport = 0
+
+	relX      = 0x00
+	relY      = 0x01
+	relHWheel = 0x06
+	relWheel  = 0x08
+
+	absX = 0x00
+	absY = 0x01
+
+	btnTouch = 0x14a
+)
+
+// evdev ioctls
+const (
+	eviocgrab = 1<<30 | 4<<16 | 'E'<<8 | 0x90
+)
+
+// eviocgabs returns EVIOCGABS(abs) for struct input_absinfo.
+func eviocgabs(abs uint32) uintptr {
+	return uintptr(2<<30 | uint32(unsafe.Sizeof(inputAbsInfo{}))<<16 | 'E'<<8 | (0x40 + abs))
+}
+
+// inputEvent is struct input_event.
+type inputEvent struct {
+	Time  unix.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// inputAbsInfo is struct input_absinfo.
+type inputAbsInfo struct {
+	Value      int32
+	Minimum    int32
+	Maximum    int32
+	Fuzz       int32
+	Flat       int32
+	Resolution int32
+}
+
+// evdevModifiers maps modifier key codes to modifiers.
+var evdevModifiers = map[uint16]Modifier{
+	29:  ModCtrl,
+	97:  ModCtrl,
+	42:  ModShift,
+	54:  ModShift,
+	56:  ModAlt,
+	100: ModAlt,
+	125: ModSuper,
+	126: ModSuper,
+}
+
+// evdevInput reads keyboards, mice and touch screens from /dev/input
+// for a screen of width x height pixels.
+type evdevInput struct {
+	devices       []*evdevDevice
+	width, height int32
+
+	x, y    int32
+	moved   bool
+	pressed map[uint16]bool // modifier keys
+	mods    Modifier
+	time    time.Time // of the event being handled
+	events  []interface{}
+	res     *resources
+	trace   func(category TraceCategory, name string, detail string)
+}
+
+// evdevTypes are the names of the event types.
+var evdevTypes = map[uint16]string{
+	evSyn: "EV_SYN",
+	evKey: "EV_KEY",
+	evRel: "EV_REL",
+	evAbs: "EV_ABS",
+}
+
+type evdevDevice struct {
+	fd   int
+	absX *inputAbsInfo
+	absY *inputAbsInfo
+}
+
+// openEvdev opens and grabs the devices matching pattern. Devices which
+// cannot be opened, e.g. without permission, are skipped. They are
+// counted in res.
+func openEvdev(pattern string, width, height int32, res *resources) *evdevInput {
+	in := &evdevInput{
+		width:   width,
+		height:  height,
+		x:       width / 2,
+		y:       height / 2,
+		pressed: make(map[uint16]bool),
+		res:     res,
+	}
+
+	paths, _ := filepath.Glob(pattern)
+	for _, path := range paths {
+		fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+		if err != nil {
+			continue
+		}
+		// keep the input from the console
+		unix.Syscall(unix.SYS_IOCTL, uintptr(fd), eviocgrab, 1)
+
+		dev := &evdevDevice{fd: fd}
+		dev.absX = getAbsInfo(fd, absX)
+		dev.absY = getAbsInfo(fd, absY)
+		in.devices = append(in.devices, dev)
+		res.acquire(resInput)
+	}
+	return in
+}
+
+func getAbsInfo(fd int, abs uint32) *inputAbsInfo {
+	info := &inputAbsInfo{}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), eviocgabs(abs), uintptr(unsafe.Pointer(info)))
+	if errno != 0 || info.Maximum <= info.Minimum {
+		return nil
+	}
+	return info
+}
+
+func (in *evdevInput) close() {
+	for _, dev := range in.devices {
+		unix.Close(dev.fd)
+		in.res.release(resInput)
+	}
+	in.devices = nil
+}
+
+// pollFds appends the devices to fds.
+func (in *evdevInput) pollFds(fds []unix.PollFd) []unix.PollFd {
+	for _, dev := range in.devices {
+		fds = append(fds, unix.PollFd{Fd: int32(dev.fd), Events: unix.POLLIN})
+	}
+	return fds
+}
+
+// read reads the devices which are ready in fds and returns their events.
+func (in *evdevInput) read(fds []unix.PollFd) []interface{} {
+	var buf [64]inputEvent
+	size := int(unsafe.Sizeof(buf[0]))
+	raw := (*[unsafe.Sizeof(buf)]byte)(unsafe.Pointer(&buf))
+
+	for _, pfd := range fds {
+		if pfd.Revents == 0 {
+			continue
+		}
+		dev := in.device(int(pfd.Fd))
+		if dev == nil {
+			continue
+		}
+		if pfd.Revents&(unix.POLLERR|unix.POLLHUP|unix.POLLNVAL) != 0 {
+			// unplugged
+			in.remove(dev)
+			continue
+		}
+		for {
+			n, err := unix.Read(dev.fd, raw[:])
+			if err != nil || n < size {
+				break
+			}
+			for i := 0; i < n/size; i++ {
+				in.handle(dev, &buf[i])
+			}
+		}
+	}
+
+	events := in.events
+	in.events = nil
+	return events
+}
+
+func (in *evdevInput) device(fd int) *evdevDevice {
+	for _, dev := range in.devices {
+		if dev.fd == fd {
+			return dev
+		}
+	}
+	return nil
+}
+
+func (in *evdevInput) remove(dev *evdevDevice) {
+	unix.Close(dev.fd)
+	in.res.release(resInput)
+	for i, d := range in.devices {
+		if d == dev {
+			in.devices = append(in.devices[:i], in.devices[i+1:]...)
+			return
+		}
+	}
+}
+
+func (in *evdevInput) handle(dev *evdevDevice, ev *inputEvent) {
+	in.time = realtimeTime(ev.Time)
+	if in.trace != nil {
+		name, ok := evdevTypes[ev.Type]
+		if !ok {
+			name = fmt.Sprintf("EV 0x%02x", ev.Type)
+		}
+		in.trace(TraceInput, name, fmt.Sprintf("code=0x%x value=%d", ev.Code, ev.Value))
+	}
+
+	switch ev.Type {
+	case evSyn:
+		if ev.Code == synReport && in.moved {
+			in.moved = false
+			in.mouse(MouseMove, ButtonNone)
+		}
+	case evKey:
+		code := uint32(ev.Code)
+		if ev.Code == btnTouch {
+			code = btnLeft
+		}
+		if button := buttonFromEvdev(code); button != ButtonNone {
+			if ev.Value == 1 {
+				in.mouse(MousePress, button)
+			} else if ev.Value == 0 {
+				in.mouse(MouseRelease, button)
+			}
+			return
+		}
+
+		// value is 0 for release, 1 for press and 2 for autorepeat
+		if _, ok := evdevModifiers[ev.Code]; ok {
+			in.pressed[ev.Code] = ev.Value != 0
+			in.mods = 0
+			for c, down := range in.pressed {
+				if down {
+					in.mods |= evdevModifiers[c]
+				}
+			}
+		}
+		in.events = append(in.events, &KeyEvent{
+			EventHeader: EventHeader{Time: in.time},
+			Key:         keyFromEvdev(code),
+			Mods:        in.mods,
+			Down:        ev.Value != 0,
+			Repeat:      ev.Value == 2,
+		})
+	case evRel:
+		switch ev.Code {
+		case relX:
+			in.moveTo(in.x+ev.Value, in.y)
+		case relY:
+			in.moveTo(in.x, in.y+ev.Value)
+		case relWheel:
+			// positive is away from the user
+			in.events = append(in.events, &MouseEvent{EventHeader: EventHeader{Time: in.time}, Action: MouseScroll, X: in.x, Y: in.y, Mods: in.mods, ScrollY: float32(-ev.Value)})
+		case relHWheel:
+			in.events = append(in.events, &MouseEvent{EventHeader: EventHeader{Time: in.time}, Action: MouseScroll, X: in.x, Y: in.y, Mods: in.mods, ScrollX: float32(ev.Value)})
+		}
+	case evAbs:
+		switch {
+		case ev.Code == absX && dev.absX != nil:
+			in.moveTo(scaleAbs(ev.Value, dev.absX, in.width), in.y)
+		case ev.Code == absY && dev.absY != nil:
+			in.moveTo(in.x, scaleAbs(ev.Value, dev.absY, in.height))
+		}
+	}
+}
+
+// scaleAbs maps an absolute axis value to 0..size-1.
+func scaleAbs(v int32, info *inputAbsInfo, size int32) int32 {
+	return int32(int64(v-info.Minimum) * int64(size-1) / int64(info.Maximum-info.Minimum))
+}
+
+func (in *evdevInput) moveTo(x, y int32) {
+	in.x = clampInt32(x, 0, in.width-1)
+	in.y = clampInt32(y, 0, in.height-1)
+	in.moved = true
+}
+
+func (in *evdevInput) mouse(action MouseAction, button MouseButton) {
+	in.events = append(in.events, &MouseEvent{
+		EventHeader: EventHeader{Time: in.time},
+		Action:      action,
+		Button:      button,
+		X:           in.x,
+		Y:           in.y,
+		Mods:        in.mods,
+	})
+}
+
+func clampInt32(v, min, max int32) int32 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,36 @@
+package gui
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestEvdevTrace(t *testing.T) {
+	var got []string
+	in := &evdevInput{width: 10, height: 10, pressed: make(map[uint16]bool)}
+	in.trace = func(category TraceCategory, name string, detail string) {
+		got = append(got, fmt.Sprintf("%v %s %s", category, name, detail))
+	}
+	tv := unix.NsecToTimeval(time.Now().UnixNano())
+	for _, ev := range []inputEvent{
+		{Time: tv, Type: evKey, Code: 30, Value: 1},
+		{Time: tv, Type: evRel, Code: relX, Value: -2},
+		{Time: tv, Type: evSyn, Code: synReport},
+		{Time: tv, Type: 0x11, Code: 1, Value: 1}, // EV_LED
+	} {
+		ev := ev
+		in.handle(&evdevDevice{}, &ev)
+	}
+	want := []string{
+		"input EV_KEY code=0x1e value=1",
+		"input EV_REL code=0x0 value=-2",
+		"input EV_SYN code=0x0 value=0",
+		"input EV 0x11 code=0x1 value=1",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("traced %q\nwant %q", got, want)
+	}
+}
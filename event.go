@@ -0,0 +1,73 @@
+package gui
+
+import (
+	"image"
+	"time"
+)
+
+// Event is an event delivered to an EventHandler.
+type Event interface {
+	Source() Window
+}
+
+// EventHandler is an optional interface of Renderer to receive events of its window.
+// HandleEvent is called on the loop thread.
+type EventHandler interface {
+	HandleEvent(e Event)
+}
+
+// EventHeader holds the fields common to all events.
+type EventHeader struct {
+	Window Window // nil for events without a window
+	// Time is when the event happened, from the native timestamp if there
+	// is one. It has a monotonic clock reading for Sub.
+	Time time.Time
+	// Pos is the pointer position at Time in pixels relative to the client area.
+	Pos image.Point
+}
+
+// Source returns the window of the event.
+func (h *EventHeader) Source() Window {
+	return h.Window
+}
+
+// header returns h to fill in the fields of any event.
+func (h *EventHeader) header() *EventHeader {
+	return h
+}
+
+// eventHeader returns the header of e, or nil if it has none.
+func eventHeader(e interface{}) *EventHeader {
+	if h, ok := e.(interface{ header() *EventHeader }); ok {
+		return h.header()
+	}
+	return nil
+}
+
+// stampEvent sets the time of e to t if it is an event without one.
+func stampEvent(e interface{}, t time.Time) {
+	if h := eventHeader(e); h != nil && h.Time.IsZero() {
+		h.Time = t
+	}
+}
+
+// sinceClock converts a timestamp of a native clock, which is age old now,
+// to time.Time. Ages beyond a minute are from another clock and ignored.
+func sinceClock(age time.Duration) time.Time {
+	now := time.Now()
+	if age < 0 || age > time.Minute {
+		return now
+	}
+	return now.Add(-age)
+}
+
+// CreateEvent is delivered once when the window has been created.
+type CreateEvent struct {
+	EventHeader
+}
+
+// CommandEvent is delivered when a menu item is selected.
+type CommandEvent struct {
+	EventHeader
+	ID int
+}
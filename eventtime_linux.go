@@ -0,0 +1,30 @@
+package gui
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// monotonicTime converts CLOCK_MONOTONIC milliseconds, which wrap at 32 bits
+// like the timestamps of Wayland, to time.Time.
+func monotonicTime(ms uint32) time.Time {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return time.Now()
+	}
+	now := uint32(ts.Nano() / int64(time.Millisecond))
+	return sinceClock(millisecondsAge(now, ms))
+}
+
+// millisecondsAge returns the age of the timestamp ms at now, also across a
+// wrap of the 32 bits.
+func millisecondsAge(now, ms uint32) time.Duration {
+	return time.Duration(int32(now-ms)) * time.Millisecond
+}
+
+// realtimeTime converts a CLOCK_REALTIME timestamp, like those of evdev, to time.Time.
+func realtimeTime(tv unix.Timeval) time.Time {
+	sec, nsec := tv.Unix()
+	return sinceClock(time.Since(time.Unix(sec, nsec)))
+}
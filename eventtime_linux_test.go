@@ -0,0 +1,48 @@
+package gui
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// near fails unless got is want within a tolerance for the scheduler.
+func near(t *testing.T, what string, got, want time.Time) {
+	t.Helper()
+	if d := got.Sub(want); d < -20*time.Millisecond || d > 20*time.Millisecond {
+		t.Errorf("%s: %v off", what, d)
+	}
+}
+
+func TestMonotonicTime(t *testing.T) {
+	now := monotonicMillis(t)
+	near(t, "now", monotonicTime(now), time.Now())
+	near(t, "100ms ago", monotonicTime(now-100), time.Now().Add(-100*time.Millisecond))
+	// times of another clock, in the future or too old, are now
+	near(t, "future", monotonicTime(now+1000), time.Now())
+	near(t, "2 minutes ago", monotonicTime(now-120000), time.Now())
+
+	// the 32 bits of milliseconds wrap after 49 days
+	if age := millisecondsAge(5, 0xffffffff-44); age != 50*time.Millisecond {
+		t.Errorf("age across the wrap %v, want 50ms", age)
+	}
+	if age := millisecondsAge(100, 150); age != -50*time.Millisecond {
+		t.Errorf("age of the future %v, want -50ms", age)
+	}
+}
+
+func TestRealtimeTime(t *testing.T) {
+	at := func(d time.Duration) unix.Timeval {
+		return unix.NsecToTimeval(time.Now().Add(d).UnixNano())
+	}
+	near(t, "100ms ago", realtimeTime(at(-100*time.Millisecond)), time.Now().Add(-100*time.Millisecond))
+	// the realtime clock may have been set since the event
+	near(t, "future", realtimeTime(at(time.Second)), time.Now())
+	near(t, "2 hours ago", realtimeTime(at(-2*time.Hour)), time.Now())
+
+	// the time is on the monotonic clock of Go, like time.Now
+	if e := realtimeTime(at(-time.Millisecond)); e.Round(0) == e {
+		t.Error("no monotonic reading")
+	}
+}
@@ -0,0 +1,504 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Environment variables of the fbdev backend
+const (
+	fbdevEnv = "GUI_FBDEV" // framebuffer device or a regular file, default /dev/fb0
+	evdevEnv = "GUI_EVDEV" // glob of input devices, default /dev/input/event*
+	ttyEnv   = "GUI_TTY"   // virtual terminal to take over, default /dev/tty
+)
+
+// framebuffer ioctls (linux/fb.h)
+const (
+	fbioGetVScreenInfo = 0x4600
+	fbioGetFScreenInfo = 0x4602
+	fbioPanDisplay     = 0x4606
+	fbioWaitForVSync   = 1<<30 | 4<<16 | 'F'<<8 | 0x20
+)
+
+// console ioctls (linux/kd.h)
+const (
+	kdSetMode   = 0x4B3A
+	kdGetMode   = 0x4B3B
+	kdGetKbMode = 0x4B44
+	kdSetKbMode = 0x4B45
+
+	kdGraphics = 1
+	kOff       = 4
+)
+
+// fbBitfield is struct fb_bitfield.
+type fbBitfield struct {
+	Offset   uint32
+	Length   uint32
+	MsbRight uint32
+}
+
+// fbVarScreenInfo is struct fb_var_screeninfo.
+type fbVarScreenInfo struct {
+	XRes, YRes               uint32
+	XResVirtual, YResVirtual uint32
+	XOffset, YOffset         uint32
+	BitsPerPixel             uint32
+	Grayscale                uint32
+	Red, Green, Blue, Transp fbBitfield
+	NonStd                   uint32
+	Activate                 uint32
+	Height, Width            uint32
+	AccelFlags               uint32
+	PixClock                 uint32
+	LeftMargin, RightMargin  uint32
+	UpperMargin, LowerMargin uint32
+	HSyncLen, VSyncLen       uint32
+	Sync, VMode, Rotate      uint32
+	Colorspace               uint32
+	Reserved                 [4]uint32
+}
+
+// fbFixScreenInfo is struct fb_fix_screeninfo.
+type fbFixScreenInfo struct {
+	ID                            [16]byte
+	SmemStart                     uintptr
+	SmemLen                       uint32
+	Type, TypeAux, Visual         uint32
+	XPanStep, YPanStep, YWrapStep uint16
+	_                             uint16
+	LineLength                    uint32
+	MmioStart                     uintptr
+	MmioLen                       uint32
+	Accel                         uint32
+	Capabilities                  uint16
+	Reserved                      [2]uint16
+}
+
+// fbdevCapabilities are the features of the fbdev backend.
+const fbdevCapabilities = CapKeyboard | CapPointer | drawnMenuCapabilities | glCapabilities
+
+// fbdevInUse is set while the framebuffer is owned by a window.
+var (
+	fbdevMu    sync.Mutex
+	fbdevInUse bool
+)
+
+func init() {
+	registerBackend(&backend{
+		name:     "fbdev",
+		priority: 50,
+		// a display server may own the console
+		explicit: true,
+		caps:     fbdevCapabilities,
+		probe:    probeFbdev,
+		newDriver: func() (driver, error) {
+			return &fbdevDriver{}, nil
+		},
+	})
+}
+
+func fbdevPath() string {
+	if path := os.Getenv(fbdevEnv); path != "" {
+		return path
+	}
+	return "/dev/fb0"
+}
+
+func probeFbdev() error {
+	path := fbdevPath()
+	if err := unix.Access(path, unix.R_OK|unix.W_OK); err != nil {
+		return fmt.Errorf("fbdev: %s: %v", path, err)
+	}
+	return nil
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), req, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// fbdevDriver shows a single fullscreen window on the Linux framebuffer.
+//
+// It takes over the virtual terminal so that the console does not draw over
+// the window and keystrokes do not reach it. A regular file is used as a
+// framebuffer of the window size in XRGB8888, which is useful for testing.
+type fbdevDriver struct {
+	fd    int
+	mem   []byte
+	vinfo fbVarScreenInfo
+	finfo fbFixScreenInfo
+	page  int // back buffer if pages is 2
+	pages int
+
+	tty      int
+	ttyMode  int32
+	kbMode   int32
+	ttyMu    sync.Mutex // takeOver, with the signal goroutine
+	takeOver bool
+	onSignal bool   // of WithRestoreOnSignal
+	stopTTY  func() // stops restoring the terminal on signals
+
+	image   *image.RGBA
+	damage  [2]damage // changed since each page was written
+	input   *evdevInput
+	trace   func(category TraceCategory, name string, detail string)
+	events  []interface{}
+	wakeup  wakePipe
+	timer   timerFd // of the timeout of poll
+	watches *fdWatches
+	res     *resources
+}
+
+func (d *fbdevDriver) open(name string, width int32, height int32) error {
+	d.fd, d.tty = -1, -1
+	if err := d.wakeup.open(); err != nil {
+		return err
+	}
+	if err := d.timer.open(); err != nil {
+		return err
+	}
+
+	fbdevMu.Lock()
+	defer fbdevMu.Unlock()
+	if fbdevInUse {
+		return errors.New("fbdev: the framebuffer is already in use")
+	}
+
+	path := fbdevPath()
+	fd, err := unix.Open(path, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("fbdev: %s: %v", path, err)
+	}
+	d.fd = fd
+	d.res.acquire(resFbdev)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("Fstat: %v", err)
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFREG {
+		err = d.openFile(width, height)
+	} else {
+		err = d.openDevice()
+	}
+	if err != nil {
+		return err
+	}
+
+	d.mem, err = unix.Mmap(fd, 0, int(d.finfo.SmemLen), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("Mmap: %v", err)
+	}
+	fbdevInUse = true
+
+	width, height = int32(d.vinfo.XRes), int32(d.vinfo.YRes)
+	d.image = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	for i := range d.damage {
+		d.damage[i].add(d.image.Rect, d.image.Rect)
+	}
+	pattern := os.Getenv(evdevEnv)
+	if pattern == "" {
+		pattern = "/dev/input/event*"
+	}
+	d.input = openEvdev(pattern, width, height, d.res)
+	d.input.trace = d.trace
+	d.events = append(d.events, sizeEvent{width: width, height: height}, exposeEvent{})
+	return nil
+}
+
+// openFile sets up a regular file as a framebuffer of width x height.
+func (d *fbdevDriver) openFile(width, height int32) error {
+	d.vinfo = fbVarScreenInfo{
+		XRes:         uint32(width),
+		YRes:         uint32(height),
+		XResVirtual:  uint32(width),
+		YResVirtual:  uint32(height),
+		BitsPerPixel: 32,
+		Red:          fbBitfield{Offset: 16, Length: 8},
+		Green:        fbBitfield{Offset: 8, Length: 8},
+		Blue:         fbBitfield{Offset: 0, Length: 8},
+	}
+	d.finfo = fbFixScreenInfo{
+		LineLength: uint32(width) * 4,
+		SmemLen:    uint32(width) * 4 * uint32(height),
+	}
+	d.pages = 1
+	if err := unix.Ftruncate(d.fd, int64(d.finfo.SmemLen)); err != nil {
+		return fmt.Errorf("Ftruncate: %v", err)
+	}
+	return nil
+}
+
+// openDevice reads the mode of the framebuffer device and takes over the terminal.
+func (d *fbdevDriver) openDevice() error {
+	if err := ioctl(d.fd, fbioGetVScreenInfo, unsafe.Pointer(&d.vinfo)); err != nil {
+		return fmt.Errorf("FBIOGET_VSCREENINFO: %v", err)
+	}
+	if err := ioctl(d.fd, fbioGetFScreenInfo, unsafe.Pointer(&d.finfo)); err != nil {
+		return fmt.Errorf("FBIOGET_FSCREENINFO: %v", err)
+	}
+	switch d.vinfo.BitsPerPixel {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("fbdev: %d bits per pixel is not supported", d.vinfo.BitsPerPixel)
+	}
+
+	// page flipping if the virtual screen holds two pages
+	d.pages = 1
+	if d.vinfo.YResVirtual >= 2*d.vinfo.YRes && d.finfo.YPanStep != 0 &&
+		uint64(d.finfo.LineLength)*uint64(2*d.vinfo.YRes) <= uint64(d.finfo.SmemLen) {
+		d.pages = 2
+		d.page = 1
+		if d.vinfo.YOffset != 0 {
+			d.page = 0
+		}
+	}
+
+	d.takeOverTTY()
+	return nil
+}
+
+// takeOverTTY switches the virtual terminal to graphics mode.
+// Nothing is done if the terminal is not a virtual console, e.g. over ssh.
+func (d *fbdevDriver) takeOverTTY() {
+	path := os.Getenv(ttyEnv)
+	if path == "" {
+		path = "/dev/tty"
+	}
+	tty, err := unix.Open(path, unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return
+	}
+	if ioctl(tty, kdGetMode, unsafe.Pointer(&d.ttyMode)) != nil ||
+		ioctl(tty, kdGetKbMode, unsafe.Pointer(&d.kbMode)) != nil {
+		unix.Close(tty)
+		return
+	}
+
+	d.tty = tty
+	d.res.acquire(resTerminal)
+	d.takeOver = true
+	unix.Syscall(unix.SYS_IOCTL, uintptr(tty), kdSetMode, kdGraphics)
+	unix.Syscall(unix.SYS_IOCTL, uintptr(tty), kdSetKbMode, kOff)
+
+	// a console left in graphics mode without a keyboard is unusable
+	if d.onSignal {
+		d.stopTTY = restoreOnSignal(d.restoreTTY)
+	}
+}
+
+func (d *fbdevDriver) setRestoreOnSignal() {
+	d.onSignal = true
+}
+
+// restoreTTY gives the virtual terminal back to the console.
+func (d *fbdevDriver) restoreTTY() {
+	d.ttyMu.Lock()
+	defer d.ttyMu.Unlock()
+	if d.takeOver {
+		unix.Syscall(unix.SYS_IOCTL, uintptr(d.tty), kdSetKbMode, uintptr(d.kbMode))
+		unix.Syscall(unix.SYS_IOCTL, uintptr(d.tty), kdSetMode, uintptr(d.ttyMode))
+		d.takeOver = false
+	}
+}
+
+// restoreOnSignal calls restore when SIGINT or SIGTERM arrives, which
+// terminate the process without closing the windows, and then raises the
+// signal again for its default action. The returned function stops it and
+// returns after restore is done or will not be called.
+func restoreOnSignal(restore func()) (stop func()) {
+	sigc := make(chan os.Signal, 1)
+	stopc := make(chan struct{})
+	done := make(chan struct{})
+	signal.Notify(sigc, unix.SIGINT, unix.SIGTERM)
+	go func() {
+		defer close(done)
+		select {
+		case sig := <-sigc:
+			restore()
+			signal.Stop(sigc)
+			unix.Kill(unix.Getpid(), sig.(unix.Signal))
+		case <-stopc:
+		}
+	}()
+	return func() {
+		signal.Stop(sigc)
+		close(stopc)
+		<-done
+	}
+}
+
+func (d *fbdevDriver) close() {
+	if d.input != nil {
+		d.input.close()
+	}
+	if d.stopTTY != nil {
+		d.stopTTY()
+		d.stopTTY = nil
+	}
+	d.restoreTTY()
+	if d.tty >= 0 {
+		unix.Close(d.tty)
+		d.tty = -1
+		d.res.release(resTerminal)
+	}
+	if d.mem != nil {
+		unix.Munmap(d.mem)
+		d.mem = nil
+
+		fbdevMu.Lock()
+		fbdevInUse = false
+		fbdevMu.Unlock()
+	}
+	if d.fd >= 0 {
+		unix.Close(d.fd)
+		d.fd = -1
+		d.res.release(resFbdev)
+	}
+	d.wakeup.close()
+	d.timer.close()
+}
+
+func (d *fbdevDriver) setResources(res *resources) {
+	d.res = res
+}
+
+func (d *fbdevDriver) handle() uintptr {
+	return uintptr(d.fd)
+}
+
+func (d *fbdevDriver) setTrace(trace func(category TraceCategory, name string, detail string)) {
+	d.trace = trace
+}
+
+func (d *fbdevDriver) poll(timeout time.Duration) ([]interface{}, error) {
+	if err := d.timer.set(timeout); err != nil {
+		return nil, err
+	}
+
+	for len(d.events) == 0 {
+		fds := d.input.pollFds(nil)
+		inputs := len(fds)
+		fds = d.watches.pollFds(append(fds, d.wakeup.pollFd(), d.timer.pollFd()))
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Poll: %v", err)
+		}
+		d.events = append(d.events, d.input.read(fds[:inputs])...)
+		d.events = append(d.events, d.watches.read(fds[inputs+2:])...)
+		if fds[inputs].Revents != 0 {
+			d.wakeup.drain()
+			break
+		}
+		if d.timer.expired(fds[inputs+1]) {
+			break
+		}
+	}
+
+	events := d.events
+	d.events = nil
+	return events, nil
+}
+
+func (d *fbdevDriver) wake() {
+	d.wakeup.wake()
+}
+
+func (d *fbdevDriver) setWatches(ws *fdWatches) {
+	d.watches = ws
+}
+
+func (d *fbdevDriver) framebuffer() *image.RGBA {
+	return d.image
+}
+
+func (d *fbdevDriver) present(region []image.Rectangle) error {
+	if d.mem == nil {
+		return nil
+	}
+
+	for i := 0; i < d.pages; i++ {
+		d.damage[i].addAll(region, d.image.Rect)
+	}
+	offset := uint32(0)
+	if d.pages == 2 {
+		offset = uint32(d.page) * d.vinfo.YRes
+	}
+	for _, r := range d.damage[d.page].take() {
+		d.convert(d.mem[int(offset)*int(d.finfo.LineLength):], r)
+	}
+
+	if d.pages == 2 {
+		vinfo := d.vinfo
+		vinfo.XOffset = 0
+		vinfo.YOffset = offset
+		var crtc uint32
+		ioctl(d.fd, fbioWaitForVSync, unsafe.Pointer(&crtc))
+		if err := ioctl(d.fd, fbioPanDisplay, unsafe.Pointer(&vinfo)); err != nil {
+			return fmt.Errorf("FBIOPAN_DISPLAY: %v", err)
+		}
+		d.page = 1 - d.page
+	}
+	return nil
+}
+
+// convert writes r of the image to dst in the pixel format of the framebuffer.
+func (d *fbdevDriver) convert(dst []byte, r image.Rectangle) {
+	src := d.image
+	stride := int(d.finfo.LineLength)
+	bpp := int(d.vinfo.BitsPerPixel) / 8
+	v := &d.vinfo
+
+	xrgb := bpp == 4 && v.Red.Offset == 16 && v.Green.Offset == 8 && v.Blue.Offset == 0 &&
+		v.Red.Length == 8 && v.Green.Length == 8 && v.Blue.Length == 8
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := dst[y*stride:]
+		pix := src.Pix[y*src.Stride:]
+		if xrgb {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				row[x*4+0] = pix[x*4+2]
+				row[x*4+1] = pix[x*4+1]
+				row[x*4+2] = pix[x*4+0]
+				row[x*4+3] = 0xff
+			}
+			continue
+		}
+
+		for x := r.Min.X; x < r.Max.X; x++ {
+			p := packPixel(v, pix[x*4+0], pix[x*4+1], pix[x*4+2])
+			for i := 0; i < bpp; i++ {
+				row[x*bpp+i] = byte(p >> (8 * uint(i)))
+			}
+		}
+	}
+}
+
+// packPixel packs r, g, b by the bitfields of the framebuffer.
+func packPixel(v *fbVarScreenInfo, r, g, b byte) uint32 {
+	pack := func(c byte, f fbBitfield) uint32 {
+		if f.Length == 0 || f.Length > 8 {
+			return uint32(c) << f.Offset
+		}
+		return uint32(c>>(8-f.Length)) << f.Offset
+	}
+	p := pack(r, v.Red) | pack(g, v.Green) | pack(b, v.Blue)
+	if v.Transp.Length != 0 {
+		p |= (1<<v.Transp.Length - 1) << v.Transp.Offset
+	}
+	return p
+}
@@ -0,0 +1,128 @@
+package gui
+
+import (
+	"image/color"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestFbdevFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gui-fbdev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fb")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, fbdevEnv, path)
+	setenv(t, evdevEnv, filepath.Join(dir, "no-input-*"))
+
+	app := NewApplication(WithBackend("fbdev"), WithRestoreOnSignal())
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRenderer(color.RGBA{0x10, 0x20, 0x30, 0xff})
+	errc := app.Loop("fbdev", 32, 16, r)
+	r.nextFrame(t)
+
+	// a second window cannot share the framebuffer
+	second := app.Loop("second", 8, 8, newTestRenderer(color.RGBA{}))
+	select {
+	case err := <-second:
+		if _, ok := err.(*WindowError); !ok {
+			t.Errorf("second window: %v, want *WindowError", err)
+		}
+	case <-testTimeout():
+		t.Fatal("the second window was opened")
+	}
+
+	// the frame is written after DrawImage returns
+	w := <-r.window
+	onSignal := false
+	done := make(chan struct{})
+	w.(*window).post(func() {
+		onSignal = w.(*window).driver.(*fbdevDriver).onSignal
+		close(done)
+	})
+	<-done
+	if !onSignal {
+		t.Error("WithRestoreOnSignal did not reach the driver")
+	}
+
+	mem, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mem) != 32*16*4 {
+		t.Fatalf("framebuffer of %d bytes, want %d", len(mem), 32*16*4)
+	}
+	// XRGB8888 in little endian
+	for i := 0; i < len(mem); i += 4 {
+		if mem[i] != 0x30 || mem[i+1] != 0x20 || mem[i+2] != 0x10 {
+			t.Fatalf("pixel %d = % x, want 30 20 10", i/4, mem[i:i+4])
+		}
+	}
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+	app.Deinit()
+	if err := CheckLeaks(app); err != nil {
+		t.Error(err)
+	}
+	fbdevMu.Lock()
+	inUse := fbdevInUse
+	fbdevMu.Unlock()
+	if inUse {
+		t.Error("the framebuffer is still in use after Deinit")
+	}
+}
+
+func TestFbdevExplicit(t *testing.T) {
+	for _, name := range defaultBackends() {
+		if name == "fbdev" {
+			t.Error("fbdev is selected without being named")
+		}
+	}
+}
+
+// TestRestoreOnSignal runs itself in a process which is terminated by
+// SIGTERM, which must call the restore function first.
+func TestRestoreOnSignal(t *testing.T) {
+	if marker := os.Getenv("GUI_TEST_RESTORE"); marker != "" {
+		restoreOnSignal(func() {
+			ioutil.WriteFile(marker, []byte("restored"), 0600)
+		})
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		select {}
+	}
+
+	dir, err := ioutil.TempDir("", "gui-signal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	marker := filepath.Join(dir, "marker")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRestoreOnSignal$")
+	cmd.Env = append(os.Environ(), "GUI_TEST_RESTORE="+marker)
+	err = cmd.Run()
+	status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() || status.Signal() != syscall.SIGTERM {
+		t.Errorf("the process ended with %v, want SIGTERM", err)
+	}
+	if b, err := ioutil.ReadFile(marker); err != nil || string(b) != "restored" {
+		t.Errorf("restore was not called: %v", err)
+	}
+
+	// stopping leaves the signals alone
+	stop := restoreOnSignal(func() { t.Error("restored without a signal") })
+	stop()
+}
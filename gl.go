@@ -0,0 +1,51 @@
+package gui
+
+import (
+	"errors"
+)
+
+// GLProfile is the OpenGL API of a context.
+type GLProfile int
+
+// Profiles
+const (
+	GLCore          GLProfile = iota // desktop OpenGL core profile
+	GLCompatibility                  // desktop OpenGL with the fixed function pipeline
+	GLES                             // OpenGL ES
+)
+
+// GLConfig is the requested OpenGL context. The zero value is any version
+// of the core profile.
+type GLConfig struct {
+	Major, Minor int // the lowest version, or 0 for any
+	Profile      GLProfile
+	DepthBits    int
+	StencilBits  int
+	Debug        bool
+}
+
+// GLContext is an OpenGL context of a window. It is bound to the loop thread
+// and its methods must be called there, e.g. from Renderer.Draw.
+//
+// On Windows it is a WGL context. Other backends need Linux, cgo and the egl
+// build tag. On X11 the context renders to an EGL window surface, which
+// SwapBuffers presents. The others render offscreen with EGL, e.g. Mesa
+// llvmpipe without a GPU, and SwapBuffers copies the image to the window,
+// which is presented when Draw returns.
+type GLContext interface {
+	// MakeCurrent makes the context current on the calling thread.
+	MakeCurrent() error
+	// SwapBuffers shows the back buffer.
+	SwapBuffers() error
+	// SetSwapInterval sets the number of vertical blanks per swap: 0 disables
+	// vsync. Offscreen EGL contexts return ErrNotSupported as they are not
+	// swapped.
+	SetSwapInterval(interval int) error
+	// ProcAddress returns the address of a GL function, or 0.
+	ProcAddress(name string) uintptr
+	// Destroy releases the context. It must be called before the loop ends.
+	Destroy()
+}
+
+// errGLThread is returned if a GLContext is used off the loop thread.
+var errGLThread = errors.New("gui: GL context used off the loop thread")
@@ -0,0 +1,440 @@
+// +build linux,cgo,egl
+
+package gui
+
+/*
+#cgo LDFLAGS: -lEGL -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stdlib.h>
+// the native types are pointers and integers without the headers of X11
+#define EGL_NO_X11
+#define MESA_EGL_NO_X11_HEADERS
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+// guiGetDisplay prefers the surfaceless platform of Mesa, which needs no
+// display server, e.g. llvmpipe on a GPU-less machine.
+static EGLDisplay guiGetDisplay(void) {
+	PFNEGLGETPLATFORMDISPLAYEXTPROC get =
+		(PFNEGLGETPLATFORMDISPLAYEXTPROC)eglGetProcAddress("eglGetPlatformDisplayEXT");
+	if (get) {
+		EGLDisplay d = get(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+		if (d != EGL_NO_DISPLAY) {
+			return d;
+		}
+	}
+	return eglGetDisplay(EGL_DEFAULT_DISPLAY);
+}
+
+typedef void *(*guiXOpenDisplayFunc)(const char *name);
+typedef int (*guiXCloseDisplayFunc)(void *display);
+
+// guiXlib returns a function of libX11, which is loaded at run time so
+// that the other backends do not need it.
+static void *guiXlib(const char *name) {
+	void *xlib = dlopen("libX11.so.6", RTLD_NOW | RTLD_GLOBAL);
+	return xlib ? dlsym(xlib, name) : NULL;
+}
+
+// guiOpenX11Display opens $DISPLAY with Xlib for the X11 platform of EGL.
+static EGLDisplay guiOpenX11Display(void **native) {
+	PFNEGLGETPLATFORMDISPLAYEXTPROC get =
+		(PFNEGLGETPLATFORMDISPLAYEXTPROC)eglGetProcAddress("eglGetPlatformDisplayEXT");
+	guiXOpenDisplayFunc open = (guiXOpenDisplayFunc)guiXlib("XOpenDisplay");
+	*native = NULL;
+	if (!get || !open || !(*native = open(NULL))) {
+		return EGL_NO_DISPLAY;
+	}
+	return get(EGL_PLATFORM_X11_KHR, *native, NULL);
+}
+
+static void guiCloseX11Display(EGLDisplay display, void *native) {
+	if (display != EGL_NO_DISPLAY) {
+		eglTerminate(display);
+	}
+	guiXCloseDisplayFunc close = (guiXCloseDisplayFunc)guiXlib("XCloseDisplay");
+	if (native && close) {
+		close(native);
+	}
+}
+
+static EGLSurface guiCreateWindowSurface(EGLDisplay display, EGLConfig config, uintptr_t window) {
+	return eglCreateWindowSurface(display, config, (EGLNativeWindowType)window, NULL);
+}
+
+typedef void (*guiReadPixelsFunc)(int, int, int, int, unsigned int, unsigned int, void *);
+
+static void guiReadPixels(void *f, int width, int height, void *dst) {
+	// GL_RGBA, GL_UNSIGNED_BYTE
+	((guiReadPixelsFunc)f)(0, 0, width, height, 0x1908, 0x1401, dst);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// glCapabilities are the features of the backends with a framebuffer.
+const glCapabilities = CapOpenGL
+
+// EGL 1.5 and EGL_KHR_create_context
+const (
+	eglContextMajorVersion      = 0x3098
+	eglContextMinorVersion      = 0x30FB
+	eglContextOpenGLProfileMask = 0x30FD
+	eglContextOpenGLDebug       = 0x31B0
+	eglContextCoreProfileBit    = 0x1
+	eglContextCompatibilityBit  = 0x2
+	eglOpenGLES3Bit             = 0x40
+)
+
+// the display shared by the offscreen contexts, which is never terminated
+var (
+	eglOnce    sync.Once
+	eglDisplay C.EGLDisplay
+	eglErr     error
+)
+
+func initEGL() (C.EGLDisplay, error) {
+	eglOnce.Do(func() {
+		eglDisplay = C.guiGetDisplay()
+		if eglDisplay == C.EGLDisplay(C.EGL_NO_DISPLAY) {
+			eglErr = errors.New("eglGetDisplay: no display")
+			return
+		}
+		if C.eglInitialize(eglDisplay, nil, nil) == C.EGL_FALSE {
+			eglErr = eglError("eglInitialize")
+		}
+	})
+	return eglDisplay, eglErr
+}
+
+// openEGLX11 opens a display of its own for the window surface of a
+// context, as the X server may be gone after the window.
+func openEGLX11() (C.EGLDisplay, unsafe.Pointer, error) {
+	var native unsafe.Pointer
+	display := C.guiOpenX11Display(&native)
+	if display == C.EGLDisplay(C.EGL_NO_DISPLAY) {
+		closeEGLX11(display, native)
+		return display, nil, errors.New("eglGetPlatformDisplayEXT: no X11 display")
+	}
+	if C.eglInitialize(display, nil, nil) == C.EGL_FALSE {
+		err := eglError("eglInitialize")
+		closeEGLX11(display, native)
+		return display, nil, err
+	}
+	return display, native, nil
+}
+
+func closeEGLX11(display C.EGLDisplay, native unsafe.Pointer) {
+	C.guiCloseX11Display(display, native)
+}
+
+func eglError(api string) error {
+	return fmt.Errorf("%s: 0x%04x", api, int(C.eglGetError()))
+}
+
+// eglContext renders to a window surface of the driver, or else offscreen
+// to a pbuffer. SwapBuffers reads the pixels of the pbuffer back into the
+// framebuffer of the driver, which presents it after Draw.
+type eglContext struct {
+	w       *window
+	display C.EGLDisplay
+	config  C.EGLConfig
+	context C.EGLContext
+	surface C.EGLSurface
+	size    image.Point
+	thread  int
+
+	// of a window surface
+	driver windowSurfaceDriver
+	window uintptr
+	native unsafe.Pointer // Xlib display
+
+	readPixels unsafe.Pointer
+	pixels     []byte
+}
+
+func (w *window) CreateGLContext(config *GLConfig) (GLContext, error) {
+	if config == nil {
+		config = &GLConfig{}
+	}
+	display, err := initEGL()
+	if err != nil {
+		return nil, err
+	}
+	// a window surface if the X11 platform works, else offscreen
+	surfaceType := C.EGLint(C.EGL_PBUFFER_BIT)
+	sd, ok := w.driver.(windowSurfaceDriver)
+	var window uintptr
+	var visual uint32
+	var native unsafe.Pointer
+	if ok {
+		if d, n, err := openEGLX11(); err == nil {
+			display, native, surfaceType = d, n, C.EGL_WINDOW_BIT
+			window, visual = sd.glWindow()
+		}
+	}
+	fail := func(err error) (GLContext, error) {
+		if native != nil {
+			closeEGLX11(display, native)
+		}
+		return nil, err
+	}
+
+	api, renderable := C.EGLenum(C.EGL_OPENGL_API), C.EGLint(C.EGL_OPENGL_BIT)
+	major, minor := config.Major, config.Minor
+	switch config.Profile {
+	case GLCore:
+		if major == 0 {
+			// profiles start at 3.2
+			major, minor = 3, 2
+		}
+	case GLES:
+		api, renderable = C.EGL_OPENGL_ES_API, C.EGL_OPENGL_ES2_BIT
+		if major == 0 {
+			major, minor = 2, 0
+		}
+		if major >= 3 {
+			renderable = eglOpenGLES3Bit
+		}
+	}
+	if C.eglBindAPI(api) == C.EGL_FALSE {
+		return fail(eglError("eglBindAPI"))
+	}
+
+	configAttribs := []C.EGLint{
+		C.EGL_SURFACE_TYPE, surfaceType,
+		C.EGL_RENDERABLE_TYPE, renderable,
+		C.EGL_RED_SIZE, 8,
+		C.EGL_GREEN_SIZE, 8,
+		C.EGL_BLUE_SIZE, 8,
+		C.EGL_ALPHA_SIZE, 8,
+		C.EGL_DEPTH_SIZE, C.EGLint(config.DepthBits),
+		C.EGL_STENCIL_SIZE, C.EGLint(config.StencilBits),
+		C.EGL_NONE,
+	}
+	cfg, err := chooseConfig(display, configAttribs, visual)
+	if err != nil {
+		return fail(err)
+	}
+
+	contextAttribs := []C.EGLint{}
+	if major != 0 {
+		contextAttribs = append(contextAttribs,
+			eglContextMajorVersion, C.EGLint(major),
+			eglContextMinorVersion, C.EGLint(minor))
+	}
+	switch config.Profile {
+	case GLCore:
+		contextAttribs = append(contextAttribs, eglContextOpenGLProfileMask, eglContextCoreProfileBit)
+	case GLCompatibility:
+		contextAttribs = append(contextAttribs, eglContextOpenGLProfileMask, eglContextCompatibilityBit)
+	}
+	if config.Debug {
+		contextAttribs = append(contextAttribs, eglContextOpenGLDebug, C.EGL_TRUE)
+	}
+	contextAttribs = append(contextAttribs, C.EGL_NONE)
+	context := C.eglCreateContext(display, cfg, C.EGLContext(C.EGL_NO_CONTEXT), &contextAttribs[0])
+	if context == C.EGLContext(C.EGL_NO_CONTEXT) {
+		return fail(eglError("eglCreateContext"))
+	}
+	w.app.res.acquire(resGLContext)
+
+	c := &eglContext{
+		w:       w,
+		display: display,
+		config:  cfg,
+		context: context,
+		surface: C.EGLSurface(C.EGL_NO_SURFACE),
+		thread:  unix.Gettid(),
+	}
+	if window != 0 {
+		c.driver, c.window, c.native = sd, window, native
+	}
+	if err := c.MakeCurrent(); err != nil {
+		c.Destroy()
+		return nil, err
+	}
+	c.readPixels = eglProc("glReadPixels")
+	if c.readPixels == nil {
+		c.Destroy()
+		return nil, errors.New("eglGetProcAddress: no glReadPixels")
+	}
+	return c, nil
+}
+
+// chooseConfig returns the first config of attribs, which has visual if
+// it is not 0.
+func chooseConfig(display C.EGLDisplay, attribs []C.EGLint, visual uint32) (C.EGLConfig, error) {
+	var configs [64]C.EGLConfig
+	var n C.EGLint
+	if C.eglChooseConfig(display, &attribs[0], &configs[0], C.EGLint(len(configs)), &n) == C.EGL_FALSE {
+		return configs[0], eglError("eglChooseConfig")
+	}
+	for _, cfg := range configs[:n] {
+		var id C.EGLint
+		if visual == 0 || C.eglGetConfigAttrib(display, cfg, C.EGL_NATIVE_VISUAL_ID, &id) != C.EGL_FALSE && uint32(id) == visual {
+			return cfg, nil
+		}
+	}
+	return configs[0], ErrNotSupported
+}
+
+// targetSize returns the size of the framebuffer of the window.
+func (c *eglContext) targetSize() image.Point {
+	if img := c.w.driver.framebuffer(); img != nil {
+		return img.Rect.Size()
+	}
+	if size := c.w.size.Size(); size.X > 0 && size.Y > 0 {
+		return size
+	}
+	return image.Pt(1, 1)
+}
+
+func (c *eglContext) check() error {
+	if c.context == C.EGLContext(C.EGL_NO_CONTEXT) {
+		return errors.New("gui: GL context destroyed")
+	}
+	if unix.Gettid() != c.thread {
+		return errGLThread
+	}
+	return nil
+}
+
+// MakeCurrent also creates the window surface or resizes the pbuffer to
+// the window.
+func (c *eglContext) MakeCurrent() error {
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	size := c.targetSize()
+	if c.window != 0 {
+		if c.surface == C.EGLSurface(C.EGL_NO_SURFACE) {
+			c.surface = C.guiCreateWindowSurface(c.display, c.config, C.uintptr_t(c.window))
+			if c.surface == C.EGLSurface(C.EGL_NO_SURFACE) {
+				return eglError("eglCreateWindowSurface")
+			}
+			c.driver.setGLSurface(true)
+		}
+	} else if c.surface == C.EGLSurface(C.EGL_NO_SURFACE) || size != c.size {
+		attribs := []C.EGLint{
+			C.EGL_WIDTH, C.EGLint(size.X),
+			C.EGL_HEIGHT, C.EGLint(size.Y),
+			C.EGL_NONE,
+		}
+		surface := C.eglCreatePbufferSurface(c.display, c.config, &attribs[0])
+		if surface == C.EGLSurface(C.EGL_NO_SURFACE) {
+			return eglError("eglCreatePbufferSurface")
+		}
+		if c.surface != C.EGLSurface(C.EGL_NO_SURFACE) {
+			C.eglMakeCurrent(c.display, C.EGLSurface(C.EGL_NO_SURFACE), C.EGLSurface(C.EGL_NO_SURFACE), C.EGLContext(C.EGL_NO_CONTEXT))
+			C.eglDestroySurface(c.display, c.surface)
+		}
+		c.surface = surface
+		c.size = size
+	}
+
+	if C.eglMakeCurrent(c.display, c.surface, c.surface, c.context) == C.EGL_FALSE {
+		return eglError("eglMakeCurrent")
+	}
+	return nil
+}
+
+// SwapBuffers presents the window surface or copies the rendered image to
+// the framebuffer of the driver.
+func (c *eglContext) SwapBuffers() error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	if c.window != 0 {
+		if C.eglSwapBuffers(c.display, c.surface) == C.EGL_FALSE {
+			return eglError("eglSwapBuffers")
+		}
+		return nil
+	}
+	img := c.w.driver.framebuffer()
+	if img == nil {
+		return nil
+	}
+
+	// the image may have been resized after MakeCurrent
+	size := img.Rect.Size()
+	if size.X > c.size.X {
+		size.X = c.size.X
+	}
+	if size.Y > c.size.Y {
+		size.Y = c.size.Y
+	}
+	if size.X <= 0 || size.Y <= 0 {
+		return nil
+	}
+	stride := size.X * 4
+	if len(c.pixels) < stride*size.Y {
+		c.pixels = make([]byte, stride*size.Y)
+	}
+	C.guiReadPixels(c.readPixels, C.int(size.X), C.int(size.Y), unsafe.Pointer(&c.pixels[0]))
+
+	// GL rows are bottom-up
+	for y := 0; y < size.Y; y++ {
+		src := c.pixels[(size.Y-1-y)*stride:]
+		copy(img.Pix[img.PixOffset(img.Rect.Min.X, img.Rect.Min.Y+y):], src[:stride])
+	}
+	return nil
+}
+
+// SetSwapInterval sets the interval of the current window surface. It
+// returns ErrNotSupported offscreen: the pbuffer is read back by
+// SwapBuffers and never swapped, so there is no vertical blank to wait for.
+func (c *eglContext) SetSwapInterval(interval int) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	if c.window == 0 {
+		return ErrNotSupported
+	}
+	if C.eglSwapInterval(c.display, C.EGLint(interval)) == C.EGL_FALSE {
+		return eglError("eglSwapInterval")
+	}
+	return nil
+}
+
+func (c *eglContext) ProcAddress(name string) uintptr {
+	return uintptr(eglProc(name))
+}
+
+func eglProc(name string) unsafe.Pointer {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	return unsafe.Pointer(C.eglGetProcAddress(cname))
+}
+
+func (c *eglContext) Destroy() {
+	if c.context == C.EGLContext(C.EGL_NO_CONTEXT) {
+		return
+	}
+	C.eglMakeCurrent(c.display, C.EGLSurface(C.EGL_NO_SURFACE), C.EGLSurface(C.EGL_NO_SURFACE), C.EGLContext(C.EGL_NO_CONTEXT))
+	if c.surface != C.EGLSurface(C.EGL_NO_SURFACE) {
+		C.eglDestroySurface(c.display, c.surface)
+		c.surface = C.EGLSurface(C.EGL_NO_SURFACE)
+		if c.window != 0 {
+			c.driver.setGLSurface(false)
+		}
+	}
+	C.eglDestroyContext(c.display, c.context)
+	c.context = C.EGLContext(C.EGL_NO_CONTEXT)
+	if c.native != nil {
+		closeEGLX11(c.display, c.native)
+		c.native = nil
+	}
+	c.w.app.res.release(resGLContext)
+}
@@ -0,0 +1,152 @@
+// +build linux,cgo,egl
+
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"testing"
+
+	"github.com/ysh86/gui/internal/gltest"
+)
+
+// glTestRenderer runs test with a GL context on its first Draw.
+type glTestRenderer struct {
+	w      Window
+	test   func(c GLContext) error
+	result chan error
+}
+
+func (r *glTestRenderer) Init() error                       { return nil }
+func (r *glTestRenderer) Deinit()                           {}
+func (r *glTestRenderer) Dpi() (float32, float32)           { return 96, 96 }
+func (r *glTestRenderer) Update(width, height uint32) error { return nil }
+
+func (r *glTestRenderer) HandleEvent(e Event) {
+	if c, ok := e.(*CreateEvent); ok {
+		r.w = c.Window
+	}
+}
+
+func (r *glTestRenderer) Draw(nativeWindow uintptr) error {
+	if r.test == nil {
+		return nil
+	}
+	c, err := r.w.CreateGLContext(nil)
+	if err == nil {
+		err = r.test(c)
+		c.Destroy()
+	}
+	r.test = nil
+	r.result <- err
+	return nil
+}
+
+// TestEGLContext renders offscreen with the EGL of Mesa, which is llvmpipe
+// without a GPU.
+func TestEGLContext(t *testing.T) {
+	setenv(t, "LIBGL_ALWAYS_SOFTWARE", "1")
+	if _, err := initEGL(); err != nil {
+		t.Skipf("no EGL: %v", err)
+	}
+
+	app := NewApplication(WithBackend("headless"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := &glTestRenderer{result: make(chan error, 1)}
+	r.test = func(c GLContext) error {
+		if err := c.MakeCurrent(); err != nil {
+			return err
+		}
+		if c.ProcAddress("glClear") == 0 {
+			return errors.New("no glClear")
+		}
+		if err := c.SetSwapInterval(1); err != ErrNotSupported {
+			return fmt.Errorf("SetSwapInterval: %v, want ErrNotSupported", err)
+		}
+		return c.SwapBuffers()
+	}
+	errc := app.Loop("egl", 32, 16, r)
+
+	select {
+	case err := <-r.result:
+		if err == ErrNotSupported {
+			t.Skip("no EGL config for the pbuffer")
+		}
+		if err != nil {
+			t.Error(err)
+		}
+	case err := <-errc:
+		t.Fatalf("Loop: %v", err)
+	case <-testTimeout():
+		t.Fatal("the window was not drawn")
+	}
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+	app.Deinit()
+	if err := CheckLeaks(app); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestEGLWindowSurface draws to the window of the fake X server with a
+// window surface, which Mesa presents with PutImage.
+func TestEGLWindowSurface(t *testing.T) {
+	setenv(t, "LIBGL_ALWAYS_SOFTWARE", "1")
+	s := startXServer(t)
+	display, native, err := openEGLX11()
+	if err != nil {
+		t.Skipf("no EGL on X11: %v", err)
+	}
+	closeEGLX11(display, native)
+
+	app := NewApplication(WithBackend("x11"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := &glTestRenderer{result: make(chan error, 1)}
+	r.test = func(c GLContext) error {
+		if err := c.MakeCurrent(); err != nil {
+			return err
+		}
+		if err := c.SetSwapInterval(1); err != nil {
+			return fmt.Errorf("SetSwapInterval: %v", err)
+		}
+		if err := gltest.Clear(c.ProcAddress, 0, 1, 0, 1); err != nil {
+			return err
+		}
+		return c.SwapBuffers()
+	}
+	errc := app.Loop("egl", 32, 16, r)
+
+	select {
+	case err := <-r.result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case err := <-errc:
+		t.Fatalf("Loop: %v", err)
+	case <-testTimeout():
+		t.Fatal("the window was not drawn")
+	}
+	green := color.RGBA{0, 0xff, 0, 0xff}
+	s.window(t, func(w *fakeXWindow) bool {
+		return w.image.RGBAAt(31, 15) == green
+	})
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+	app.Deinit()
+	if err := CheckLeaks(app); err != nil {
+		t.Error(err)
+	}
+}
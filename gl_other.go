@@ -0,0 +1,12 @@
+// +build !windows
+// +build !linux !cgo !egl
+
+package gui
+
+// glCapabilities are the features of the backends with a framebuffer.
+// OpenGL needs the egl build tag.
+const glCapabilities Capability = 0
+
+func (w *window) CreateGLContext(config *GLConfig) (GLContext, error) {
+	return nil, ErrNotSupported
+}
@@ -0,0 +1,195 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// wglContext is a WGL context of a window.
+type wglContext struct {
+	window  windows.Handle
+	dc      windows.Handle
+	context windows.Handle
+	thread  uint32
+	res     *resources
+}
+
+func (w *window) CreateGLContext(config *GLConfig) (GLContext, error) {
+	if config == nil {
+		config = &GLConfig{}
+	}
+
+	dc, err := GetDC(w.handle)
+	if err != nil {
+		return nil, fmt.Errorf("GetDC: %v", err)
+	}
+	c := &wglContext{window: w.handle, dc: dc, thread: windows.GetCurrentThreadId(), res: &w.app.res}
+
+	pfd := &PixelFormatDescriptor{
+		Version:     1,
+		Flags:       PFD_DRAW_TO_WINDOW | PFD_SUPPORT_OPENGL | PFD_DOUBLEBUFFER,
+		PixelType:   PFD_TYPE_RGBA,
+		ColorBits:   32,
+		AlphaBits:   8,
+		DepthBits:   byte(config.DepthBits),
+		StencilBits: byte(config.StencilBits),
+		LayerType:   PFD_MAIN_PLANE,
+	}
+	pfd.Size = uint16(unsafe.Sizeof(*pfd))
+	format, err := ChoosePixelFormat(dc, pfd)
+	if err != nil {
+		c.Destroy()
+		return nil, fmt.Errorf("ChoosePixelFormat: %v", err)
+	}
+	if err := SetPixelFormat(dc, format, pfd); err != nil {
+		c.Destroy()
+		return nil, fmt.Errorf("SetPixelFormat: %v", err)
+	}
+
+	// wglCreateContextAttribsARB is loaded with a legacy context
+	legacy, err := WglCreateContext(dc)
+	if err != nil {
+		c.Destroy()
+		return nil, fmt.Errorf("wglCreateContext: %v", err)
+	}
+	c.context = legacy
+	c.res.acquire(resGLContext)
+	if err := WglMakeCurrent(dc, legacy); err != nil {
+		c.Destroy()
+		return nil, fmt.Errorf("wglMakeCurrent: %v", err)
+	}
+
+	create := c.ProcAddress("wglCreateContextAttribsARB")
+	if create == 0 {
+		if config.Major == 0 && config.Profile == GLCompatibility {
+			return c, nil
+		}
+		c.Destroy()
+		return nil, ErrNotSupported
+	}
+
+	attribs := wglAttribs(config)
+	ctx, _, e := syscall.Syscall(create, 3, uintptr(dc), 0, uintptr(unsafe.Pointer(&attribs[0])))
+	if ctx == 0 {
+		c.Destroy()
+		return nil, fmt.Errorf("wglCreateContextAttribsARB: %v", e)
+	}
+	c.context = windows.Handle(ctx)
+	WglDeleteContext(legacy)
+	if err := WglMakeCurrent(dc, c.context); err != nil {
+		c.Destroy()
+		return nil, fmt.Errorf("wglMakeCurrent: %v", err)
+	}
+	return c, nil
+}
+
+// wglAttribs returns the attribute list of wglCreateContextAttribsARB for config.
+func wglAttribs(config *GLConfig) []int32 {
+	major, minor := config.Major, config.Minor
+	var profile int32
+	switch config.Profile {
+	case GLCore:
+		profile = WGL_CONTEXT_CORE_PROFILE_BIT_ARB
+		if major == 0 {
+			// profiles start at 3.2
+			major, minor = 3, 2
+		}
+	case GLCompatibility:
+		profile = WGL_CONTEXT_COMPATIBILITY_PROFILE_BIT_ARB
+	case GLES:
+		profile = WGL_CONTEXT_ES2_PROFILE_BIT_EXT
+		if major == 0 {
+			major, minor = 2, 0
+		}
+	}
+
+	attribs := []int32{WGL_CONTEXT_PROFILE_MASK_ARB, profile}
+	if major != 0 {
+		attribs = append(attribs,
+			WGL_CONTEXT_MAJOR_VERSION_ARB, int32(major),
+			WGL_CONTEXT_MINOR_VERSION_ARB, int32(minor))
+	}
+	if config.Debug {
+		attribs = append(attribs, WGL_CONTEXT_FLAGS_ARB, WGL_CONTEXT_DEBUG_BIT_ARB)
+	}
+	return append(attribs, 0)
+}
+
+func (c *wglContext) check() error {
+	if c.context == 0 {
+		return errors.New("gui: GL context destroyed")
+	}
+	if windows.GetCurrentThreadId() != c.thread {
+		return errGLThread
+	}
+	return nil
+}
+
+func (c *wglContext) MakeCurrent() error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	if err := WglMakeCurrent(c.dc, c.context); err != nil {
+		return fmt.Errorf("wglMakeCurrent: %v", err)
+	}
+	return nil
+}
+
+func (c *wglContext) SwapBuffers() error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	if err := SwapBuffers(c.dc); err != nil {
+		return fmt.Errorf("SwapBuffers: %v", err)
+	}
+	return nil
+}
+
+func (c *wglContext) SetSwapInterval(interval int) error {
+	if err := c.MakeCurrent(); err != nil {
+		return err
+	}
+	swap := c.ProcAddress("wglSwapIntervalEXT")
+	if swap == 0 {
+		return ErrNotSupported
+	}
+	if r, _, e := syscall.Syscall(swap, 1, uintptr(interval), 0, 0); r == 0 {
+		return fmt.Errorf("wglSwapIntervalEXT: %v", e)
+	}
+	return nil
+}
+
+// ProcAddress looks up extensions and GL 1.2+ functions with wglGetProcAddress
+// and GL 1.1 functions in opengl32.dll.
+func (c *wglContext) ProcAddress(name string) uintptr {
+	b, err := windows.BytePtrFromString(name)
+	if err != nil {
+		return 0
+	}
+	// some drivers return 1, 2, 3 or -1 on failure
+	if p := WglGetProcAddress(b); p > 3 && p != ^uintptr(0) {
+		return p
+	}
+	proc := modopengl32.NewProc(name)
+	if proc.Find() != nil {
+		return 0
+	}
+	return proc.Addr()
+}
+
+func (c *wglContext) Destroy() {
+	if c.context != 0 {
+		WglMakeCurrent(0, 0)
+		WglDeleteContext(c.context)
+		c.context = 0
+		c.res.release(resGLContext)
+	}
+	if c.dc != 0 {
+		ReleaseDC(c.window, c.dc)
+		c.dc = 0
+	}
+}
@@ -1,11 +1,35 @@
 package gui
 
+import (
+	"errors"
+	"image"
+	"time"
+)
+
+// ErrNotSupported is returned when a feature is not available on the current platform.
+var ErrNotSupported = errors.New("gui: not supported")
+
 // Application is the GUI application.
 type Application interface {
 	Init() error
 	Deinit()
+	// EnableLog logs to stderr and traces all events there if there is no
+	// tracer of WithTracer.
 	EnableLog() error
+	// Loop creates a window and handles its events on a new thread. The
+	// channel receives nil when the window is closed or an error, e.g.
+	// *RendererError, *WindowError, *ThreadInitError or QuitError.
 	Loop(windowName string, width int32, height int32, renderer Renderer) <-chan error
+	// Quit closes the windows of all loops, which send nil for code 0 or
+	// QuitError. It may be called on any goroutine. Loops started later
+	// quit at once.
+	Quit(code int)
+	Shortcuts() *Shortcuts
+
+	// Backend returns the name of the backend selected by Init.
+	Backend() string
+	// Supports reports whether the backend has all of caps.
+	Supports(caps Capability) bool
 }
 
 // Renderer is a renderer for drawing window contents.
@@ -17,4 +41,80 @@ type Renderer interface {
 	Draw(nativeWindow uintptr) error
 }
 
+// SoftwareRenderer is an optional interface of Renderer to draw in memory on
+// every backend. DrawImage is called instead of Draw and dst is presented
+// when it returns, through a DIB section on Windows. dst has the size of the
+// last Update.
+type SoftwareRenderer interface {
+	DrawImage(dst *image.RGBA) error
+}
+
+// RegionRenderer is an optional interface of Renderer to redraw only the
+// damaged region in pixels. DrawRegion is called instead of Draw and the
+// rest of the window keeps its contents.
+type RegionRenderer interface {
+	DrawRegion(nativeWindow uintptr, region []image.Rectangle) error
+}
+
+// SoftwareRegionRenderer is RegionRenderer for SoftwareRenderer.
+// dst holds the previous frame outside of region.
+type SoftwareRegionRenderer interface {
+	DrawImageRegion(dst *image.RGBA, region []image.Rectangle) error
+}
+
+// NativeHandler is an optional interface of Renderer to see the native
+// messages of its window before the library handles them. HandleNative is
+// called on the loop thread and returns true if it has handled m, which the
+// library then skips.
+type NativeHandler interface {
+	HandleNative(m *NativeMessage) bool
+}
+
+// IdleHandler is an optional interface of Renderer for lazy work, e.g.
+// freeing caches. Idle is called on the loop thread once per iteration of
+// the loop when it is about to wait for messages.
+type IdleHandler interface {
+	Idle()
+}
+
 //go:generate go run $GOROOT/src/syscall/mksyscall_windows.go -systemdll -output zgui_windows.go gui_windows.go
+
+// Window is a window created by Application.Loop.
+// Its methods must be called on the loop thread, e.g. from EventHandler.HandleEvent.
+type Window interface {
+	Name() string
+	NativeHandle() uintptr
+	// SetMenu sets the menu bar, nil removes it. The backends without
+	// native menus draw it over the top of the window.
+	SetMenu(menu *Menu) error
+	// PopupMenu shows menu at the pointer. The selected item is delivered
+	// as a CommandEvent. The backends without native menus draw it over the
+	// window and return at once, or return ErrNotSupported if the window
+	// has no framebuffer.
+	PopupMenu(menu *Menu) error
+	Shortcuts() *Shortcuts
+	// Invalidate marks r, clipped to the window, to be redrawn.
+	// Overlapping rectangles are merged and drawn once.
+	Invalidate(r image.Rectangle)
+	// CreateGLContext creates an OpenGL context drawing to the window,
+	// or returns ErrNotSupported.
+	CreateGLContext(config *GLConfig) (GLContext, error)
+	// AfterFunc calls f on the loop thread after d, so that it may change
+	// the state of the renderer without locks.
+	AfterFunc(d time.Duration, f func()) *Timer
+	// Tick calls f on the loop thread every d until the timer is stopped.
+	// Ticks missed while the loop is busy are dropped.
+	Tick(d time.Duration, f func()) *Timer
+	// Watch calls f on the loop thread while the file descriptor fd is
+	// ready for events, or ErrNotSupported. If fd is closed before the
+	// watcher is stopped, f is called with Hangup and the watcher stops.
+	// On Windows, fd is a waitable handle and f is called with Readable
+	// while it is signaled.
+	Watch(fd uintptr, events Readiness, f func(ready Readiness)) (*Watcher, error)
+	// Drain receives the values of the channel ch on the loop thread and
+	// calls f with each. At most max values are received between the
+	// events of the window. It stops when ch is closed.
+	Drain(ch interface{}, max int, f func(v interface{})) *Watcher
+	// Stats returns the statistics of the loop.
+	Stats() LoopStats
+}
@@ -0,0 +1,99 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"testing"
+	"time"
+)
+
+// testTimeout fires when a test has waited too long for an event.
+func testTimeout() <-chan time.Time {
+	return time.After(10 * time.Second)
+}
+
+// setenv sets an environment variable for the test.
+func setenv(t *testing.T, key, value string) {
+	prev, had := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// testRenderer is a SoftwareRenderer which fills the window with a color
+// and reports its frames and events.
+type testRenderer struct {
+	color  color.RGBA
+	frames chan *image.RGBA // copies of the frames
+	events chan Event
+	window chan Window // of CreateEvent
+}
+
+func newTestRenderer(c color.RGBA) *testRenderer {
+	return &testRenderer{
+		color:  c,
+		frames: make(chan *image.RGBA, 16),
+		events: make(chan Event, 64),
+		window: make(chan Window, 1),
+	}
+}
+
+func (r *testRenderer) Init() error                       { return nil }
+func (r *testRenderer) Deinit()                           {}
+func (r *testRenderer) Dpi() (float32, float32)           { return 96, 96 }
+func (r *testRenderer) Update(width, height uint32) error { return nil }
+func (r *testRenderer) Draw(nativeWindow uintptr) error   { return nil }
+
+func (r *testRenderer) DrawImage(dst *image.RGBA) error {
+	draw.Draw(dst, dst.Rect, &image.Uniform{r.color}, image.Point{}, draw.Src)
+	frame := image.NewRGBA(dst.Rect)
+	copy(frame.Pix, dst.Pix)
+	select {
+	case r.frames <- frame:
+	default:
+	}
+	return nil
+}
+
+func (r *testRenderer) HandleEvent(e Event) {
+	if c, ok := e.(*CreateEvent); ok {
+		r.window <- c.Window
+	}
+	select {
+	case r.events <- e:
+	default:
+	}
+}
+
+// nextFrame waits for the next frame of r.
+func (r *testRenderer) nextFrame(t *testing.T) *image.RGBA {
+	select {
+	case f := <-r.frames:
+		return f
+	case <-testTimeout():
+		t.Fatal("no frame was drawn")
+		return nil
+	}
+}
+
+// nextEvent waits for the next event of r which f accepts.
+func (r *testRenderer) nextEvent(t *testing.T, f func(e Event) bool) Event {
+	for {
+		select {
+		case e := <-r.events:
+			if f(e) {
+				return e
+			}
+		case <-testTimeout():
+			t.Fatal("no event was received")
+			return nil
+		}
+	}
+}
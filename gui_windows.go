@@ -128,11 +128,92 @@ const (
 )
 const (
 	// Messages
+	WM_NULL          = 0x0000
 	WM_CREATE        = 0x0001
 	WM_DESTROY       = 0x0002
 	WM_SIZE          = 0x0005
 	WM_PAINT         = 0x000F
+	WM_CLOSE         = 0x0010
+	WM_ERASEBKGND    = 0x0014
+	WM_CONTEXTMENU   = 0x007B
 	WM_DISPLAYCHANGE = 0x007E
+	WM_NCDESTROY     = 0x0082
+	WM_KEYDOWN       = 0x0100
+	WM_KEYUP         = 0x0101
+	WM_CHAR          = 0x0102
+	WM_SYSKEYDOWN    = 0x0104
+	WM_SYSKEYUP      = 0x0105
+	WM_COMMAND       = 0x0111
+	WM_TIMER         = 0x0113
+	WM_LBUTTONUP     = 0x0202
+	WM_LBUTTONDBLCLK = 0x0203
+	WM_RBUTTONUP     = 0x0205
+	WM_USER          = 0x0400
+	WM_APP           = 0x8000
+)
+const (
+	// CreateWindow() parent for message-only windows
+	HWND_MESSAGE = ^uintptr(2) // (HWND)-3
+)
+const (
+	// Virtual keys
+	VK_BACK       = 0x08
+	VK_TAB        = 0x09
+	VK_RETURN     = 0x0D
+	VK_SHIFT      = 0x10
+	VK_CONTROL    = 0x11
+	VK_MENU       = 0x12
+	VK_ESCAPE     = 0x1B
+	VK_SPACE      = 0x20
+	VK_PRIOR      = 0x21
+	VK_NEXT       = 0x22
+	VK_END        = 0x23
+	VK_HOME       = 0x24
+	VK_LEFT       = 0x25
+	VK_UP         = 0x26
+	VK_RIGHT      = 0x27
+	VK_DOWN       = 0x28
+	VK_INSERT     = 0x2D
+	VK_DELETE     = 0x2E
+	VK_LWIN       = 0x5B
+	VK_RWIN       = 0x5C
+	VK_F1         = 0x70
+	VK_F12        = 0x7B
+	VK_OEM_PLUS   = 0xBB
+	VK_OEM_COMMA  = 0xBC
+	VK_OEM_MINUS  = 0xBD
+	VK_OEM_PERIOD = 0xBE
+	VK_OEM_2      = 0xBF
+)
+const (
+	// Menu flags
+	MF_BYCOMMAND  = 0x00000000
+	MF_BYPOSITION = 0x00000400
+	MF_STRING     = 0x00000000
+	MF_GRAYED     = 0x00000001
+	MF_DISABLED   = 0x00000002
+	MF_CHECKED    = 0x00000008
+	MF_POPUP      = 0x00000010
+	MF_SEPARATOR  = 0x00000800
+)
+const (
+	// TrackPopupMenu() flags
+	TPM_LEFTBUTTON  = 0x0000
+	TPM_RIGHTBUTTON = 0x0002
+	TPM_LEFTALIGN   = 0x0000
+	TPM_TOPALIGN    = 0x0000
+	TPM_NONOTIFY    = 0x0080
+	TPM_RETURNCMD   = 0x0100
+)
+const (
+	// MsgWaitForMultipleObjectsEx() and PeekMessage()
+	INFINITE             = 0xFFFFFFFF
+	WAIT_OBJECT_0        = 0x00000000
+	WAIT_ABANDONED_0     = 0x00000080
+	MAXIMUM_WAIT_OBJECTS = 64
+	QS_ALLINPUT          = 0x04FF
+	MWMO_INPUTAVAILABLE  = 0x0004
+	PM_NOREMOVE          = 0x0000
 )
 const (
 	// Icons
@@ -178,6 +259,141 @@ const (
 
 	GWLP_HWNDPARENT = -8 // Don't use! Use the SetParent function.
 )
+const (
+	// MessageBox() flags
+	MB_OK               = 0x00000000
+	MB_OKCANCEL         = 0x00000001
+	MB_ABORTRETRYIGNORE = 0x00000002
+	MB_YESNOCANCEL      = 0x00000003
+	MB_YESNO            = 0x00000004
+	MB_RETRYCANCEL      = 0x00000005
+	MB_ICONHAND         = 0x00000010
+	MB_ICONQUESTION     = 0x00000020
+	MB_ICONEXCLAMATION  = 0x00000030
+	MB_ICONASTERISK     = 0x00000040
+	MB_ICONWARNING      = MB_ICONEXCLAMATION
+	MB_ICONERROR        = MB_ICONHAND
+	MB_ICONINFORMATION  = MB_ICONASTERISK
+	MB_APPLMODAL        = 0x00000000
+	MB_SYSTEMMODAL      = 0x00001000
+	MB_TASKMODAL        = 0x00002000
+	MB_SETFOREGROUND    = 0x00010000
+	MB_TOPMOST          = 0x00040000
+)
+const (
+	// Dialog box command IDs
+	IDOK     = 1
+	IDCANCEL = 2
+	IDABORT  = 3
+	IDRETRY  = 4
+	IDIGNORE = 5
+	IDYES    = 6
+	IDNO     = 7
+)
+
+// wingdi.h
+const (
+	// Region types (0 is ERROR)
+	NULLREGION    = 1
+	SIMPLEREGION  = 2
+	COMPLEXREGION = 3
+)
+const (
+	BI_RGB         = 0
+	DIB_RGB_COLORS = 0
+	SRCCOPY        = 0x00CC0020
+)
+const (
+	// PixelFormatDescriptor flags
+	PFD_DOUBLEBUFFER   = 0x00000001
+	PFD_DRAW_TO_WINDOW = 0x00000004
+	PFD_SUPPORT_OPENGL = 0x00000020
+	PFD_TYPE_RGBA      = 0
+	PFD_MAIN_PLANE     = 0
+)
+
+// wglext.h
+const (
+	WGL_CONTEXT_MAJOR_VERSION_ARB             = 0x2091
+	WGL_CONTEXT_MINOR_VERSION_ARB             = 0x2092
+	WGL_CONTEXT_FLAGS_ARB                     = 0x2094
+	WGL_CONTEXT_PROFILE_MASK_ARB              = 0x9126
+	WGL_CONTEXT_DEBUG_BIT_ARB                 = 0x0001
+	WGL_CONTEXT_CORE_PROFILE_BIT_ARB          = 0x0001
+	WGL_CONTEXT_COMPATIBILITY_PROFILE_BIT_ARB = 0x0002
+	WGL_CONTEXT_ES2_PROFILE_BIT_EXT           = 0x0004
+)
+
+// commdlg.h
+const (
+	OFN_READONLY         = 0x00000001
+	OFN_OVERWRITEPROMPT  = 0x00000002
+	OFN_HIDEREADONLY     = 0x00000004
+	OFN_NOCHANGEDIR      = 0x00000008
+	OFN_ALLOWMULTISELECT = 0x00000200
+	OFN_PATHMUSTEXIST    = 0x00000800
+	OFN_FILEMUSTEXIST    = 0x00001000
+	OFN_EXPLORER         = 0x00080000
+)
+const (
+	CC_RGBINIT  = 0x00000001
+	CC_FULLOPEN = 0x00000002
+	CC_ANYCOLOR = 0x00000100
+)
+const (
+	// CommDlgExtendedError() codes
+	FNERR_BUFFERTOOSMALL = 0x3003
+)
+
+// shlobj.h
+const (
+	BIF_RETURNONLYFSDIRS = 0x00000001
+	BIF_EDITBOX          = 0x00000010
+	BIF_NEWDIALOGSTYLE   = 0x00000040
+	BIF_USENEWUI         = (BIF_NEWDIALOGSTYLE | BIF_EDITBOX)
+)
+
+// shellapi.h
+const (
+	NIM_ADD        = 0x00000000
+	NIM_MODIFY     = 0x00000001
+	NIM_DELETE     = 0x00000002
+	NIM_SETVERSION = 0x00000004
+)
+const (
+	NIF_MESSAGE  = 0x00000001
+	NIF_ICON     = 0x00000002
+	NIF_TIP      = 0x00000004
+	NIF_INFO     = 0x00000010
+	NIF_SHOWTIP  = 0x00000080
+	NIIF_NONE    = 0x00000000
+	NIIF_INFO    = 0x00000001
+	NIIF_WARNING = 0x00000002
+	NIIF_ERROR   = 0x00000003
+	NIIF_USER    = 0x00000004
+)
+const (
+	NOTIFYICON_VERSION_4 = 4
+
+	NIN_SELECT           = (WM_USER + 0)
+	NIN_KEYSELECT        = (WM_USER + 1)
+	NIN_BALLOONSHOW      = (WM_USER + 2)
+	NIN_BALLOONHIDE      = (WM_USER + 3)
+	NIN_BALLOONTIMEOUT   = (WM_USER + 4)
+	NIN_BALLOONUSERCLICK = (WM_USER + 5)
+)
+
+// winerror.h
+const (
+	S_OK               = 0
+	S_FALSE            = 1
+	RPC_E_CHANGED_MODE = 0x80010106
+)
+
+// minwindef.h
+const (
+	MAX_PATH = 260
+)
 
 // macros
 func MAKEINTRESOURCE(value uint16) *uint16 {
@@ -223,6 +439,150 @@ type CreateStruct struct {
 	ExStyle      uint32
 }
 
+// OpenFileName is a struct for GetOpenFileName() and GetSaveFileName().
+type OpenFileName struct {
+	StructSize    uint32
+	Owner         windows.Handle
+	Instance      windows.Handle
+	Filter        *uint16
+	CustomFilter  *uint16
+	MaxCustFilter uint32
+	FilterIndex   uint32
+	File          *uint16
+	MaxFile       uint32
+	FileTitle     *uint16
+	MaxFileTitle  uint32
+	InitialDir    *uint16
+	Title         *uint16
+	Flags         uint32
+	FileOffset    uint16
+	FileExtension uint16
+	DefExt        *uint16
+	CustData      uintptr
+	Hook          uintptr
+	TemplateName  *uint16
+	Reserved      uintptr
+	Reserved2     uint32
+	FlagsEx       uint32
+}
+
+// ChooseColorInfo is a struct for ChooseColor().
+type ChooseColorInfo struct {
+	StructSize   uint32
+	Owner        windows.Handle
+	Instance     windows.Handle
+	Result       uint32 // COLORREF
+	CustColors   *[16]uint32
+	Flags        uint32
+	CustData     uintptr
+	Hook         uintptr
+	TemplateName *uint16
+}
+
+// BrowseInfo is a struct for SHBrowseForFolder().
+type BrowseInfo struct {
+	Owner       windows.Handle
+	Root        uintptr
+	DisplayName *uint16
+	Title       *uint16
+	Flags       uint32
+	Callback    uintptr
+	LParam      uintptr
+	Image       int32
+}
+
+// NotifyIconData is a struct for Shell_NotifyIcon().
+type NotifyIconData struct {
+	Size            uint32
+	Wnd             windows.Handle
+	ID              uint32
+	Flags           uint32
+	CallbackMessage uint32
+	Icon            windows.Handle
+	Tip             [128]uint16
+	State           uint32
+	StateMask       uint32
+	Info            [256]uint16
+	Version         uint32 // or Timeout
+	InfoTitle       [64]uint16
+	InfoFlags       uint32
+	GUIDItem        windows.GUID
+	BalloonIcon     windows.Handle
+}
+
+// IconCursorInfo is a struct for CreateIconIndirect().
+type IconCursorInfo struct {
+	Icon     int32 // BOOL
+	XHotspot uint32
+	YHotspot uint32
+	Mask     windows.Handle
+	Color    windows.Handle
+}
+
+// RgnDataHeader is a struct for GetRegionData(). The rectangles follow it.
+type RgnDataHeader struct {
+	Size    uint32
+	Type    uint32
+	Count   uint32
+	RgnSize uint32
+	Bound   Rect
+}
+
+// PaintStruct is a struct for BeginPaint().
+type PaintStruct struct {
+	Hdc         windows.Handle
+	Erase       int32 // BOOL
+	Paint       Rect
+	Restore     int32
+	IncUpdate   int32
+	RGBReserved [32]byte
+}
+
+// BitmapInfoHeader is a struct for CreateDIBSection().
+type BitmapInfoHeader struct {
+	Size          uint32
+	Width         int32
+	Height        int32 // negative for top-down rows
+	Planes        uint16
+	BitCount      uint16
+	Compression   uint32
+	SizeImage     uint32
+	XPelsPerMeter int32
+	YPelsPerMeter int32
+	ClrUsed       uint32
+	ClrImportant  uint32
+}
+
+// PixelFormatDescriptor is a struct for ChoosePixelFormat().
+type PixelFormatDescriptor struct {
+	Size           uint16
+	Version        uint16
+	Flags          uint32
+	PixelType      byte
+	ColorBits      byte
+	RedBits        byte
+	RedShift       byte
+	GreenBits      byte
+	GreenShift     byte
+	BlueBits       byte
+	BlueShift      byte
+	AlphaBits      byte
+	AlphaShift     byte
+	AccumBits      byte
+	AccumRedBits   byte
+	AccumGreenBits byte
+	AccumBlueBits  byte
+	AccumAlphaBits byte
+	DepthBits      byte
+	StencilBits    byte
+	AuxBuffers     byte
+	LayerType      byte
+	Reserved       byte
+	LayerMask      uint32
+	VisibleMask    uint32
+	DamageMask     uint32
+}
+
 // Atom is a returned value from RegisterClassEx()
 type Atom uint16
 
@@ -253,12 +613,14 @@ type Msg struct {
 // windows api calls
 
 //sys	GetModuleHandle(modulename *uint16) (module windows.Handle, err error) = GetModuleHandleW
-//sys	CoInitializeEx(reserved uintptr, coInit uint32) (err error) [failretval!=0] = ole32.CoInitializeEx
+//sys	CoInitializeEx(reserved uintptr, coInit uint32) (ret error) = ole32.CoInitializeEx
 //sys	CoUninitialize() = ole32.CoUninitialize
+//sys	CoTaskMemFree(address uintptr) = ole32.CoTaskMemFree
 //sys	MessageBoxEx(window windows.Handle, text *uint16, caption *uint16, style uint32, languageID uint16) (id int32, err error) = user32.MessageBoxExW
 //sys   LoadIcon(instance windows.Handle, iconName *uint16) (icon windows.Handle, err error) [failretval==0] = user32.LoadIconW
 //sys   LoadCursor(instance windows.Handle, cursorName *uint16) (cursor windows.Handle, err error) [failretval==0] = user32.LoadCursorW
 //sys	RegisterClassEx(class *WndClassEx) (atom Atom, err error) = user32.RegisterClassExW
+//sys	UnregisterClass(className *uint16, instance windows.Handle) (err error) [failretval==0] = user32.UnregisterClassW
 //sys	CreateWindowEx(exStyle uint32, classname *uint16, windowname *uint16, style uint32, x int32, y int32, width int32, height int32, parent windows.Handle, menu windows.Handle, instance windows.Handle, lparam uintptr) (window windows.Handle, err error) = user32.CreateWindowExW
 //sys	ShowWindow(window windows.Handle, command int32) (err error) [failretval!=0] = user32.ShowWindow
 //sys	UpdateWindow(window windows.Handle) (err error) = user32.UpdateWindow
@@ -272,3 +634,55 @@ type Msg struct {
 //sys	GetClientRect(window windows.Handle, rect *Rect) (err error) [failretval==0] = user32.GetClientRect
 //sys	ValidateRect(window windows.Handle, rect *Rect) (err error) [failretval==0] = user32.ValidateRect
 //sys	InvalidateRect(window windows.Handle, rect *Rect, erase bool) (err error) [failretval==0] = user32.InvalidateRect
+//sys	GetUpdateRgn(window windows.Handle, region windows.Handle, erase bool) (result int32) = user32.GetUpdateRgn
+//sys	CreateRectRgn(left int32, top int32, right int32, bottom int32) (region windows.Handle, err error) [failretval==0] = gdi32.CreateRectRgn
+//sys	GetRegionData(region windows.Handle, count uint32, data *byte) (result uint32) = gdi32.GetRegionData
+//sys	GetOpenFileName(ofn *OpenFileName) (ok bool) = comdlg32.GetOpenFileNameW
+//sys	GetSaveFileName(ofn *OpenFileName) (ok bool) = comdlg32.GetSaveFileNameW
+//sys	ChooseColor(cc *ChooseColorInfo) (ok bool) = comdlg32.ChooseColorW
+//sys	CommDlgExtendedError() (code uint32) = comdlg32.CommDlgExtendedError
+//sys	SHBrowseForFolder(bi *BrowseInfo) (idl uintptr) = shell32.SHBrowseForFolderW
+//sys	SHGetPathFromIDList(idl uintptr, path *uint16) (ok bool) = shell32.SHGetPathFromIDListW
+//sys	PostMessage(window windows.Handle, message uint32, wParam uintptr, lParam uintptr) (err error) [failretval==0] = user32.PostMessageW
+//sys	CreateMenu() (menu windows.Handle, err error) [failretval==0] = user32.CreateMenu
+//sys	CreatePopupMenu() (menu windows.Handle, err error) [failretval==0] = user32.CreatePopupMenu
+//sys	DestroyMenu(menu windows.Handle) (err error) [failretval==0] = user32.DestroyMenu
+//sys	AppendMenu(menu windows.Handle, flags uint32, idNewItem uintptr, newItem *uint16) (err error) [failretval==0] = user32.AppendMenuW
+//sys	CheckMenuRadioItem(menu windows.Handle, first uint32, last uint32, check uint32, flags uint32) (err error) [failretval==0] = user32.CheckMenuRadioItem
+//sys	SetMenu(window windows.Handle, menu windows.Handle) (err error) [failretval==0] = user32.SetMenu
+//sys	DrawMenuBar(window windows.Handle) (err error) [failretval==0] = user32.DrawMenuBar
+//sys	TrackPopupMenuEx(menu windows.Handle, flags uint32, x int32, y int32, window windows.Handle, params uintptr) (result int32, err error) [failretval==0] = user32.TrackPopupMenuEx
+//sys	GetCursorPos(point *Point) (err error) [failretval==0] = user32.GetCursorPos
+//sys	SetForegroundWindow(window windows.Handle) (ok bool) = user32.SetForegroundWindow
+//sys	GetKeyState(virtKey int32) (state int16) = user32.GetKeyState
+//sys	DestroyWindow(window windows.Handle) (err error) [failretval==0] = user32.DestroyWindow
+//sys	CreateIconIndirect(info *IconCursorInfo) (icon windows.Handle, err error) [failretval==0] = user32.CreateIconIndirect
+//sys	DestroyIcon(icon windows.Handle) (err error) [failretval==0] = user32.DestroyIcon
+//sys	CreateBitmap(width int32, height int32, planes uint32, bitCount uint32, bits unsafe.Pointer) (bitmap windows.Handle, err error) [failretval==0] = gdi32.CreateBitmap
+//sys	DeleteObject(object windows.Handle) (err error) [failretval==0] = gdi32.DeleteObject
+//sys	BeginPaint(window windows.Handle, paint *PaintStruct) (dc windows.Handle, err error) [failretval==0] = user32.BeginPaint
+//sys	EndPaint(window windows.Handle, paint *PaintStruct) (ok bool) = user32.EndPaint
+//sys	CreateCompatibleDC(dc windows.Handle) (memDC windows.Handle, err error) [failretval==0] = gdi32.CreateCompatibleDC
+//sys	DeleteDC(dc windows.Handle) (err error) [failretval==0] = gdi32.DeleteDC
+//sys	CreateDIBSection(dc windows.Handle, info *BitmapInfoHeader, usage uint32, bits *unsafe.Pointer, section windows.Handle, offset uint32) (bitmap windows.Handle, err error) [failretval==0] = gdi32.CreateDIBSection
+//sys	SelectObject(dc windows.Handle, object windows.Handle) (previous windows.Handle) = gdi32.SelectObject
+//sys	BitBlt(dc windows.Handle, x int32, y int32, width int32, height int32, src windows.Handle, srcX int32, srcY int32, rop uint32) (err error) [failretval==0] = gdi32.BitBlt
+//sys	GdiFlush() (ok bool) = gdi32.GdiFlush
+//sys	GetDC(window windows.Handle) (dc windows.Handle, err error) [failretval==0] = user32.GetDC
+//sys	ReleaseDC(window windows.Handle, dc windows.Handle) (released int32) = user32.ReleaseDC
+//sys	ChoosePixelFormat(dc windows.Handle, pfd *PixelFormatDescriptor) (format int32, err error) [failretval==0] = gdi32.ChoosePixelFormat
+//sys	SetPixelFormat(dc windows.Handle, format int32, pfd *PixelFormatDescriptor) (err error) [failretval==0] = gdi32.SetPixelFormat
+//sys	SwapBuffers(dc windows.Handle) (err error) [failretval==0] = gdi32.SwapBuffers
+//sys	WglCreateContext(dc windows.Handle) (context windows.Handle, err error) [failretval==0] = opengl32.wglCreateContext
+//sys	WglDeleteContext(context windows.Handle) (err error) [failretval==0] = opengl32.wglDeleteContext
+//sys	WglMakeCurrent(dc windows.Handle, context windows.Handle) (err error) [failretval==0] = opengl32.wglMakeCurrent
+//sys	WglGetProcAddress(name *byte) (proc uintptr) = opengl32.wglGetProcAddress
+//sys	Shell_NotifyIcon(message uint32, data *NotifyIconData) (err error) [failretval==0] = shell32.Shell_NotifyIconW
+//sys	GetMessageTime() (time int32) = user32.GetMessageTime
+//sys	GetMessagePos() (pos uint32) = user32.GetMessagePos
+//sys	GetTickCount() (ticks uint32) = kernel32.GetTickCount
+//sys	ScreenToClient(window windows.Handle, point *Point) (err error) [failretval==0] = user32.ScreenToClient
+//sys	SetTimer(window windows.Handle, id uintptr, elapse uint32, timerFunc uintptr) (result uintptr, err error) [failretval==0] = user32.SetTimer
+//sys	KillTimer(window windows.Handle, id uintptr) (err error) [failretval==0] = user32.KillTimer
+//sys	MsgWaitForMultipleObjectsEx(count uint32, handles *windows.Handle, milliseconds uint32, wakeMask uint32, flags uint32) (event uint32, err error) [failretval==0xFFFFFFFF] = user32.MsgWaitForMultipleObjectsEx
+//sys	PeekMessage(message *Msg, window windows.Handle, messageFilterMin uint32, messageFilterMax uint32, removeMessage uint32) (ok bool) = user32.PeekMessageW
@@ -0,0 +1,78 @@
+// +build !windows
+
+package gui
+
+import (
+	"image"
+	"time"
+)
+
+func init() {
+	registerBackend(&backend{
+		name:     "headless",
+		priority: 100,
+		caps:     CapMultiWindow | drawnMenuCapabilities | glCapabilities,
+		probe: func() error {
+			return nil
+		},
+		newDriver: func() (driver, error) {
+			return &headlessDriver{}, nil
+		},
+	})
+}
+
+// headlessDriver draws a window into memory without showing it.
+// The window stays open until the loop is stopped.
+type headlessDriver struct {
+	image  *image.RGBA
+	events []interface{}
+	wakec  chan struct{}
+}
+
+func (d *headlessDriver) open(name string, width int32, height int32) error {
+	d.image = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	d.wakec = make(chan struct{}, 1)
+	d.events = append(d.events, sizeEvent{width: width, height: height}, exposeEvent{})
+	return nil
+}
+
+func (d *headlessDriver) close() {
+}
+
+func (d *headlessDriver) handle() uintptr {
+	return 0
+}
+
+func (d *headlessDriver) poll(timeout time.Duration) ([]interface{}, error) {
+	if len(d.events) == 0 {
+		var timer <-chan time.Time
+		if timeout >= 0 {
+			t := time.NewTimer(timeout)
+			defer t.Stop()
+			timer = t.C
+		}
+		select {
+		case <-d.wakec:
+		case <-timer:
+		}
+	}
+
+	events := d.events
+	d.events = nil
+	return events, nil
+}
+
+func (d *headlessDriver) wake() {
+	select {
+	case d.wakec <- struct{}{}:
+	default:
+	}
+}
+
+func (d *headlessDriver) framebuffer() *image.RGBA {
+	return d.image
+}
+
+func (d *headlessDriver) present(region []image.Rectangle) error {
+	return nil
+}
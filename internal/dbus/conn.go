@@ -0,0 +1,420 @@
+package dbus
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned for calls on a closed connection.
+var ErrClosed = errors.New("dbus: connection closed")
+
+// Handler handles a method call of an exported object and returns the reply.
+// A returned *Error is sent with its name, other errors as org.freedesktop.DBus.Error.Failed.
+type Handler func(m *Message) (Signature, []interface{}, error)
+
+// Conn is a connection to a message bus.
+type Conn struct {
+	conn net.Conn
+
+	mu      sync.Mutex
+	serial  uint32
+	pending map[uint32]chan *Message
+	signals []chan<- *Message
+	objects map[ObjectPath]map[string]Handler
+	closed  bool
+	err     error
+
+	name string
+}
+
+// SessionBus connects to the session bus named by DBUS_SESSION_BUS_ADDRESS.
+func SessionBus() (*Conn, error) {
+	addr := os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+	if addr == "" {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			addr = "unix:path=" + dir + "/bus"
+		} else {
+			return nil, errors.New("dbus: DBUS_SESSION_BUS_ADDRESS is not set")
+		}
+	}
+	return Dial(addr)
+}
+
+// Dial connects to the bus at addr, authenticates and registers with the bus.
+func Dial(addr string) (*Conn, error) {
+	var lastErr error
+	for _, a := range strings.Split(addr, ";") {
+		nc, err := dialAddress(a)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c := &Conn{
+			conn:    nc,
+			pending: make(map[uint32]chan *Message),
+			objects: make(map[ObjectPath]map[string]Handler),
+		}
+		if err := c.auth(); err != nil {
+			nc.Close()
+			lastErr = err
+			continue
+		}
+		go c.readLoop()
+
+		reply, err := c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "Hello", "")
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		if len(reply.Body) > 0 {
+			c.name, _ = reply.Body[0].(string)
+		}
+		return c, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("dbus: no usable address in %q", addr)
+	}
+	return nil, lastErr
+}
+
+func dialAddress(addr string) (net.Conn, error) {
+	i := strings.IndexByte(addr, ':')
+	if i < 0 {
+		return nil, fmt.Errorf("dbus: invalid address %q", addr)
+	}
+	transport := addr[:i]
+	params := make(map[string]string)
+	for _, kv := range strings.Split(addr[i+1:], ",") {
+		if j := strings.IndexByte(kv, '='); j >= 0 {
+			params[kv[:j]] = kv[j+1:]
+		}
+	}
+
+	switch transport {
+	case "unix":
+		if p, ok := params["path"]; ok {
+			return net.Dial("unix", p)
+		}
+		if p, ok := params["abstract"]; ok {
+			return net.Dial("unix", "@"+p)
+		}
+	case "tcp":
+		return net.Dial("tcp", net.JoinHostPort(params["host"], params["port"]))
+	}
+	return nil, fmt.Errorf("dbus: unsupported address %q", addr)
+}
+
+func (c *Conn) auth() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Getuid())))
+	if _, err := c.conn.Write([]byte("\x00AUTH EXTERNAL " + uid + "\r\n")); err != nil {
+		return err
+	}
+
+	// read the reply byte by byte not to consume the first message
+	var line []byte
+	b := make([]byte, 1)
+	for !strings.HasSuffix(string(line), "\r\n") {
+		if _, err := c.conn.Read(b); err != nil {
+			return err
+		}
+		line = append(line, b[0])
+	}
+	if !strings.HasPrefix(string(line), "OK ") {
+		return fmt.Errorf("dbus: authentication failed: %s", strings.TrimSpace(string(line)))
+	}
+
+	_, err := c.conn.Write([]byte("BEGIN\r\n"))
+	return err
+}
+
+// Name returns the unique name of the connection.
+func (c *Conn) Name() string {
+	return c.name
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+
+	return c.conn.Close()
+}
+
+// Send sends m with a new serial.
+func (c *Conn) Send(m *Message) error {
+	_, err := c.send(m, false)
+	return err
+}
+
+func (c *Conn) send(m *Message, wantReply bool) (chan *Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	}
+	c.serial++
+	m.Serial = c.serial
+
+	b, err := m.marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	var ch chan *Message
+	if wantReply {
+		ch = make(chan *Message, 1)
+		c.pending[m.Serial] = ch
+	}
+	if _, err := c.conn.Write(b); err != nil {
+		delete(c.pending, m.Serial)
+		return nil, err
+	}
+	return ch, nil
+}
+
+// Call calls a method and waits for the reply.
+func (c *Conn) Call(dest string, path ObjectPath, iface, method string, sig Signature, args ...interface{}) (*Message, error) {
+	ch, err := c.send(&Message{
+		Type:        TypeMethodCall,
+		Path:        path,
+		Interface:   iface,
+		Member:      method,
+		Destination: dest,
+		Signature:   sig,
+		Body:        args,
+	}, true)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, ok := <-ch
+	if !ok {
+		c.mu.Lock()
+		err := c.err
+		c.mu.Unlock()
+		if err == nil {
+			err = ErrClosed
+		}
+		return nil, err
+	}
+	if reply.Type == TypeError {
+		return nil, &Error{Name: reply.ErrorName, Body: reply.Body}
+	}
+	return reply, nil
+}
+
+// AddMatch asks the bus to route messages matching rule to this connection.
+func (c *Conn) AddMatch(rule string) error {
+	_, err := c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "AddMatch", "s", rule)
+	return err
+}
+
+// RemoveMatch undoes AddMatch.
+func (c *Conn) RemoveMatch(rule string) error {
+	_, err := c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RemoveMatch", "s", rule)
+	return err
+}
+
+// Signal registers ch to receive all incoming signals.
+// Sends to ch never block; a full channel drops the signal.
+// The channel is closed when the connection is lost.
+func (c *Conn) Signal(ch chan<- *Message) {
+	c.mu.Lock()
+	c.signals = append(c.signals, ch)
+	c.mu.Unlock()
+}
+
+// RemoveSignal unregisters ch.
+func (c *Conn) RemoveSignal(ch chan<- *Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, s := range c.signals {
+		if s == ch {
+			c.signals = append(c.signals[:i], c.signals[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Conn) readLoop() {
+	r := bufio.NewReader(c.conn)
+	for {
+		m, err := readMessage(r)
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.closed = true
+			for serial, ch := range c.pending {
+				close(ch)
+				delete(c.pending, serial)
+			}
+			for _, ch := range c.signals {
+				close(ch)
+			}
+			c.signals = nil
+			c.mu.Unlock()
+			c.conn.Close()
+			return
+		}
+
+		switch m.Type {
+		case TypeMethodReturn, TypeError:
+			c.mu.Lock()
+			ch, ok := c.pending[m.ReplySerial]
+			delete(c.pending, m.ReplySerial)
+			c.mu.Unlock()
+			if ok {
+				ch <- m
+			}
+		case TypeSignal:
+			c.mu.Lock()
+			for _, ch := range c.signals {
+				select {
+				case ch <- m:
+				default:
+				}
+			}
+			c.mu.Unlock()
+		case TypeMethodCall:
+			c.handleCall(m)
+		}
+	}
+}
+
+// RequestName asks the bus to assign name to this connection.
+func (c *Conn) RequestName(name string, flags uint32) error {
+	reply, err := c.Call("org.freedesktop.DBus", "/org/freedesktop/DBus", "org.freedesktop.DBus", "RequestName", "su", name, flags)
+	if err != nil {
+		return err
+	}
+	// 1: primary owner, 4: already owner
+	if code, _ := reply.Body[0].(uint32); code != 1 && code != 4 {
+		return fmt.Errorf("dbus: name %s is not available (%d)", name, code)
+	}
+	return nil
+}
+
+// Export registers h to handle method calls of iface on path.
+// Handlers run on their own goroutines.
+func (c *Conn) Export(path ObjectPath, iface string, h Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.objects[path] == nil {
+		c.objects[path] = make(map[string]Handler)
+	}
+	c.objects[path][iface] = h
+}
+
+// Unexport removes all handlers of path.
+func (c *Conn) Unexport(path ObjectPath) {
+	c.mu.Lock()
+	delete(c.objects, path)
+	c.mu.Unlock()
+}
+
+// Emit sends a signal.
+func (c *Conn) Emit(path ObjectPath, iface, member string, sig Signature, args ...interface{}) error {
+	return c.Send(&Message{
+		Type:      TypeSignal,
+		Flags:     FlagNoReplyExpected,
+		Path:      path,
+		Interface: iface,
+		Member:    member,
+		Signature: sig,
+		Body:      args,
+	})
+}
+
+func (c *Conn) handleCall(m *Message) {
+	c.mu.Lock()
+	var h Handler
+	if ifaces, ok := c.objects[m.Path]; ok {
+		if m.Interface != "" {
+			h = ifaces[m.Interface]
+		} else {
+			// the interface is optional for method calls
+			for _, ih := range ifaces {
+				h = ih
+				break
+			}
+		}
+	}
+	c.mu.Unlock()
+
+	if h == nil {
+		c.reply(m, "", nil, &Error{
+			Name: "org.freedesktop.DBus.Error.UnknownMethod",
+			Body: []interface{}{fmt.Sprintf("no such method %s.%s", m.Interface, m.Member)},
+		})
+		return
+	}
+
+	go func() {
+		// a panicking handler fails the call instead of the program
+		defer func() {
+			if r := recover(); r != nil {
+				c.reply(m, "", nil, &Error{
+					Name: "org.freedesktop.DBus.Error.Failed",
+					Body: []interface{}{fmt.Sprintf("panic in %s.%s: %v", m.Interface, m.Member, r)},
+				})
+			}
+		}()
+		sig, body, err := h(m)
+		c.reply(m, sig, body, err)
+	}()
+}
+
+func (c *Conn) reply(m *Message, sig Signature, body []interface{}, err error) {
+	if m.Flags&FlagNoReplyExpected != 0 {
+		return
+	}
+
+	if err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			e = &Error{Name: "org.freedesktop.DBus.Error.Failed", Body: []interface{}{err.Error()}}
+		}
+		var text []interface{}
+		if len(e.Body) > 0 {
+			if s, ok := e.Body[0].(string); ok {
+				text = []interface{}{s}
+			}
+		}
+		msg := &Message{
+			Type:        TypeError,
+			ErrorName:   e.Name,
+			ReplySerial: m.Serial,
+			Destination: m.Sender,
+			Body:        text,
+		}
+		if len(text) > 0 {
+			msg.Signature = "s"
+		}
+		c.Send(msg)
+		return
+	}
+
+	c.Send(&Message{
+		Type:        TypeMethodReturn,
+		ReplySerial: m.Serial,
+		Destination: m.Sender,
+		Signature:   sig,
+		Body:        body,
+	})
+}
@@ -0,0 +1,85 @@
+package dbus
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// startBus starts a private session bus, or skips the test without dbus-daemon.
+func startBus(t *testing.T) string {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address=1")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("dbus-daemon: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Skipf("dbus-daemon: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+func dial(t *testing.T, addr string) *Conn {
+	c, err := Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCall(t *testing.T) {
+	addr := startBus(t)
+	server, client := dial(t, addr), dial(t, addr)
+
+	server.Export("/test", "test.Iface", func(m *Message) (Signature, []interface{}, error) {
+		switch m.Member {
+		case "Echo":
+			return m.Signature, m.Body, nil
+		case "Panic":
+			var body []interface{}
+			_ = body[1]
+		}
+		return "", nil, &Error{Name: "test.Error", Body: []interface{}{"no " + m.Member}}
+	})
+
+	reply, err := client.Call(server.Name(), "/test", "test.Iface", "Echo", "sa{sv}", "hello", map[string]Variant{"n": MakeVariant(int32(1))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := reply.Body[0].(string); s != "hello" {
+		t.Errorf("Echo = %v", reply.Body)
+	}
+
+	_, err = client.Call(server.Name(), "/test", "test.Iface", "Nope", "")
+	if e, ok := err.(*Error); !ok || e.Name != "test.Error" {
+		t.Errorf("Nope: %v, want test.Error", err)
+	}
+
+	// the panic is an error reply and the connection keeps working
+	_, err = client.Call(server.Name(), "/test", "test.Iface", "Panic", "")
+	if e, ok := err.(*Error); !ok || e.Name != "org.freedesktop.DBus.Error.Failed" {
+		t.Errorf("Panic: %v, want Failed", err)
+	}
+	if _, err := client.Call(server.Name(), "/test", "test.Iface", "Echo", ""); err != nil {
+		t.Errorf("Echo after the panic: %v", err)
+	}
+
+	_, err = client.Call(server.Name(), "/none", "test.Iface", "Echo", "")
+	if e, ok := err.(*Error); !ok || e.Name != "org.freedesktop.DBus.Error.UnknownMethod" {
+		t.Errorf("unknown path: %v, want UnknownMethod", err)
+	}
+}
@@ -0,0 +1,700 @@
+// Package dbus is a minimal D-Bus client used by the non-Windows backends.
+package dbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// Message types
+const (
+	TypeMethodCall   = 1
+	TypeMethodReturn = 2
+	TypeError        = 3
+	TypeSignal       = 4
+)
+
+// Message flags
+const (
+	FlagNoReplyExpected = 0x1
+	FlagNoAutoStart     = 0x2
+)
+
+// Header fields
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+)
+
+// maxMessageSize is the limit of a message defined by the specification.
+const maxMessageSize = 128 * 1024 * 1024
+
+// ObjectPath is a D-Bus object path.
+type ObjectPath string
+
+// Signature is a D-Bus type signature.
+type Signature string
+
+// Variant is a value with its own signature.
+type Variant struct {
+	Sig   Signature
+	Value interface{}
+}
+
+// MakeVariant creates a variant with the signature guessed from the Go type of v.
+func MakeVariant(v interface{}) Variant {
+	sig, err := signatureOf(reflect.TypeOf(v))
+	if err != nil {
+		panic(err)
+	}
+	return Variant{Sig: Signature(sig), Value: v}
+}
+
+// Message is a D-Bus message.
+type Message struct {
+	Type   byte
+	Flags  byte
+	Serial uint32
+
+	Path        ObjectPath
+	Interface   string
+	Member      string
+	ErrorName   string
+	ReplySerial uint32
+	Destination string
+	Sender      string
+	Signature   Signature
+
+	Body []interface{}
+}
+
+// Error is an error reply.
+type Error struct {
+	Name string
+	Body []interface{}
+}
+
+func (e *Error) Error() string {
+	if len(e.Body) > 0 {
+		if s, ok := e.Body[0].(string); ok {
+			return fmt.Sprintf("dbus: %s: %s", e.Name, s)
+		}
+	}
+	return "dbus: " + e.Name
+}
+
+func (m *Message) marshal() ([]byte, error) {
+	var body encoder
+	sig := string(m.Signature)
+	rest := sig
+	for _, v := range m.Body {
+		if rest == "" {
+			return nil, fmt.Errorf("dbus: too many values for signature %q", sig)
+		}
+		t, r, err := nextType(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = r
+		if err := body.encode(t, v); err != nil {
+			return nil, err
+		}
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("dbus: too few values for signature %q", sig)
+	}
+
+	var fields []interface{}
+	addField := func(code byte, sig string, v interface{}) {
+		fields = append(fields, []interface{}{code, Variant{Signature(sig), v}})
+	}
+	if m.Path != "" {
+		addField(fieldPath, "o", m.Path)
+	}
+	if m.Interface != "" {
+		addField(fieldInterface, "s", m.Interface)
+	}
+	if m.Member != "" {
+		addField(fieldMember, "s", m.Member)
+	}
+	if m.ErrorName != "" {
+		addField(fieldErrorName, "s", m.ErrorName)
+	}
+	if m.ReplySerial != 0 {
+		addField(fieldReplySerial, "u", m.ReplySerial)
+	}
+	if m.Destination != "" {
+		addField(fieldDestination, "s", m.Destination)
+	}
+	if m.Signature != "" {
+		addField(fieldSignature, "g", m.Signature)
+	}
+
+	var hdr encoder
+	hdr.buf = append(hdr.buf, 'l', m.Type, m.Flags, 1)
+	hdr.encode("u", uint32(len(body.buf)))
+	hdr.encode("u", m.Serial)
+	if err := hdr.encode("a(yv)", fields); err != nil {
+		return nil, err
+	}
+	hdr.align(8)
+
+	return append(hdr.buf, body.buf...), nil
+}
+
+func readMessage(r io.Reader) (*Message, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+	switch fixed[0] {
+	case 'l':
+		order = binary.LittleEndian
+	case 'B':
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("dbus: invalid endianness %q", fixed[0])
+	}
+	bodyLen := order.Uint32(fixed[4:])
+	fieldsLen := order.Uint32(fixed[12:])
+	hdrLen := 16 + int(fieldsLen)
+	hdrLen += (8 - hdrLen%8) % 8
+	if hdrLen+int(bodyLen) > maxMessageSize {
+		return nil, errors.New("dbus: message too large")
+	}
+
+	buf := make([]byte, hdrLen+int(bodyLen))
+	copy(buf, fixed)
+	if _, err := io.ReadFull(r, buf[16:]); err != nil {
+		return nil, err
+	}
+
+	m := &Message{
+		Type:   fixed[1],
+		Flags:  fixed[2],
+		Serial: order.Uint32(fixed[8:]),
+	}
+
+	d := &decoder{buf: buf[:hdrLen], order: order, pos: 12}
+	v, err := d.decode("a(yv)")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range v.([]interface{}) {
+		field := f.([]interface{})
+		value := field[1].(Variant).Value
+		switch field[0].(byte) {
+		case fieldPath:
+			m.Path, _ = value.(ObjectPath)
+		case fieldInterface:
+			m.Interface, _ = value.(string)
+		case fieldMember:
+			m.Member, _ = value.(string)
+		case fieldErrorName:
+			m.ErrorName, _ = value.(string)
+		case fieldReplySerial:
+			m.ReplySerial, _ = value.(uint32)
+		case fieldDestination:
+			m.Destination, _ = value.(string)
+		case fieldSender:
+			m.Sender, _ = value.(string)
+		case fieldSignature:
+			m.Signature, _ = value.(Signature)
+		}
+	}
+
+	d = &decoder{buf: buf[hdrLen:], order: order}
+	rest := string(m.Signature)
+	for rest != "" {
+		t, r, err := nextType(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = r
+		v, err := d.decode(t)
+		if err != nil {
+			return nil, err
+		}
+		m.Body = append(m.Body, v)
+	}
+
+	return m, nil
+}
+
+// nextType splits the first complete type off sig.
+func nextType(sig string) (string, string, error) {
+	if sig == "" {
+		return "", "", errors.New("dbus: empty signature")
+	}
+	switch sig[0] {
+	case 'a':
+		t, _, err := nextType(sig[1:])
+		if err != nil {
+			return "", "", err
+		}
+		return sig[:1+len(t)], sig[1+len(t):], nil
+	case '(', '{':
+		end := byte(')')
+		if sig[0] == '{' {
+			end = '}'
+		}
+		i := 1
+		for i < len(sig) && sig[i] != end {
+			t, _, err := nextType(sig[i:])
+			if err != nil {
+				return "", "", err
+			}
+			i += len(t)
+		}
+		if i >= len(sig) {
+			return "", "", fmt.Errorf("dbus: unterminated signature %q", sig)
+		}
+		return sig[:i+1], sig[i+1:], nil
+	case 'y', 'b', 'n', 'q', 'i', 'u', 'x', 't', 'd', 's', 'o', 'g', 'v', 'h':
+		return sig[:1], sig[1:], nil
+	}
+	return "", "", fmt.Errorf("dbus: invalid signature %q", sig)
+}
+
+func alignment(t byte) int {
+	switch t {
+	case 'y', 'g', 'v':
+		return 1
+	case 'n', 'q':
+		return 2
+	case 'x', 't', 'd', '(', '{':
+		return 8
+	}
+	return 4
+}
+
+func signatureOf(t reflect.Type) (string, error) {
+	if t == nil {
+		return "", errors.New("dbus: nil value")
+	}
+	switch t {
+	case reflect.TypeOf(ObjectPath("")):
+		return "o", nil
+	case reflect.TypeOf(Signature("")):
+		return "g", nil
+	case reflect.TypeOf(Variant{}):
+		return "v", nil
+	}
+	switch t.Kind() {
+	case reflect.Uint8:
+		return "y", nil
+	case reflect.Bool:
+		return "b", nil
+	case reflect.Int16:
+		return "n", nil
+	case reflect.Uint16:
+		return "q", nil
+	case reflect.Int32, reflect.Int:
+		return "i", nil
+	case reflect.Uint32:
+		return "u", nil
+	case reflect.Int64:
+		return "x", nil
+	case reflect.Uint64:
+		return "t", nil
+	case reflect.Float64:
+		return "d", nil
+	case reflect.String:
+		return "s", nil
+	case reflect.Slice:
+		e, err := signatureOf(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "a" + e, nil
+	case reflect.Map:
+		k, err := signatureOf(t.Key())
+		if err != nil {
+			return "", err
+		}
+		v, err := signatureOf(t.Elem())
+		if err != nil {
+			return "", err
+		}
+		return "a{" + k + v + "}", nil
+	}
+	return "", fmt.Errorf("dbus: unsupported type %v", t)
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.buf)%n != 0 {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.buf = append(e.buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.align(8)
+	for i := uint(0); i < 8; i++ {
+		e.buf = append(e.buf, byte(v>>(8*i)))
+	}
+}
+
+// encode appends v as the single complete type sig.
+func (e *encoder) encode(sig string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return fmt.Errorf("dbus: nil value for %q", sig)
+	}
+
+	switch sig[0] {
+	case 'y':
+		if rv.Kind() != reflect.Uint8 {
+			return fmt.Errorf("dbus: %T is not a byte", v)
+		}
+		e.buf = append(e.buf, byte(rv.Uint()))
+	case 'b':
+		if rv.Kind() != reflect.Bool {
+			return fmt.Errorf("dbus: %T is not a bool", v)
+		}
+		if rv.Bool() {
+			e.uint32(1)
+		} else {
+			e.uint32(0)
+		}
+	case 'n', 'q':
+		e.align(2)
+		var u uint16
+		switch rv.Kind() {
+		case reflect.Int16:
+			u = uint16(rv.Int())
+		case reflect.Uint16:
+			u = uint16(rv.Uint())
+		default:
+			return fmt.Errorf("dbus: %T is not a 16-bit integer", v)
+		}
+		e.buf = append(e.buf, byte(u), byte(u>>8))
+	case 'i', 'u', 'h':
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int32:
+			e.uint32(uint32(rv.Int()))
+		case reflect.Uint32:
+			e.uint32(uint32(rv.Uint()))
+		default:
+			return fmt.Errorf("dbus: %T is not a 32-bit integer", v)
+		}
+	case 'x', 't':
+		switch rv.Kind() {
+		case reflect.Int64:
+			e.uint64(uint64(rv.Int()))
+		case reflect.Uint64:
+			e.uint64(rv.Uint())
+		default:
+			return fmt.Errorf("dbus: %T is not a 64-bit integer", v)
+		}
+	case 'd':
+		if rv.Kind() != reflect.Float64 {
+			return fmt.Errorf("dbus: %T is not a float64", v)
+		}
+		e.uint64(math.Float64bits(rv.Float()))
+	case 's', 'o':
+		if rv.Kind() != reflect.String {
+			return fmt.Errorf("dbus: %T is not a string", v)
+		}
+		s := rv.String()
+		e.uint32(uint32(len(s)))
+		e.buf = append(e.buf, s...)
+		e.buf = append(e.buf, 0)
+	case 'g':
+		if rv.Kind() != reflect.String {
+			return fmt.Errorf("dbus: %T is not a signature", v)
+		}
+		s := rv.String()
+		e.buf = append(e.buf, byte(len(s)))
+		e.buf = append(e.buf, s...)
+		e.buf = append(e.buf, 0)
+	case 'v':
+		variant, ok := v.(Variant)
+		if !ok {
+			variant = MakeVariant(v)
+		}
+		e.encode("g", variant.Sig)
+		return e.encode(string(variant.Sig), variant.Value)
+	case '(':
+		fields, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("dbus: %T is not a struct", v)
+		}
+		e.align(8)
+		rest := sig[1 : len(sig)-1]
+		for _, f := range fields {
+			if rest == "" {
+				return fmt.Errorf("dbus: too many fields for %q", sig)
+			}
+			t, r, err := nextType(rest)
+			if err != nil {
+				return err
+			}
+			rest = r
+			if err := e.encode(t, f); err != nil {
+				return err
+			}
+		}
+		if rest != "" {
+			return fmt.Errorf("dbus: too few fields for %q", sig)
+		}
+	case 'a':
+		elem := sig[1:]
+		e.uint32(0)
+		lenPos := len(e.buf) - 4
+		e.align(alignment(elem[0]))
+		start := len(e.buf)
+
+		if elem[0] == '{' {
+			if rv.Kind() != reflect.Map {
+				return fmt.Errorf("dbus: %T is not a map", v)
+			}
+			kt, r, err := nextType(elem[1 : len(elem)-1])
+			if err != nil {
+				return err
+			}
+			vt, _, err := nextType(r)
+			if err != nil {
+				return err
+			}
+			keys := rv.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+			})
+			for _, k := range keys {
+				e.align(8)
+				if err := e.encode(kt, k.Interface()); err != nil {
+					return err
+				}
+				if err := e.encode(vt, rv.MapIndex(k).Interface()); err != nil {
+					return err
+				}
+			}
+		} else {
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				return fmt.Errorf("dbus: %T is not a slice", v)
+			}
+			for i := 0; i < rv.Len(); i++ {
+				if err := e.encode(elem, rv.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+		}
+
+		n := uint32(len(e.buf) - start)
+		e.buf[lenPos] = byte(n)
+		e.buf[lenPos+1] = byte(n >> 8)
+		e.buf[lenPos+2] = byte(n >> 16)
+		e.buf[lenPos+3] = byte(n >> 24)
+	default:
+		return fmt.Errorf("dbus: unsupported signature %q", sig)
+	}
+
+	return nil
+}
+
+type decoder struct {
+	buf   []byte
+	order binary.ByteOrder
+	pos   int
+}
+
+var errShort = errors.New("dbus: message truncated")
+
+func (d *decoder) align(n int) error {
+	for d.pos%n != 0 {
+		d.pos++
+	}
+	if d.pos > len(d.buf) {
+		return errShort
+	}
+	return nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if d.pos+n > len(d.buf) {
+		return nil, errShort
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	b, err := d.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return d.order.Uint32(b), nil
+}
+
+// decode reads a single complete type sig.
+//
+// Arrays of bytes decode to []byte, dicts to map[interface{}]interface{},
+// other arrays and structs to []interface{}.
+func (d *decoder) decode(sig string) (interface{}, error) {
+	switch sig[0] {
+	case 'y':
+		b, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0], nil
+	case 'b':
+		u, err := d.uint32()
+		return u != 0, err
+	case 'n', 'q':
+		if err := d.align(2); err != nil {
+			return nil, err
+		}
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'n' {
+			return int16(d.order.Uint16(b)), nil
+		}
+		return d.order.Uint16(b), nil
+	case 'i', 'h':
+		u, err := d.uint32()
+		return int32(u), err
+	case 'u':
+		return d.uint32()
+	case 'x', 't', 'd':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		u := d.order.Uint64(b)
+		switch sig[0] {
+		case 'x':
+			return int64(u), nil
+		case 'd':
+			return math.Float64frombits(u), nil
+		}
+		return u, nil
+	case 's', 'o':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n) + 1)
+		if err != nil {
+			return nil, err
+		}
+		if sig[0] == 'o' {
+			return ObjectPath(b[:n]), nil
+		}
+		return string(b[:n]), nil
+	case 'g':
+		n, err := d.next(1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n[0]) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Signature(b[:n[0]]), nil
+	case 'v':
+		s, err := d.decode("g")
+		if err != nil {
+			return nil, err
+		}
+		vsig := string(s.(Signature))
+		if t, rest, err := nextType(vsig); err != nil || t == "" || rest != "" {
+			return nil, fmt.Errorf("dbus: invalid variant signature %q", vsig)
+		}
+		v, err := d.decode(vsig)
+		if err != nil {
+			return nil, err
+		}
+		return Variant{Sig: Signature(vsig), Value: v}, nil
+	case '(', '{':
+		if err := d.align(8); err != nil {
+			return nil, err
+		}
+		var fields []interface{}
+		rest := sig[1 : len(sig)-1]
+		for rest != "" {
+			t, r, err := nextType(rest)
+			if err != nil {
+				return nil, err
+			}
+			rest = r
+			v, err := d.decode(t)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, v)
+		}
+		return fields, nil
+	case 'a':
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		elem := sig[1:]
+		if err := d.align(alignment(elem[0])); err != nil {
+			return nil, err
+		}
+		end := d.pos + int(n)
+		if end > len(d.buf) {
+			return nil, errShort
+		}
+		if elem == "y" {
+			b, _ := d.next(int(n))
+			return bytes.Repeat(b, 1), nil
+		}
+		if elem[0] == '{' {
+			m := make(map[interface{}]interface{})
+			for d.pos < end {
+				v, err := d.decode(elem)
+				if err != nil {
+					return nil, err
+				}
+				kv := v.([]interface{})
+				m[kv[0]] = kv[1]
+			}
+			return m, nil
+		}
+		var items []interface{}
+		for d.pos < end {
+			v, err := d.decode(elem)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("dbus: unsupported signature %q", sig)
+}
@@ -0,0 +1,31 @@
+// +build linux,cgo
+
+// Package gltest calls GL functions for the tests, which cannot use cgo.
+package gltest
+
+/*
+#include <stdint.h>
+
+typedef void (*clearColorFunc)(float, float, float, float);
+typedef void (*clearFunc)(unsigned int);
+
+static void clearWith(uintptr_t clearColor, uintptr_t clear, float r, float g, float b, float a) {
+	((clearColorFunc)clearColor)(r, g, b, a);
+	// GL_COLOR_BUFFER_BIT
+	((clearFunc)clear)(0x4000);
+}
+*/
+import "C"
+
+import "errors"
+
+// Clear clears the color buffer of the current context with the color,
+// looking up the functions with proc.
+func Clear(proc func(name string) uintptr, r, g, b, a float32) error {
+	clearColor, clear := proc("glClearColor"), proc("glClear")
+	if clearColor == 0 || clear == 0 {
+		return errors.New("gltest: no glClearColor or glClear")
+	}
+	C.clearWith(C.uintptr_t(clearColor), C.uintptr_t(clear), C.float(r), C.float(g), C.float(b), C.float(a))
+	return nil
+}
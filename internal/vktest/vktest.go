@@ -0,0 +1,93 @@
+// +build linux,cgo
+
+// Package vktest creates Vulkan instances for the tests, which cannot use cgo.
+package vktest
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stddef.h>
+#include <stdlib.h>
+
+typedef void *(*getInstanceProcAddrFunc)(uintptr_t instance, const char *name);
+
+typedef struct {
+	int32_t sType;
+	const void *pNext;
+	uint32_t flags;
+	const void *pApplicationInfo;
+	uint32_t enabledLayerCount;
+	const char *const *ppEnabledLayerNames;
+	uint32_t enabledExtensionCount;
+	const char *const *ppEnabledExtensionNames;
+} instanceCreateInfo;
+
+typedef int32_t (*createInstanceFunc)(const instanceCreateInfo *info, uintptr_t allocator, uintptr_t *instance);
+typedef void (*destroySurfaceFunc)(uintptr_t instance, uint64_t surface, uintptr_t allocator);
+typedef void (*destroyInstanceFunc)(uintptr_t instance, uintptr_t allocator);
+
+// VK_STRUCTURE_TYPE_INSTANCE_CREATE_INFO
+#define INSTANCE_CREATE_INFO 1
+// VK_ERROR_EXTENSION_NOT_PRESENT
+#define ERROR_EXTENSION_NOT_PRESENT -7
+
+static getInstanceProcAddrFunc getProc;
+
+static int load(void) {
+	if (!getProc) {
+		void *vulkan = dlopen("libvulkan.so.1", RTLD_NOW | RTLD_LOCAL);
+		if (vulkan) {
+			getProc = (getInstanceProcAddrFunc)dlsym(vulkan, "vkGetInstanceProcAddr");
+		}
+	}
+	return getProc != NULL;
+}
+
+static int32_t createInstance(const char **exts, uint32_t n, uintptr_t *instance) {
+	createInstanceFunc create = (createInstanceFunc)getProc(0, "vkCreateInstance");
+	if (!create) {
+		return ERROR_EXTENSION_NOT_PRESENT;
+	}
+	instanceCreateInfo info = {INSTANCE_CREATE_INFO, NULL, 0, NULL, 0, NULL, n, exts};
+	return create(&info, 0, instance);
+}
+
+static void destroyInstance(uintptr_t instance, uint64_t surface) {
+	if (surface) {
+		((destroySurfaceFunc)getProc(instance, "vkDestroySurfaceKHR"))(instance, surface, 0);
+	}
+	((destroyInstanceFunc)getProc(instance, "vkDestroyInstance"))(instance, 0);
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Instance creates a VkInstance with the extensions exts. destroy destroys
+// surface, if not 0, and the instance.
+func Instance(exts []string) (instance uintptr, destroy func(surface uint64), err error) {
+	if C.load() == 0 {
+		return 0, nil, errors.New("vktest: no libvulkan.so.1")
+	}
+	names := make([]*C.char, len(exts)+1)
+	for i, e := range exts {
+		names[i] = C.CString(e)
+		defer C.free(unsafe.Pointer(names[i]))
+	}
+	cnames := (**C.char)(C.malloc(C.size_t(len(names)) * C.size_t(unsafe.Sizeof(names[0]))))
+	defer C.free(unsafe.Pointer(cnames))
+	copy((*[1 << 10]*C.char)(unsafe.Pointer(cnames))[:len(names):len(names)], names)
+
+	var inst C.uintptr_t
+	if r := C.createInstance(cnames, C.uint32_t(len(exts)), &inst); r != 0 {
+		return 0, nil, fmt.Errorf("vkCreateInstance: VkResult %d", int32(r))
+	}
+	return uintptr(inst), func(surface uint64) {
+		C.destroyInstance(inst, C.uint64_t(surface))
+	}, nil
+}
@@ -0,0 +1,400 @@
+// +build linux
+
+// Package wayland implements the client side of the Wayland wire protocol.
+//
+// It knows nothing about individual interfaces: requests are sent by opcode
+// and events are delivered to the handler of their object.
+package wayland
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrClosed is returned when the compositor has closed the connection.
+var ErrClosed = errors.New("wayland: connection closed")
+
+// DisplayID is the object ID of wl_display.
+const DisplayID = 1
+
+// wl_display opcodes
+const (
+	displaySync        = 0
+	displayGetRegistry = 1
+
+	displayError    = 0
+	displayDeleteID = 1
+)
+
+// maxFds is the maximum number of file descriptors in one message.
+const maxFds = 28
+
+// Handler handles an event of an object.
+type Handler func(opcode uint16, e *Event)
+
+// Fixed is a 24.8 signed fixed point number.
+type Fixed int32
+
+// FixedFromFloat converts f to Fixed.
+func FixedFromFloat(f float64) Fixed {
+	return Fixed(math.Round(f * 256))
+}
+
+// Float converts f to float64.
+func (f Fixed) Float() float64 {
+	return float64(f) / 256
+}
+
+// Fd is a file descriptor argument. It is sent out of band.
+type Fd int
+
+// Error is a protocol error reported by the compositor.
+type Error struct {
+	Object  uint32
+	Code    uint32
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("wayland: error %d on object %d: %s", e.Code, e.Object, e.Message)
+}
+
+// Conn is a connection to a Wayland compositor.
+// It is not safe for concurrent use.
+type Conn struct {
+	fd int
+
+	handlers map[uint32]Handler
+	nextID   uint32
+	free     []uint32
+
+	rbuf []byte
+	fds  []int
+	err  error
+}
+
+// Dial connects to the compositor named by $WAYLAND_SOCKET or $WAYLAND_DISPLAY.
+func Dial() (*Conn, error) {
+	if s := os.Getenv("WAYLAND_SOCKET"); s != "" {
+		fd, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("wayland: WAYLAND_SOCKET %q: %v", s, err)
+		}
+		os.Unsetenv("WAYLAND_SOCKET")
+		unix.CloseOnExec(fd)
+		return newConn(fd), nil
+	}
+
+	name := os.Getenv("WAYLAND_DISPLAY")
+	if name == "" {
+		name = "wayland-0"
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		dir := os.Getenv("XDG_RUNTIME_DIR")
+		if dir == "" {
+			return nil, errors.New("wayland: XDG_RUNTIME_DIR is not set")
+		}
+		path = filepath.Join(dir, name)
+	}
+
+	fd, err := unix.Socket(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("wayland: socket: %v", err)
+	}
+	if err := unix.Connect(fd, &unix.SockaddrUnix{Name: path}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("wayland: connect %s: %v", path, err)
+	}
+	return newConn(fd), nil
+}
+
+func newConn(fd int) *Conn {
+	c := &Conn{
+		fd:       fd,
+		handlers: make(map[uint32]Handler),
+		nextID:   DisplayID + 1,
+	}
+	c.handlers[DisplayID] = c.handleDisplay
+	return c
+}
+
+// Fd returns the socket, which becomes readable when events arrive.
+func (c *Conn) Fd() int {
+	return c.fd
+}
+
+// Close closes the connection and the file descriptors received but not taken.
+func (c *Conn) Close() error {
+	for _, fd := range c.fds {
+		unix.Close(fd)
+	}
+	c.fds = nil
+	return unix.Close(c.fd)
+}
+
+// NewID allocates an object ID for a new_id argument and sets its handler.
+func (c *Conn) NewID(h Handler) uint32 {
+	var id uint32
+	if n := len(c.free); n > 0 {
+		id = c.free[n-1]
+		c.free = c.free[:n-1]
+	} else {
+		id = c.nextID
+		c.nextID++
+	}
+	if h != nil {
+		c.handlers[id] = h
+	}
+	return id
+}
+
+// SetHandler replaces the handler of the object id.
+func (c *Conn) SetHandler(id uint32, h Handler) {
+	c.handlers[id] = h
+}
+
+// GetRegistry creates a wl_registry.
+func (c *Conn) GetRegistry(h Handler) (uint32, error) {
+	id := c.NewID(h)
+	return id, c.Request(DisplayID, displayGetRegistry, id)
+}
+
+// Request sends a request to the object id.
+//
+// Arguments are encoded by type: uint32 (uint, object, new_id), int32,
+// Fixed, string, []byte (array) and Fd.
+func (c *Conn) Request(id uint32, opcode uint16, args ...interface{}) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	msg := make([]byte, 8, 64)
+	var fds []int
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint32:
+			msg = appendUint32(msg, v)
+		case int32:
+			msg = appendUint32(msg, uint32(v))
+		case Fixed:
+			msg = appendUint32(msg, uint32(v))
+		case string:
+			msg = appendUint32(msg, uint32(len(v)+1))
+			msg = append(msg, v...)
+			msg = append(msg, 0)
+			msg = pad(msg)
+		case []byte:
+			msg = appendUint32(msg, uint32(len(v)))
+			msg = append(msg, v...)
+			msg = pad(msg)
+		case Fd:
+			fds = append(fds, int(v))
+		default:
+			return fmt.Errorf("wayland: unsupported argument type %T", arg)
+		}
+	}
+	if len(msg) > 0xffff {
+		return fmt.Errorf("wayland: request of %d bytes is too large", len(msg))
+	}
+	binary.LittleEndian.PutUint32(msg[0:], id)
+	binary.LittleEndian.PutUint32(msg[4:], uint32(len(msg))<<16|uint32(opcode))
+
+	var oob []byte
+	if len(fds) > 0 {
+		oob = unix.UnixRights(fds...)
+	}
+	for {
+		err := unix.Sendmsg(c.fd, msg, oob, nil, unix.MSG_NOSIGNAL)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			c.err = fmt.Errorf("wayland: sendmsg: %v", err)
+			return c.err
+		}
+		return nil
+	}
+}
+
+// Dispatch reads the events available on the socket and calls their handlers.
+// If block is false and no event is available, it returns immediately.
+func (c *Conn) Dispatch(block bool) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	buf := make([]byte, 4096)
+	oob := make([]byte, unix.CmsgSpace(maxFds*4))
+	flags := unix.MSG_CMSG_CLOEXEC
+	if !block {
+		flags |= unix.MSG_DONTWAIT
+	}
+	n, oobn, _, _, err := unix.Recvmsg(c.fd, buf, oob, flags)
+	if err == unix.EAGAIN || err == unix.EINTR {
+		return nil
+	}
+	if err != nil {
+		c.err = fmt.Errorf("wayland: recvmsg: %v", err)
+		return c.err
+	}
+	if n == 0 {
+		c.err = ErrClosed
+		return c.err
+	}
+	if oobn > 0 {
+		msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+		if err == nil {
+			for i := range msgs {
+				fds, err := unix.ParseUnixRights(&msgs[i])
+				if err == nil {
+					c.fds = append(c.fds, fds...)
+				}
+			}
+		}
+	}
+	c.rbuf = append(c.rbuf, buf[:n]...)
+
+	for len(c.rbuf) >= 8 {
+		id := binary.LittleEndian.Uint32(c.rbuf[0:])
+		word := binary.LittleEndian.Uint32(c.rbuf[4:])
+		size := int(word >> 16)
+		if size < 8 {
+			c.err = fmt.Errorf("wayland: invalid message size %d", size)
+			return c.err
+		}
+		if len(c.rbuf) < size {
+			break
+		}
+		e := &Event{conn: c, id: id, data: c.rbuf[8:size]}
+		c.rbuf = c.rbuf[size:]
+		if h := c.handlers[id]; h != nil {
+			h(uint16(word), e)
+		}
+		if c.err != nil {
+			return c.err
+		}
+	}
+	if len(c.rbuf) == 0 {
+		c.rbuf = nil
+	}
+	return nil
+}
+
+// Roundtrip blocks until the compositor has processed all requests sent so far
+// and their events have been dispatched.
+func (c *Conn) Roundtrip() error {
+	done := false
+	id := c.NewID(func(opcode uint16, e *Event) {
+		done = true
+	})
+	if err := c.Request(DisplayID, displaySync, id); err != nil {
+		return err
+	}
+	for !done {
+		if err := c.Dispatch(true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Conn) handleDisplay(opcode uint16, e *Event) {
+	switch opcode {
+	case displayError:
+		c.err = &Error{Object: e.Uint(), Code: e.Uint(), Message: e.String()}
+	case displayDeleteID:
+		id := e.Uint()
+		delete(c.handlers, id)
+		c.free = append(c.free, id)
+	}
+}
+
+// Event is the payload of an event. Arguments are read in order.
+type Event struct {
+	conn *Conn
+	id   uint32
+	data []byte
+}
+
+// Object returns the ID of the object which sent the event.
+func (e *Event) Object() uint32 {
+	return e.id
+}
+
+// Data returns the arguments not read yet without reading them.
+// The slice is valid only during the handler.
+func (e *Event) Data() []byte {
+	return e.data
+}
+
+// Uint reads a uint, object or new_id argument.
+func (e *Event) Uint() uint32 {
+	if len(e.data) < 4 {
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(e.data)
+	e.data = e.data[4:]
+	return v
+}
+
+// Int reads an int argument.
+func (e *Event) Int() int32 {
+	return int32(e.Uint())
+}
+
+// Fixed reads a fixed argument.
+func (e *Event) Fixed() Fixed {
+	return Fixed(e.Uint())
+}
+
+// String reads a string argument.
+func (e *Event) String() string {
+	b := e.Array()
+	if n := len(b); n > 0 && b[n-1] == 0 {
+		b = b[:n-1]
+	}
+	return string(b)
+}
+
+// Array reads an array argument. The slice is valid only during the handler.
+func (e *Event) Array() []byte {
+	n := int(e.Uint())
+	padded := (n + 3) &^ 3
+	if n > len(e.data) || padded > len(e.data) {
+		e.data = nil
+		return nil
+	}
+	b := e.data[:n]
+	e.data = e.data[padded:]
+	return b
+}
+
+// Fd takes a received file descriptor argument. The caller must close it.
+func (e *Event) Fd() int {
+	c := e.conn
+	if len(c.fds) == 0 {
+		return -1
+	}
+	fd := c.fds[0]
+	c.fds = c.fds[1:]
+	return fd
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
@@ -0,0 +1,252 @@
+// Package websocket implements the server side of the WebSocket protocol (RFC 6455).
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Message types
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+const continuationFrame = 0
+
+// maxMessageSize limits messages from clients.
+const maxMessageSize = 1 << 20
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrClosed is returned after a close message has been received.
+var ErrClosed = errors.New("websocket: connection closed")
+
+// Conn is a WebSocket connection.
+// ReadMessage must be called from one goroutine; WriteMessage may be called concurrently.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	wmu sync.Mutex
+}
+
+// Upgrade switches an HTTP request to the WebSocket protocol.
+// An error response has been written if it fails.
+//
+// Browsers send the Origin of the page opening the connection, which is
+// rejected with 403 Forbidden unless its host is the host of the request or
+// it is one of origins, e.g. "https://example.com". Requests without Origin
+// are not from browsers and are accepted.
+func Upgrade(w http.ResponseWriter, r *http.Request, origins []string) (*Conn, error) {
+	if !checkOrigin(r, origins) {
+		http.Error(w, "websocket: origin not allowed", http.StatusForbidden)
+		return nil, errors.New("websocket: origin not allowed")
+	}
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket: not a websocket handshake", http.StatusBadRequest)
+		return nil, errors.New("websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "websocket: unsupported version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "websocket: missing key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket: hijacking is not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: hijacking is not supported")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: %v", err)
+	}
+
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	accept := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: %v", err)
+	}
+
+	return &Conn{conn: conn, r: rw.Reader}, nil
+}
+
+func checkOrigin(r *http.Request, origins []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, o := range origins {
+		if strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Close closes the connection without the closing handshake.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// RemoteAddr returns the address of the client.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage returns the next text or binary message.
+// Pings are answered and a close message is echoed before ErrClosed is returned.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var msgType int
+	var msg []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.WriteMessage(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			c.WriteMessage(CloseMessage, payload)
+			return 0, nil, ErrClosed
+		case TextMessage, BinaryMessage:
+			if msgType != 0 {
+				return 0, nil, errors.New("websocket: unexpected data frame")
+			}
+			msgType = opcode
+		case continuationFrame:
+			if msgType == 0 {
+				return 0, nil, errors.New("websocket: unexpected continuation frame")
+			}
+		default:
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %d", opcode)
+		}
+
+		if len(msg)+len(payload) > maxMessageSize {
+			return 0, nil, errors.New("websocket: message too large")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msgType, msg, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = hdr[0]&0x80 != 0
+	opcode = int(hdr[0] & 0x0f)
+	masked := hdr[1]&0x80 != 0
+	length := uint64(hdr[1] & 0x7f)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked {
+		return false, 0, nil, errors.New("websocket: unmasked client frame")
+	}
+	if length > maxMessageSize {
+		return false, 0, nil, errors.New("websocket: message too large")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends data as a single unmasked frame.
+func (c *Conn) WriteMessage(msgType int, data []byte) error {
+	hdr := make([]byte, 2, 10)
+	hdr[0] = 0x80 | byte(msgType)
+	switch n := len(data); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = append(hdr, byte(n>>8), byte(n))
+	default:
+		hdr[1] = 127
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		hdr = append(hdr, ext[:]...)
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.conn.Write(append(hdr, data...)); err != nil {
+		return err
+	}
+	return nil
+}
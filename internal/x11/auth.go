@@ -0,0 +1,77 @@
+// +build linux
+
+package x11
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// address families of the authority file
+const (
+	familyInternet  = 0
+	familyInternet6 = 6
+	familyLocal     = 256
+	familyWild      = 65535
+)
+
+const cookieName = "MIT-MAGIC-COOKIE-1"
+
+// readAuthority returns the cookie for the display number on the address,
+// or nothing to connect without authentication.
+func readAuthority(family uint16, addr []byte, number string) (string, []byte) {
+	path := os.Getenv("XAUTHORITY")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", nil
+		}
+		path = filepath.Join(home, ".Xauthority")
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil
+	}
+	return findCookie(bytes.NewReader(b), family, addr, number)
+}
+
+// findCookie returns the first MIT-MAGIC-COOKIE-1 of the entries of r which
+// matches the address and the display number.
+func findCookie(r io.Reader, family uint16, addr []byte, number string) (string, []byte) {
+	for {
+		var f uint16
+		if err := binary.Read(r, binary.BigEndian, &f); err != nil {
+			return "", nil
+		}
+		a, err1 := readCounted(r)
+		n, err2 := readCounted(r)
+		name, err3 := readCounted(r)
+		data, err4 := readCounted(r)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			return "", nil
+		}
+		if f != familyWild && (f != family || !bytes.Equal(a, addr)) {
+			continue
+		}
+		if len(n) > 0 && string(n) != number {
+			continue
+		}
+		if string(name) == cookieName {
+			return cookieName, data
+		}
+	}
+}
+
+func readCounted(r io.Reader) ([]byte, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
@@ -0,0 +1,617 @@
+// +build linux
+
+// Package x11 implements the client side of the X11 core protocol.
+//
+// Like the wayland package it knows little about individual requests:
+// they are sent by opcode, replies are returned by sequence number and
+// events are delivered to the handler of the connection.
+package x11
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrClosed is returned when the server has closed the connection.
+var ErrClosed = errors.New("x11: connection closed")
+
+// core requests used by the package
+const (
+	getInputFocus = 43
+)
+
+// message codes
+const (
+	codeError = 0
+	codeReply = 1
+)
+
+// image byte orders
+const (
+	LSBFirst = 0
+	MSBFirst = 1
+)
+
+// Handler handles an event.
+type Handler func(e *Message)
+
+// Error is an error reported by the server for a request.
+type Error struct {
+	Code     uint8
+	Sequence uint16
+	Value    uint32 // bad resource ID, atom or value
+	Major    uint8  // opcode of the request
+	Minor    uint16
+}
+
+var errorNames = []string{
+	1: "BadRequest", "BadValue", "BadWindow", "BadPixmap", "BadAtom",
+	"BadCursor", "BadFont", "BadMatch", "BadDrawable", "BadAccess",
+	"BadAlloc", "BadColormap", "BadGContext", "BadIDChoice", "BadName",
+	"BadLength", "BadImplementation",
+}
+
+func (e *Error) Error() string {
+	name := "error " + strconv.Itoa(int(e.Code))
+	if int(e.Code) < len(errorNames) {
+		name = errorNames[e.Code]
+	}
+	return fmt.Sprintf("x11: %s for request %d.%d (value %#x)", name, e.Major, e.Minor, e.Value)
+}
+
+// Visual is a visual type of a screen.
+type Visual struct {
+	ID                           uint32
+	Class                        uint8
+	RedMask, GreenMask, BlueMask uint32
+}
+
+// Screen is the default screen of the display.
+type Screen struct {
+	Root              uint32
+	Colormap          uint32
+	WhitePixel        uint32
+	BlackPixel        uint32
+	Width, Height     uint16 // in pixels
+	WidthMM, HeightMM uint16
+	RootDepth         uint8
+	RootVisual        Visual
+	BitsPerPixel      uint8 // of the pixmap format of RootDepth
+	ScanlinePad       uint8 // in bits
+}
+
+// Conn is a connection to an X server.
+// It is not safe for concurrent use.
+type Conn struct {
+	fd int
+
+	// from the connection setup
+	Screen         Screen
+	ImageByteOrder uint8
+	MinKeycode     uint8
+	MaxKeycode     uint8
+	// MaxRequestLength is the maximum length of a request in bytes.
+	MaxRequestLength int
+
+	idBase, idMask, nextID uint32
+
+	seq     uint16 // of the last request
+	handler Handler
+	replies map[uint16]*Message
+	errors  map[uint16]*Error
+	waiting map[uint16]bool // requests whose replies are expected
+
+	rbuf []byte
+	err  error
+}
+
+// Dial connects to the X server named by $DISPLAY, authenticating with the
+// MIT-MAGIC-COOKIE-1 of $XAUTHORITY or ~/.Xauthority if there is one.
+//
+// DISPLAY is [host]:display[.screen]. An empty host or "unix" is the local
+// socket, a host starting with a slash is the path of a socket and other
+// hosts are connected with TCP. Only the first screen is used.
+func Dial() (*Conn, error) {
+	display := os.Getenv("DISPLAY")
+	if display == "" {
+		return nil, errors.New("x11: DISPLAY is not set")
+	}
+	host, number, err := parseDisplay(display)
+	if err != nil {
+		return nil, err
+	}
+
+	var fd int
+	var family uint16 = familyLocal
+	var addr []byte
+	switch {
+	case host == "" || host == "unix":
+		// the abstract socket is preferred like in libxcb
+		path := "/tmp/.X11-unix/X" + number
+		fd, err = dialUnix("@" + path)
+		if err != nil {
+			fd, err = dialUnix(path)
+		}
+	case strings.HasPrefix(host, "/"):
+		fd, err = dialUnix(host)
+	default:
+		fd, family, addr, err = dialTCP(host, number)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("x11: connect %s: %v", display, err)
+	}
+	if family == familyLocal {
+		name, _ := os.Hostname()
+		addr = []byte(name)
+	}
+
+	c := &Conn{
+		fd:      fd,
+		replies: make(map[uint16]*Message),
+		errors:  make(map[uint16]*Error),
+		waiting: make(map[uint16]bool),
+	}
+	authName, authData := readAuthority(family, addr, number)
+	if err := c.setup(authName, authData); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseDisplay returns the host and the display number of DISPLAY.
+func parseDisplay(display string) (string, string, error) {
+	i := strings.LastIndexByte(display, ':')
+	if i < 0 {
+		return "", "", fmt.Errorf("x11: invalid DISPLAY %q", display)
+	}
+	host, number := display[:i], display[i+1:]
+	if j := strings.IndexByte(number, '.'); j >= 0 {
+		number = number[:j]
+	}
+	if _, err := strconv.ParseUint(number, 10, 16); err != nil {
+		return "", "", fmt.Errorf("x11: invalid DISPLAY %q", display)
+	}
+	host = strings.TrimPrefix(host, "unix/")
+	host = strings.TrimPrefix(host, "tcp/")
+	return host, number, nil
+}
+
+func dialUnix(path string) (int, error) {
+	fd, err := unix.Socket(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return -1, err
+	}
+	if err := unix.Connect(fd, &unix.SockaddrUnix{Name: path}); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// dialTCP connects to the display on host and returns the address family
+// and address for the authority.
+func dialTCP(host, number string) (int, uint16, []byte, error) {
+	n, _ := strconv.Atoi(number)
+	addr, err := net.ResolveTCPAddr("tcp", net.JoinHostPort(host, strconv.Itoa(6000+n)))
+	if err != nil {
+		return -1, 0, nil, err
+	}
+
+	var sa unix.Sockaddr
+	domain, family, ip := unix.AF_INET, uint16(familyInternet), []byte(addr.IP.To4())
+	if ip != nil {
+		sa4 := &unix.SockaddrInet4{Port: addr.Port}
+		copy(sa4.Addr[:], ip)
+		sa = sa4
+	} else {
+		domain, family, ip = unix.AF_INET6, familyInternet6, []byte(addr.IP.To16())
+		sa6 := &unix.SockaddrInet6{Port: addr.Port}
+		copy(sa6.Addr[:], ip)
+		sa = sa6
+	}
+	fd, err := unix.Socket(domain, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return -1, 0, nil, err
+	}
+	if err := unix.Connect(fd, sa); err != nil {
+		unix.Close(fd)
+		return -1, 0, nil, err
+	}
+	unix.SetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_NODELAY, 1)
+	if addr.IP.IsLoopback() {
+		return fd, familyLocal, nil, nil
+	}
+	return fd, family, ip, nil
+}
+
+// setup sends the connection setup and reads the default screen.
+func (c *Conn) setup(authName string, authData []byte) error {
+	msg := []byte{'l', 0}
+	msg = appendUint16(msg, 11) // protocol version 11.0
+	msg = appendUint16(msg, 0)
+	msg = appendUint16(msg, uint16(len(authName)))
+	msg = appendUint16(msg, uint16(len(authData)))
+	msg = append(msg, 0, 0)
+	msg = pad(append(msg, authName...))
+	msg = pad(append(msg, authData...))
+	if err := c.write(msg); err != nil {
+		return err
+	}
+
+	head := make([]byte, 8)
+	if err := c.readFull(head); err != nil {
+		return err
+	}
+	data := make([]byte, 4*int(binary.LittleEndian.Uint16(head[6:])))
+	if err := c.readFull(data); err != nil {
+		return err
+	}
+	switch head[0] {
+	case 0: // Failed
+		n := int(head[1])
+		if n > len(data) {
+			n = len(data)
+		}
+		return fmt.Errorf("x11: connection refused: %s", strings.TrimSpace(string(data[:n])))
+	case 2: // Authenticate
+		return fmt.Errorf("x11: authentication required: %s", strings.TrimRight(string(data), "\x00"))
+	}
+	return c.parseSetup(data)
+}
+
+func (c *Conn) parseSetup(data []byte) error {
+	if len(data) < 32 {
+		return errors.New("x11: short connection setup")
+	}
+	m := &Message{data: data}
+	m.Skip(4) // release
+	c.idBase, c.idMask = m.Uint32(), m.Uint32()
+	m.Skip(4) // motion buffer
+	vendorLen := int(m.Uint16())
+	c.MaxRequestLength = 4 * int(m.Uint16())
+	screens, formats := m.Uint8(), int(m.Uint8())
+	c.ImageByteOrder = m.Uint8()
+	m.Skip(3) // bitmap format
+	c.MinKeycode, c.MaxKeycode = m.Uint8(), m.Uint8()
+	m.Skip(4)
+	m.Skip((vendorLen + 3) &^ 3)
+
+	type format struct{ depth, bpp, pad uint8 }
+	list := make([]format, formats)
+	for i := range list {
+		list[i] = format{m.Uint8(), m.Uint8(), m.Uint8()}
+		m.Skip(5)
+	}
+	if screens == 0 || len(m.data) < 40 {
+		return errors.New("x11: no screen")
+	}
+
+	s := &c.Screen
+	s.Root, s.Colormap = m.Uint32(), m.Uint32()
+	s.WhitePixel, s.BlackPixel = m.Uint32(), m.Uint32()
+	m.Skip(4) // input masks
+	s.Width, s.Height = m.Uint16(), m.Uint16()
+	s.WidthMM, s.HeightMM = m.Uint16(), m.Uint16()
+	m.Skip(4) // installed maps
+	s.RootVisual.ID = m.Uint32()
+	m.Skip(2) // backing stores, save unders
+	s.RootDepth = m.Uint8()
+	depths := int(m.Uint8())
+	for i := 0; i < depths; i++ {
+		m.Skip(2) // depth
+		visuals := int(m.Uint16())
+		m.Skip(4)
+		for j := 0; j < visuals; j++ {
+			v := Visual{ID: m.Uint32(), Class: m.Uint8()}
+			m.Skip(3) // bits per RGB, colormap entries
+			v.RedMask, v.GreenMask, v.BlueMask = m.Uint32(), m.Uint32(), m.Uint32()
+			m.Skip(4)
+			if v.ID == s.RootVisual.ID {
+				s.RootVisual = v
+			}
+		}
+	}
+	for _, f := range list {
+		if f.depth == s.RootDepth {
+			s.BitsPerPixel, s.ScanlinePad = f.bpp, f.pad
+		}
+	}
+	return nil
+}
+
+// Fd returns the socket, which becomes readable when events arrive.
+func (c *Conn) Fd() int {
+	return c.fd
+}
+
+// Close closes the connection. The server frees its resources.
+func (c *Conn) Close() error {
+	return unix.Close(c.fd)
+}
+
+// NewID allocates a resource ID for a window, GC or other resource.
+func (c *Conn) NewID() uint32 {
+	c.nextID++
+	return c.idBase | (c.nextID & c.idMask)
+}
+
+// SetHandler sets the handler of the events.
+func (c *Conn) SetHandler(h Handler) {
+	c.handler = h
+}
+
+// Request sends a request and returns its sequence number.
+//
+// Arguments are encoded by type: uint8, uint16, int16, uint32, int32,
+// []byte and string. The request is padded to a multiple of 4 bytes.
+func (c *Conn) Request(opcode uint8, detail uint8, args ...interface{}) (uint16, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	msg := make([]byte, 4, 64)
+	msg[0], msg[1] = opcode, detail
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case uint8:
+			msg = append(msg, v)
+		case uint16:
+			msg = appendUint16(msg, v)
+		case int16:
+			msg = appendUint16(msg, uint16(v))
+		case uint32:
+			msg = appendUint32(msg, v)
+		case int32:
+			msg = appendUint32(msg, uint32(v))
+		case []byte:
+			msg = append(msg, v...)
+		case string:
+			msg = append(msg, v...)
+		default:
+			return 0, fmt.Errorf("x11: unsupported argument type %T", arg)
+		}
+	}
+	msg = pad(msg)
+	if len(msg) > c.MaxRequestLength {
+		return 0, fmt.Errorf("x11: request of %d bytes is too large", len(msg))
+	}
+	binary.LittleEndian.PutUint16(msg[2:], uint16(len(msg)/4))
+
+	if err := c.write(msg); err != nil {
+		c.err = err
+		return 0, err
+	}
+	c.seq++
+	return c.seq, nil
+}
+
+// Reply waits for the reply of the request seq, dispatching the events
+// received meanwhile.
+func (c *Conn) Reply(seq uint16) (*Message, error) {
+	c.waiting[seq] = true
+	defer delete(c.waiting, seq)
+	for {
+		if r, ok := c.replies[seq]; ok {
+			delete(c.replies, seq)
+			return r, nil
+		}
+		if e, ok := c.errors[seq]; ok {
+			delete(c.errors, seq)
+			return nil, e
+		}
+		if err := c.Dispatch(true); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Sync blocks until the server has processed all requests sent so far.
+// Errors of the requests are returned.
+func (c *Conn) Sync() error {
+	seq, err := c.Request(getInputFocus, 0)
+	if err != nil {
+		return err
+	}
+	_, err = c.Reply(seq)
+	return err
+}
+
+// Dispatch reads the messages available on the socket and calls the handler
+// for the events. If block is false and none is available, it returns
+// immediately. An error for a request whose reply is not awaited is
+// returned and closes the connection for further use.
+func (c *Conn) Dispatch(block bool) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	buf := make([]byte, 4096)
+	flags := 0
+	if !block {
+		flags = unix.MSG_DONTWAIT
+	}
+	n, _, err := unix.Recvfrom(c.fd, buf, flags)
+	if err == unix.EAGAIN || err == unix.EINTR {
+		return nil
+	}
+	if err != nil {
+		c.err = fmt.Errorf("x11: recv: %v", err)
+		return c.err
+	}
+	if n == 0 {
+		c.err = ErrClosed
+		return c.err
+	}
+	c.rbuf = append(c.rbuf, buf[:n]...)
+
+	for len(c.rbuf) >= 32 {
+		size := 32
+		if c.rbuf[0] == codeReply {
+			size += 4 * int(binary.LittleEndian.Uint32(c.rbuf[4:]))
+		}
+		if len(c.rbuf) < size {
+			break
+		}
+		msg := c.rbuf[:size:size]
+		c.rbuf = c.rbuf[size:]
+		c.handle(msg)
+		if c.err != nil {
+			return c.err
+		}
+	}
+	if len(c.rbuf) == 0 {
+		c.rbuf = nil
+	}
+	return nil
+}
+
+func (c *Conn) handle(msg []byte) {
+	seq := binary.LittleEndian.Uint16(msg[2:])
+	switch msg[0] {
+	case codeError:
+		e := &Error{
+			Code:     msg[1],
+			Sequence: seq,
+			Value:    binary.LittleEndian.Uint32(msg[4:]),
+			Minor:    binary.LittleEndian.Uint16(msg[8:]),
+			Major:    msg[10],
+		}
+		if c.waiting[seq] {
+			c.errors[seq] = e
+		} else {
+			c.err = e
+		}
+	case codeReply:
+		if c.waiting[seq] {
+			c.replies[seq] = &Message{Code: codeReply, Detail: msg[1], data: append([]byte(nil), msg[8:]...)}
+		}
+	default:
+		if c.handler != nil {
+			c.handler(&Message{Code: msg[0] &^ 0x80, Detail: msg[1], Sent: msg[0]&0x80 != 0, data: msg[4:]})
+		}
+	}
+}
+
+func (c *Conn) write(b []byte) error {
+	for len(b) > 0 {
+		n, err := unix.Write(c.fd, b)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("x11: write: %v", err)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func (c *Conn) readFull(b []byte) error {
+	for len(b) > 0 {
+		n, err := unix.Read(c.fd, b)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("x11: read: %v", err)
+		}
+		if n == 0 {
+			return ErrClosed
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// Message is an event or a reply. Its fields after the code, the detail
+// and the sequence number, and for replies the length, are read in order.
+type Message struct {
+	Code   uint8 // event code, or 1 for a reply
+	Detail uint8
+	Sent   bool // the event was sent with SendEvent
+	data   []byte
+}
+
+// Data returns the fields not read yet without reading them.
+// The slice of an event is valid only during the handler.
+func (m *Message) Data() []byte {
+	return m.data
+}
+
+// Skip skips n bytes.
+func (m *Message) Skip(n int) {
+	if n > len(m.data) {
+		n = len(m.data)
+	}
+	m.data = m.data[n:]
+}
+
+// Uint8 reads a CARD8 or BYTE.
+func (m *Message) Uint8() uint8 {
+	if len(m.data) < 1 {
+		return 0
+	}
+	v := m.data[0]
+	m.data = m.data[1:]
+	return v
+}
+
+// Uint16 reads a CARD16.
+func (m *Message) Uint16() uint16 {
+	if len(m.data) < 2 {
+		m.data = nil
+		return 0
+	}
+	v := binary.LittleEndian.Uint16(m.data)
+	m.data = m.data[2:]
+	return v
+}
+
+// Int16 reads an INT16.
+func (m *Message) Int16() int16 {
+	return int16(m.Uint16())
+}
+
+// Uint32 reads a CARD32, a resource ID or an atom.
+func (m *Message) Uint32() uint32 {
+	if len(m.data) < 4 {
+		m.data = nil
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(m.data)
+	m.data = m.data[4:]
+	return v
+}
+
+// Bytes reads n bytes. The slice of an event is valid only during the handler.
+func (m *Message) Bytes(n int) []byte {
+	if n > len(m.data) {
+		n = len(m.data)
+	}
+	b := m.data[:n]
+	m.data = m.data[n:]
+	return b
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
@@ -0,0 +1,72 @@
+// +build linux
+
+package x11
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseDisplay(t *testing.T) {
+	for _, c := range []struct {
+		display      string
+		host, number string
+		ok           bool
+	}{
+		{":0", "", "0", true},
+		{":1.0", "", "1", true},
+		{"unix:2", "unix", "2", true},
+		{"localhost:10.0", "localhost", "10", true},
+		{"tcp/example.com:3", "example.com", "3", true},
+		{"/tmp/launch-x/org.xquartz:0", "/tmp/launch-x/org.xquartz", "0", true},
+		{"[::1]:0", "[::1]", "0", true},
+		{"0", "", "", false},
+		{":x", "", "", false},
+	} {
+		host, number, err := parseDisplay(c.display)
+		if (err == nil) != c.ok || host != c.host || number != c.number {
+			t.Errorf("%q: %q %q %v", c.display, host, number, err)
+		}
+	}
+}
+
+func authEntry(family uint16, fields ...string) []byte {
+	b := []byte{byte(family >> 8), byte(family)}
+	for _, f := range fields {
+		b = append(b, byte(len(f)>>8), byte(len(f)))
+		b = append(b, f...)
+	}
+	return b
+}
+
+func TestFindCookie(t *testing.T) {
+	var file []byte
+	file = append(file, authEntry(familyLocal, "other", "0", cookieName, "other host")...)
+	file = append(file, authEntry(familyLocal, "host", "1", cookieName, "display 1")...)
+	file = append(file, authEntry(familyLocal, "host", "0", "XDM-AUTHORIZATION-1", "xdm")...)
+	file = append(file, authEntry(familyLocal, "host", "0", cookieName, "display 0")...)
+	file = append(file, authEntry(familyWild, "", "", cookieName, "wild")...)
+
+	for _, c := range []struct {
+		family uint16
+		addr   string
+		number string
+		want   string
+	}{
+		{familyLocal, "host", "0", "display 0"},
+		{familyLocal, "host", "1", "display 1"},
+		{familyLocal, "host", "2", "wild"},
+		{familyInternet, "\x7f\x00\x00\x01", "0", "wild"},
+	} {
+		name, data := findCookie(bytes.NewReader(file), c.family, []byte(c.addr), c.number)
+		if name != cookieName || string(data) != c.want {
+			t.Errorf("%d %q :%s: %q %q, want %q", c.family, c.addr, c.number, name, data, c.want)
+		}
+	}
+
+	// a truncated file has no cookie
+	name, _ := findCookie(bytes.NewReader(file[:10]), familyLocal, []byte("host"), "0")
+	if name != "" {
+		t.Errorf("truncated: %q", name)
+	}
+}
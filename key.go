@@ -0,0 +1,151 @@
+package gui
+
+import "strconv"
+
+// Key is a key independent of the backend.
+type Key int
+
+// Keys
+const (
+	KeyUnknown Key = iota
+
+	KeyA
+	KeyB
+	KeyC
+	KeyD
+	KeyE
+	KeyF
+	KeyG
+	KeyH
+	KeyI
+	KeyJ
+	KeyK
+	KeyL
+	KeyM
+	KeyN
+	KeyO
+	KeyP
+	KeyQ
+	KeyR
+	KeyS
+	KeyT
+	KeyU
+	KeyV
+	KeyW
+	KeyX
+	KeyY
+	KeyZ
+
+	Key0
+	Key1
+	Key2
+	Key3
+	Key4
+	Key5
+	Key6
+	Key7
+	Key8
+	Key9
+
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+
+	KeyEnter
+	KeyEscape
+	KeyBackspace
+	KeyTab
+	KeySpace
+	KeyInsert
+	KeyDelete
+	KeyHome
+	KeyEnd
+	KeyPageUp
+	KeyPageDown
+	KeyLeft
+	KeyRight
+	KeyUp
+	KeyDown
+
+	KeyMinus
+	KeyEqual
+	KeyComma
+	KeyPeriod
+	KeySlash
+
+	KeyShift
+	KeyControl
+	KeyAlt
+	KeySuper
+)
+
+// Modifier is a set of modifier keys.
+type Modifier uint8
+
+// Modifiers
+const (
+	ModShift Modifier = 1 << iota
+	ModCtrl
+	ModAlt
+	ModSuper
+)
+
+// KeyEvent is delivered when a key is pressed or released.
+type KeyEvent struct {
+	EventHeader
+	Key    Key
+	Mods   Modifier
+	Down   bool
+	Repeat bool
+}
+
+var keyNames = map[Key]string{
+	KeyEnter:     "Enter",
+	KeyEscape:    "Esc",
+	KeyBackspace: "Backspace",
+	KeyTab:       "Tab",
+	KeySpace:     "Space",
+	KeyInsert:    "Insert",
+	KeyDelete:    "Delete",
+	KeyHome:      "Home",
+	KeyEnd:       "End",
+	KeyPageUp:    "PageUp",
+	KeyPageDown:  "PageDown",
+	KeyLeft:      "Left",
+	KeyRight:     "Right",
+	KeyUp:        "Up",
+	KeyDown:      "Down",
+	KeyMinus:     "-",
+	KeyEqual:     "=",
+	KeyComma:     ",",
+	KeyPeriod:    ".",
+	KeySlash:     "/",
+	KeyShift:     "Shift",
+	KeyControl:   "Ctrl",
+	KeyAlt:       "Alt",
+	KeySuper:     "Super",
+}
+
+func (k Key) String() string {
+	switch {
+	case k >= KeyA && k <= KeyZ:
+		return string(rune('A' + k - KeyA))
+	case k >= Key0 && k <= Key9:
+		return string(rune('0' + k - Key0))
+	case k >= KeyF1 && k <= KeyF12:
+		return "F" + strconv.Itoa(int(k-KeyF1)+1)
+	}
+	if name, ok := keyNames[k]; ok {
+		return name
+	}
+	return "Unknown"
+}
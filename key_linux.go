@@ -0,0 +1,107 @@
+package gui
+
+// evdevKeys maps Linux input event codes (linux/input-event-codes.h) to keys.
+// Wayland key events use the same codes.
+var evdevKeys = map[uint32]Key{
+	1:  KeyEscape,
+	2:  Key1,
+	3:  Key2,
+	4:  Key3,
+	5:  Key4,
+	6:  Key5,
+	7:  Key6,
+	8:  Key7,
+	9:  Key8,
+	10: Key9,
+	11: Key0,
+	12: KeyMinus,
+	13: KeyEqual,
+	14: KeyBackspace,
+	15: KeyTab,
+	16: KeyQ,
+	17: KeyW,
+	18: KeyE,
+	19: KeyR,
+	20: KeyT,
+	21: KeyY,
+	22: KeyU,
+	23: KeyI,
+	24: KeyO,
+	25: KeyP,
+	28: KeyEnter,
+	29: KeyControl, // left
+	30: KeyA,
+	31: KeyS,
+	32: KeyD,
+	33: KeyF,
+	34: KeyG,
+	35: KeyH,
+	36: KeyJ,
+	37: KeyK,
+	38: KeyL,
+	42: KeyShift, // left
+	44: KeyZ,
+	45: KeyX,
+	46: KeyC,
+	47: KeyV,
+	48: KeyB,
+	49: KeyN,
+	50: KeyM,
+	51: KeyComma,
+	52: KeyPeriod,
+	53: KeySlash,
+	54: KeyShift, // right
+	56: KeyAlt,   // left
+	57: KeySpace,
+	59: KeyF1,
+	60: KeyF2,
+	61: KeyF3,
+	62: KeyF4,
+	63: KeyF5,
+	64: KeyF6,
+	65: KeyF7,
+	66: KeyF8,
+	67: KeyF9,
+	68: KeyF10,
+	87: KeyF11,
+	88: KeyF12,
+	96: KeyEnter,   // keypad
+	97: KeyControl, // right
+
+	100: KeyAlt, // right
+	102: KeyHome,
+	103: KeyUp,
+	104: KeyPageUp,
+	105: KeyLeft,
+	106: KeyRight,
+	107: KeyEnd,
+	108: KeyDown,
+	109: KeyPageDown,
+	110: KeyInsert,
+	111: KeyDelete,
+	125: KeySuper, // left
+	126: KeySuper, // right
+}
+
+// evdev button codes
+const (
+	btnLeft   = 0x110
+	btnRight  = 0x111
+	btnMiddle = 0x112
+)
+
+func keyFromEvdev(code uint32) Key {
+	return evdevKeys[code]
+}
+
+func buttonFromEvdev(code uint32) MouseButton {
+	switch code {
+	case btnLeft:
+		return ButtonLeft
+	case btnRight:
+		return ButtonRight
+	case btnMiddle:
+		return ButtonMiddle
+	}
+	return ButtonNone
+}
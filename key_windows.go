@@ -0,0 +1,60 @@
+package gui
+
+var vkKeys = map[uintptr]Key{
+	VK_BACK:       KeyBackspace,
+	VK_TAB:        KeyTab,
+	VK_RETURN:     KeyEnter,
+	VK_SHIFT:      KeyShift,
+	VK_CONTROL:    KeyControl,
+	VK_MENU:       KeyAlt,
+	VK_ESCAPE:     KeyEscape,
+	VK_SPACE:      KeySpace,
+	VK_PRIOR:      KeyPageUp,
+	VK_NEXT:       KeyPageDown,
+	VK_END:        KeyEnd,
+	VK_HOME:       KeyHome,
+	VK_LEFT:       KeyLeft,
+	VK_UP:         KeyUp,
+	VK_RIGHT:      KeyRight,
+	VK_DOWN:       KeyDown,
+	VK_INSERT:     KeyInsert,
+	VK_DELETE:     KeyDelete,
+	VK_LWIN:       KeySuper,
+	VK_RWIN:       KeySuper,
+	VK_OEM_PLUS:   KeyEqual,
+	VK_OEM_COMMA:  KeyComma,
+	VK_OEM_MINUS:  KeyMinus,
+	VK_OEM_PERIOD: KeyPeriod,
+	VK_OEM_2:      KeySlash,
+}
+
+// keyFromVK converts a virtual key code of WM_KEYDOWN to a Key.
+func keyFromVK(vk uintptr) Key {
+	switch {
+	case vk >= 'A' && vk <= 'Z':
+		return KeyA + Key(vk-'A')
+	case vk >= '0' && vk <= '9':
+		return Key0 + Key(vk-'0')
+	case vk >= VK_F1 && vk <= VK_F12:
+		return KeyF1 + Key(vk-VK_F1)
+	}
+	return vkKeys[vk]
+}
+
+// currentModifiers returns the modifier state of the current message.
+func currentModifiers() Modifier {
+	var mods Modifier
+	if GetKeyState(VK_SHIFT) < 0 {
+		mods |= ModShift
+	}
+	if GetKeyState(VK_CONTROL) < 0 {
+		mods |= ModCtrl
+	}
+	if GetKeyState(VK_MENU) < 0 {
+		mods |= ModAlt
+	}
+	if GetKeyState(VK_LWIN) < 0 || GetKeyState(VK_RWIN) < 0 {
+		mods |= ModSuper
+	}
+	return mods
+}
@@ -0,0 +1,117 @@
+package gui
+
+import "time"
+
+// LoopStats are statistics of the loop of a window.
+type LoopStats struct {
+	Messages          uint64        // dispatched messages or events
+	MessagesPerSecond float64       // over the last second or more
+	Blocked           time.Duration // waiting for messages
+	Busy              time.Duration // handling them, drawing and idle work
+	LongestDispatch   time.Duration
+	LongestMessage    string // name of the longest dispatch, e.g. "WM_PAINT" or "key"
+}
+
+// DispatchObserver sees each message or event dispatched by the loops, on
+// their threads. name is the name of the native message on Windows, e.g.
+// "WM_PAINT", and of the event on the other backends, e.g. "key". A panic
+// in them is handled as one of the renderer, in PhaseObserver.
+type DispatchObserver interface {
+	BeforeDispatch(w Window, name string)
+	AfterDispatch(w Window, name string, elapsed time.Duration)
+}
+
+// WithDispatchObserver adds observer to the observers of the loops.
+func WithDispatchObserver(observer DispatchObserver) Option {
+	return func(o *options) {
+		o.observers = append(o.observers, observer)
+	}
+}
+
+// loopStats counts the iterations of a loop.
+type loopStats struct {
+	LoopStats
+	woken     time.Time // end of the last wait
+	rateStart time.Time
+	rateCount uint64
+}
+
+// waited adds the time of a wait from start to end, and the time since the
+// last one as busy.
+func (s *loopStats) waited(start, end time.Time) {
+	if !s.woken.IsZero() {
+		s.Busy += start.Sub(s.woken)
+	}
+	s.Blocked += end.Sub(start)
+	s.woken = end
+}
+
+func (s *loopStats) dispatched(name string, elapsed time.Duration, now time.Time) {
+	s.Messages++
+	s.rateCount++
+	if elapsed > s.LongestDispatch {
+		s.LongestDispatch = elapsed
+		s.LongestMessage = name
+	}
+	s.roll(now)
+}
+
+// roll ends the interval of MessagesPerSecond after a second.
+func (s *loopStats) roll(now time.Time) {
+	if s.rateStart.IsZero() {
+		s.rateStart = now
+		return
+	}
+	elapsed := now.Sub(s.rateStart)
+	if elapsed < time.Second {
+		return
+	}
+	s.MessagesPerSecond = float64(s.rateCount) / elapsed.Seconds()
+	s.rateStart = now
+	s.rateCount = 0
+}
+
+func (w *window) Stats() LoopStats {
+	now := time.Now()
+	w.stats.roll(now)
+	stats := w.stats.LoopStats
+	if !w.stats.woken.IsZero() {
+		// the current iteration
+		stats.Busy += now.Sub(w.stats.woken)
+	}
+	return stats
+}
+
+// beginDispatch tells the observers about a dispatch and returns its start.
+func (w *window) beginDispatch(name string) time.Time {
+	for _, o := range w.app.opts.observers {
+		w.guard(PhaseObserver, func() error {
+			o.BeforeDispatch(w, name)
+			return nil
+		})
+	}
+	return time.Now()
+}
+
+// endDispatch counts a dispatch from start.
+func (w *window) endDispatch(name string, start time.Time) {
+	now := time.Now()
+	elapsed := now.Sub(start)
+	w.stats.dispatched(name, elapsed, now)
+	for _, o := range w.app.opts.observers {
+		w.guard(PhaseObserver, func() error {
+			o.AfterDispatch(w, name, elapsed)
+			return nil
+		})
+	}
+}
+
+// idle calls the IdleHandler of the renderer before the loop waits.
+func (w *window) idle() {
+	if ih, ok := w.renderer.(IdleHandler); ok {
+		w.guard(PhaseIdle, func() error {
+			ih.Idle()
+			return nil
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// +build !windows
+
+package gui
+
+import (
+	"image/color"
+	"testing"
+	"time"
+)
+
+// panickingObserver panics in the dispatches of after.
+type panickingObserver struct {
+	before, after string
+}
+
+func (o *panickingObserver) BeforeDispatch(w Window, name string) {
+	if name == o.before {
+		panic("before " + name)
+	}
+}
+
+func (o *panickingObserver) AfterDispatch(w Window, name string, elapsed time.Duration) {
+	if name == o.after {
+		panic("after " + name)
+	}
+}
+
+func TestObserverPanic(t *testing.T) {
+	errs := make(chan *RendererError, 4)
+	app := NewApplication(
+		WithBackend("headless"),
+		WithDispatchObserver(&panickingObserver{before: "size", after: "expose"}),
+		WithErrorHandler(func(err *RendererError) error {
+			errs <- err
+			return nil
+		}),
+	)
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("observer", 8, 8, r)
+
+	for _, want := range []string{"before size", "after expose"} {
+		select {
+		case err := <-errs:
+			if err.Phase != PhaseObserver || err.Stack == nil || err.Window != "observer" {
+				t.Errorf("error %v in %v, want a panic in Observer", err, err.Phase)
+			}
+			if got := err.Err.Error(); got != "panic: "+want {
+				t.Errorf("error %q, want a panic %q", got, want)
+			}
+		case <-testTimeout():
+			t.Fatalf("no error for %q", want)
+		}
+	}
+	// the loop goes on
+	r.nextFrame(t)
+	if PhaseObserver.String() != "Observer" {
+		t.Errorf("PhaseObserver is %q", PhaseObserver)
+	}
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+}
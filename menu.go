@@ -0,0 +1,89 @@
+package gui
+
+import "fmt"
+
+// Menu is a declarative menu model for menu bars and context menus.
+type Menu struct {
+	Items []*MenuItem
+}
+
+// MenuItem is an item of a Menu.
+//
+// Selecting an item with a non-zero ID delivers a CommandEvent with that ID.
+// IDs must fit in 16 bits. Checkable items toggle Checked on selection,
+// and items sharing a non-zero RadioGroup behave as radio items.
+type MenuItem struct {
+	ID         int
+	Label      string
+	Shortcut   string // displayed next to the label, e.g. "Ctrl+S"
+	Submenu    *Menu
+	Separator  bool
+	Checkable  bool
+	Checked    bool
+	RadioGroup int
+	Disabled   bool
+}
+
+// Separator returns a separator item.
+func Separator() *MenuItem {
+	return &MenuItem{Separator: true}
+}
+
+// Find returns the item with id in m or its submenus, or nil.
+func (m *Menu) Find(id int) *MenuItem {
+	if m == nil {
+		return nil
+	}
+	for _, item := range m.Items {
+		if item.ID == id && !item.Separator && item.Submenu == nil {
+			return item
+		}
+		if found := item.Submenu.Find(id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// validate checks that every command ID fits in 16 bits.
+func (m *Menu) validate() error {
+	if m == nil {
+		return nil
+	}
+	for _, item := range m.Items {
+		if item.ID < 0 || item.ID > 0xFFFF {
+			return fmt.Errorf("menu item %q: ID %d out of range", item.Label, item.ID)
+		}
+		if err := item.Submenu.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activate updates the check state for the selected item and returns it.
+func (m *Menu) activate(id int) *MenuItem {
+	item := m.Find(id)
+	if item == nil || item.Disabled {
+		return nil
+	}
+	if item.RadioGroup != 0 {
+		m.uncheckGroup(item.RadioGroup)
+		item.Checked = true
+	} else if item.Checkable {
+		item.Checked = !item.Checked
+	}
+	return item
+}
+
+func (m *Menu) uncheckGroup(group int) {
+	if m == nil {
+		return
+	}
+	for _, item := range m.Items {
+		if item.RadioGroup == group {
+			item.Checked = false
+		}
+		item.Submenu.uncheckGroup(group)
+	}
+}
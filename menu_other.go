@@ -0,0 +1,465 @@
+// +build !windows
+
+package gui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// The backends without native menus draw them over the framebuffer: the
+// menu bar over the top of the window and the popup menus over the
+// contents. While a popup menu is open it takes the keyboard and the
+// pointer.
+
+// drawnMenuCapabilities are the menu features of the drawn menus.
+const drawnMenuCapabilities = CapMenuBar | CapPopupMenu
+
+// Metrics of the drawn menus in pixels
+const (
+	menuItemHeight      = 16
+	menuSeparatorHeight = 7
+	menuPadding         = 6  // left and right of a label
+	menuSymbolWidth     = 12 // check marks and submenu arrows
+	menuShortcutGap     = 16 // between a label and its shortcut
+)
+
+// Colors of the drawn menus
+var (
+	menuBackground    = color.RGBA{0xf0, 0xf0, 0xf0, 0xff}
+	menuBorder        = color.RGBA{0x80, 0x80, 0x80, 0xff}
+	menuText          = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	menuDisabledText  = color.RGBA{0xa0, 0xa0, 0xa0, 0xff}
+	menuHotBackground = color.RGBA{0x33, 0x66, 0xcc, 0xff}
+	menuHotText       = color.RGBA{0xff, 0xff, 0xff, 0xff}
+)
+
+// drawnMenus are the open menus of a window.
+type drawnMenus struct {
+	// popups are the open popup menu and its open submenus
+	popups []*menuPopup
+	// fromBar is set if popups[0] is the menu of the menu bar item barItem
+	fromBar bool
+	barItem int
+}
+
+// menuPopup is an open popup menu.
+type menuPopup struct {
+	menu   *Menu
+	parent int // item of the previous popup which opened it
+	rect   image.Rectangle
+	items  []image.Rectangle // by item
+	hot    int               // highlighted item, or -1
+}
+
+// selectable reports whether item can be highlighted.
+func (item *MenuItem) selectable() bool {
+	return !item.Separator && !item.Disabled
+}
+
+// newMenuPopup lays out menu at pt, moved into bounds if it does not fit.
+// If it does not fit to the right of pt, it is placed to the left of left.
+func newMenuPopup(menu *Menu, parent int, pt image.Point, left int, bounds image.Rectangle) *menuPopup {
+	labels, shortcuts := 0, 0
+	height := 2 // border
+	for _, item := range menu.Items {
+		if item.Separator {
+			height += menuSeparatorHeight
+			continue
+		}
+		if w := textWidth(item.Label); w > labels {
+			labels = w
+		}
+		if w := textWidth(item.Shortcut); w > shortcuts {
+			shortcuts = w
+		}
+		height += menuItemHeight
+	}
+	width := 2 + 2*menuSymbolWidth + labels
+	if shortcuts > 0 {
+		width += menuShortcutGap + shortcuts
+	}
+
+	if pt.X+width > bounds.Max.X {
+		pt.X = left - width
+	}
+	if pt.X+width > bounds.Max.X {
+		pt.X = bounds.Max.X - width
+	}
+	if pt.X < bounds.Min.X {
+		pt.X = bounds.Min.X
+	}
+	if pt.Y+height > bounds.Max.Y {
+		pt.Y = bounds.Max.Y - height
+	}
+	if pt.Y < bounds.Min.Y {
+		pt.Y = bounds.Min.Y
+	}
+
+	p := &menuPopup{
+		menu:   menu,
+		parent: parent,
+		rect:   image.Rectangle{pt, pt.Add(image.Pt(width, height))},
+		hot:    -1,
+	}
+	y := p.rect.Min.Y + 1
+	for _, item := range menu.Items {
+		h := menuItemHeight
+		if item.Separator {
+			h = menuSeparatorHeight
+		}
+		p.items = append(p.items, image.Rect(p.rect.Min.X+1, y, p.rect.Max.X-1, y+h))
+		y += h
+	}
+	return p
+}
+
+// itemAt returns the selectable item at pt, or -1.
+func (p *menuPopup) itemAt(pt image.Point) int {
+	for i, r := range p.items {
+		if pt.In(r) && p.menu.Items[i].selectable() {
+			return i
+		}
+	}
+	return -1
+}
+
+// next returns the next selectable item after i in the direction dir, wrapping around, or -1.
+func (p *menuPopup) next(i int, dir int) int {
+	n := len(p.items)
+	if i < 0 && dir < 0 {
+		i = 0
+	}
+	for step := 1; step <= n; step++ {
+		j := ((i+dir*step)%n + n) % n
+		if p.menu.Items[j].selectable() {
+			return j
+		}
+	}
+	return -1
+}
+
+// menuBarRect returns the rectangle of the menu bar, which is empty without a menu.
+func (w *window) menuBarRect() image.Rectangle {
+	if w.menu == nil || len(w.menu.Items) == 0 {
+		return image.Rectangle{}
+	}
+	return image.Rect(w.size.Min.X, w.size.Min.Y, w.size.Max.X, w.size.Min.Y+menuItemHeight).Intersect(w.size)
+}
+
+// menuBarItems returns the rectangles of the items of the menu bar.
+func (w *window) menuBarItems() []image.Rectangle {
+	bar := w.menuBarRect()
+	if bar.Empty() {
+		return nil
+	}
+	rects := make([]image.Rectangle, len(w.menu.Items))
+	x := bar.Min.X
+	for i, item := range w.menu.Items {
+		width := textWidth(item.Label) + 2*menuPadding
+		rects[i] = image.Rect(x, bar.Min.Y, x+width, bar.Max.Y)
+		x += width
+	}
+	return rects
+}
+
+// menuBarItemAt returns the selectable item of the menu bar at pt, or -1.
+func (w *window) menuBarItemAt(pt image.Point) int {
+	for i, r := range w.menuBarItems() {
+		if pt.In(r) && w.menu.Items[i].selectable() {
+			return i
+		}
+	}
+	return -1
+}
+
+func (w *window) SetMenu(menu *Menu) error {
+	if err := menu.validate(); err != nil {
+		return err
+	}
+	w.closePopups(0)
+	w.damage.add(w.menuBarRect(), w.size)
+	w.menu = menu
+	w.damage.add(w.menuBarRect(), w.size)
+	return nil
+}
+
+// PopupMenu opens menu at the pointer. It returns at once, the selected
+// item is delivered as a CommandEvent.
+func (w *window) PopupMenu(menu *Menu) error {
+	if err := menu.validate(); err != nil {
+		return err
+	}
+	if w.driver == nil || w.driver.framebuffer() == nil {
+		// the menus are drawn in the framebuffer only
+		return ErrNotSupported
+	}
+	w.closePopups(0)
+	if menu == nil || len(menu.Items) == 0 {
+		return nil
+	}
+	w.openPopup(newMenuPopup(menu, -1, w.pointer, w.pointer.X, w.size))
+	return nil
+}
+
+func (w *window) openPopup(p *menuPopup) {
+	w.menus.popups = append(w.menus.popups, p)
+	w.damage.add(p.rect, w.size)
+}
+
+// closePopups closes the popups from depth on, all of them for 0.
+func (w *window) closePopups(depth int) {
+	m := &w.menus
+	if depth >= len(m.popups) {
+		return
+	}
+	for _, p := range m.popups[depth:] {
+		w.damage.add(p.rect, w.size)
+	}
+	m.popups = m.popups[:depth]
+	if depth == 0 && m.fromBar {
+		m.fromBar = false
+		w.damage.add(w.menuBarRect(), w.size)
+	}
+}
+
+// openBarMenu opens the menu of item i of the menu bar, or selects the
+// item if it has no submenu.
+func (w *window) openBarMenu(i int) {
+	w.closePopups(0)
+	item := w.menu.Items[i]
+	if item.Submenu == nil {
+		w.command(w.menu, item.ID)
+		return
+	}
+	r := w.menuBarItems()[i]
+	w.openPopup(newMenuPopup(item.Submenu, -1, image.Pt(r.Min.X, r.Max.Y), r.Max.X, w.size))
+	w.menus.fromBar, w.menus.barItem = true, i
+	w.damage.add(w.menuBarRect(), w.size)
+}
+
+// nextBarMenu opens the next menu of the menu bar in the direction dir.
+func (w *window) nextBarMenu(dir int) {
+	n := len(w.menu.Items)
+	for step := 1; step < n; step++ {
+		i := ((w.menus.barItem+dir*step)%n + n) % n
+		if item := w.menu.Items[i]; item.selectable() && item.Submenu != nil {
+			w.openBarMenu(i)
+			return
+		}
+	}
+}
+
+// setHot highlights item i of p.
+func (w *window) setHot(p *menuPopup, i int) {
+	if p.hot != i {
+		p.hot = i
+		w.damage.add(p.rect, w.size)
+	}
+}
+
+// openSubmenu opens the submenu of item i of the popup at depth, closing
+// the deeper ones. It returns the submenu, or nil if the item has none.
+func (w *window) openSubmenu(depth int, i int) *menuPopup {
+	m := &w.menus
+	p := m.popups[depth]
+	if depth+1 < len(m.popups) && m.popups[depth+1].parent == i {
+		return m.popups[depth+1]
+	}
+	w.closePopups(depth + 1)
+	item := p.menu.Items[i]
+	if item.Submenu == nil || len(item.Submenu.Items) == 0 || !item.selectable() {
+		return nil
+	}
+	r := p.items[i]
+	sub := newMenuPopup(item.Submenu, i, image.Pt(p.rect.Max.X-1, r.Min.Y-1), p.rect.Min.X+1, w.size)
+	w.openPopup(sub)
+	return sub
+}
+
+// choose selects item i of the popup at depth: a submenu is opened, other
+// items close the menus and are delivered as a CommandEvent.
+func (w *window) choose(depth int, i int) {
+	m := &w.menus
+	item := m.popups[depth].menu.Items[i]
+	if !item.selectable() {
+		return
+	}
+	if item.Submenu != nil {
+		if sub := w.openSubmenu(depth, i); sub != nil {
+			w.setHot(sub, sub.next(-1, 1))
+		}
+		return
+	}
+	root := m.popups[0].menu
+	if m.fromBar {
+		root = w.menu
+	}
+	w.closePopups(0)
+	w.command(root, item.ID)
+}
+
+// menuMouse handles e if it is for the menus and reports whether it did.
+func (w *window) menuMouse(e *MouseEvent) bool {
+	m := &w.menus
+	pt := image.Pt(int(e.X), int(e.Y))
+	bar := w.menuBarRect()
+	if len(m.popups) == 0 {
+		if !pt.In(bar) {
+			return false
+		}
+		if e.Action == MousePress && e.Button == ButtonLeft {
+			if i := w.menuBarItemAt(pt); i >= 0 {
+				w.openBarMenu(i)
+			}
+		}
+		return true
+	}
+
+	// the deepest popup under the pointer
+	depth := len(m.popups) - 1
+	for ; depth >= 0; depth-- {
+		if pt.In(m.popups[depth].rect) {
+			break
+		}
+	}
+
+	switch e.Action {
+	case MouseMove:
+		if depth < 0 {
+			if i := w.menuBarItemAt(pt); m.fromBar && i >= 0 && i != m.barItem {
+				w.openBarMenu(i)
+			}
+			break
+		}
+		p := m.popups[depth]
+		i := p.itemAt(pt)
+		if i < 0 {
+			break
+		}
+		w.setHot(p, i)
+		w.openSubmenu(depth, i)
+	case MousePress:
+		if depth >= 0 {
+			break
+		}
+		if i := w.menuBarItemAt(pt); m.fromBar && i >= 0 && i != m.barItem {
+			w.openBarMenu(i)
+			break
+		}
+		// a press outside closes the menus
+		w.closePopups(0)
+	case MouseRelease:
+		if depth < 0 {
+			break
+		}
+		if i := m.popups[depth].itemAt(pt); i >= 0 {
+			w.choose(depth, i)
+		}
+	}
+	return true
+}
+
+// menuKey handles a key while a popup is open and reports whether it did.
+func (w *window) menuKey(key Key, down bool) bool {
+	m := &w.menus
+	if len(m.popups) == 0 {
+		return false
+	}
+	if !down {
+		return true
+	}
+	depth := len(m.popups) - 1
+	p := m.popups[depth]
+	switch key {
+	case KeyEscape:
+		w.closePopups(depth)
+	case KeyUp:
+		w.setHot(p, p.next(p.hot, -1))
+	case KeyDown:
+		w.setHot(p, p.next(p.hot, 1))
+	case KeyRight:
+		if p.hot >= 0 && p.menu.Items[p.hot].Submenu != nil {
+			w.choose(depth, p.hot)
+		} else if m.fromBar {
+			w.nextBarMenu(1)
+		}
+	case KeyLeft:
+		if depth > 0 {
+			w.closePopups(depth)
+		} else if m.fromBar {
+			w.nextBarMenu(-1)
+		}
+	case KeyEnter, KeySpace:
+		if p.hot >= 0 {
+			w.choose(depth, p.hot)
+		}
+	}
+	return true
+}
+
+// drawMenus draws the menu bar and the open popups into img and returns
+// the rectangles drawn.
+func (w *window) drawMenus(img *image.RGBA) []image.Rectangle {
+	var drawn []image.Rectangle
+	m := &w.menus
+	if bar := w.menuBarRect(); !bar.Empty() {
+		fillRect(img, bar, menuBackground)
+		fillRect(img, image.Rect(bar.Min.X, bar.Max.Y-1, bar.Max.X, bar.Max.Y), menuBorder)
+		for i, r := range w.menuBarItems() {
+			item := w.menu.Items[i]
+			text := menuText
+			if m.fromBar && m.barItem == i {
+				fillRect(img, r.Intersect(bar), menuHotBackground)
+				text = menuHotText
+			} else if item.Disabled {
+				text = menuDisabledText
+			}
+			drawText(img, r.Min.X+menuPadding, r.Min.Y+(menuItemHeight-glyphHeight)/2, item.Label, text, bar)
+		}
+		drawn = append(drawn, bar)
+	}
+
+	for _, p := range m.popups {
+		fillRect(img, p.rect, menuBorder)
+		fillRect(img, p.rect.Inset(1), menuBackground)
+		for i, item := range p.menu.Items {
+			r := p.items[i]
+			if item.Separator {
+				y := r.Min.Y + r.Dy()/2
+				fillRect(img, image.Rect(r.Min.X+menuPadding, y, r.Max.X-menuPadding, y+1), menuBorder)
+				continue
+			}
+			text := menuText
+			if item.Disabled {
+				text = menuDisabledText
+			} else if p.hot == i {
+				fillRect(img, r, menuHotBackground)
+				text = menuHotText
+			}
+			y := r.Min.Y + (menuItemHeight-glyphHeight)/2
+			symbol := (menuSymbolWidth - glyphWidth) / 2
+			if item.Checked {
+				g := checkGlyph
+				if item.RadioGroup != 0 {
+					g = radioGlyph
+				}
+				drawGlyph(img, r.Min.X+symbol, y, g, text, r)
+			}
+			drawText(img, r.Min.X+menuSymbolWidth, y, item.Label, text, r)
+			if item.Shortcut != "" {
+				drawText(img, r.Max.X-menuSymbolWidth-textWidth(item.Shortcut), y, item.Shortcut, text, r)
+			}
+			if item.Submenu != nil {
+				drawGlyph(img, r.Max.X-menuSymbolWidth+symbol, y, subMenuGlyph, text, r)
+			}
+		}
+		drawn = append(drawn, p.rect)
+	}
+	return drawn
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+}
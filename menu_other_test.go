@@ -0,0 +1,211 @@
+// +build !windows
+
+package gui
+
+import (
+	"image"
+	"testing"
+)
+
+// eventRecorder records the events of a window.
+type eventRecorder struct {
+	events []Event
+}
+
+func (r *eventRecorder) HandleEvent(e Event) {
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) commands() []int {
+	var ids []int
+	for _, e := range r.events {
+		if c, ok := e.(*CommandEvent); ok {
+			ids = append(ids, c.ID)
+		}
+	}
+	return ids
+}
+
+// newTestWindow returns a window of the headless driver which is not run.
+func newTestWindow(t *testing.T, width, height int32) (*window, *eventRecorder) {
+	d := &headlessDriver{}
+	if err := d.open("test", width, height); err != nil {
+		t.Fatal(err)
+	}
+	rec := &eventRecorder{}
+	w := &window{
+		app:       &application{opts: newOptions(nil), shortcuts: NewShortcuts()},
+		driver:    d,
+		name:      "test",
+		handler:   rec,
+		size:      image.Rect(0, 0, int(width), int(height)),
+		shortcuts: NewShortcuts(),
+		held:      make(shortcutKeys),
+		donec:     make(chan struct{}),
+	}
+	return w, rec
+}
+
+func (w *window) testMouse(action MouseAction, pt image.Point) {
+	e := &MouseEvent{Action: action, Button: ButtonLeft, X: int32(pt.X), Y: int32(pt.Y)}
+	w.pointer = pt
+	if !w.menuMouse(e) {
+		e.Window = w
+		w.dispatch(e)
+	}
+}
+
+func center(r image.Rectangle) image.Point {
+	return r.Min.Add(r.Size().Div(2))
+}
+
+func TestMenuFont(t *testing.T) {
+	if n := len(menuFont); n != '~'-' '+1 {
+		t.Errorf("%d glyphs, want %d", n, '~'-' '+1)
+	}
+	if w := textWidth("File"); w != 4*glyphAdvance-1 {
+		t.Errorf("textWidth = %d", w)
+	}
+}
+
+func TestMenuBar(t *testing.T) {
+	w, rec := newTestWindow(t, 320, 240)
+	wrap := &MenuItem{ID: 3, Label: "Wrap", Checkable: true}
+	menu := &Menu{Items: []*MenuItem{
+		{Label: "File", Submenu: &Menu{Items: []*MenuItem{
+			{ID: 1, Label: "Open", Shortcut: "Ctrl+O"},
+			Separator(),
+			{ID: 2, Label: "Quit", Disabled: true},
+		}}},
+		{Label: "View", Submenu: &Menu{Items: []*MenuItem{
+			wrap,
+			{Label: "Zoom", Submenu: &Menu{Items: []*MenuItem{
+				{ID: 4, Label: "In"},
+				{ID: 5, Label: "Out"},
+			}}},
+		}}},
+	}}
+	if err := w.SetMenu(menu); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.damage.take(); len(got) != 1 || got[0] != w.menuBarRect() {
+		t.Errorf("damage = %v, want the menu bar %v", got, w.menuBarRect())
+	}
+
+	// the bar is drawn over the contents
+	img := w.driver.framebuffer()
+	drawn := w.drawMenus(img)
+	if len(drawn) != 1 || img.RGBAAt(0, 0) != menuBackground {
+		t.Errorf("drawn %v, pixel %v", drawn, img.RGBAAt(0, 0))
+	}
+
+	// clicking File opens its menu below the item
+	items := w.menuBarItems()
+	w.testMouse(MousePress, center(items[0]))
+	if len(w.menus.popups) != 1 || !w.menus.fromBar || w.menus.barItem != 0 {
+		t.Fatalf("popups %d, fromBar %v", len(w.menus.popups), w.menus.fromBar)
+	}
+	p := w.menus.popups[0]
+	if p.rect.Min != image.Pt(items[0].Min.X, items[0].Max.Y) {
+		t.Errorf("popup at %v", p.rect.Min)
+	}
+
+	// the disabled item and the separator are skipped
+	w.menuKey(KeyDown, true)
+	w.menuKey(KeyDown, true)
+	if p.hot != 0 {
+		t.Errorf("hot = %d, want 0", p.hot)
+	}
+
+	// moving over View switches the menu
+	w.testMouse(MouseMove, center(items[1]))
+	if w.menus.barItem != 1 || w.menus.popups[0].menu != menu.Items[1].Submenu {
+		t.Fatalf("bar item %d", w.menus.barItem)
+	}
+
+	// hovering Zoom opens its submenu, Out is chosen by the keyboard
+	p = w.menus.popups[0]
+	w.testMouse(MouseMove, center(p.items[1]))
+	if len(w.menus.popups) != 2 {
+		t.Fatalf("%d popups, want the submenu", len(w.menus.popups))
+	}
+	sub := w.menus.popups[1]
+	if sub.rect.Min.X != p.rect.Max.X-1 {
+		t.Errorf("submenu at %v, popup %v", sub.rect, p.rect)
+	}
+	w.menuKey(KeyDown, true)
+	w.menuKey(KeyDown, true)
+	w.menuKey(KeyEnter, true)
+	if len(w.menus.popups) != 0 || w.menus.fromBar {
+		t.Errorf("menus are open after a selection")
+	}
+
+	// the checkable item toggles
+	w.openBarMenu(1)
+	w.testMouse(MouseRelease, center(w.menus.popups[0].items[0]))
+	if !wrap.Checked {
+		t.Error("Wrap is not checked")
+	}
+
+	if ids := rec.commands(); len(ids) != 2 || ids[0] != 5 || ids[1] != 3 {
+		t.Errorf("commands = %v, want [5 3]", ids)
+	}
+	if len(rec.events) != 2 {
+		t.Errorf("events = %d, the menus must take the mouse", len(rec.events))
+	}
+}
+
+func TestPopupMenu(t *testing.T) {
+	w, rec := newTestWindow(t, 100, 100)
+	menu := &Menu{Items: []*MenuItem{
+		{Label: "No command"},
+		{ID: 7, Label: "Seven"},
+	}}
+
+	// moved into the window
+	w.pointer = image.Pt(90, 90)
+	if err := w.PopupMenu(menu); err != nil {
+		t.Fatal(err)
+	}
+	p := w.menus.popups[0]
+	if !p.rect.In(w.size) {
+		t.Errorf("popup %v is outside of the window", p.rect)
+	}
+	w.damage.take()
+	if err := w.draw(nil); err != nil {
+		t.Fatal(err)
+	}
+	if c := w.driver.framebuffer().RGBAAt(p.rect.Min.X, p.rect.Min.Y); c != menuBorder {
+		t.Errorf("popup corner %v, want the border", c)
+	}
+
+	// items with ID 0 are not delivered
+	w.testMouse(MouseRelease, center(p.items[0]))
+	if len(rec.commands()) != 0 {
+		t.Errorf("commands = %v for an item without ID", rec.commands())
+	}
+
+	// keys go to the menu, Escape closes it
+	w.PopupMenu(menu)
+	w.key(KeyA, 0, true, false)
+	w.key(KeyEscape, 0, true, false)
+	if len(w.menus.popups) != 0 {
+		t.Error("Escape did not close the menu")
+	}
+	if len(rec.events) != 0 {
+		t.Errorf("events = %v", rec.events)
+	}
+
+	// a press outside closes it and is not delivered
+	w.pointer = image.Pt(0, 0)
+	w.PopupMenu(menu)
+	p = w.menus.popups[0]
+	w.damage.take()
+	w.testMouse(MousePress, image.Pt(99, 99))
+	if len(w.menus.popups) != 0 || len(rec.events) != 0 {
+		t.Errorf("popups %d, events %v", len(w.menus.popups), rec.events)
+	}
+	if !p.rect.In(w.damage.bounds()) {
+		t.Error("closing did not damage the popup")
+	}
+}
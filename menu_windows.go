@@ -0,0 +1,69 @@
+package gui
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+// buildMenu creates an HMENU for m, a menu bar if bar is true or a popup menu otherwise.
+func buildMenu(m *Menu, bar bool) (windows.Handle, error) {
+	var h windows.Handle
+	var err error
+	if bar {
+		h, err = CreateMenu()
+	} else {
+		h, err = CreatePopupMenu()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("CreateMenu: %v", err)
+	}
+
+	for _, item := range m.Items {
+		if item.Separator {
+			if err := AppendMenu(h, MF_SEPARATOR, 0, nil); err != nil {
+				DestroyMenu(h)
+				return 0, fmt.Errorf("AppendMenu: %v", err)
+			}
+			continue
+		}
+
+		label := item.Label
+		if item.Shortcut != "" {
+			label += "\t" + item.Shortcut
+		}
+		labelUTF16, err := windows.UTF16PtrFromString(label)
+		if err != nil {
+			DestroyMenu(h)
+			return 0, fmt.Errorf("UTF16PtrFromString %s: %v", label, err)
+		}
+
+		flags := uint32(MF_STRING)
+		if item.Disabled {
+			flags |= MF_GRAYED
+		}
+		id := uintptr(item.ID)
+		if item.Submenu != nil {
+			sub, err := buildMenu(item.Submenu, false)
+			if err != nil {
+				DestroyMenu(h)
+				return 0, err
+			}
+			flags |= MF_POPUP
+			id = uintptr(sub)
+		} else if item.Checked && item.RadioGroup == 0 {
+			flags |= MF_CHECKED
+		}
+
+		if err := AppendMenu(h, flags, id, labelUTF16); err != nil {
+			DestroyMenu(h)
+			return 0, fmt.Errorf("AppendMenu: %v", err)
+		}
+		if item.Checked && item.RadioGroup != 0 && item.Submenu == nil {
+			// draws a bullet instead of a check mark
+			CheckMenuRadioItem(h, uint32(item.ID), uint32(item.ID), uint32(item.ID), MF_BYCOMMAND)
+		}
+	}
+
+	return h, nil
+}
@@ -0,0 +1,38 @@
+package gui
+
+// MouseButton is a mouse button.
+type MouseButton int
+
+// Mouse buttons
+const (
+	ButtonNone MouseButton = iota
+	ButtonLeft
+	ButtonRight
+	ButtonMiddle
+)
+
+// MouseAction is the kind of a MouseEvent.
+type MouseAction int
+
+// Mouse actions
+const (
+	MouseMove MouseAction = iota
+	MousePress
+	MouseRelease
+	MouseScroll
+	MouseEnter
+	MouseLeave
+)
+
+// MouseEvent is delivered when the pointer moves over the window, a button
+// is pressed or released, or the wheel is scrolled.
+type MouseEvent struct {
+	EventHeader
+	Action MouseAction
+	Button MouseButton // for MousePress and MouseRelease
+	X, Y   int32       // position in pixels relative to the client area
+	Mods   Modifier
+
+	// ScrollX and ScrollY are in wheel notches, positive to the right and down.
+	ScrollX, ScrollY float32
+}
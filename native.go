@@ -0,0 +1,46 @@
+package gui
+
+// NativeMessage is a raw message of the window system. The fields of the
+// other platforms are zero.
+//
+// On Windows, messages are seen in the window procedure. Queued messages
+// are seen before, with Queued set, when they are taken from the queue,
+// before shortcuts and TranslateMessage. Result is returned by the window
+// procedure for a handled message. WM_NCDESTROY is always handled by the
+// library too.
+//
+// On Wayland, the events of the objects of the window are seen. Events
+// with a file descriptor, like wl_keyboard.keymap, are always handled by
+// the library too. On X11, the core events of the window are seen with
+// their code as Opcode and the bytes after the sequence number as Args.
+// Other backends have no native messages.
+type NativeMessage struct {
+	// Windows
+	HWND    uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Queued  bool
+	Result  uintptr
+
+	// Wayland and X11
+	Object uint32 // object ID, or the window of X11
+	Opcode uint16
+	Name   string // interface and event, e.g. "wl_pointer.motion" or "MotionNotify"
+	Args   []byte // wire arguments, valid only during HandleNative
+}
+
+// handleNative passes m to the NativeHandler of w, if any, and reports
+// whether it has handled m.
+func (w *window) handleNative(m *NativeMessage) bool {
+	nh, ok := w.renderer.(NativeHandler)
+	if !ok {
+		return false
+	}
+	handled := false
+	w.guard(PhaseNative, func() error {
+		handled = nh.HandleNative(m)
+		return nil
+	})
+	return handled
+}
@@ -0,0 +1,137 @@
+// +build !windows
+
+package gui
+
+import (
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+)
+
+// nativeTestDriver is a headless driver whose keys are native messages.
+type nativeTestDriver struct {
+	headlessDriver
+	native func(m *NativeMessage) bool
+
+	mu   sync.Mutex
+	keys []Key
+}
+
+func (d *nativeTestDriver) setNative(native func(m *NativeMessage) bool) {
+	d.native = native
+}
+
+// press queues a native message of key.
+func (d *nativeTestDriver) press(key Key) {
+	d.mu.Lock()
+	d.keys = append(d.keys, key)
+	d.mu.Unlock()
+	d.wake()
+}
+
+func (d *nativeTestDriver) poll(timeout time.Duration) ([]interface{}, error) {
+	d.mu.Lock()
+	pending := len(d.keys) != 0
+	d.mu.Unlock()
+	if pending {
+		timeout = 0
+	}
+	events, err := d.headlessDriver.poll(timeout)
+
+	d.mu.Lock()
+	keys := d.keys
+	d.keys = nil
+	d.mu.Unlock()
+	for _, key := range keys {
+		m := &NativeMessage{Name: "key", Opcode: uint16(key)}
+		if d.native != nil && d.native(m) {
+			continue
+		}
+		events = append(events, &KeyEvent{Key: key, Down: true})
+	}
+	return events, err
+}
+
+// nativeRenderer handles the native messages of a key.
+type nativeRenderer struct {
+	*testRenderer
+	handled  Key
+	messages chan NativeMessage
+}
+
+func (r *nativeRenderer) HandleNative(m *NativeMessage) bool {
+	r.messages <- *m
+	return m.Opcode == uint16(r.handled)
+}
+
+// startNativeWindow runs a window of r on a nativeTestDriver.
+func startNativeWindow(t *testing.T, r Renderer) *nativeTestDriver {
+	d := &nativeTestDriver{}
+	registerTestBackend(t, &backend{
+		name:      "native",
+		explicit:  true,
+		probe:     func() error { return nil },
+		newDriver: func() (driver, error) { return d, nil },
+	})
+	app := NewApplication(WithBackend("native"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	errc := app.Loop("native", 8, 8, r)
+	t.Cleanup(func() {
+		app.Quit(0)
+		if err := <-errc; err != nil {
+			t.Errorf("Loop: %v", err)
+		}
+		app.Deinit()
+	})
+	return d
+}
+
+func isKey(e Event) bool {
+	_, ok := e.(*KeyEvent)
+	return ok
+}
+
+func TestNativeHandler(t *testing.T) {
+	r := &nativeRenderer{
+		testRenderer: newTestRenderer(color.RGBA{}),
+		handled:      KeyA,
+		messages:     make(chan NativeMessage, 4),
+	}
+	d := startNativeWindow(t, r)
+	<-r.window
+
+	// the handled key is not dispatched, the next one is
+	d.press(KeyA)
+	d.press(KeyB)
+	for _, want := range []Key{KeyA, KeyB} {
+		select {
+		case m := <-r.messages:
+			if m.Name != "key" || m.Opcode != uint16(want) {
+				t.Errorf("message %+v, want the key %v", m, want)
+			}
+		case <-testTimeout():
+			t.Fatalf("the message of %v was not seen", want)
+		}
+	}
+	if k := r.nextEvent(t, isKey).(*KeyEvent); k.Key != KeyB {
+		t.Errorf("dispatched %v, want only %v", k.Key, KeyB)
+	}
+}
+
+// TestNativeWithoutHandler checks that the driver is not hooked for a
+// renderer without HandleNative.
+func TestNativeWithoutHandler(t *testing.T) {
+	r := newTestRenderer(color.RGBA{})
+	d := startNativeWindow(t, r)
+	<-r.window
+	if d.native != nil {
+		t.Error("the driver is hooked")
+	}
+	d.press(KeyA)
+	if k := r.nextEvent(t, isKey).(*KeyEvent); k.Key != KeyA {
+		t.Errorf("dispatched %v, want %v", k.Key, KeyA)
+	}
+}
@@ -0,0 +1,18 @@
+package gui
+
+import "image"
+
+// NotifyAction is a button of a desktop notification.
+type NotifyAction struct {
+	Label string
+	Func  func()
+}
+
+// Notify shows a transient desktop notification.
+//
+// Action callbacks run on a goroutine owned by the library. Clicking the
+// notification invokes the first action. Windows shows a balloon without
+// buttons, so it returns ErrNotSupported for more than one action.
+func Notify(title, body string, icon image.Image, actions []NotifyAction) error {
+	return notify(title, body, icon, actions)
+}
@@ -0,0 +1,288 @@
+// +build !windows
+
+package gui
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/ysh86/gui/internal/dbus"
+)
+
+const (
+	notifyName  = "org.freedesktop.Notifications"
+	notifyPath  = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notifyIface = "org.freedesktop.Notifications"
+)
+
+// notifier talks org.freedesktop.Notifications and dispatches action signals.
+type notifier struct {
+	conn *dbus.Conn
+
+	mu      sync.Mutex
+	actions map[uint32]map[string]func()
+	closed  map[uint32]func(reason uint32)
+}
+
+// notification is a notification with the parameters of Notify which
+// Notify does not expose.
+type notification struct {
+	title, body string
+	appIcon     string // icon name
+	icon        image.Image
+	actions     []NotifyAction
+	funcs       map[string]func() // by action key
+	closed      func(reason uint32)
+	timeout     int32 // -1 for the server default, 0 for never
+}
+
+var (
+	notifierMu sync.Mutex
+	notifierOn *notifier
+)
+
+func notify(title, body string, icon image.Image, actions []NotifyAction) error {
+	funcs := make(map[string]func())
+	for i, a := range actions {
+		funcs[strconv.Itoa(i)] = a.Func
+	}
+	if len(actions) > 0 {
+		// clicking the notification itself
+		funcs["default"] = actions[0].Func
+	}
+
+	n, err := sessionNotifier()
+	if err != nil {
+		return err
+	}
+	_, err = n.notify(title, body, icon, actions, funcs)
+	return err
+}
+
+// sessionNotifier returns the notifier of the session bus, reconnecting if it was lost.
+func sessionNotifier() (*notifier, error) {
+	notifierMu.Lock()
+	defer notifierMu.Unlock()
+
+	if notifierOn != nil {
+		return notifierOn, nil
+	}
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+	n, err := newNotifier(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	notifierOn = n
+	return n, nil
+}
+
+// newNotifier subscribes to the notification signals on conn, which may be any bus including a fake one.
+func newNotifier(conn *dbus.Conn) (*notifier, error) {
+	n := &notifier{
+		conn:    conn,
+		actions: make(map[uint32]map[string]func()),
+		closed:  make(map[uint32]func(reason uint32)),
+	}
+
+	signals := make(chan *dbus.Message, 16)
+	conn.Signal(signals)
+	if err := conn.AddMatch("type='signal',interface='" + notifyIface + "'"); err != nil {
+		return nil, err
+	}
+	go n.signalLoop(signals)
+
+	return n, nil
+}
+
+func (n *notifier) signalLoop(signals <-chan *dbus.Message) {
+	for m := range signals {
+		if m.Interface != notifyIface || len(m.Body) < 2 {
+			continue
+		}
+		id, _ := m.Body[0].(uint32)
+
+		switch m.Member {
+		case "ActionInvoked":
+			key, _ := m.Body[1].(string)
+			n.mu.Lock()
+			f := n.actions[id][key]
+			n.mu.Unlock()
+			if f != nil {
+				f()
+			}
+		case "NotificationClosed":
+			reason, _ := m.Body[1].(uint32)
+			n.mu.Lock()
+			f := n.closed[id]
+			delete(n.actions, id)
+			delete(n.closed, id)
+			n.mu.Unlock()
+			if f != nil {
+				f(reason)
+			}
+		}
+	}
+
+	// the connection is lost, the next Notify reconnects
+	notifierMu.Lock()
+	if notifierOn == n {
+		notifierOn = nil
+	}
+	notifierMu.Unlock()
+
+	// reason 0 is the lost connection
+	n.mu.Lock()
+	closed := n.closed
+	n.closed = make(map[uint32]func(reason uint32))
+	n.mu.Unlock()
+	for _, f := range closed {
+		f(0)
+	}
+}
+
+// notify shows a notification and registers funcs by action key.
+func (n *notifier) notify(title, body string, icon image.Image, actions []NotifyAction, funcs map[string]func()) (uint32, error) {
+	return n.show(&notification{
+		title:   title,
+		body:    body,
+		icon:    icon,
+		actions: actions,
+		funcs:   funcs,
+		timeout: -1,
+	})
+}
+
+// show shows nt and registers its functions.
+func (n *notifier) show(nt *notification) (uint32, error) {
+	// alternating keys and labels
+	keys := []string{}
+	for i, a := range nt.actions {
+		keys = append(keys, strconv.Itoa(i), a.Label)
+	}
+	if _, ok := nt.funcs["default"]; ok {
+		keys = append(keys, "default", "")
+	}
+
+	hints := map[string]dbus.Variant{}
+	if nt.icon != nil {
+		src := toNRGBA(nt.icon)
+		hints["image-data"] = dbus.Variant{Sig: "(iiibiiay)", Value: []interface{}{
+			int32(src.Rect.Dx()),
+			int32(src.Rect.Dy()),
+			int32(src.Stride),
+			true, // has alpha
+			int32(8),
+			int32(4),
+			src.Pix,
+		}}
+	}
+
+	// hold the lock, an action may be invoked before the reply arrives
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	reply, err := n.conn.Call(notifyName, notifyPath, notifyIface, "Notify", "susssasa{sv}i",
+		filepath.Base(os.Args[0]), uint32(0), nt.appIcon, nt.title, nt.body, keys, hints, nt.timeout)
+	if err != nil {
+		return 0, fmt.Errorf("Notify: %v", err)
+	}
+	id, _ := reply.Body[0].(uint32)
+	if len(nt.funcs) > 0 {
+		n.actions[id] = nt.funcs
+	}
+	if nt.closed != nil {
+		n.closed[id] = nt.closed
+	}
+	return id, nil
+}
+
+// buttonResults are the buttons of a message box in order.
+var buttonResults = map[DialogButtons][]DialogResult{
+	ButtonsOK:          {ResultOK},
+	ButtonsOKCancel:    {ResultOK, ResultCancel},
+	ButtonsYesNo:       {ResultYes, ResultNo},
+	ButtonsYesNoCancel: {ResultYes, ResultNo, ResultCancel},
+	ButtonsRetryCancel: {ResultRetry, ResultCancel},
+}
+
+var resultLabels = map[DialogResult]string{
+	ResultOK:     "OK",
+	ResultCancel: "Cancel",
+	ResultYes:    "Yes",
+	ResultNo:     "No",
+	ResultRetry:  "Retry",
+}
+
+// dialogIconNames are the icon names of the freedesktop icon theme.
+var dialogIconNames = map[DialogIcon]string{
+	IconInfo:     "dialog-information",
+	IconWarning:  "dialog-warning",
+	IconError:    "dialog-error",
+	IconQuestion: "dialog-question",
+}
+
+// messageBox shows a notification which does not expire with the buttons
+// as actions and waits for one of them. Closing it is Cancel, or OK if it
+// is the only button.
+func (n *notifier) messageBox(title, text string, buttons DialogButtons, icon DialogIcon) (DialogResult, error) {
+	results, ok := buttonResults[buttons]
+	if !ok {
+		return ResultNone, fmt.Errorf("MessageBox: unknown buttons %d", buttons)
+	}
+	dismissed := ResultNone
+	for _, r := range results {
+		if r == ResultCancel || len(results) == 1 {
+			dismissed = r
+		}
+	}
+
+	// the first of an action and the closing wins
+	done := make(chan DialogResult, 1)
+	send := func(r DialogResult) {
+		select {
+		case done <- r:
+		default:
+		}
+	}
+	nt := &notification{
+		title:   title,
+		body:    text,
+		appIcon: dialogIconNames[icon],
+		funcs:   make(map[string]func()),
+		timeout: 0,
+	}
+	for i, r := range results {
+		r := r
+		nt.actions = append(nt.actions, NotifyAction{Label: resultLabels[r]})
+		nt.funcs[strconv.Itoa(i)] = func() { send(r) }
+	}
+	lost := make(chan struct{}, 1)
+	nt.closed = func(reason uint32) {
+		if reason == 0 {
+			lost <- struct{}{}
+			return
+		}
+		send(dismissed)
+	}
+
+	if _, err := n.show(nt); err != nil {
+		return ResultNone, fmt.Errorf("MessageBox: %v", err)
+	}
+	select {
+	case r := <-done:
+		if r == ResultNone {
+			return ResultNone, ErrCanceled
+		}
+		return r, nil
+	case <-lost:
+		return ResultNone, fmt.Errorf("MessageBox: %v", dbus.ErrClosed)
+	}
+}
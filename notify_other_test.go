@@ -0,0 +1,90 @@
+// +build !windows
+
+package gui
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	addr := startBus(t)
+	server := newFakeNotifications(t, addr)
+
+	invoked := make(chan string, 4)
+	icon := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	icon.Set(1, 2, color.RGBA{0xff, 0, 0, 0xff})
+	err := Notify("title", "body", icon, []NotifyAction{
+		{Label: "Open", Func: func() { invoked <- "Open" }},
+		{Label: "Later", Func: func() { invoked <- "Later" }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := server.nextCall(t)
+	if c.summary != "title" || c.body != "body" || c.timeout != -1 {
+		t.Errorf("Notify(%q, %q, timeout %d)", c.summary, c.body, c.timeout)
+	}
+	want := []string{"0", "Open", "1", "Later", "default", ""}
+	if len(c.actions) != len(want) {
+		t.Fatalf("actions = %q, want %q", c.actions, want)
+	}
+	for i := range want {
+		if c.actions[i] != want[i] {
+			t.Errorf("action %d = %q, want %q", i, c.actions[i], want[i])
+		}
+	}
+	data, ok := c.hints["image-data"].([]interface{})
+	if !ok || len(data) != 7 || data[0] != int32(2) || data[1] != int32(3) {
+		t.Errorf("image-data = %v", c.hints["image-data"])
+	} else if pix := data[6].([]byte); len(pix) != 2*3*4 || pix[(2*2+1)*4] != 0xff {
+		t.Errorf("image-data pixels = %v", pix)
+	}
+
+	// the buttons and clicking the notification
+	for _, a := range []struct{ key, want string }{{"1", "Later"}, {"default", "Open"}} {
+		server.invoke(t, c.id, a.key)
+		select {
+		case got := <-invoked:
+			if got != a.want {
+				t.Errorf("%s invoked %s, want %s", a.key, got, a.want)
+			}
+		case <-testTimeout():
+			t.Fatalf("%s was not invoked", a.key)
+		}
+	}
+
+	// the actions are gone with the notification
+	server.close(t, c.id, 2)
+	server.invoke(t, c.id, "0")
+	if err := Notify("again", "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	server.nextCall(t)
+	select {
+	case got := <-invoked:
+		t.Errorf("%s invoked after the notification was closed", got)
+	default:
+	}
+}
+
+func TestNotifyReconnect(t *testing.T) {
+	addr := startBus(t)
+	server := newFakeNotifications(t, addr)
+
+	if err := Notify("one", "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	server.nextCall(t)
+
+	// a lost connection is dialed again by the next notification
+	resetNotifier()
+	if err := Notify("two", "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c := server.nextCall(t); c.summary != "two" {
+		t.Errorf("summary = %q", c.summary)
+	}
+}
@@ -0,0 +1,33 @@
+package gui
+
+import "image"
+
+// notify shows a balloon of a transient tray icon which goes away with the balloon.
+// Balloons have no buttons, so clicking it invokes the only action.
+func notify(title, body string, icon image.Image, actions []NotifyAction) error {
+	if len(actions) > 1 {
+		return ErrNotSupported
+	}
+
+	opts := &TrayOptions{
+		Icon:    icon,
+		Tooltip: title,
+		Handler: func(e Event) {
+			if te, ok := e.(*TrayEvent); ok && te.Kind == TrayBalloonClick {
+				if len(actions) > 0 && actions[0].Func != nil {
+					go actions[0].Func()
+				}
+			}
+		},
+	}
+
+	t, err := newTrayIconWindows(opts, true)
+	if err != nil {
+		return err
+	}
+	if err := t.ShowBalloon(title, body); err != nil {
+		t.Close()
+		return err
+	}
+	return nil
+}
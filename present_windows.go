@@ -0,0 +1,113 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// dibPresenter presents a window drawn by SoftwareRenderer. The renderer
+// draws into an image which is converted to a DIB section and blitted to
+// the window in WM_PAINT.
+type dibPresenter struct {
+	image  *image.RGBA
+	dc     windows.Handle // memory DC holding the DIB section
+	bitmap windows.Handle
+	old    windows.Handle // bitmap of dc before the DIB section
+	bits   []byte         // BGRX, top-down
+	res    *resources
+}
+
+// resize recreates the image and the DIB section for the client size.
+// Nothing is presented while the size is zero, e.g. minimized.
+func (p *dibPresenter) resize(width, height int32) error {
+	if p.image != nil && p.image.Rect.Dx() == int(width) && p.image.Rect.Dy() == int(height) {
+		return nil
+	}
+	p.close()
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+
+	dc, err := CreateCompatibleDC(0)
+	if err != nil {
+		return fmt.Errorf("CreateCompatibleDC: %v", err)
+	}
+	info := &BitmapInfoHeader{
+		Width:       width,
+		Height:      -height,
+		Planes:      1,
+		BitCount:    32,
+		Compression: BI_RGB,
+	}
+	info.Size = uint32(unsafe.Sizeof(*info))
+	var bits unsafe.Pointer
+	bitmap, err := CreateDIBSection(dc, info, DIB_RGB_COLORS, &bits, 0, 0)
+	if err != nil {
+		DeleteDC(dc)
+		return fmt.Errorf("CreateDIBSection: %v", err)
+	}
+
+	size := int(width) * int(height) * 4
+	p.dc = dc
+	p.bitmap = bitmap
+	p.old = SelectObject(dc, bitmap)
+	p.bits = (*[1 << 30]byte)(bits)[:size:size]
+	p.image = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	p.res.acquire(resDIB)
+	return nil
+}
+
+func (p *dibPresenter) close() {
+	if p.dc != 0 {
+		SelectObject(p.dc, p.old)
+		DeleteObject(p.bitmap)
+		DeleteDC(p.dc)
+		p.res.release(resDIB)
+	}
+	p.dc, p.bitmap, p.old = 0, 0, 0
+	p.bits = nil
+	p.image = nil
+}
+
+// framebuffer returns the image for SoftwareRenderer, or nil.
+func (p *dibPresenter) framebuffer() *image.RGBA {
+	return p.image
+}
+
+// present copies region of the image to the window DC from BeginPaint.
+func (p *dibPresenter) present(dc windows.Handle, region []image.Rectangle) error {
+	if p.image == nil {
+		return nil
+	}
+
+	// GDI may still use the bits
+	GdiFlush()
+	for _, r := range region {
+		r = r.Intersect(p.image.Rect)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i, end := p.image.PixOffset(r.Min.X, y), p.image.PixOffset(r.Max.X, y)
+			src, dst := p.image.Pix[i:end], p.bits[i:end]
+			for j := 0; j < len(src); j += 4 {
+				dst[j+0] = src[j+2]
+				dst[j+1] = src[j+1]
+				dst[j+2] = src[j+0]
+				dst[j+3] = 0xff
+			}
+		}
+	}
+
+	for _, r := range region {
+		r = r.Intersect(p.image.Rect)
+		if r.Empty() {
+			continue
+		}
+		if err := BitBlt(dc, int32(r.Min.X), int32(r.Min.Y), int32(r.Dx()), int32(r.Dy()),
+			p.dc, int32(r.Min.X), int32(r.Min.Y), SRCCOPY); err != nil {
+			return fmt.Errorf("BitBlt: %v", err)
+		}
+	}
+	return nil
+}
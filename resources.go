@@ -0,0 +1,98 @@
+package gui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kinds of tracked resources
+const (
+	resClass     = "window class"
+	resWindow    = "window"
+	resMenu      = "menu"
+	resDIB       = "DIB section"
+	resGLContext = "GL context"
+	resPoller    = "fd poller"
+	resDrain     = "drain goroutine"
+	resDisplay   = "display connection"
+	resFbdev     = "framebuffer device"
+	resInput     = "input device"
+	resTerminal  = "terminal"
+	resListener  = "listener"
+)
+
+// resources counts the native resources of an application by kind, so
+// that CheckLeaks finds the ones which are not released. A nil resources
+// counts nothing, e.g. for a driver outside of Loop.
+type resources struct {
+	mu   sync.Mutex
+	live map[string]int
+}
+
+func (r *resources) acquire(kind string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.live == nil {
+		r.live = make(map[string]int)
+	}
+	r.live[kind]++
+}
+
+func (r *resources) release(kind string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.live[kind]--; r.live[kind] <= 0 {
+		delete(r.live, kind)
+	}
+}
+
+// LeakError is returned by CheckLeaks with the numbers of native resources
+// which are still alive, by kind.
+type LeakError struct {
+	Resources map[string]int
+}
+
+func (e *LeakError) Error() string {
+	kinds := make([]string, 0, len(e.Resources))
+	for kind := range e.Resources {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for i, kind := range kinds {
+		kinds[i] = fmt.Sprintf("%s=%d", kind, e.Resources[kind])
+	}
+	return "gui: leaked " + strings.Join(kinds, ", ")
+}
+
+// CheckLeaks returns *LeakError if native resources of app are still
+// alive, e.g. in a test after Deinit. The resources are the window class,
+// windows, menu bars, DIB sections, GL contexts, the goroutines of
+// Window.Watch and Window.Drain, and the connections and devices of the
+// windows of each backend: the Wayland connection, the framebuffer, input
+// and terminal devices of fbdev and tui, and the listeners of vnc and web.
+// Shared icons and cursors are not tracked, nor are tray icons and the
+// D-Bus connection of notifications, which belong to no application.
+func CheckLeaks(app Application) error {
+	a, ok := app.(*application)
+	if !ok {
+		return ErrNotSupported
+	}
+	a.res.mu.Lock()
+	defer a.res.mu.Unlock()
+	if len(a.res.live) == 0 {
+		return nil
+	}
+	live := make(map[string]int, len(a.res.live))
+	for kind, n := range a.res.live {
+		live[kind] = n
+	}
+	return &LeakError{Resources: live}
+}
@@ -0,0 +1,98 @@
+// +build !windows
+
+package gui
+
+import (
+	"errors"
+	"image/color"
+	"sync"
+	"testing"
+)
+
+// TestLifecycle runs a window twice per backend, from Init to Deinit, and
+// checks that the resources are tracked while it runs and then released.
+func TestLifecycle(t *testing.T) {
+	setenv(t, vncAddrEnv, "127.0.0.1:0")
+	setenv(t, webAddrEnv, "127.0.0.1:0")
+	for _, test := range []struct {
+		backend string
+		live    map[string]int
+	}{
+		{"headless", map[string]int{resWindow: 1}},
+		{"vnc", map[string]int{resWindow: 1, resListener: 1}},
+		{"web", map[string]int{resWindow: 1, resListener: 1}},
+	} {
+		app := NewApplication(WithBackend(test.backend))
+		for round := 0; round < 2; round++ {
+			if err := app.Init(); err != nil {
+				t.Fatalf("%s: Init: %v", test.backend, err)
+			}
+			if got := app.Backend(); got != test.backend {
+				t.Errorf("%s: Backend = %q", test.backend, got)
+			}
+			r := newTestRenderer(color.RGBA{})
+			errc := app.Loop("lifecycle", 8, 8, r)
+			r.nextFrame(t)
+
+			var leaks *LeakError
+			if err := CheckLeaks(app); !errors.As(err, &leaks) || !equalCounts(leaks.Resources, test.live) {
+				t.Errorf("%s: CheckLeaks while running = %v, want %v", test.backend, err, test.live)
+			}
+
+			app.Quit(0)
+			if err := <-errc; err != nil {
+				t.Errorf("%s: Loop: %v", test.backend, err)
+			}
+			app.Deinit()
+			if err := CheckLeaks(app); err != nil {
+				t.Errorf("%s: round %d: %v", test.backend, round, err)
+			}
+			if got := app.Backend(); got != "" {
+				t.Errorf("%s: Backend after Deinit = %q", test.backend, got)
+			}
+		}
+	}
+}
+
+func equalCounts(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, n := range a {
+		if b[k] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// TestBackendDuringDeinit reads the backend while another goroutine
+// initializes and releases it, for the race detector.
+func TestBackendDuringDeinit(t *testing.T) {
+	app := NewApplication(WithBackend("headless"))
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if name := app.Backend(); name != "" && name != "headless" {
+				t.Errorf("Backend = %q", name)
+			}
+			app.Supports(CapMultiWindow)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if err := app.Init(); err != nil {
+			t.Fatal(err)
+		}
+		app.Deinit()
+	}
+	close(stop)
+	wg.Wait()
+}
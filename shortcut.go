@@ -0,0 +1,253 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Shortcut is a key with modifiers, e.g. "Ctrl+Shift+S".
+type Shortcut struct {
+	Key  Key
+	Mods Modifier
+}
+
+var modifierNames = map[string]Modifier{
+	"shift":   ModShift,
+	"ctrl":    ModCtrl,
+	"control": ModCtrl,
+	"alt":     ModAlt,
+	"option":  ModAlt,
+	"super":   ModSuper,
+	"win":     ModSuper,
+	"cmd":     ModSuper,
+	"meta":    ModSuper,
+}
+
+var keyAliases = map[string]Key{
+	"return": KeyEnter,
+	"escape": KeyEscape,
+	"ins":    KeyInsert,
+	"del":    KeyDelete,
+	"pgup":   KeyPageUp,
+	"pgdn":   KeyPageDown,
+	"minus":  KeyMinus,
+	"equal":  KeyEqual,
+	"plus":   KeyEqual,
+}
+
+// ParseShortcut parses a shortcut like "Ctrl+Shift+S" or "Alt+F4".
+// Names are case insensitive.
+func ParseShortcut(s string) (Shortcut, error) {
+	var sc Shortcut
+	parts := strings.Split(s, "+")
+	// "Ctrl++" ends with an empty part for the plus key
+	if len(parts) > 1 && parts[len(parts)-1] == "" && parts[len(parts)-2] == "" {
+		parts = append(parts[:len(parts)-2], "plus")
+	}
+
+	for i, p := range parts {
+		name := strings.ToLower(strings.TrimSpace(p))
+		if i < len(parts)-1 {
+			mod, ok := modifierNames[name]
+			if !ok {
+				return Shortcut{}, fmt.Errorf("shortcut %q: unknown modifier %q", s, p)
+			}
+			if sc.Mods&mod != 0 {
+				return Shortcut{}, fmt.Errorf("shortcut %q: duplicate modifier %q", s, p)
+			}
+			sc.Mods |= mod
+			continue
+		}
+
+		sc.Key = parseKey(name)
+		if sc.Key == KeyUnknown {
+			return Shortcut{}, fmt.Errorf("shortcut %q: unknown key %q", s, p)
+		}
+	}
+
+	return sc, nil
+}
+
+func parseKey(name string) Key {
+	if k, ok := keyAliases[name]; ok {
+		return k
+	}
+	for k := KeyA; k <= KeySlash; k++ {
+		if strings.ToLower(k.String()) == name {
+			return k
+		}
+	}
+	return KeyUnknown
+}
+
+func (s Shortcut) String() string {
+	var parts []string
+	if s.Mods&ModCtrl != 0 {
+		parts = append(parts, "Ctrl")
+	}
+	if s.Mods&ModAlt != 0 {
+		parts = append(parts, "Alt")
+	}
+	if s.Mods&ModShift != 0 {
+		parts = append(parts, "Shift")
+	}
+	if s.Mods&ModSuper != 0 {
+		parts = append(parts, "Super")
+	}
+	return strings.Join(append(parts, s.Key.String()), "+")
+}
+
+// ConflictError is returned when a shortcut is already bound in the same scope.
+type ConflictError struct {
+	Shortcut Shortcut
+	ID       int // already bound command ID
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("shortcut %s is already bound to command %d", e.Shortcut, e.ID)
+}
+
+// Shortcuts is a table of shortcuts to command IDs.
+//
+// The application and every window have their own scope.
+// A matched shortcut is delivered as a CommandEvent instead of a KeyEvent;
+// window shortcuts take precedence over application shortcuts.
+// The key up of a matched key is not delivered, and neither are its
+// auto-repeated key downs unless the shortcut is added with AddRepeating.
+type Shortcuts struct {
+	mu    sync.Mutex
+	table map[Shortcut]binding
+}
+
+// binding is the command of a shortcut.
+type binding struct {
+	id     int
+	repeat bool // fired by auto-repeated key downs
+}
+
+// NewShortcuts creates an empty shortcut table.
+func NewShortcuts() *Shortcuts {
+	return &Shortcuts{
+		table: make(map[Shortcut]binding),
+	}
+}
+
+// Add binds spec to the command id.
+// It returns a *ConflictError if spec is already bound in s.
+func (s *Shortcuts) Add(spec string, id int) error {
+	return s.add(spec, binding{id: id})
+}
+
+// AddRepeating binds spec to the command id like Add, and the command is
+// also delivered for each auto-repeated key down while the key is held.
+func (s *Shortcuts) AddRepeating(spec string, id int) error {
+	return s.add(spec, binding{id: id, repeat: true})
+}
+
+func (s *Shortcuts) add(spec string, b binding) error {
+	sc, err := ParseShortcut(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.table[sc]; ok {
+		return &ConflictError{Shortcut: sc, ID: existing.id}
+	}
+	s.table[sc] = b
+	return nil
+}
+
+// Remove unbinds spec.
+func (s *Shortcuts) Remove(spec string) error {
+	sc, err := ParseShortcut(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.table, sc)
+	s.mu.Unlock()
+	return nil
+}
+
+// Lookup returns the command ID bound to key with mods.
+func (s *Shortcuts) Lookup(key Key, mods Modifier) (int, bool) {
+	b, ok := s.lookup(key, mods)
+	return b.id, ok
+}
+
+func (s *Shortcuts) lookup(key Key, mods Modifier) (binding, bool) {
+	if s == nil {
+		return binding{}, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.table[Shortcut{Key: key, Mods: mods}]
+	return b, ok
+}
+
+// Conflicts returns the shortcuts bound in both s and other, e.g. a window
+// scope shadowing the application scope.
+func (s *Shortcuts) Conflicts(other *Shortcuts) []Shortcut {
+	s.mu.Lock()
+	var mine []Shortcut
+	for sc := range s.table {
+		mine = append(mine, sc)
+	}
+	s.mu.Unlock()
+
+	var conflicts []Shortcut
+	for _, sc := range mine {
+		if _, ok := other.Lookup(sc.Key, sc.Mods); ok {
+			conflicts = append(conflicts, sc)
+		}
+	}
+	return conflicts
+}
+
+// resolveShortcut looks key up in scopes in order.
+func resolveShortcut(key Key, mods Modifier, scopes ...*Shortcuts) (binding, bool) {
+	switch key {
+	case KeyUnknown, KeyShift, KeyControl, KeyAlt, KeySuper:
+		return binding{}, false
+	}
+	for _, s := range scopes {
+		if b, ok := s.lookup(key, mods); ok {
+			return b, true
+		}
+	}
+	return binding{}, false
+}
+
+// shortcutKeys are the keys of a window which are held down after their
+// key down matched a shortcut.
+type shortcutKeys map[Key]bool
+
+// match resolves a key event of a window. It returns the command to fire
+// if fire is set, and reports whether the event is taken by a shortcut
+// instead of being delivered as a KeyEvent.
+func (held shortcutKeys) match(key Key, mods Modifier, down bool, repeat bool, scopes ...*Shortcuts) (id int, fire bool, taken bool) {
+	if !down {
+		// the key up of a matched key down
+		taken = held[key]
+		delete(held, key)
+		return 0, false, taken
+	}
+	b, ok := resolveShortcut(key, mods, scopes...)
+	if !ok {
+		return 0, false, false
+	}
+	if repeat && !b.repeat {
+		// repeats of a key which was not matched, e.g. if the modifier
+		// was pressed after it, are delivered
+		return 0, false, held[key]
+	}
+	held[key] = true
+	return b.id, true, true
+}
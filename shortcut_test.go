@@ -0,0 +1,76 @@
+package gui
+
+import "testing"
+
+func TestParseShortcut(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Shortcut
+	}{
+		{"Ctrl+S", Shortcut{KeyS, ModCtrl}},
+		{"ctrl+shift+s", Shortcut{KeyS, ModCtrl | ModShift}},
+		{"Alt+F4", Shortcut{KeyF4, ModAlt}},
+		{"Ctrl++", Shortcut{KeyEqual, ModCtrl}},
+	}
+	for _, tt := range tests {
+		got, err := ParseShortcut(tt.spec)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseShortcut(%q) = %v, %v, want %v", tt.spec, got, err, tt.want)
+		}
+	}
+	for _, spec := range []string{"Hyper+S", "Ctrl+Ctrl+S", "Ctrl+Nope"} {
+		if _, err := ParseShortcut(spec); err == nil {
+			t.Errorf("ParseShortcut(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestShortcutRepeat(t *testing.T) {
+	app, win := NewShortcuts(), NewShortcuts()
+	if err := app.Add("Ctrl+S", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := win.AddRepeating("Ctrl+Equal", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := win.Add("Ctrl+Plus", 3); err == nil {
+		t.Error("Add of a bound shortcut succeeded")
+	}
+
+	type result struct {
+		id          int
+		fire, taken bool
+	}
+	held := make(shortcutKeys)
+	steps := []struct {
+		key          Key
+		mods         Modifier
+		down, repeat bool
+		want         result
+	}{
+		// repeats of Ctrl+S are taken without firing, the key up is taken
+		{KeyS, ModCtrl, true, false, result{1, true, true}},
+		{KeyS, ModCtrl, true, true, result{0, false, true}},
+		{KeyS, ModCtrl, true, true, result{0, false, true}},
+		{KeyS, 0, false, false, result{0, false, true}},
+		{KeyS, 0, false, false, result{0, false, false}},
+
+		// repeating shortcuts fire on each repeat
+		{KeyEqual, ModCtrl, true, false, result{2, true, true}},
+		{KeyEqual, ModCtrl, true, true, result{2, true, true}},
+		{KeyEqual, ModCtrl, false, false, result{0, false, true}},
+
+		// S was held before Ctrl, so its repeats are keys
+		{KeyS, 0, true, false, result{0, false, false}},
+		{KeyS, ModCtrl, true, true, result{0, false, false}},
+		{KeyS, ModCtrl, false, false, result{0, false, false}},
+
+		{KeyControl, ModCtrl, true, false, result{0, false, false}},
+	}
+	for i, s := range steps {
+		id, fire, taken := held.match(s.key, s.mods, s.down, s.repeat, win, app)
+		if got := (result{id, fire, taken}); got != s.want {
+			t.Errorf("%d: %v down=%v repeat=%v: %+v, want %+v", i, s.key, s.down, s.repeat, got, s.want)
+		}
+	}
+}
@@ -3,19 +3,159 @@
 package gui
 
 import (
+	"fmt"
+	"image"
 	"log"
+	"math"
 	"os"
+	"reflect"
 	"runtime"
+	"sync"
+	"time"
 )
 
 type application struct {
 	logger *log.Logger
-	// ...
+	opts   *options
+
+	shortcuts *Shortcuts
+
+	mu       sync.Mutex
+	backend  *backend         // selected by Init
+	windows  map[*window]bool // open windows to wake
+	quitting bool
+	quitCode int
+	closing  bool // by Deinit
+
+	running sync.WaitGroup // loops and their goroutines
+	res     resources
+}
+
+// window is a window of a driver and its renderer.
+type window struct {
+	app      *application
+	driver   driver
+	name     string
+	renderer Renderer
+	handler  EventHandler
+	size     image.Rectangle
+	damage   damage
+	pointer  image.Point // last pointer position
+	// eventTime is the time of the driver event being handled
+	eventTime time.Time
+	// err stops the loop, see guard
+	err error
+
+	timers   timerQueue
+	timerSeq uint64
+	watches  fdWatches
+	stats    loopStats
+
+	// functions of post, see runPosted
+	postMu sync.Mutex
+	posted []func()
+	closed bool
+	donec  chan struct{}
+
+	menu      *Menu
+	menus     drawnMenus
+	shortcuts *Shortcuts
+	held      shortcutKeys
+}
+
+// driver is a display backend. Each window has its own driver and all
+// methods are called on the loop thread.
+type driver interface {
+	// open creates the window with the size in pixels.
+	open(name string, width int32, height int32) error
+	close()
+
+	// handle is passed to Renderer.Draw.
+	handle() uintptr
+
+	// poll returns the pending events: sizeEvent, exposeEvent, closeEvent,
+	// *KeyEvent and *MouseEvent without Window.
+	// If there are none, it waits up to timeout, or forever if timeout is negative.
+	poll(timeout time.Duration) ([]interface{}, error)
+
+	// framebuffer returns the image for SoftwareRenderer, or nil.
+	framebuffer() *image.RGBA
+	// present shows the contents of the window. Only region has changed.
+	present(region []image.Rectangle) error
+
+	// wake makes a waiting poll return. It is called on other goroutines
+	// until close.
+	wake()
+}
+
+// sizeEvent is sent by a driver when the window has been resized to pixels.
+type sizeEvent struct {
+	width, height int32
+}
+
+// exposeEvent is sent by a driver when the window must be redrawn.
+type exposeEvent struct{}
+
+// closeEvent is sent by a driver when the user closes the window.
+type closeEvent struct{}
+
+// watchEvent is sent by a driver when a watched file descriptor is ready.
+type watchEvent struct {
+	watcher *Watcher
+	ready   Readiness
+	invalid bool // the file descriptor is not open
+}
+
+// fireWatchEvent fires the watcher of e, and stops it if its file
+// descriptor is not open: poll(2) would report it again at once.
+func (w *window) fireWatchEvent(e watchEvent) {
+	w.fireWatch(e.watcher, e.ready)
+	if e.invalid {
+		e.watcher.Stop()
+	}
+}
+
+// tracingDriver is a driver which traces its native events with trace.
+type tracingDriver interface {
+	setTrace(trace func(category TraceCategory, name string, detail string))
+}
+
+// watchDriver is a driver which polls the file descriptors of ws with its
+// own and sends watchEvent.
+type watchDriver interface {
+	setWatches(ws *fdWatches)
+}
+
+// resourceDriver is a driver which counts its native resources in res.
+type resourceDriver interface {
+	setResources(res *resources)
+}
+
+// signalDriver is a driver which restores the console on SIGINT and
+// SIGTERM if WithRestoreOnSignal is set.
+type signalDriver interface {
+	setRestoreOnSignal()
+}
+
+// nativeDriver is a driver which passes its native events to native first.
+// They are skipped if it returns true.
+type nativeDriver interface {
+	setNative(native func(m *NativeMessage) bool)
 }
 
 // NewApplication creates a new GUI application.
-func NewApplication() Application {
-	return &application{}
+//
+// Init selects the first available backend of WithBackend, GUI_BACKEND or
+// the registered backends in the order "wayland", "x11", "headless".
+// Server backends like "vnc" and "web", the terminal backend "tui" and the
+// kiosk backend "fbdev", which takes over the console, are used only if
+// named.
+func NewApplication(opts ...Option) Application {
+	return &application{
+		opts:      newOptions(opts),
+		shortcuts: NewShortcuts(),
+		windows:   make(map[*window]bool),
+	}
 }
 
 func (a *application) EnableLog() error {
@@ -23,38 +163,464 @@ func (a *application) EnableLog() error {
 	if a.logger != nil {
 		a.logger.Print("start logging")
 	}
+	if a.opts.tracer == nil {
+		a.opts.tracer = NewTextTracer(os.Stderr)
+		a.opts.traceCategories = TraceAll
+	}
 	return nil
 }
 
 func (a *application) Init() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.backend != nil {
+		return nil
+	}
+	b, err := selectBackend(a.opts.backends)
+	if err != nil {
+		return err
+	}
+	a.backend = b
+
+	if a.logger != nil {
+		a.logger.Printf("backend: %s, %v\n", b.name, b.caps)
+	}
 	return nil
 }
 
+// Deinit closes the windows, waits for their loops and releases the
+// backend. It must not be called on a loop thread.
 func (a *application) Deinit() {
-	if a != nil {
-		// nothing to do
+	a.mu.Lock()
+	if a.backend == nil {
+		a.mu.Unlock()
+		return
+	}
+	a.closing = true
+	for w := range a.windows {
+		w.driver.wake()
+	}
+	a.mu.Unlock()
+	a.running.Wait()
+
+	a.mu.Lock()
+	a.closing, a.quitting, a.quitCode = false, false, 0
+	a.backend = nil
+	a.mu.Unlock()
+}
+
+func (a *application) Shortcuts() *Shortcuts {
+	return a.shortcuts
+}
+
+func (a *application) Quit(code int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.quitting {
+		a.quitting, a.quitCode = true, code
+	}
+	for w := range a.windows {
+		w.driver.wake()
+	}
+}
+
+// quit returns the exit code if Quit or Deinit has been called.
+func (a *application) quit() (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.quitCode, a.quitting || a.closing
+}
+
+// currentBackend returns the backend selected by Init, or nil.
+func (a *application) currentBackend() *backend {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.backend
+}
+
+func (a *application) Backend() string {
+	b := a.currentBackend()
+	if b == nil {
+		return ""
 	}
+	return b.name
+}
+
+func (a *application) Supports(caps Capability) bool {
+	b := a.currentBackend()
+	if b == nil {
+		return false
+	}
+	return b.caps&caps == caps
 }
 
 func (a *application) Loop(windowName string, width int32, height int32, renderer Renderer) <-chan error {
 	errc := make(chan error, 1)
 
+	a.running.Add(1)
 	go func() {
+		defer a.running.Done()
+
 		// lock thread for message handling
 		runtime.LockOSThread()
+		deinitThread, err := a.initThread(windowName)
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer deinitThread()
 
-		// create a window
-		// ...
+		// validate Renderer I/F
+		isValid := true
+		raw := reflect.ValueOf(renderer)
+		if !raw.IsValid() || raw.Kind() != reflect.Ptr || raw.IsNil() {
+			isValid = false
+		}
 
-		// message loop
-		for {
-			// drawing...
+		w := &window{
+			app:       a,
+			name:      windowName,
+			shortcuts: NewShortcuts(),
+			held:      make(shortcutKeys),
+			donec:     make(chan struct{}),
+		}
+		if isValid {
+			if err := callRenderer(windowName, PhaseInit, renderer.Init); err != nil {
+				errc <- err
+				return
+			}
+			defer renderer.Deinit()
 
-			// quit
-			errc <- nil
-			break
+			dpiX, dpiY := renderer.Dpi()
+			width = int32(math.Ceil(float64(float32(width) * dpiX / 96.0)))
+			height = int32(math.Ceil(float64(float32(height) * dpiY / 96.0)))
+			w.renderer = renderer
+			w.handler, _ = renderer.(EventHandler)
 		}
+
+		b := a.currentBackend()
+		if b == nil {
+			errc <- ErrNotInitialized
+			return
+		}
+
+		// create a window
+		d, err := b.newDriver()
+		if err != nil {
+			errc <- &WindowError{Window: windowName, Err: err}
+			return
+		}
+		opened := false
+		defer func() {
+			d.close()
+			if opened {
+				a.res.release(resWindow)
+			}
+		}()
+		if td, ok := d.(tracingDriver); ok && a.opts.tracer != nil {
+			td.setTrace(func(category TraceCategory, name string, detail string) {
+				a.opts.trace(&TraceEvent{
+					Backend:  b.name,
+					Window:   windowName,
+					Source:   "dispatch",
+					Category: category,
+					Name:     name,
+					Detail:   detail,
+				})
+			})
+		}
+		if wd, ok := d.(watchDriver); ok {
+			wd.setWatches(&w.watches)
+		}
+		if rd, ok := d.(resourceDriver); ok {
+			rd.setResources(&a.res)
+		}
+		if sd, ok := d.(signalDriver); ok && a.opts.restoreOnSignal {
+			sd.setRestoreOnSignal()
+		}
+		if nd, ok := d.(nativeDriver); ok {
+			if _, ok := w.renderer.(NativeHandler); ok {
+				nd.setNative(w.handleNative)
+			}
+		}
+		if err := d.open(windowName, width, height); err != nil {
+			errc <- &WindowError{Window: windowName, Err: err}
+			return
+		}
+		opened = true
+		a.res.acquire(resWindow)
+		w.driver = d
+
+		// Quit wakes the driver until it is closed
+		a.mu.Lock()
+		a.windows[w] = true
+		a.mu.Unlock()
+		defer func() {
+			a.mu.Lock()
+			delete(a.windows, w)
+			a.mu.Unlock()
+			w.closePosts()
+			w.watches.close()
+		}()
+		if c := a.opts.clock; c != nil {
+			c.add(w)
+			defer c.remove(w)
+		}
+		w.dispatch(&CreateEvent{EventHeader{Window: w}})
+
+		// message loop
+		errc <- w.run()
 	}()
 
 	return errc
 }
+
+// run handles the events of the driver until the window is closed.
+func (w *window) run() error {
+	for {
+		if w.err != nil {
+			return w.err
+		}
+		if code, ok := w.app.quit(); ok {
+			return quitError(code)
+		}
+
+		timeout := w.pollTimeout()
+		if timeout != 0 {
+			// the idle work may draw or set timers
+			w.idle()
+			if w.err != nil {
+				return w.err
+			}
+			timeout = w.pollTimeout()
+		}
+		waited := time.Now()
+		events, err := w.driver.poll(timeout)
+		if err != nil {
+			return err
+		}
+		w.stats.waited(waited, time.Now())
+
+		for _, e := range events {
+			w.traceEvent(e)
+			name, _ := eventName(e)
+			start := w.beginDispatch(name)
+			w.eventTime = time.Now()
+			if h := eventHeader(e); h != nil && !h.Time.IsZero() {
+				w.eventTime = h.Time
+			}
+
+			switch e := e.(type) {
+			case sizeEvent:
+				w.size = image.Rect(0, 0, int(e.width), int(e.height))
+				w.closePopups(0)
+				if w.renderer != nil {
+					w.guard(PhaseUpdate, func() error {
+						return w.renderer.Update(uint32(e.width), uint32(e.height))
+					})
+				}
+			case exposeEvent:
+				w.damage.add(w.size, w.size)
+			case closeEvent:
+				return nil
+			case watchEvent:
+				w.fireWatchEvent(e)
+			case *KeyEvent:
+				w.key(e.Key, e.Mods, e.Down, e.Repeat)
+			case *MouseEvent:
+				if e.Action != MouseLeave {
+					w.pointer = image.Pt(int(e.X), int(e.Y))
+				}
+				if w.menuMouse(e) {
+					break
+				}
+				e.Window = w
+				w.dispatch(e)
+			}
+			w.eventTime = time.Time{}
+			w.endDispatch(name, start)
+			if w.err != nil {
+				return w.err
+			}
+		}
+
+		w.runPosted()
+		w.fireTimers()
+		if w.err != nil {
+			return w.err
+		}
+
+		if !w.damage.empty() {
+			if err := w.draw(w.damage.take()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pollTimeout returns how long poll may wait.
+func (w *window) pollTimeout() time.Duration {
+	// damage from drawing is drawn after the pending events
+	if !w.damage.empty() {
+		return 0
+	}
+	return w.timerTimeout()
+}
+
+// eventName returns the name and the trace category of an event returned by poll.
+func eventName(e interface{}) (string, TraceCategory) {
+	switch e.(type) {
+	case sizeEvent:
+		return "size", TraceLifecycle
+	case exposeEvent:
+		return "expose", TracePaint
+	case closeEvent:
+		return "close", TraceLifecycle
+	case watchEvent:
+		return "watch", TraceOther
+	case *KeyEvent:
+		return "key", TraceInput
+	case *MouseEvent:
+		return "mouse", TraceInput
+	}
+	return fmt.Sprintf("%T", e), TraceOther
+}
+
+// traceEvent traces an event returned by poll.
+func (w *window) traceEvent(e interface{}) {
+	name, category := eventName(e)
+	if !w.app.opts.tracing(category) {
+		return
+	}
+	var detail string
+	switch e := e.(type) {
+	case sizeEvent:
+		detail = fmt.Sprintf("width=%d height=%d", e.width, e.height)
+	case watchEvent:
+		detail = fmt.Sprintf("fd=%d ready=%v", e.watcher.fd, e.ready)
+	case *KeyEvent:
+		detail = fmt.Sprintf("key=%v mods=0x%x down=%v repeat=%v", e.Key, e.Mods, e.Down, e.Repeat)
+	case *MouseEvent:
+		detail = fmt.Sprintf("action=%d button=%d x=%d y=%d mods=0x%x scroll=%g,%g", e.Action, e.Button, e.X, e.Y, e.Mods, e.ScrollX, e.ScrollY)
+	}
+	w.app.opts.trace(&TraceEvent{
+		Backend:  w.app.Backend(),
+		Window:   w.name,
+		Source:   "poll",
+		Category: category,
+		Name:     name,
+		Detail:   detail,
+	})
+}
+
+// draw renders the region of the window and presents it with the menus
+// drawn over it. Renderers without region support redraw the whole window.
+func (w *window) draw(region []image.Rectangle) error {
+	full := []image.Rectangle{w.size}
+	img := w.driver.framebuffer()
+	if w.renderer != nil {
+		w.guard(PhaseDraw, func() error {
+			if sr, ok := w.renderer.(SoftwareRenderer); ok && img != nil {
+				if rr, ok := w.renderer.(SoftwareRegionRenderer); ok {
+					return rr.DrawImageRegion(img, region)
+				}
+				region = full
+				return sr.DrawImage(img)
+			}
+			if rr, ok := w.renderer.(RegionRenderer); ok {
+				return rr.DrawRegion(w.driver.handle(), region)
+			}
+			region = full
+			return w.renderer.Draw(w.driver.handle())
+		})
+		if w.err != nil {
+			return w.err
+		}
+	}
+	if img != nil {
+		if drawn := w.drawMenus(img); len(drawn) > 0 {
+			var d damage
+			d.addAll(region, w.size)
+			d.addAll(drawn, w.size)
+			region = d.take()
+		}
+	}
+	return w.driver.present(region)
+}
+
+func (w *window) Name() string {
+	return w.name
+}
+
+func (w *window) NativeHandle() uintptr {
+	if w.driver == nil {
+		return 0
+	}
+	return w.driver.handle()
+}
+
+func (w *window) Shortcuts() *Shortcuts {
+	return w.shortcuts
+}
+
+func (w *window) Invalidate(r image.Rectangle) {
+	w.damage.add(r, w.size)
+}
+
+// key delivers a key as a CommandEvent if it is bound to a shortcut, or as a KeyEvent.
+func (w *window) key(key Key, mods Modifier, down bool, repeat bool) {
+	if w.menuKey(key, down) {
+		return
+	}
+	if id, fire, taken := w.held.match(key, mods, down, repeat, w.shortcuts, w.app.shortcuts); taken {
+		if fire {
+			w.command(w.menu, id)
+		}
+		return
+	}
+	w.dispatch(&KeyEvent{
+		EventHeader: EventHeader{Window: w},
+		Key:         key,
+		Mods:        mods,
+		Down:        down,
+		Repeat:      repeat,
+	})
+}
+
+// command handles a selected item of menu or a shortcut. Items with ID 0
+// have no command.
+func (w *window) command(menu *Menu, id int) {
+	if id == 0 {
+		return
+	}
+	menu.activate(id)
+	w.dispatch(&CommandEvent{EventHeader: EventHeader{Window: w}, ID: id})
+}
+
+// dispatch delivers e to the event handler of the window.
+// Events without a time happen at the driver event being handled, or now.
+func (w *window) dispatch(e Event) {
+	if h := eventHeader(e); h != nil {
+		if h.Time.IsZero() {
+			h.Time = w.eventTime
+			if h.Time.IsZero() {
+				h.Time = time.Now()
+			}
+		}
+		h.Pos = w.pointer
+	}
+	if w.handler != nil {
+		w.guard(PhaseEvent, func() error {
+			w.handler.HandleEvent(e)
+			return nil
+		})
+	}
+}
+
+// wakeLoop makes the loop run the functions of post.
+func (w *window) wakeLoop() {
+	w.driver.wake()
+}
+
+// stop is called by guard. run returns w.err after the current event.
+func (w *window) stop() {}
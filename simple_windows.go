@@ -2,28 +2,89 @@ package gui
 
 import (
 	"fmt"
+	"image"
 	"log"
 	"math"
 	"os"
 	"reflect"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
 )
 
+// quitMessage is posted to each window by Application.Quit.
+const quitMessage = WM_APP + 2
+
+// postMessage makes the window run the functions of post.
+const postMessage = WM_APP + 3
+
+// win32Capabilities are the features of the Windows backend.
+const win32Capabilities = CapMultiWindow | CapDPI | CapMenuBar | CapPopupMenu | CapKeyboard | CapOpenGL
+
 type application struct {
 	logger *log.Logger
+	opts   *options
 
 	instance windows.Handle
 	cmdLine  string
 	cmdShow  int32
 	atom     Atom
+
+	shortcuts *Shortcuts
+
+	mu       sync.Mutex
+	hwnds    map[windows.Handle]*window
+	quitting bool
+	quitCode int
+	closing  bool // by Deinit
+
+	wndProc uintptr        // callback of windowProc, which is never freed
+	running sync.WaitGroup // loops and their goroutines
+	res     resources
+}
+
+// window is a native window and its renderer.
+type window struct {
+	app      *application
+	handle   windows.Handle
+	name     string
+	renderer Renderer
+	handler  EventHandler
+	// presenter is set for SoftwareRenderer
+	presenter *dibPresenter
+	// err stops the loop, see guard
+	err error
+
+	timers   map[uintptr]*Timer // by ID of SetTimer
+	timerID  uintptr
+	watchers []*Watcher // handles waited for by wait
+	stats    loopStats
+
+	// functions of post, see runPosted
+	postMu sync.Mutex
+	posted []func()
+	closed bool
+	donec  chan struct{}
+
+	menu      *Menu
+	hmenu     windows.Handle
+	popupMenu *Menu
+	shortcuts *Shortcuts
+	held      shortcutKeys
 }
 
 // NewApplication creates a new GUI application.
-func NewApplication() Application {
-	return &application{}
+// The only backend on Windows is "win32".
+func NewApplication(opts ...Option) Application {
+	return &application{
+		opts:      newOptions(opts),
+		shortcuts: NewShortcuts(),
+		hwnds:     make(map[windows.Handle]*window),
+	}
 }
 
 func (a *application) EnableLog() error {
@@ -31,15 +92,23 @@ func (a *application) EnableLog() error {
 	if a.logger != nil {
 		a.logger.Print("start logging")
 	}
-
-	captionUTF16, _ := windows.UTF16PtrFromString("Hello")
-	textUTF16, _ := windows.UTF16PtrFromString("from logger: Hello 世界")
-	MessageBoxEx(0, textUTF16, captionUTF16, 0, 0)
-
+	if a.opts.tracer == nil {
+		a.opts.tracer = NewTextTracer(os.Stderr)
+		a.opts.traceCategories = TraceAll
+	}
 	return nil
 }
 
 func (a *application) Init() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.atom != 0 {
+		return nil
+	}
+	if len(a.opts.backends) > 0 && !containsString(a.opts.backends, "win32") {
+		return fmt.Errorf("%w: %s", ErrNoBackend, strings.Join(a.opts.backends, ", "))
+	}
+
 	// dummy _tWinMain()
 	i, err := GetModuleHandle(nil)
 	if err != nil {
@@ -49,8 +118,8 @@ func (a *application) Init() error {
 	a.cmdLine = ""
 	a.cmdShow = SW_SHOWNORMAL
 
-	// register a window class
-	className := "GO GUI: simple window app"
+	// register a window class, one per application
+	className := fmt.Sprintf("GO GUI: simple window app %p", a)
 	classNameUTF16, err := windows.UTF16PtrFromString(className)
 	if err != nil {
 		return fmt.Errorf("UTF16PtrFromString %s: %v", className, err)
@@ -63,10 +132,13 @@ func (a *application) Init() error {
 	if err != nil {
 		return fmt.Errorf("LoadCursor: %v", err)
 	}
+	if a.wndProc == 0 {
+		a.wndProc = windows.NewCallback(a.windowProc)
+	}
 	wndClass := &WndClassEx{
 		Size:       0,
-		Style:      CS_HREDRAW | CS_VREDRAW,
-		WndProc:    windows.NewCallback(a.windowProc),
+		Style:      CS_HREDRAW | CS_VREDRAW | CS_OWNDC, // CS_OWNDC for OpenGL
+		WndProc:    a.wndProc,
 		ClsExtra:   0,
 		WndExtra:   0,
 		Instance:   a.instance,
@@ -83,22 +155,95 @@ func (a *application) Init() error {
 		return fmt.Errorf("RegisterClassEx %v: %v", wndClass, err)
 	}
 	a.atom = atom
+	a.res.acquire(resClass)
 
 	return nil
 }
 
+// Deinit closes the windows, waits for their loops and unregisters the
+// window class. It must not be called on a loop thread.
 func (a *application) Deinit() {
-	if a != nil {
-		// nothing to do
+	a.mu.Lock()
+	atom := a.atom
+	if atom == 0 {
+		a.mu.Unlock()
+		return
+	}
+	a.closing = true
+	for hwnd := range a.hwnds {
+		PostMessage(hwnd, quitMessage, 0, 0)
+	}
+	a.mu.Unlock()
+	a.running.Wait()
+
+	if err := UnregisterClass((*uint16)(unsafe.Pointer(uintptr(atom))), a.instance); err != nil {
+		if a.logger != nil {
+			a.logger.Printf("UnregisterClass: %v\n", err)
+		}
+	} else {
+		a.res.release(resClass)
+	}
+
+	a.mu.Lock()
+	a.closing, a.quitting, a.quitCode = false, false, 0
+	a.atom = 0
+	a.mu.Unlock()
+}
+
+func (a *application) Shortcuts() *Shortcuts {
+	return a.shortcuts
+}
+
+func (a *application) Quit(code int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.quitting {
+		a.quitting, a.quitCode = true, code
+	}
+	for hwnd := range a.hwnds {
+		PostMessage(hwnd, quitMessage, 0, 0)
 	}
 }
 
+// quit returns the exit code if Quit has been called.
+func (a *application) quit() (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.quitCode, a.quitting || a.closing
+}
+
+func (a *application) Backend() string {
+	return "win32"
+}
+
+func (a *application) Supports(caps Capability) bool {
+	return win32Capabilities&caps == caps
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *application) Loop(windowName string, width int32, height int32, renderer Renderer) <-chan error {
 	errc := make(chan error, 1)
 
+	a.running.Add(1)
 	go func() {
+		defer a.running.Done()
+
 		// lock thread for the GetMessage() API & Renderer
 		runtime.LockOSThread()
+		deinitThread, err := a.initThread(windowName)
+		if err != nil {
+			errc <- err
+			return
+		}
+		defer deinitThread()
 
 		// validate Renderer I/F
 		isValid := true
@@ -107,10 +252,15 @@ func (a *application) Loop(windowName string, width int32, height int32, rendere
 			isValid = false
 		}
 
-		var ptr uintptr
+		w := &window{
+			app:       a,
+			name:      windowName,
+			shortcuts: NewShortcuts(),
+			held:      make(shortcutKeys),
+			donec:     make(chan struct{}),
+		}
 		if isValid {
-			err := renderer.Init()
-			if err != nil {
+			if err := callRenderer(windowName, PhaseInit, renderer.Init); err != nil {
 				errc <- err
 				return
 			}
@@ -119,55 +269,110 @@ func (a *application) Loop(windowName string, width int32, height int32, rendere
 			dpiX, dpiY := renderer.Dpi()
 			width = int32(math.Ceil(float64(float32(width) * dpiX / 96.0)))
 			height = int32(math.Ceil(float64(float32(height) * dpiY / 96.0)))
-			ptr = uintptr(unsafe.Pointer(&renderer))
+			w.renderer = renderer
+			w.handler, _ = renderer.(EventHandler)
+			if _, ok := renderer.(SoftwareRenderer); ok {
+				w.presenter = &dibPresenter{res: &a.res}
+			}
 		}
 
-		w, err := a.appendWindow(windowName, width, height, ptr)
-		if err != nil {
-			errc <- err
+		if err := a.appendWindow(w, width, height); err != nil {
+			errc <- &WindowError{Window: windowName, Err: err}
 			return
 		}
+		defer w.closePosts()
+		if _, ok := a.quit(); ok {
+			PostMessage(w.handle, quitMessage, 0, 0)
+		}
+		w.dispatch(&CreateEvent{EventHeader{Window: w, Time: time.Now(), Pos: w.cursorPos()}})
 
 		// message loop
 		var msg Msg
 		for {
+			if !PeekMessage(&msg, 0, 0, 0, PM_NOREMOVE) {
+				w.idle()
+			}
+			waited := time.Now()
+			if err := w.wait(); err != nil {
+				// releases the window and its resources
+				DestroyWindow(w.handle)
+				errc <- err
+				return
+			}
 			result, err := GetMessage(&msg, 0, 0, 0)
 			if err != nil {
-				errc <- fmt.Errorf("GetMessage: %p, %v", unsafe.Pointer(w), err)
+				DestroyWindow(w.handle)
+				errc <- fmt.Errorf("GetMessage: %p, %v", unsafe.Pointer(w.handle), err)
 				return
 			}
+			w.stats.waited(waited, time.Now())
 
-			if a.logger != nil {
-				a.logger.Printf("GetMessage: %p, 0x%08x, %p, %p\n", unsafe.Pointer(w), msg.message, unsafe.Pointer(msg.wParam), unsafe.Pointer(msg.lParam))
-			}
+			a.traceMessage("GetMessage", msg.hwnd, msg.message, msg.wParam, msg.lParam)
 
 			if result == 0 {
 				// WM_QUIT (wParam is ExitCode)
-				if msg.wParam == 0 {
-					errc <- nil
+				if w.err != nil {
+					errc <- w.err
 				} else {
-					errc <- fmt.Errorf("GetMessage: %p, WM_QUIT, %d", unsafe.Pointer(w), msg.wParam)
+					errc <- quitError(int(int32(msg.wParam)))
 				}
 				break
 			}
 
-			TranslateMessage(&msg)
-			DispatchMessage(&msg)
+			name := messageName(msg.message)
+			start := w.beginDispatch(name)
+			a.dispatchMessage(&msg)
+			w.endDispatch(name, start)
 		}
 	}()
 
 	return errc
 }
 
-func (a *application) appendWindow(name string, width int32, height int32, rendererPtr uintptr) (windows.Handle, error) {
-	nameUTF16, err := windows.UTF16PtrFromString(name)
+// dispatchMessage passes a message from the queue to its window.
+func (a *application) dispatchMessage(msg *Msg) {
+	a.mu.Lock()
+	target := a.hwnds[msg.hwnd]
+	a.mu.Unlock()
+	if target != nil && target.handleNative(&NativeMessage{
+		HWND:    uintptr(msg.hwnd),
+		Message: msg.message,
+		WParam:  msg.wParam,
+		LParam:  msg.lParam,
+		Queued:  true,
+	}) {
+		return
+	}
+
+	// shortcuts are resolved before TranslateMessage() makes WM_CHAR
+	switch msg.message {
+	case WM_KEYDOWN, WM_SYSKEYDOWN, WM_KEYUP, WM_SYSKEYUP:
+		down := msg.message == WM_KEYDOWN || msg.message == WM_SYSKEYDOWN
+		// bit 30 is the previous key state, set for auto-repeat
+		repeat := down && msg.lParam&(1<<30) != 0
+		if target != nil && target.shortcut(keyFromVK(msg.wParam), currentModifiers(), down, repeat) {
+			return
+		}
+	}
+
+	TranslateMessage(msg)
+	DispatchMessage(msg)
+}
+
+func (a *application) appendWindow(w *window, width int32, height int32) error {
+	nameUTF16, err := windows.UTF16PtrFromString(w.name)
 	if err != nil {
-		return 0, fmt.Errorf("UTF16PtrFromString %s: %v", name, err)
+		return fmt.Errorf("UTF16PtrFromString %s: %v", w.name, err)
 	}
 
-	w, err := CreateWindowEx(
+	a.mu.Lock()
+	atom := a.atom
+	a.mu.Unlock()
+
+	// the window is kept alive by a.hwnds after WM_CREATE
+	h, err := CreateWindowEx(
 		0,
-		(*uint16)(unsafe.Pointer(uintptr(a.atom))),
+		(*uint16)(unsafe.Pointer(uintptr(atom))),
 		nameUTF16,
 		WS_OVERLAPPEDWINDOW,
 		CW_USEDEFAULT, CW_USEDEFAULT, // x, y
@@ -175,69 +380,411 @@ func (a *application) appendWindow(name string, width int32, height int32, rende
 		0,
 		0,
 		a.instance,
-		rendererPtr,
+		uintptr(unsafe.Pointer(w)),
 	)
 	if err != nil {
-		return 0, fmt.Errorf("CreateWindowEx: %v", err)
+		return fmt.Errorf("CreateWindowEx: %v", err)
 	}
 
-	_ = ShowWindow(w, a.cmdShow) // ignore return value
-	_ = UpdateWindow(w)          // ignore return value
+	_ = ShowWindow(h, a.cmdShow) // ignore return value
+	_ = UpdateWindow(h)          // ignore return value
 
-	return w, err
+	return nil
 }
 
-func (a *application) windowProc(window windows.Handle, message uint32, wParam uintptr, lParam uintptr) uintptr {
-	if a.logger != nil {
-		a.logger.Printf("windowProc: %p, 0x%08x\n", unsafe.Pointer(window), message)
-	}
+func (a *application) windowProc(hwnd windows.Handle, message uint32, wParam uintptr, lParam uintptr) uintptr {
+	a.traceMessage("windowProc", hwnd, message, wParam, lParam)
 
-	// save renderer as user data
+	// save window as user data
 	if message == WM_CREATE {
 		cs := (*CreateStruct)(unsafe.Pointer(lParam))
-		ptr := cs.CreateParams
+		w := (*window)(unsafe.Pointer(cs.CreateParams))
+		w.handle = hwnd
 
 		SetWindowLongPtr(
-			window,
+			hwnd,
 			GWLP_USERDATA,
-			ptr,
+			cs.CreateParams,
 		)
 
+		a.mu.Lock()
+		a.hwnds[hwnd] = w
+		a.mu.Unlock()
+		a.res.acquire(resWindow)
+
+		m := &NativeMessage{HWND: uintptr(hwnd), Message: message, WParam: wParam, LParam: lParam}
+		if w.handleNative(m) {
+			return m.Result
+		}
 		return 1
 	}
 
-	// use user data as renderer
+	// use user data as window
 	ptr, err := GetWindowLongPtr(
-		window,
+		hwnd,
 		GWLP_USERDATA,
 	)
 	if err != nil {
 		ptr = 0
 	}
-	renderer := (*Renderer)(unsafe.Pointer(ptr))
+	w := (*window)(unsafe.Pointer(ptr))
+	if w == nil {
+		r, _ := DefWindowProc(hwnd, message, wParam, lParam)
+		return r
+	}
+	renderer := w.renderer
+
+	// WM_NCDESTROY releases the window
+	m := &NativeMessage{HWND: uintptr(hwnd), Message: message, WParam: wParam, LParam: lParam}
+	if w.handleNative(m) && message != WM_NCDESTROY {
+		return m.Result
+	}
 
 	switch message {
 	case WM_SIZE:
 		if renderer != nil {
 			width := uint32(LOWORD(lParam))
 			height := uint32(HIWORD(lParam))
-			(*renderer).Update(width, height)
+			if w.presenter != nil {
+				if err := w.presenter.resize(int32(width), int32(height)); err != nil && a.logger != nil {
+					a.logger.Printf("windowProc: %p, %v\n", unsafe.Pointer(hwnd), err)
+				}
+			}
+			w.guard(PhaseUpdate, func() error {
+				return renderer.Update(width, height)
+			})
 		}
 		return 0
+	case WM_ERASEBKGND:
+		if w.presenter != nil {
+			// the whole client area is painted
+			return 1
+		}
 	case WM_DISPLAYCHANGE:
-		InvalidateRect(window, nil, false)
+		InvalidateRect(hwnd, nil, false)
 		return 0
 	case WM_PAINT:
+		if w.presenter != nil {
+			w.paint()
+			return 0
+		}
 		if renderer != nil {
-			(*renderer).Draw(uintptr(window))
-			ValidateRect(window, nil)
+			w.guard(PhaseDraw, func() error {
+				if rr, ok := renderer.(RegionRenderer); ok {
+					return rr.DrawRegion(uintptr(hwnd), updateRegion(hwnd))
+				}
+				return renderer.Draw(uintptr(hwnd))
+			})
+			ValidateRect(hwnd, nil)
+		}
+		return 0
+	case WM_KEYDOWN, WM_KEYUP, WM_SYSKEYDOWN, WM_SYSKEYUP:
+		w.dispatch(&KeyEvent{
+			EventHeader: EventHeader{Window: w},
+			Key:         keyFromVK(wParam),
+			Mods:        currentModifiers(),
+			Down:        message == WM_KEYDOWN || message == WM_SYSKEYDOWN,
+			Repeat:      (message == WM_KEYDOWN || message == WM_SYSKEYDOWN) && lParam&(1<<30) != 0,
+		})
+		if message == WM_KEYDOWN || message == WM_KEYUP {
+			return 0
+		}
+		// Alt+F4 and the system menu
+	case WM_COMMAND:
+		// menu (0) or accelerator (1)
+		if lParam == 0 && HIWORD(wParam) <= 1 {
+			w.command(int(LOWORD(wParam)))
+			return 0
+		}
+	case WM_TIMER:
+		if w.timer(wParam) {
+			return 0
 		}
+	case postMessage:
+		w.runPosted()
+		return 0
+	case quitMessage:
+		DestroyWindow(hwnd)
 		return 0
 	case WM_DESTROY:
-		PostQuitMessage(0)
+		code, _ := a.quit()
+		PostQuitMessage(int32(code))
 		return 1
+	case WM_NCDESTROY:
+		w.closeTimers()
+		w.closeWatches()
+		if w.presenter != nil {
+			w.presenter.close()
+		}
+		if w.hmenu != 0 {
+			// destroyed with the window
+			w.hmenu = 0
+			a.res.release(resMenu)
+		}
+		a.res.release(resWindow)
+		a.mu.Lock()
+		delete(a.hwnds, hwnd)
+		a.mu.Unlock()
+		SetWindowLongPtr(hwnd, GWLP_USERDATA, 0)
 	}
 
-	r, _ := DefWindowProc(window, message, wParam, lParam)
+	r, _ := DefWindowProc(hwnd, message, wParam, lParam)
 	return r
 }
+
+// traceMessage traces a message of hwnd seen in source.
+func (a *application) traceMessage(source string, hwnd windows.Handle, message uint32, wParam uintptr, lParam uintptr) {
+	category := messageCategory(message)
+	if !a.opts.tracing(category) {
+		return
+	}
+
+	var name string
+	a.mu.Lock()
+	if w := a.hwnds[hwnd]; w != nil {
+		name = w.name
+	}
+	a.mu.Unlock()
+
+	a.opts.trace(&TraceEvent{
+		Backend:  "win32",
+		Window:   name,
+		Source:   source,
+		Category: category,
+		Name:     messageName(message),
+		ID:       message,
+		Detail:   fmt.Sprintf("hwnd=%p wParam=0x%x lParam=0x%x", unsafe.Pointer(hwnd), wParam, lParam),
+	})
+}
+
+// paint draws the update region with SoftwareRenderer and presents it.
+// Renderers without region support redraw the whole window.
+func (w *window) paint() {
+	region := updateRegion(w.handle)
+
+	var ps PaintStruct
+	dc, err := BeginPaint(w.handle, &ps)
+	if err != nil {
+		return
+	}
+	defer EndPaint(w.handle, &ps)
+
+	img := w.presenter.framebuffer()
+	if img == nil {
+		return
+	}
+	w.guard(PhaseDraw, func() error {
+		if rr, ok := w.renderer.(SoftwareRegionRenderer); ok {
+			return rr.DrawImageRegion(img, region)
+		}
+		region = []image.Rectangle{img.Rect}
+		return w.renderer.(SoftwareRenderer).DrawImage(img)
+	})
+	if w.err != nil {
+		return
+	}
+	if err := w.presenter.present(dc, region); err != nil && w.app.logger != nil {
+		w.app.logger.Printf("paint: %p, %v\n", unsafe.Pointer(w.handle), err)
+	}
+}
+
+func (w *window) Name() string {
+	return w.name
+}
+
+func (w *window) NativeHandle() uintptr {
+	return uintptr(w.handle)
+}
+
+func (w *window) SetMenu(menu *Menu) error {
+	if err := menu.validate(); err != nil {
+		return err
+	}
+
+	var h windows.Handle
+	if menu != nil {
+		var err error
+		h, err = buildMenu(menu, true)
+		if err != nil {
+			return err
+		}
+	}
+	if err := SetMenu(w.handle, h); err != nil {
+		if h != 0 {
+			DestroyMenu(h)
+		}
+		return fmt.Errorf("SetMenu: %v", err)
+	}
+	if w.hmenu != 0 {
+		DestroyMenu(w.hmenu)
+		w.app.res.release(resMenu)
+	}
+	if h != 0 {
+		w.app.res.acquire(resMenu)
+	}
+	w.menu = menu
+	w.hmenu = h
+
+	DrawMenuBar(w.handle)
+	return nil
+}
+
+func (w *window) PopupMenu(menu *Menu) error {
+	if err := menu.validate(); err != nil {
+		return err
+	}
+
+	h, err := buildMenu(menu, false)
+	if err != nil {
+		return err
+	}
+	defer DestroyMenu(h)
+
+	var pt Point
+	if err := GetCursorPos(&pt); err != nil {
+		return fmt.Errorf("GetCursorPos: %v", err)
+	}
+
+	// the selection is returned instead of posted as WM_COMMAND, so that
+	// popupMenu is only set while the menu is tracked. 0 is returned if
+	// the menu is canceled, which is not told apart from a failure.
+	w.popupMenu = menu
+	SetForegroundWindow(w.handle)
+	id, _ := TrackPopupMenuEx(h, TPM_LEFTALIGN|TPM_TOPALIGN|TPM_RIGHTBUTTON|TPM_RETURNCMD|TPM_NONOTIFY, pt.X, pt.Y, w.handle, 0)
+	PostMessage(w.handle, WM_NULL, 0, 0)
+	w.command(int(id))
+	w.popupMenu = nil
+
+	return nil
+}
+
+func (w *window) Shortcuts() *Shortcuts {
+	return w.shortcuts
+}
+
+func (w *window) Invalidate(r image.Rectangle) {
+	var client Rect
+	if err := GetClientRect(w.handle, &client); err != nil {
+		return
+	}
+	r = r.Intersect(rectangle(client))
+	if r.Empty() {
+		return
+	}
+	rc := Rect{Left: int32(r.Min.X), Top: int32(r.Min.Y), Right: int32(r.Max.X), Bottom: int32(r.Max.Y)}
+	InvalidateRect(w.handle, &rc, false)
+}
+
+// updateRegion returns the rectangles of the update region in WM_PAINT,
+// or the client area if it is not available.
+func updateRegion(hwnd windows.Handle) []image.Rectangle {
+	var client Rect
+	GetClientRect(hwnd, &client)
+	bounds := rectangle(client)
+	full := []image.Rectangle{bounds}
+
+	rgn, err := CreateRectRgn(0, 0, 0, 0)
+	if err != nil {
+		return full
+	}
+	defer DeleteObject(rgn)
+	if GetUpdateRgn(hwnd, rgn, false) < SIMPLEREGION {
+		return full
+	}
+
+	size := GetRegionData(rgn, 0, nil)
+	if size < uint32(unsafe.Sizeof(RgnDataHeader{})) {
+		return full
+	}
+	buf := make([]byte, size)
+	if GetRegionData(rgn, size, &buf[0]) == 0 {
+		return full
+	}
+	hdr := (*RgnDataHeader)(unsafe.Pointer(&buf[0]))
+	rects := (*[1 << 20]Rect)(unsafe.Pointer(&buf[hdr.Size]))[:hdr.Count:hdr.Count]
+
+	var d damage
+	for _, rc := range rects {
+		d.add(rectangle(rc), bounds)
+	}
+	if d.empty() {
+		return full
+	}
+	return d.take()
+}
+
+// messageTime returns the time of the current message, which is in
+// milliseconds of GetTickCount.
+func messageTime() time.Time {
+	age := uint32(GetTickCount()) - uint32(GetMessageTime())
+	return sinceClock(time.Duration(int32(age)) * time.Millisecond)
+}
+
+// clientPos converts a screen position to the client area of w.
+func (w *window) clientPos(x, y int32) image.Point {
+	pt := Point{X: x, Y: y}
+	ScreenToClient(w.handle, &pt)
+	return image.Pt(int(pt.X), int(pt.Y))
+}
+
+// cursorPos returns the cursor position in the client area of w.
+func (w *window) cursorPos() image.Point {
+	var pt Point
+	if err := GetCursorPos(&pt); err != nil {
+		return image.Point{}
+	}
+	return w.clientPos(pt.X, pt.Y)
+}
+
+// rectangle converts rc to image.Rectangle.
+func rectangle(rc Rect) image.Rectangle {
+	return image.Rect(int(rc.Left), int(rc.Top), int(rc.Right), int(rc.Bottom))
+}
+
+// shortcut delivers the command bound to key, if any, and reports whether
+// the key message is taken by a shortcut.
+func (w *window) shortcut(key Key, mods Modifier, down bool, repeat bool) bool {
+	id, fire, taken := w.held.match(key, mods, down, repeat, w.shortcuts, w.app.shortcuts)
+	if fire {
+		w.command(id)
+	}
+	return taken
+}
+
+// command handles a selected menu item or shortcut. Items with ID 0 have
+// no command.
+func (w *window) command(id int) {
+	if id == 0 {
+		return
+	}
+	if item := w.popupMenu.activate(id); item == nil {
+		if item := w.menu.activate(id); item != nil && (item.Checkable || item.RadioGroup != 0) {
+			// rebuild the menu bar for the new check state
+			w.SetMenu(w.menu)
+		}
+	}
+
+	w.dispatch(&CommandEvent{EventHeader: EventHeader{Window: w}, ID: id})
+}
+
+// dispatch delivers e to the event handler of the window.
+// Events without a time happen at the current message.
+func (w *window) dispatch(e Event) {
+	if h := eventHeader(e); h != nil && h.Time.IsZero() {
+		h.Time = messageTime()
+		pos := GetMessagePos()
+		h.Pos = w.clientPos(int32(int16(LOWORD(uintptr(pos)))), int32(int16(HIWORD(uintptr(pos)))))
+	}
+	if w.handler != nil {
+		w.guard(PhaseEvent, func() error {
+			w.handler.HandleEvent(e)
+			return nil
+		})
+	}
+}
+
+// stop is called by guard. The window is closed after the current message
+// and Loop sends w.err on WM_QUIT.
+func (w *window) stop() {
+	PostMessage(w.handle, WM_CLOSE, 0, 0)
+}
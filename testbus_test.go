@@ -0,0 +1,144 @@
+// +build !windows
+
+package gui
+
+import (
+	"bufio"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ysh86/gui/internal/dbus"
+)
+
+// startBus starts a private session bus for the test and points
+// DBUS_SESSION_BUS_ADDRESS at it. It skips the test without dbus-daemon.
+func startBus(t *testing.T) string {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address=1")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("dbus-daemon: %v", err)
+	}
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		t.Skipf("dbus-daemon: %v", err)
+	}
+	addr = strings.TrimSpace(addr)
+
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	setenv(t, "DBUS_SESSION_BUS_ADDRESS", addr)
+	resetNotifier()
+	t.Cleanup(resetNotifier)
+	return addr
+}
+
+// resetNotifier closes the cached notifier so that the next one connects
+// to the current bus.
+func resetNotifier() {
+	notifierMu.Lock()
+	n := notifierOn
+	notifierOn = nil
+	notifierMu.Unlock()
+	if n != nil {
+		n.conn.Close()
+	}
+}
+
+// notifyCall is a call of Notify received by fakeNotifications.
+type notifyCall struct {
+	id      uint32
+	appIcon string
+	summary string
+	body    string
+	actions []string // alternating keys and labels
+	hints   map[string]interface{}
+	timeout int32
+}
+
+// fakeNotifications serves org.freedesktop.Notifications on the bus.
+type fakeNotifications struct {
+	conn  *dbus.Conn
+	calls chan notifyCall
+
+	mu   sync.Mutex
+	next uint32
+}
+
+func newFakeNotifications(t *testing.T, addr string) *fakeNotifications {
+	conn, err := dbus.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	f := &fakeNotifications{conn: conn, calls: make(chan notifyCall, 16)}
+	conn.Export(notifyPath, notifyIface, f.handle)
+	if err := conn.RequestName(notifyName, 0); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fakeNotifications) handle(m *dbus.Message) (dbus.Signature, []interface{}, error) {
+	if m.Member != "Notify" || len(m.Body) < 8 {
+		return "", nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod"}
+	}
+	f.mu.Lock()
+	f.next++
+	c := notifyCall{id: f.next}
+	f.mu.Unlock()
+
+	c.appIcon, _ = m.Body[2].(string)
+	c.summary, _ = m.Body[3].(string)
+	c.body, _ = m.Body[4].(string)
+	keys, _ := m.Body[5].([]interface{})
+	for _, k := range keys {
+		s, _ := k.(string)
+		c.actions = append(c.actions, s)
+	}
+	c.hints = make(map[string]interface{})
+	hints, _ := m.Body[6].(map[interface{}]interface{})
+	for k, v := range hints {
+		name, _ := k.(string)
+		c.hints[name] = v.(dbus.Variant).Value
+	}
+	c.timeout, _ = m.Body[7].(int32)
+	f.calls <- c
+	return "u", []interface{}{c.id}, nil
+}
+
+// nextCall waits for the next call of Notify.
+func (f *fakeNotifications) nextCall(t *testing.T) notifyCall {
+	select {
+	case c := <-f.calls:
+		return c
+	case <-testTimeout():
+		t.Fatal("Notify was not called")
+		return notifyCall{}
+	}
+}
+
+func (f *fakeNotifications) invoke(t *testing.T, id uint32, key string) {
+	if err := f.conn.Emit(notifyPath, notifyIface, "ActionInvoked", "us", id, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (f *fakeNotifications) close(t *testing.T, id uint32, reason uint32) {
+	if err := f.conn.Emit(notifyPath, notifyIface, "NotificationClosed", "uu", id, reason); err != nil {
+		t.Fatal(err)
+	}
+}
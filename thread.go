@@ -0,0 +1,53 @@
+package gui
+
+// Apartment is the COM apartment of the loop threads on Windows.
+type Apartment int
+
+// Apartments
+const (
+	ApartmentNone Apartment = iota // COM is not initialized by the loop
+	ApartmentSTA                   // single-threaded, e.g. for dialogs, drag and drop and Direct2D
+	ApartmentMTA                   // multithreaded
+)
+
+// WithCOMApartment initializes COM in apartment on each loop thread before
+// Renderer.Init and uninitializes it after Renderer.Deinit. It is ignored
+// on the other platforms.
+func WithCOMApartment(apartment Apartment) Option {
+	return func(o *options) {
+		o.apartment = apartment
+	}
+}
+
+// WithThreadInit calls init on each loop thread after it is locked and COM
+// is initialized, before Renderer.Init, e.g. to set up GL or D-Bus for the
+// thread. deinit, if not nil, is called on the thread after Renderer.Deinit.
+// Loop sends *ThreadInitError if init fails.
+func WithThreadInit(init func(window string) (deinit func(), err error)) Option {
+	return func(o *options) {
+		o.threadInit = init
+	}
+}
+
+// initThread prepares the locked loop thread of window and returns the
+// function which undoes it in reverse order.
+func (a *application) initThread(window string) (func(), error) {
+	uninitCOM, err := initCOM(a.opts.apartment)
+	if err != nil {
+		return nil, &ThreadInitError{Window: window, Err: err}
+	}
+	var deinit func()
+	if a.opts.threadInit != nil {
+		deinit, err = a.opts.threadInit(window)
+		if err != nil {
+			uninitCOM()
+			return nil, &ThreadInitError{Window: window, Err: err}
+		}
+	}
+	return func() {
+		if deinit != nil {
+			deinit()
+		}
+		uninitCOM()
+	}, nil
+}
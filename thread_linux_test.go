@@ -0,0 +1,131 @@
+package gui
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// threadCall is a call on a thread.
+type threadCall struct {
+	name string
+	tid  int
+}
+
+// threadLog records the calls of the thread hooks and the renderer.
+type threadLog struct {
+	mu    sync.Mutex
+	calls []threadCall
+	done  chan struct{} // closed by the last call
+}
+
+func (l *threadLog) add(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, threadCall{name, unix.Gettid()})
+	if name == "deinit" {
+		close(l.done)
+	}
+}
+
+func (l *threadLog) names() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var names []string
+	for _, c := range l.calls {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// threadRenderer records Init and Deinit.
+type threadRenderer struct {
+	log    *threadLog
+	window chan Window
+}
+
+func (r *threadRenderer) Init() error                       { r.log.add("Init"); return nil }
+func (r *threadRenderer) Deinit()                           { r.log.add("Deinit") }
+func (r *threadRenderer) Dpi() (float32, float32)           { return 96, 96 }
+func (r *threadRenderer) Update(width, height uint32) error { return nil }
+func (r *threadRenderer) Draw(nativeWindow uintptr) error   { return nil }
+
+func (r *threadRenderer) HandleEvent(e Event) {
+	if c, ok := e.(*CreateEvent); ok {
+		r.window <- c.Window
+	}
+}
+
+func TestThreadInit(t *testing.T) {
+	l := &threadLog{done: make(chan struct{})}
+	app := NewApplication(WithBackend("headless"), WithThreadInit(func(window string) (func(), error) {
+		if window != "thread" {
+			t.Errorf("init of %q", window)
+		}
+		l.add("init")
+		return func() { l.add("deinit") }, nil
+	}))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := &threadRenderer{log: l, window: make(chan Window, 1)}
+	errc := app.Loop("thread", 8, 8, r)
+	w := (<-r.window).(*window)
+	var loopTid int
+	onLoop(t, w, func() { loopTid = unix.Gettid() })
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+	select {
+	case <-l.done:
+	case <-testTimeout():
+		t.Fatal("deinit was not called")
+	}
+
+	want := []string{"init", "Init", "Deinit", "deinit"}
+	if names := l.names(); len(names) != len(want) {
+		t.Fatalf("calls %v, want %v", names, want)
+	}
+	for i, c := range l.calls {
+		if c.name != want[i] || c.tid != loopTid {
+			t.Errorf("call %d: %s on thread %d, want %s on the loop thread %d", i, c.name, c.tid, want[i], loopTid)
+		}
+	}
+}
+
+func TestThreadInitError(t *testing.T) {
+	failed := errors.New("no bus")
+	l := &threadLog{done: make(chan struct{})}
+	app := NewApplication(WithBackend("headless"), WithThreadInit(func(window string) (func(), error) {
+		l.add("init")
+		return nil, failed
+	}))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := &threadRenderer{log: l, window: make(chan Window, 1)}
+
+	var err error
+	select {
+	case err = <-app.Loop("thread", 8, 8, r):
+	case <-testTimeout():
+		t.Fatal("Loop did not fail")
+	}
+	var terr *ThreadInitError
+	if !errors.As(err, &terr) || terr.Window != "thread" || terr.Err != failed {
+		t.Fatalf("Loop: %v, want a *ThreadInitError", err)
+	}
+	if !errors.Is(err, ErrThreadInit) || errors.Is(err, ErrRendererInit) {
+		t.Errorf("errors.Is of %v", err)
+	}
+	// the renderer is not initialized
+	if names := l.names(); len(names) != 1 || names[0] != "init" {
+		t.Errorf("calls %v, want only init", names)
+	}
+}
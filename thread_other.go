@@ -0,0 +1,8 @@
+// +build !windows
+
+package gui
+
+// initCOM does nothing without COM.
+func initCOM(apartment Apartment) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,28 @@
+package gui
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// initCOM initializes COM on the current thread for apartment and returns
+// the function to uninitialize it.
+func initCOM(apartment Apartment) (func(), error) {
+	var coInit uint32
+	switch apartment {
+	case ApartmentNone:
+		return func() {}, nil
+	case ApartmentSTA:
+		coInit = COINIT_APARTMENTTHREADED
+	case ApartmentMTA:
+		coInit = COINIT_MULTITHREADED
+	default:
+		return nil, fmt.Errorf("CoInitializeEx: unknown apartment %d", apartment)
+	}
+
+	// S_FALSE is an initialized thread, which is uninitialized as well
+	if err := CoInitializeEx(0, coInit|COINIT_DISABLE_OLE1DDE); err != nil && err != syscall.Errno(S_FALSE) {
+		return nil, fmt.Errorf("CoInitializeEx: %v", err)
+	}
+	return CoUninitialize, nil
+}
@@ -0,0 +1,112 @@
+package gui
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer calls a function on the loop thread of its window,
+// see Window.AfterFunc and Window.Tick.
+type Timer struct {
+	w      *window
+	f      func()
+	period time.Duration // of Tick, or 0
+	done   bool          // stopped or fired
+
+	nativeTimer
+}
+
+// Stop stops t and reports whether it was running, i.e. AfterFunc has not
+// called its function yet. It must be called on the loop thread.
+func (t *Timer) Stop() bool {
+	if t.done {
+		return false
+	}
+	t.done = true
+	t.w.stopTimer(t)
+	return true
+}
+
+// fire calls the function of t on the loop thread.
+func (w *window) fire(t *Timer) {
+	if t.period == 0 {
+		t.done = true
+	}
+	w.guard(PhaseTimer, func() error {
+		t.f()
+		return nil
+	})
+}
+
+// VirtualClock is a clock which only moves by Advance, so that tests see the
+// timers of their windows fire deterministically, see WithVirtualClock.
+type VirtualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	windows map[*window]bool
+}
+
+// NewVirtualClock returns a clock at start.
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{
+		now:     start,
+		windows: make(map[*window]bool),
+	}
+}
+
+// Now returns the time of the clock.
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock by d and returns when the loops have called the
+// timers due by then in the order of their times. It must not be called on
+// the thread of such a loop, e.g. from a timer.
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	windows := make([]*window, 0, len(c.windows))
+	for w := range c.windows {
+		windows = append(windows, w)
+	}
+	c.mu.Unlock()
+
+	for _, w := range windows {
+		fired := make(chan struct{})
+		if !w.post(func() {
+			w.fireTimers()
+			close(fired)
+		}) {
+			continue
+		}
+		select {
+		case <-fired:
+		case <-w.donec:
+		}
+	}
+}
+
+// add makes Advance fire the timers of w until remove.
+func (c *VirtualClock) add(w *window) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.windows[w] = true
+}
+
+func (c *VirtualClock) remove(w *window) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.windows, w)
+}
+
+// WithVirtualClock makes the timers use c instead of the system clock, e.g.
+// for tests with the headless backend. Ticks are not dropped when the clock
+// is advanced by more than a period. It is ignored on Windows, whose timers
+// are native.
+func WithVirtualClock(c *VirtualClock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
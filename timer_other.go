@@ -0,0 +1,117 @@
+// +build !windows
+
+package gui
+
+import (
+	"container/heap"
+	"time"
+)
+
+// nativeTimer is the place of a Timer in the timerQueue of its window.
+type nativeTimer struct {
+	when  time.Time
+	seq   uint64 // orders timers of the same time
+	index int    // in the queue, or -1
+}
+
+// timerQueue is a heap of timers by time.
+type timerQueue []*Timer
+
+func (q timerQueue) Len() int { return len(q) }
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].when.Equal(q[j].when) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].when.Before(q[j].when)
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x interface{}) {
+	t := x.(*Timer)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+
+func (q *timerQueue) Pop() interface{} {
+	old := *q
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	t.index = -1
+	*q = old[:len(old)-1]
+	return t
+}
+
+func (w *window) AfterFunc(d time.Duration, f func()) *Timer {
+	return w.addTimer(d, 0, f)
+}
+
+func (w *window) Tick(d time.Duration, f func()) *Timer {
+	if d <= 0 {
+		panic("gui: non-positive interval for Tick")
+	}
+	return w.addTimer(d, d, f)
+}
+
+func (w *window) addTimer(d time.Duration, period time.Duration, f func()) *Timer {
+	t := &Timer{w: w, f: f, period: period}
+	t.when = w.now().Add(d)
+	w.timerSeq++
+	t.seq = w.timerSeq
+	heap.Push(&w.timers, t)
+	return t
+}
+
+func (w *window) stopTimer(t *Timer) {
+	if t.index >= 0 {
+		heap.Remove(&w.timers, t.index)
+	}
+}
+
+// now returns the time of the clock of the timers.
+func (w *window) now() time.Time {
+	if c := w.app.opts.clock; c != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// timerTimeout returns how long poll may wait for the next timer, or -1.
+func (w *window) timerTimeout() time.Duration {
+	if len(w.timers) == 0 {
+		return -1
+	}
+	d := w.timers[0].when.Sub(w.now())
+	if d <= 0 {
+		return 0
+	}
+	if w.app.opts.clock != nil {
+		// VirtualClock.Advance wakes the loop
+		return -1
+	}
+	return d
+}
+
+// fireTimers calls the timers which are due in the order of their times.
+func (w *window) fireTimers() {
+	now := w.now()
+	for len(w.timers) > 0 && !w.timers[0].when.After(now) && w.err == nil {
+		t := w.timers[0]
+		if t.period == 0 {
+			heap.Pop(&w.timers)
+		} else {
+			t.when = t.when.Add(t.period)
+			if w.app.opts.clock == nil && !t.when.After(now) {
+				// drop the missed ticks
+				t.when = now.Add(t.period - now.Sub(t.when)%t.period)
+			}
+			heap.Fix(&w.timers, t.index)
+		}
+		w.fire(t)
+	}
+}
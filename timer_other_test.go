@@ -0,0 +1,162 @@
+// +build !windows
+
+package gui
+
+import (
+	"image/color"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startClockWindow runs a headless window whose timers use a virtual clock.
+func startClockWindow(t *testing.T) (*window, *VirtualClock) {
+	clock := NewVirtualClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	app := NewApplication(WithBackend("headless"), WithVirtualClock(clock))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("timer", 8, 8, r)
+	w := (<-r.window).(*window)
+	t.Cleanup(func() {
+		app.Quit(0)
+		if err := <-errc; err != nil {
+			t.Errorf("Loop: %v", err)
+		}
+		app.Deinit()
+		if err := CheckLeaks(app); err != nil {
+			t.Error(err)
+		}
+	})
+	return w, clock
+}
+
+// onLoop calls f on the loop thread of w and waits for it.
+func onLoop(t *testing.T, w *window, f func()) {
+	done := make(chan struct{})
+	if !w.post(func() {
+		f()
+		close(done)
+	}) {
+		t.Fatal("the loop has ended")
+	}
+	select {
+	case <-done:
+	case <-testTimeout():
+		t.Fatal("the loop did not run")
+	}
+}
+
+// firings records the calls of timers.
+type firings struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (f *firings) add(name string) func() {
+	return func() {
+		f.mu.Lock()
+		f.names = append(f.names, name)
+		f.mu.Unlock()
+	}
+}
+
+func (f *firings) check(t *testing.T, when string, want ...string) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !reflect.DeepEqual(f.names, want) {
+		t.Errorf("%s: fired %v, want %v", when, f.names, want)
+	}
+}
+
+func TestVirtualClockAfterFunc(t *testing.T) {
+	w, clock := startClockWindow(t)
+	var f firings
+	onLoop(t, w, func() {
+		w.AfterFunc(10*time.Millisecond, f.add("10ms"))
+		w.AfterFunc(5*time.Millisecond, f.add("5ms"))
+		w.AfterFunc(5*time.Millisecond, f.add("5ms again"))
+	})
+
+	clock.Advance(4 * time.Millisecond)
+	f.check(t, "at 4ms")
+	clock.Advance(time.Millisecond)
+	f.check(t, "at 5ms", "5ms", "5ms again")
+	clock.Advance(time.Hour)
+	f.check(t, "later", "5ms", "5ms again", "10ms")
+	clock.Advance(time.Hour)
+	f.check(t, "once", "5ms", "5ms again", "10ms")
+}
+
+func TestVirtualClockTick(t *testing.T) {
+	w, clock := startClockWindow(t)
+	var f firings
+	var tick *Timer
+	onLoop(t, w, func() {
+		tick = w.Tick(10*time.Millisecond, f.add("tick"))
+	})
+
+	clock.Advance(9 * time.Millisecond)
+	f.check(t, "at 9ms")
+	clock.Advance(26 * time.Millisecond)
+	// ticks are not dropped
+	f.check(t, "at 35ms", "tick", "tick", "tick")
+	clock.Advance(5 * time.Millisecond)
+	f.check(t, "at 40ms", "tick", "tick", "tick", "tick")
+
+	onLoop(t, w, func() {
+		if !tick.Stop() {
+			t.Error("Stop of a Tick returned false")
+		}
+	})
+	clock.Advance(time.Second)
+	f.check(t, "after Stop", "tick", "tick", "tick", "tick")
+}
+
+func TestVirtualClockStop(t *testing.T) {
+	w, clock := startClockWindow(t)
+	var f firings
+	var stopped, fired, self *Timer
+	onLoop(t, w, func() {
+		stopped = w.AfterFunc(time.Millisecond, f.add("stopped"))
+		fired = w.AfterFunc(time.Millisecond, f.add("fired"))
+		// a tick stopping itself
+		n := 0
+		self = w.Tick(time.Millisecond, func() {
+			f.add("self")()
+			if n++; n == 2 {
+				self.Stop()
+			}
+		})
+		if !stopped.Stop() {
+			t.Error("Stop of a pending timer returned false")
+		}
+		if stopped.Stop() {
+			t.Error("Stop of a stopped timer returned true")
+		}
+	})
+
+	clock.Advance(time.Millisecond)
+	f.check(t, "at 1ms", "fired", "self")
+	clock.Advance(5 * time.Millisecond)
+	f.check(t, "at 6ms", "fired", "self", "self")
+	onLoop(t, w, func() {
+		if fired.Stop() {
+			t.Error("Stop of a fired timer returned true")
+		}
+		if self.Stop() {
+			t.Error("Stop of a stopped tick returned true")
+		}
+	})
+}
+
+func TestVirtualClockWithoutWindows(t *testing.T) {
+	clock := NewVirtualClock(time.Time{})
+	clock.Advance(time.Minute)
+	if got := clock.Now(); !got.Equal(time.Time{}.Add(time.Minute)) {
+		t.Errorf("Now = %v", got)
+	}
+}
@@ -0,0 +1,96 @@
+package gui
+
+import (
+	"time"
+	"unsafe"
+)
+
+// timerIDBase is the first ID of SetTimer for Timer, above the IDs which
+// renderers may use with the window.
+const timerIDBase = 0x10000
+
+// nativeTimer is the ID of a Timer for SetTimer.
+type nativeTimer struct {
+	id uintptr
+}
+
+func (w *window) AfterFunc(d time.Duration, f func()) *Timer {
+	return w.addTimer(d, 0, f)
+}
+
+func (w *window) Tick(d time.Duration, f func()) *Timer {
+	if d <= 0 {
+		panic("gui: non-positive interval for Tick")
+	}
+	return w.addTimer(d, d, f)
+}
+
+// addTimer sets a timer of the window, which sends WM_TIMER after d and
+// then every d.
+func (w *window) addTimer(d time.Duration, period time.Duration, f func()) *Timer {
+	t := &Timer{w: w, f: f, period: period}
+	if w.timers == nil {
+		w.timers = make(map[uintptr]*Timer)
+	}
+	w.timerID++
+	t.id = timerIDBase + w.timerID
+
+	if _, err := SetTimer(w.handle, t.id, timerElapse(d), 0); err != nil {
+		if w.app.logger != nil {
+			w.app.logger.Printf("SetTimer: %p, %v\n", unsafe.Pointer(w.handle), err)
+		}
+		t.done = true
+		return t
+	}
+	w.timers[t.id] = t
+	return t
+}
+
+// timerElapse returns d in milliseconds for SetTimer, rounded up.
+// The first tick of Tick is after its period too.
+func timerElapse(d time.Duration) uint32 {
+	const max = 0x7fffffff // USER_TIMER_MAXIMUM
+	ms := (d + time.Millisecond - 1) / time.Millisecond
+	if ms < 0 {
+		return 0
+	}
+	if ms > max {
+		return max
+	}
+	return uint32(ms)
+}
+
+func (w *window) stopTimer(t *Timer) {
+	if w.timers[t.id] != t {
+		return
+	}
+	KillTimer(w.handle, t.id)
+	delete(w.timers, t.id)
+}
+
+// timer handles WM_TIMER and reports whether id is of a Timer.
+func (w *window) timer(id uintptr) bool {
+	t := w.timers[id]
+	if t == nil {
+		return false
+	}
+	if t.period == 0 {
+		KillTimer(w.handle, id)
+		delete(w.timers, id)
+	}
+	w.fire(t)
+	return true
+}
+
+// closeTimers stops the timers when the window is destroyed.
+func (w *window) closeTimers() {
+	for id, t := range w.timers {
+		t.done = true
+		delete(w.timers, id)
+	}
+}
+
+// fireTimers does nothing: the timers are native and no window is added to
+// a VirtualClock, which is ignored on Windows.
+func (w *window) fireTimers() {
+}
@@ -0,0 +1,62 @@
+package gui
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// timerFd wakes a driver blocked in poll(2) when the timeout of poll,
+// i.e. the next Timer of the window, expires.
+type timerFd struct {
+	fd int
+	ok bool
+}
+
+func (t *timerFd) open() error {
+	fd, err := unix.TimerfdCreate(unix.CLOCK_MONOTONIC, unix.TFD_CLOEXEC|unix.TFD_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("TimerfdCreate: %v", err)
+	}
+	t.fd, t.ok = fd, true
+	return nil
+}
+
+func (t *timerFd) close() {
+	if t.ok {
+		unix.Close(t.fd)
+		t.ok = false
+	}
+}
+
+// set arms the timer to expire after timeout, or disarms it if timeout is
+// negative.
+func (t *timerFd) set(timeout time.Duration) error {
+	var spec unix.ItimerSpec
+	if timeout >= 0 {
+		if timeout == 0 {
+			// a zero value disarms the timer
+			timeout = 1
+		}
+		spec.Value = unix.NsecToTimespec(int64(timeout))
+	}
+	if err := unix.TimerfdSettime(t.fd, 0, &spec, nil); err != nil {
+		return fmt.Errorf("TimerfdSettime: %v", err)
+	}
+	return nil
+}
+
+func (t *timerFd) pollFd() unix.PollFd {
+	return unix.PollFd{Fd: int32(t.fd), Events: unix.POLLIN}
+}
+
+// expired reads the expiration after poll, and reports if there was one.
+func (t *timerFd) expired(fd unix.PollFd) bool {
+	if fd.Revents == 0 {
+		return false
+	}
+	var buf [8]byte
+	n, _ := unix.Read(t.fd, buf[:])
+	return n == len(buf)
+}
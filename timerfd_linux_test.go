@@ -0,0 +1,103 @@
+package gui
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestTimerFd(t *testing.T) {
+	var timer timerFd
+	if err := timer.open(); err != nil {
+		t.Fatal(err)
+	}
+	defer timer.close()
+
+	wait := func(ms int) bool {
+		fds := []unix.PollFd{timer.pollFd()}
+		if _, err := unix.Poll(fds, ms); err != nil {
+			t.Fatal(err)
+		}
+		return timer.expired(fds[0])
+	}
+	for _, timeout := range []time.Duration{0, 10 * time.Millisecond} {
+		start := time.Now()
+		if err := timer.set(timeout); err != nil {
+			t.Fatal(err)
+		}
+		if !wait(1000) {
+			t.Errorf("%v: not expired", timeout)
+		}
+		if d := time.Since(start); d < timeout {
+			t.Errorf("%v: expired after %v", timeout, d)
+		}
+		// an expiration is read once
+		if wait(0) {
+			t.Errorf("%v: expired twice", timeout)
+		}
+	}
+
+	if err := timer.set(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := timer.set(-1); err != nil {
+		t.Fatal(err)
+	}
+	if wait(20) {
+		t.Error("a disarmed timer expired")
+	}
+}
+
+// TestTimerX11 waits for the timers in the poll loop of X11, which the
+// timer fd wakes without any event of the server.
+func TestTimerX11(t *testing.T) {
+	startXServer(t)
+	app := NewApplication(WithBackend("x11"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("timer", 8, 8, r)
+	w := (<-r.window).(*window)
+
+	var f firings
+	fired := make(chan struct{})
+	var start time.Time
+	var ticks int
+	var tick *Timer
+	onLoop(t, w, func() {
+		start = time.Now()
+		w.AfterFunc(30*time.Millisecond, func() {
+			f.add("30ms")()
+			if d := time.Since(start); d < 30*time.Millisecond {
+				t.Errorf("fired after %v", d)
+			}
+		})
+		tick = w.Tick(10*time.Millisecond, func() {
+			if ticks++; ticks == 2 {
+				f.add("tick")()
+				tick.Stop()
+				close(fired)
+			}
+		})
+	})
+	select {
+	case <-fired:
+	case <-testTimeout():
+		t.Fatal("the ticker did not fire")
+	}
+	time.Sleep(50 * time.Millisecond)
+	onLoop(t, w, func() {})
+	f.check(t, "after 50ms", "tick", "30ms")
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+	app.Deinit()
+	if err := CheckLeaks(app); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,135 @@
+package gui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceCategory is a set of categories of traced events.
+type TraceCategory uint32
+
+// Trace categories
+const (
+	TraceInput     TraceCategory = 1 << iota // keys, pointer and IME
+	TracePaint                               // drawing and exposure
+	TraceLifecycle                           // creation, size, focus and closing
+	TraceOther
+	TraceAll = TraceInput | TracePaint | TraceLifecycle | TraceOther
+)
+
+var traceCategoryNames = []string{
+	"input",
+	"paint",
+	"lifecycle",
+	"other",
+}
+
+func (c TraceCategory) String() string {
+	var names []string
+	for i, name := range traceCategoryNames {
+		if c&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// MarshalText encodes the names of the categories, e.g. in JSON.
+func (c TraceCategory) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// TraceEvent is a native message or event seen by the loop of a window.
+type TraceEvent struct {
+	Time     time.Time     `json:"time"`
+	Backend  string        `json:"backend"`
+	Window   string        `json:"window"`           // name of the window, if known
+	Source   string        `json:"source"`           // e.g. "GetMessage", "windowProc" or "poll"
+	Category TraceCategory `json:"category"`         // a single category
+	Name     string        `json:"name"`             // native name, e.g. "WM_PAINT" or "wl_pointer.motion"
+	ID       uint32        `json:"id,omitempty"`     // native message ID, if any
+	Detail   string        `json:"detail,omitempty"` // parameters
+}
+
+// Tracer receives the traced events on the loop threads.
+type Tracer interface {
+	Trace(e *TraceEvent)
+}
+
+// TracerFunc is a function as Tracer.
+type TracerFunc func(e *TraceEvent)
+
+// Trace calls f(e).
+func (f TracerFunc) Trace(e *TraceEvent) {
+	f(e)
+}
+
+// WithTracer traces the events of categories, or of all if it is 0, to t.
+// EnableLog traces all events to stderr if there is no tracer.
+//
+// Besides the events of poll, the backends trace their native events by
+// name: window messages on Windows, Wayland and X11 events, the evdev event
+// types of fbdev, the escape sequences of tui, the RFB messages of vnc and
+// the DOM events of web. The headless backend has none.
+func WithTracer(t Tracer, categories TraceCategory) Option {
+	return func(o *options) {
+		if categories == 0 {
+			categories = TraceAll
+		}
+		o.tracer = t
+		o.traceCategories = categories
+	}
+}
+
+// tracing reports whether events of category are traced.
+func (o *options) tracing(category TraceCategory) bool {
+	return o.tracer != nil && o.traceCategories&category != 0
+}
+
+// trace sends e to the tracer if its category is traced.
+func (o *options) trace(e *TraceEvent) {
+	if !o.tracing(e.Category) {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	o.tracer.Trace(e)
+}
+
+// NewTextTracer writes a line per event to w.
+func NewTextTracer(w io.Writer) Tracer {
+	var mu sync.Mutex
+	return TracerFunc(func(e *TraceEvent) {
+		line := fmt.Sprintf("%s %s %q %s: %s", e.Time.Format("2006/01/02 15:04:05.000000"), e.Backend, e.Window, e.Source, e.Name)
+		if e.ID != 0 {
+			line += fmt.Sprintf(" (0x%04x)", e.ID)
+		}
+		line += " [" + e.Category.String() + "]"
+		if e.Detail != "" {
+			line += " " + e.Detail
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, line+"\n")
+	})
+}
+
+// NewJSONTracer writes a JSON object per line to w.
+func NewJSONTracer(w io.Writer) Tracer {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+	return TracerFunc(func(e *TraceEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(e)
+	})
+}
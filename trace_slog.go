@@ -0,0 +1,34 @@
+// +build go1.21
+
+package gui
+
+import (
+	"context"
+	"log/slog"
+)
+
+// NewSlogTracer logs each event to l at level with the event name as the message.
+func NewSlogTracer(l *slog.Logger, level slog.Level) Tracer {
+	return TracerFunc(func(e *TraceEvent) {
+		ctx := context.Background()
+		if !l.Enabled(ctx, level) {
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("backend", e.Backend),
+			slog.String("window", e.Window),
+			slog.String("source", e.Source),
+			slog.String("category", e.Category.String()),
+		}
+		if e.ID != 0 {
+			attrs = append(attrs, slog.Any("id", e.ID))
+		}
+		if e.Detail != "" {
+			attrs = append(attrs, slog.String("detail", e.Detail))
+		}
+		// keep the time of the event
+		r := slog.NewRecord(e.Time, level, e.Name, 0)
+		r.AddAttrs(attrs...)
+		l.Handler().Handle(ctx, r)
+	})
+}
@@ -0,0 +1,44 @@
+// +build go1.21
+
+package gui
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogTracer(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	e := testTraceEvent
+	NewSlogTracer(l, slog.LevelInfo).Trace(&e)
+	// below the level of the handler
+	NewSlogTracer(l, slog.LevelDebug).Trace(&e)
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%v: %s", err, buf.Bytes())
+	}
+	want := map[string]interface{}{
+		"time":     "2020-01-02T03:04:05.000006Z",
+		"level":    "INFO",
+		"msg":      "wl_pointer.motion",
+		"backend":  "wayland",
+		"window":   "main",
+		"source":   "dispatch",
+		"category": "input",
+		"id":       float64(0x12),
+		"detail":   "x=1 y=2",
+	}
+	if len(got) != len(want) {
+		t.Errorf("record %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
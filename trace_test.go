@@ -0,0 +1,137 @@
+package gui
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+// traceRecorder keeps the traced events.
+type traceRecorder struct {
+	mu     sync.Mutex
+	events []TraceEvent
+	added  chan struct{}
+}
+
+func newTraceRecorder() *traceRecorder {
+	return &traceRecorder{added: make(chan struct{}, 1)}
+}
+
+func (r *traceRecorder) Trace(e *TraceEvent) {
+	r.mu.Lock()
+	r.events = append(r.events, *e)
+	r.mu.Unlock()
+	select {
+	case r.added <- struct{}{}:
+	default:
+	}
+}
+
+// wait waits for a traced event of source and name.
+func (r *traceRecorder) wait(t *testing.T, source, name string) TraceEvent {
+	t.Helper()
+	for {
+		r.mu.Lock()
+		for _, e := range r.events {
+			if e.Source == source && e.Name == name {
+				r.mu.Unlock()
+				return e
+			}
+		}
+		r.mu.Unlock()
+		select {
+		case <-r.added:
+		case <-testTimeout():
+			t.Fatalf("%s %s was not traced", source, name)
+		}
+	}
+}
+
+var testTraceEvent = TraceEvent{
+	Time:     time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC),
+	Backend:  "wayland",
+	Window:   "main",
+	Source:   "dispatch",
+	Category: TraceInput,
+	Name:     "wl_pointer.motion",
+	ID:       0x12,
+	Detail:   "x=1 y=2",
+}
+
+func TestTextTracer(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTextTracer(&buf)
+	e := testTraceEvent
+	tracer.Trace(&e)
+	e.ID, e.Detail, e.Category = 0, "", TracePaint
+	tracer.Trace(&e)
+
+	want := "2020/01/02 03:04:05.000006 wayland \"main\" dispatch: wl_pointer.motion (0x0012) [input] x=1 y=2\n" +
+		"2020/01/02 03:04:05.000006 wayland \"main\" dispatch: wl_pointer.motion [paint]\n"
+	if buf.String() != want {
+		t.Errorf("output\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONTracer(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewJSONTracer(&buf)
+	e := testTraceEvent
+	tracer.Trace(&e)
+	e.ID, e.Detail = 0, ""
+	tracer.Trace(&e)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("%d lines:\n%s", len(lines), buf.Bytes())
+	}
+	want := `{"time":"2020-01-02T03:04:05.000006Z","backend":"wayland","window":"main","source":"dispatch","category":"input","name":"wl_pointer.motion","id":18,"detail":"x=1 y=2"}`
+	if string(lines[0]) != want {
+		t.Errorf("line %s\nwant %s", lines[0], want)
+	}
+	// the empty fields are omitted
+	var fields map[string]interface{}
+	if err := json.Unmarshal(lines[1], &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["id"]; ok {
+		t.Errorf("id in %s", lines[1])
+	}
+	if _, ok := fields["detail"]; ok {
+		t.Errorf("detail in %s", lines[1])
+	}
+}
+
+func TestTraceCategoryString(t *testing.T) {
+	for c, want := range map[TraceCategory]string{
+		0:                           "none",
+		TraceInput:                  "input",
+		TracePaint | TraceOther:     "paint|other",
+		TraceAll:                    "input|paint|lifecycle|other",
+		TraceLifecycle | 1<<10:      "lifecycle",
+		TraceInput | TraceLifecycle: "input|lifecycle",
+	} {
+		if s := c.String(); s != want {
+			t.Errorf("%d: %q, want %q", uint32(c), s, want)
+		}
+	}
+}
+
+// TestWithTracer traces only the categories of WithTracer.
+func TestWithTracer(t *testing.T) {
+	r := newTraceRecorder()
+	o := newOptions([]Option{WithTracer(r, TracePaint)})
+	o.trace(&TraceEvent{Category: TraceInput, Name: "key"})
+	o.trace(&TraceEvent{Category: TracePaint, Name: "expose"})
+	if len(r.events) != 1 || r.events[0].Name != "expose" || r.events[0].Time.IsZero() {
+		t.Errorf("traced %+v, want expose with a time", r.events)
+	}
+
+	// all categories for 0
+	o = newOptions([]Option{WithTracer(r, 0)})
+	if o.traceCategories != TraceAll {
+		t.Errorf("categories %v, want all", o.traceCategories)
+	}
+}
@@ -0,0 +1,66 @@
+package gui
+
+import (
+	"image"
+	"image/draw"
+)
+
+// TrayEventKind is the kind of a TrayEvent.
+type TrayEventKind int
+
+// Tray event kinds
+const (
+	TrayClick TrayEventKind = iota
+	TrayDoubleClick
+	TraySecondaryClick
+	TrayContextMenu // only when the tray icon has no menu
+	TrayBalloonClick
+)
+
+// TrayEvent is delivered when the user interacts with a tray icon.
+// Its Window is nil.
+type TrayEvent struct {
+	EventHeader
+	Kind TrayEventKind
+	X, Y int32 // screen position, if known
+}
+
+// TrayOptions are the parameters of a tray icon.
+type TrayOptions struct {
+	Icon    image.Image // nil for the default application icon
+	Tooltip string
+	Menu    *Menu // shown on right click
+
+	// Handler receives *TrayEvent and *CommandEvent for menu selections.
+	// It is called on a goroutine owned by the tray icon.
+	Handler func(e Event)
+}
+
+// TrayIcon is an icon in the system tray (notification area).
+// It works without any window.
+type TrayIcon interface {
+	SetIcon(icon image.Image) error
+	SetTooltip(text string) error
+	SetMenu(menu *Menu) error
+	ShowBalloon(title, text string) error
+	Close() error
+}
+
+// NewTrayIcon creates a tray icon and shows it.
+func NewTrayIcon(opts *TrayOptions) (TrayIcon, error) {
+	if opts == nil {
+		opts = &TrayOptions{}
+	}
+	if err := opts.Menu.validate(); err != nil {
+		return nil, err
+	}
+	return newTrayIcon(opts)
+}
+
+// toNRGBA converts img to non-premultiplied RGBA with the origin at (0, 0).
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return dst
+}
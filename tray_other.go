@@ -0,0 +1,391 @@
+// +build !windows
+
+package gui
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ysh86/gui/internal/dbus"
+)
+
+const (
+	sniPath      = dbus.ObjectPath("/StatusNotifierItem")
+	sniInterface = "org.kde.StatusNotifierItem"
+	menuPath     = dbus.ObjectPath("/MenuBar")
+	menuIface    = "com.canonical.dbusmenu"
+	propsIface   = "org.freedesktop.DBus.Properties"
+)
+
+var trayCount uint32
+
+// trayIcon is a StatusNotifierItem with a com.canonical.dbusmenu menu on the session bus.
+type trayIcon struct {
+	conn     *dbus.Conn
+	name     string
+	handler  func(e Event)
+	notifier *notifier
+
+	mu       sync.Mutex
+	pixmap   []interface{} // a(iiay)
+	tooltip  string
+	menu     *Menu
+	revision uint32
+	items    map[int32]*MenuItem // dbusmenu ID to item
+}
+
+func newTrayIcon(opts *TrayOptions) (TrayIcon, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+	return newTrayIconOnConn(conn, opts)
+}
+
+// newTrayIconOnConn registers a tray icon on conn, which may be any bus including a fake one.
+func newTrayIconOnConn(conn *dbus.Conn, opts *TrayOptions) (TrayIcon, error) {
+	t := &trayIcon{
+		conn:    conn,
+		name:    fmt.Sprintf("org.kde.StatusNotifierItem-%d-%d", os.Getpid(), atomic.AddUint32(&trayCount, 1)),
+		handler: opts.Handler,
+		tooltip: opts.Tooltip,
+		menu:    opts.Menu,
+	}
+	if opts.Icon != nil {
+		t.pixmap = sniPixmap(opts.Icon)
+	}
+	t.layout()
+
+	// do not queue, the name is unique to this icon
+	if err := conn.RequestName(t.name, 0x4); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.Export(sniPath, sniInterface, t.handleItem)
+	conn.Export(sniPath, propsIface, t.handleItemProps)
+	conn.Export(menuPath, menuIface, t.handleMenu)
+	conn.Export(menuPath, propsIface, t.handleMenuProps)
+
+	_, err := conn.Call("org.kde.StatusNotifierWatcher", "/StatusNotifierWatcher", "org.kde.StatusNotifierWatcher", "RegisterStatusNotifierItem", "s", t.name)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("RegisterStatusNotifierItem: %v", err)
+	}
+	return t, nil
+}
+
+func (t *trayIcon) SetIcon(icon image.Image) error {
+	t.mu.Lock()
+	t.pixmap = nil
+	if icon != nil {
+		t.pixmap = sniPixmap(icon)
+	}
+	t.mu.Unlock()
+
+	return t.conn.Emit(sniPath, sniInterface, "NewIcon", "")
+}
+
+func (t *trayIcon) SetTooltip(text string) error {
+	t.mu.Lock()
+	t.tooltip = text
+	t.mu.Unlock()
+
+	return t.conn.Emit(sniPath, sniInterface, "NewToolTip", "")
+}
+
+func (t *trayIcon) SetMenu(menu *Menu) error {
+	if err := menu.validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.menu = menu
+	revision := t.layout()
+	t.mu.Unlock()
+
+	return t.conn.Emit(menuPath, menuIface, "LayoutUpdated", "ui", revision, int32(0))
+}
+
+func (t *trayIcon) ShowBalloon(title, text string) error {
+	t.mu.Lock()
+	if t.notifier == nil {
+		n, err := newNotifier(t.conn)
+		if err != nil {
+			t.mu.Unlock()
+			return err
+		}
+		t.notifier = n
+	}
+	t.mu.Unlock()
+
+	_, err := t.notifier.notify(title, text, nil, nil, map[string]func(){
+		"default": func() {
+			t.dispatch(&TrayEvent{Kind: TrayBalloonClick})
+		},
+	})
+	return err
+}
+
+func (t *trayIcon) Close() error {
+	return t.conn.Close()
+}
+
+func (t *trayIcon) dispatch(e Event) {
+	if t.handler != nil {
+		t.handler(e)
+	}
+}
+
+func (t *trayIcon) handleItem(m *dbus.Message) (dbus.Signature, []interface{}, error) {
+	var x, y int32
+	if len(m.Body) >= 2 {
+		x, _ = m.Body[0].(int32)
+		y, _ = m.Body[1].(int32)
+	}
+
+	switch m.Member {
+	case "Activate":
+		t.dispatch(&TrayEvent{Kind: TrayClick, X: x, Y: y})
+	case "SecondaryActivate":
+		t.dispatch(&TrayEvent{Kind: TraySecondaryClick, X: x, Y: y})
+	case "ContextMenu":
+		t.dispatch(&TrayEvent{Kind: TrayContextMenu, X: x, Y: y})
+	case "Scroll":
+	default:
+		return "", nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod", Body: []interface{}{m.Member}}
+	}
+	return "", nil, nil
+}
+
+func (t *trayIcon) itemProps() map[string]dbus.Variant {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pixmap := t.pixmap
+	if pixmap == nil {
+		pixmap = []interface{}{}
+	}
+	return map[string]dbus.Variant{
+		"Category":   dbus.MakeVariant("ApplicationStatus"),
+		"Id":         dbus.MakeVariant(t.name),
+		"Title":      dbus.MakeVariant(t.tooltip),
+		"Status":     dbus.MakeVariant("Active"),
+		"IconName":   dbus.MakeVariant(""),
+		"IconPixmap": {Sig: "a(iiay)", Value: pixmap},
+		"ToolTip":    {Sig: "(sa(iiay)ss)", Value: []interface{}{"", []interface{}{}, t.tooltip, ""}},
+		"ItemIsMenu": dbus.MakeVariant(false),
+		"Menu":       dbus.MakeVariant(menuPath),
+	}
+}
+
+func (t *trayIcon) handleItemProps(m *dbus.Message) (dbus.Signature, []interface{}, error) {
+	return handleProps(m, sniInterface, t.itemProps())
+}
+
+func (t *trayIcon) handleMenuProps(m *dbus.Message) (dbus.Signature, []interface{}, error) {
+	return handleProps(m, menuIface, map[string]dbus.Variant{
+		"Version":       dbus.MakeVariant(uint32(3)),
+		"Status":        dbus.MakeVariant("normal"),
+		"TextDirection": dbus.MakeVariant("ltr"),
+		"IconThemePath": dbus.MakeVariant([]string{}),
+	})
+}
+
+// handleProps implements org.freedesktop.DBus.Properties for read-only properties.
+func handleProps(m *dbus.Message, iface string, props map[string]dbus.Variant) (dbus.Signature, []interface{}, error) {
+	switch m.Member {
+	case "Get":
+		if len(m.Body) == 2 {
+			name, _ := m.Body[1].(string)
+			if v, ok := props[name]; ok {
+				return "v", []interface{}{v}, nil
+			}
+		}
+		return "", nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownProperty", Body: []interface{}{fmt.Sprint(m.Body)}}
+	case "GetAll":
+		return "a{sv}", []interface{}{props}, nil
+	case "Set":
+		return "", nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.PropertyReadOnly", Body: []interface{}{fmt.Sprint(m.Body)}}
+	}
+	return "", nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod", Body: []interface{}{m.Member}}
+}
+
+// menuArgs is the minimum number of arguments of the dbusmenu methods.
+var menuArgs = map[string]int{
+	"GetLayout":          2,
+	"GetGroupProperties": 1,
+	"Event":              2,
+	"EventGroup":         1,
+}
+
+func (t *trayIcon) handleMenu(m *dbus.Message) (dbus.Signature, []interface{}, error) {
+	if len(m.Body) < menuArgs[m.Member] {
+		return "", nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.InvalidArgs", Body: []interface{}{fmt.Sprintf("%s: %d arguments", m.Member, len(m.Body))}}
+	}
+
+	switch m.Member {
+	case "GetLayout":
+		parent, _ := m.Body[0].(int32)
+		depth, _ := m.Body[1].(int32)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		props := map[string]dbus.Variant{"children-display": dbus.MakeVariant("submenu")}
+		menu := t.menu
+		if parent != 0 {
+			item, ok := t.items[parent]
+			if !ok {
+				return "", nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.InvalidArgs", Body: []interface{}{fmt.Sprintf("no item %d", parent)}}
+			}
+			props, menu = menuItemProps(item), item.Submenu
+		}
+		// the children of an item are numbered after it
+		next := parent
+		node := []interface{}{parent, props, t.layoutChildren(menu, &next, depth)}
+		return "u(ia{sv}av)", []interface{}{t.revision, node}, nil
+	case "GetGroupProperties":
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		ids, _ := m.Body[0].([]interface{})
+		var groups []interface{}
+		for _, v := range ids {
+			id, _ := v.(int32)
+			if item, ok := t.items[id]; ok {
+				groups = append(groups, []interface{}{id, menuItemProps(item)})
+			}
+		}
+		if groups == nil {
+			groups = []interface{}{}
+		}
+		return "a(ia{sv})", []interface{}{groups}, nil
+	case "Event":
+		id, _ := m.Body[0].(int32)
+		eventID, _ := m.Body[1].(string)
+		if eventID == "clicked" {
+			t.clicked(id)
+		}
+		return "", nil, nil
+	case "EventGroup":
+		events, _ := m.Body[0].([]interface{})
+		for _, e := range events {
+			fields, _ := e.([]interface{})
+			if len(fields) >= 2 && fields[1] == "clicked" {
+				id, _ := fields[0].(int32)
+				t.clicked(id)
+			}
+		}
+		return "ai", []interface{}{[]int32{}}, nil
+	case "AboutToShow":
+		return "b", []interface{}{false}, nil
+	case "AboutToShowGroup":
+		return "aiai", []interface{}{[]int32{}, []int32{}}, nil
+	}
+	return "", nil, &dbus.Error{Name: "org.freedesktop.DBus.Error.UnknownMethod", Body: []interface{}{m.Member}}
+}
+
+func (t *trayIcon) clicked(id int32) {
+	t.mu.Lock()
+	item := t.items[id]
+	menu := t.menu
+	t.mu.Unlock()
+
+	if item == nil || item.ID == 0 {
+		return
+	}
+	if menu.activate(item.ID) != nil {
+		t.mu.Lock()
+		revision := t.layout()
+		t.mu.Unlock()
+		if item.Checkable || item.RadioGroup != 0 {
+			t.conn.Emit(menuPath, menuIface, "LayoutUpdated", "ui", revision, int32(0))
+		}
+		t.dispatch(&CommandEvent{ID: item.ID})
+	}
+}
+
+// layout numbers the items of the menu and returns the new revision.
+// The caller must hold t.mu.
+func (t *trayIcon) layout() uint32 {
+	t.items = make(map[int32]*MenuItem)
+	var next int32
+	var walk func(m *Menu)
+	walk = func(m *Menu) {
+		if m == nil {
+			return
+		}
+		for _, item := range m.Items {
+			next++
+			t.items[next] = item
+			walk(item.Submenu)
+		}
+	}
+	walk(t.menu)
+
+	t.revision++
+	return t.revision
+}
+
+// layoutChildren returns the av of (ia{sv}av) for the items of m in the
+// order of layout, down to depth levels or all of them if depth is negative.
+func (t *trayIcon) layoutChildren(m *Menu, next *int32, depth int32) []interface{} {
+	children := []interface{}{}
+	if m == nil {
+		return children
+	}
+	for _, item := range m.Items {
+		*next++
+		id := *next
+		// the items below depth are numbered all the same
+		node := []interface{}{id, menuItemProps(item), t.layoutChildren(item.Submenu, next, depth-1)}
+		if depth != 0 {
+			children = append(children, dbus.Variant{Sig: "(ia{sv}av)", Value: node})
+		}
+	}
+	return children
+}
+
+func menuItemProps(item *MenuItem) map[string]dbus.Variant {
+	if item.Separator {
+		return map[string]dbus.Variant{"type": dbus.MakeVariant("separator")}
+	}
+
+	props := map[string]dbus.Variant{
+		"label":   dbus.MakeVariant(item.Label),
+		"enabled": dbus.MakeVariant(!item.Disabled),
+	}
+	if item.Submenu != nil {
+		props["children-display"] = dbus.MakeVariant("submenu")
+	}
+	if item.Checkable || item.RadioGroup != 0 {
+		toggle := "checkmark"
+		if item.RadioGroup != 0 {
+			toggle = "radio"
+		}
+		state := int32(0)
+		if item.Checked {
+			state = 1
+		}
+		props["toggle-type"] = dbus.MakeVariant(toggle)
+		props["toggle-state"] = dbus.MakeVariant(state)
+	}
+	return props
+}
+
+// sniPixmap converts img to a(iiay) of ARGB32 in network byte order.
+func sniPixmap(img image.Image) []interface{} {
+	src := toNRGBA(img)
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+
+	argb := make([]byte, width*height*4)
+	for i := 0; i < width*height; i++ {
+		p := src.Pix[i*4 : i*4+4]
+		binary.BigEndian.PutUint32(argb[i*4:], uint32(p[3])<<24|uint32(p[0])<<16|uint32(p[1])<<8|uint32(p[2]))
+	}
+	return []interface{}{[]interface{}{int32(width), int32(height), argb}}
+}
@@ -0,0 +1,139 @@
+// +build !windows
+
+package gui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ysh86/gui/internal/dbus"
+)
+
+// newTestTray registers a tray icon with a fake StatusNotifierWatcher on a
+// private bus and returns it with a client connection.
+func newTestTray(t *testing.T, opts *TrayOptions) (*trayIcon, *dbus.Conn) {
+	addr := startBus(t)
+
+	watcher, err := dbus.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { watcher.Close() })
+	registered := make(chan string, 1)
+	watcher.Export("/StatusNotifierWatcher", "org.kde.StatusNotifierWatcher", func(m *dbus.Message) (dbus.Signature, []interface{}, error) {
+		name, _ := m.Body[0].(string)
+		registered <- name
+		return "", nil, nil
+	})
+	if err := watcher.RequestName("org.kde.StatusNotifierWatcher", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := dbus.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icon, err := newTrayIconOnConn(conn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { icon.Close() })
+	tray := icon.(*trayIcon)
+	if name := <-registered; name != tray.name {
+		t.Errorf("registered %q, want %q", name, tray.name)
+	}
+
+	client, err := dbus.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return tray, client
+}
+
+// layoutString formats the IDs of a (ia{sv}av) layout like "0(1 2(3))".
+func layoutString(node interface{}) string {
+	fields := node.([]interface{})
+	s := fmt.Sprint(fields[0])
+	children := fields[2].([]interface{})
+	if len(children) == 0 {
+		return s
+	}
+	var ids []string
+	for _, c := range children {
+		ids = append(ids, layoutString(c.(dbus.Variant).Value))
+	}
+	return s + "(" + strings.Join(ids, " ") + ")"
+}
+
+func TestTrayMenu(t *testing.T) {
+	events := make(chan Event, 4)
+	menu := &Menu{Items: []*MenuItem{
+		{Label: "File", Submenu: &Menu{Items: []*MenuItem{
+			{ID: 1, Label: "Open"},
+			{Label: "Recent", Submenu: &Menu{Items: []*MenuItem{
+				{ID: 2, Label: "a"},
+				{ID: 3, Label: "b"},
+			}}},
+		}}},
+		{ID: 4, Label: "Quit"},
+	}}
+	tray, client := newTestTray(t, &TrayOptions{Menu: menu, Handler: func(e Event) { events <- e }})
+
+	call := func(method string, sig dbus.Signature, args ...interface{}) (*dbus.Message, error) {
+		return client.Call(tray.name, menuPath, menuIface, method, sig, args...)
+	}
+
+	layouts := []struct {
+		parent, depth int32
+		want          string
+	}{
+		{0, -1, "0(1(2 3(4 5)) 6)"},
+		{0, 1, "0(1 6)"},
+		{1, 1, "1(2 3)"},
+		{3, -1, "3(4 5)"},
+		{3, 0, "3"},
+	}
+	for _, l := range layouts {
+		reply, err := call("GetLayout", "iias", l.parent, l.depth, []string{})
+		if err != nil {
+			t.Fatalf("GetLayout(%d, %d): %v", l.parent, l.depth, err)
+		}
+		if got := layoutString(reply.Body[1]); got != l.want {
+			t.Errorf("GetLayout(%d, %d) = %s, want %s", l.parent, l.depth, got, l.want)
+		}
+	}
+
+	// malformed calls are rejected instead of crashing the handler
+	invalid := []struct {
+		method string
+		sig    dbus.Signature
+		args   []interface{}
+	}{
+		{"GetLayout", "iias", []interface{}{int32(99), int32(-1), []string{}}},
+		{"GetLayout", "", nil},
+		{"GetGroupProperties", "", nil},
+		{"Event", "i", []interface{}{int32(2)}},
+		{"EventGroup", "", nil},
+	}
+	for _, c := range invalid {
+		_, err := call(c.method, c.sig, c.args...)
+		if e, ok := err.(*dbus.Error); !ok || e.Name != "org.freedesktop.DBus.Error.InvalidArgs" {
+			t.Errorf("%s%v: %v, want InvalidArgs", c.method, c.args, err)
+		}
+	}
+
+	// clicking Quit delivers its command
+	if _, err := call("Event", "isvu", int32(6), "clicked", dbus.MakeVariant(""), uint32(0)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-events:
+		if c, ok := e.(*CommandEvent); !ok || c.ID != 4 {
+			t.Errorf("event %#v, want command 4", e)
+		}
+	case <-testTimeout():
+		t.Fatal("no command")
+	}
+}
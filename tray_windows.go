@@ -0,0 +1,396 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// trayCallbackMessage is sent by the shell for notification icon events.
+const trayCallbackMessage = WM_APP + 1
+
+var (
+	trayClassOnce sync.Once
+	trayClassAtom Atom
+	trayClassErr  error
+	trayInstance  windows.Handle
+
+	trayMu    sync.Mutex
+	trayIcons = make(map[windows.Handle]*trayIcon)
+)
+
+// trayIcon owns a message-only window on its own thread to receive notifications.
+type trayIcon struct {
+	handle    windows.Handle
+	handler   func(e Event)
+	done      chan struct{}
+	transient bool // destroyed when the balloon goes away
+
+	mu      sync.Mutex
+	icon    windows.Handle
+	ownIcon bool
+	tooltip string
+	menu    *Menu
+}
+
+func newTrayIcon(opts *TrayOptions) (TrayIcon, error) {
+	t, err := newTrayIconWindows(opts, false)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// newTrayIconWindows starts the tray thread. A transient icon is destroyed with its balloon.
+func newTrayIconWindows(opts *TrayOptions, transient bool) (*trayIcon, error) {
+	t := &trayIcon{
+		transient: transient,
+		handler:   opts.Handler,
+		done:      make(chan struct{}),
+		tooltip:   opts.Tooltip,
+		menu:      opts.Menu,
+	}
+
+	errc := make(chan error, 1)
+	go t.loop(opts.Icon, errc)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func registerTrayClass() error {
+	trayClassOnce.Do(func() {
+		i, err := GetModuleHandle(nil)
+		if err != nil {
+			trayClassErr = fmt.Errorf("GetModuleHandle: %v", err)
+			return
+		}
+		trayInstance = i
+
+		className := "GO GUI: tray icon"
+		classNameUTF16, err := windows.UTF16PtrFromString(className)
+		if err != nil {
+			trayClassErr = fmt.Errorf("UTF16PtrFromString %s: %v", className, err)
+			return
+		}
+		wndClass := &WndClassEx{
+			WndProc:   windows.NewCallback(trayWindowProc),
+			Instance:  trayInstance,
+			ClassName: classNameUTF16,
+		}
+		wndClass.Size = uint32(unsafe.Sizeof(*wndClass))
+		atom, err := RegisterClassEx(wndClass)
+		if err != nil {
+			trayClassErr = fmt.Errorf("RegisterClassEx %v: %v", wndClass, err)
+			return
+		}
+		trayClassAtom = atom
+	})
+	return trayClassErr
+}
+
+func (t *trayIcon) loop(icon image.Image, errc chan<- error) {
+	// lock thread for the GetMessage() API
+	runtime.LockOSThread()
+	defer close(t.done)
+
+	if err := registerTrayClass(); err != nil {
+		errc <- err
+		return
+	}
+
+	h, err := CreateWindowEx(
+		0,
+		(*uint16)(unsafe.Pointer(uintptr(trayClassAtom))),
+		nil,
+		0,
+		0, 0, 0, 0,
+		windows.Handle(HWND_MESSAGE),
+		0,
+		trayInstance,
+		0,
+	)
+	if err != nil {
+		errc <- fmt.Errorf("CreateWindowEx: %v", err)
+		return
+	}
+	t.handle = h
+	trayMu.Lock()
+	trayIcons[h] = t
+	trayMu.Unlock()
+
+	t.icon, t.ownIcon, err = loadTrayIcon(icon)
+	if err != nil {
+		DestroyWindow(h)
+		errc <- err
+		return
+	}
+	data := t.data(NIF_MESSAGE | NIF_ICON | NIF_TIP | NIF_SHOWTIP)
+	if err := Shell_NotifyIcon(NIM_ADD, data); err != nil {
+		t.mu.Lock()
+		if t.ownIcon && t.icon != 0 {
+			DestroyIcon(t.icon)
+		}
+		t.icon = 0
+		t.mu.Unlock()
+		DestroyWindow(h)
+		errc <- fmt.Errorf("Shell_NotifyIcon: %v", err)
+		return
+	}
+	data.Version = NOTIFYICON_VERSION_4
+	Shell_NotifyIcon(NIM_SETVERSION, data)
+	errc <- nil
+
+	var msg Msg
+	for {
+		result, err := GetMessage(&msg, 0, 0, 0)
+		if err != nil || result == 0 {
+			break
+		}
+		TranslateMessage(&msg)
+		DispatchMessage(&msg)
+	}
+}
+
+// data returns NOTIFYICONDATA with the current state.
+func (t *trayIcon) data(flags uint32) *NotifyIconData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data := &NotifyIconData{
+		Wnd:             t.handle,
+		ID:              1,
+		Flags:           flags,
+		CallbackMessage: trayCallbackMessage,
+		Icon:            t.icon,
+	}
+	data.Size = uint32(unsafe.Sizeof(*data))
+	copyUTF16(data.Tip[:], t.tooltip)
+	return data
+}
+
+// loadTrayIcon creates an icon from img, or loads the default icon which must not be destroyed.
+func loadTrayIcon(img image.Image) (icon windows.Handle, own bool, err error) {
+	if img == nil {
+		icon, err = LoadIcon(0, MAKEINTRESOURCE(IDI_APPLICATION))
+		if err != nil {
+			return 0, false, fmt.Errorf("LoadIcon: %v", err)
+		}
+		return icon, false, nil
+	}
+
+	icon, err = createIcon(img)
+	if err != nil {
+		return 0, false, err
+	}
+	return icon, true, nil
+}
+
+func (t *trayIcon) SetIcon(img image.Image) error {
+	icon, own, err := loadTrayIcon(img)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	old, ownOld := t.icon, t.ownIcon
+	t.icon, t.ownIcon = icon, own
+	t.mu.Unlock()
+
+	// the shell copies the icon, so the old one can go now
+	err = Shell_NotifyIcon(NIM_MODIFY, t.data(NIF_ICON))
+	if ownOld {
+		DestroyIcon(old)
+	}
+	if err != nil {
+		return fmt.Errorf("Shell_NotifyIcon: %v", err)
+	}
+	return nil
+}
+
+func (t *trayIcon) SetTooltip(text string) error {
+	t.mu.Lock()
+	t.tooltip = text
+	t.mu.Unlock()
+
+	if err := Shell_NotifyIcon(NIM_MODIFY, t.data(NIF_TIP|NIF_SHOWTIP)); err != nil {
+		return fmt.Errorf("Shell_NotifyIcon: %v", err)
+	}
+	return nil
+}
+
+func (t *trayIcon) SetMenu(menu *Menu) error {
+	if err := menu.validate(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.menu = menu
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *trayIcon) ShowBalloon(title, text string) error {
+	data := t.data(NIF_INFO)
+	copyUTF16(data.InfoTitle[:], title)
+	copyUTF16(data.Info[:], text)
+	data.InfoFlags = NIIF_INFO
+	t.mu.Lock()
+	if t.ownIcon {
+		data.InfoFlags = NIIF_USER
+	}
+	t.mu.Unlock()
+
+	if err := Shell_NotifyIcon(NIM_MODIFY, data); err != nil {
+		return fmt.Errorf("Shell_NotifyIcon: %v", err)
+	}
+	return nil
+}
+
+func (t *trayIcon) Close() error {
+	if err := PostMessage(t.handle, WM_CLOSE, 0, 0); err != nil {
+		return fmt.Errorf("PostMessage: %v", err)
+	}
+	<-t.done
+	return nil
+}
+
+func (t *trayIcon) dispatch(e Event) {
+	if t.handler != nil {
+		t.handler(e)
+	}
+}
+
+// contextMenu shows the menu at x, y on the tray thread.
+func (t *trayIcon) contextMenu(x, y int32) {
+	t.mu.Lock()
+	menu := t.menu
+	t.mu.Unlock()
+
+	if menu == nil {
+		t.dispatch(&TrayEvent{Kind: TrayContextMenu, X: x, Y: y})
+		return
+	}
+
+	h, err := buildMenu(menu, false)
+	if err != nil {
+		return
+	}
+	defer DestroyMenu(h)
+
+	// the menu is dismissed on click outside only if the window is foreground
+	SetForegroundWindow(t.handle)
+	id, _ := TrackPopupMenuEx(h, TPM_LEFTALIGN|TPM_RIGHTBUTTON|TPM_RETURNCMD|TPM_NONOTIFY, x, y, t.handle, 0)
+	PostMessage(t.handle, WM_NULL, 0, 0)
+
+	if id != 0 {
+		menu.activate(int(id))
+		t.dispatch(&CommandEvent{ID: int(id)})
+	}
+}
+
+func trayWindowProc(hwnd windows.Handle, message uint32, wParam uintptr, lParam uintptr) uintptr {
+	trayMu.Lock()
+	t := trayIcons[hwnd]
+	trayMu.Unlock()
+
+	if t != nil {
+		switch message {
+		case trayCallbackMessage:
+			// NOTIFYICON_VERSION_4: wParam holds the anchor point
+			x := int32(int16(LOWORD(wParam)))
+			y := int32(int16(HIWORD(wParam)))
+			switch LOWORD(lParam) {
+			case NIN_SELECT, NIN_KEYSELECT:
+				t.dispatch(&TrayEvent{Kind: TrayClick, X: x, Y: y})
+			case WM_LBUTTONDBLCLK:
+				t.dispatch(&TrayEvent{Kind: TrayDoubleClick, X: x, Y: y})
+			case WM_CONTEXTMENU:
+				t.contextMenu(x, y)
+			case NIN_BALLOONUSERCLICK:
+				t.dispatch(&TrayEvent{Kind: TrayBalloonClick, X: x, Y: y})
+				if t.transient {
+					DestroyWindow(hwnd)
+				}
+			case NIN_BALLOONTIMEOUT, NIN_BALLOONHIDE:
+				if t.transient {
+					DestroyWindow(hwnd)
+				}
+			}
+			return 0
+		case WM_DESTROY:
+			Shell_NotifyIcon(NIM_DELETE, t.data(0))
+			t.mu.Lock()
+			if t.ownIcon && t.icon != 0 {
+				DestroyIcon(t.icon)
+			}
+			t.icon = 0
+			t.mu.Unlock()
+
+			trayMu.Lock()
+			delete(trayIcons, hwnd)
+			trayMu.Unlock()
+
+			PostQuitMessage(0)
+			return 0
+		}
+	}
+
+	r, _ := DefWindowProc(hwnd, message, wParam, lParam)
+	return r
+}
+
+// createIcon creates an HICON from img with its alpha channel.
+func createIcon(img image.Image) (windows.Handle, error) {
+	src := toNRGBA(img)
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	if width == 0 || height == 0 {
+		return 0, fmt.Errorf("createIcon: empty image")
+	}
+
+	bgra := make([]byte, len(src.Pix))
+	for i := 0; i < len(src.Pix); i += 4 {
+		bgra[i+0] = src.Pix[i+2]
+		bgra[i+1] = src.Pix[i+1]
+		bgra[i+2] = src.Pix[i+0]
+		bgra[i+3] = src.Pix[i+3]
+	}
+	color, err := CreateBitmap(int32(width), int32(height), 1, 32, unsafe.Pointer(&bgra[0]))
+	if err != nil {
+		return 0, fmt.Errorf("CreateBitmap: %v", err)
+	}
+	defer DeleteObject(color)
+
+	// the mask is unused for 32-bit icons with alpha, rows are WORD aligned
+	maskBits := make([]byte, ((width+15)/16)*2*height)
+	mask, err := CreateBitmap(int32(width), int32(height), 1, 1, unsafe.Pointer(&maskBits[0]))
+	if err != nil {
+		return 0, fmt.Errorf("CreateBitmap: %v", err)
+	}
+	defer DeleteObject(mask)
+
+	icon, err := CreateIconIndirect(&IconCursorInfo{
+		Icon:  1,
+		Mask:  mask,
+		Color: color,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("CreateIconIndirect: %v", err)
+	}
+	return icon, nil
+}
+
+// copyUTF16 copies s into the fixed size buffer dst, truncating and NUL terminating it.
+func copyUTF16(dst []uint16, s string) {
+	u := utf16z(s)
+	if len(u) > len(dst) {
+		u = u[:len(dst)]
+		u[len(u)-1] = 0
+	}
+	copy(dst, u)
+}
@@ -0,0 +1,861 @@
+package gui
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// tuiModeEnv forces the output mode: "halfblock", "sixel" or "kitty".
+const tuiModeEnv = "GUI_TUI_MODE"
+
+// tuiCapabilities are the features of the terminal backend.
+const tuiCapabilities = CapKeyboard | CapPointer | drawnMenuCapabilities | glCapabilities
+
+// terminal output modes
+const (
+	tuiHalfBlock = iota // two pixels per cell with 24-bit colors
+	tuiSixel
+	tuiKitty
+)
+
+// tuiInUse is set while the terminal is owned by a window.
+var (
+	tuiMu    sync.Mutex
+	tuiInUse bool
+)
+
+func init() {
+	registerBackend(&backend{
+		name:     "tui",
+		priority: 200,
+		explicit: true,
+		caps:     tuiCapabilities,
+		probe: func() error {
+			fd, err := unix.Open("/dev/tty", unix.O_RDWR|unix.O_CLOEXEC, 0)
+			if err != nil {
+				return fmt.Errorf("tui: /dev/tty: %v", err)
+			}
+			return unix.Close(fd)
+		},
+		newDriver: func() (driver, error) {
+			return &tuiDriver{}, nil
+		},
+	})
+}
+
+// tuiDriver renders a window into the controlling terminal, e.g. over ssh.
+//
+// The terminal sends no key releases, so each key is delivered as a press
+// followed by a release. Ctrl+C closes the window. The terminal has one
+// window at a time.
+type tuiDriver struct {
+	tty     int
+	saved   *unix.Termios
+	winch   chan os.Signal
+	winched chan struct{} // closed when SIGWINCH is no longer handled
+	resized int32         // set by SIGWINCH before waking
+	wakeup  wakePipe
+	timer   timerFd // of the timeout of poll
+	watches *fdWatches
+	mode    int
+	forced  bool
+	cols    int
+	rows    int
+	cellW   int // pixels per cell
+	cellH   int
+	imageID int  // kitty image on the screen
+	owner   bool // of tuiInUse
+	res     *resources
+
+	image  *image.RGBA
+	shown  *image.RGBA // frame on the terminal
+	input  []byte
+	events []interface{}
+	trace  func(category TraceCategory, name string, detail string)
+}
+
+func (d *tuiDriver) open(name string, width int32, height int32) error {
+	d.tty = -1
+	tuiMu.Lock()
+	defer tuiMu.Unlock()
+	if tuiInUse {
+		return errors.New("tui: the terminal is already in use")
+	}
+	tuiInUse, d.owner = true, true
+
+	tty, err := unix.Open("/dev/tty", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("tui: /dev/tty: %v", err)
+	}
+	d.tty = tty
+	d.res.acquire(resTerminal)
+
+	saved, err := unix.IoctlGetTermios(tty, unix.TCGETS)
+	if err != nil {
+		return fmt.Errorf("tui: TCGETS: %v", err)
+	}
+	raw := *saved
+	raw.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	raw.Oflag &^= unix.OPOST
+	raw.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	raw.Cflag &^= unix.CSIZE | unix.PARENB
+	raw.Cflag |= unix.CS8
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(tty, unix.TCSETS, &raw); err != nil {
+		return fmt.Errorf("tui: TCSETS: %v", err)
+	}
+	d.saved = saved
+
+	if err := d.wakeup.open(); err != nil {
+		return err
+	}
+	if err := d.timer.open(); err != nil {
+		return err
+	}
+	d.winch = make(chan os.Signal, 1)
+	d.winched = make(chan struct{})
+	signal.Notify(d.winch, syscall.SIGWINCH)
+	// close waits for it before closing the pipe, whose fd may be reused
+	go func(ch <-chan os.Signal, done chan<- struct{}, w int) {
+		defer close(done)
+		for range ch {
+			atomic.StoreInt32(&d.resized, 1)
+			unix.Write(w, []byte{0})
+		}
+	}(d.winch, d.winched, d.wakeup.w)
+
+	d.selectMode()
+
+	// alternate screen, no cursor, SGR mouse with motion; the title
+	d.write("\x1b[?1049h\x1b[?25l\x1b[?1003h\x1b[?1006h\x1b[2J\x1b]2;" + strings.Map(printable, name) + "\x07")
+	return d.resize()
+}
+
+func printable(r rune) rune {
+	if r < 0x20 || r == 0x7f {
+		return -1
+	}
+	return r
+}
+
+// selectMode chooses the output by GUI_TUI_MODE, the environment or the
+// device attributes of the terminal.
+func (d *tuiDriver) selectMode() {
+	switch os.Getenv(tuiModeEnv) {
+	case "halfblock":
+		d.mode, d.forced = tuiHalfBlock, true
+		return
+	case "sixel":
+		d.mode, d.forced = tuiSixel, true
+		return
+	case "kitty":
+		d.mode, d.forced = tuiKitty, true
+		return
+	}
+
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || strings.Contains(term, "kitty"),
+		os.Getenv("TERM_PROGRAM") == "WezTerm", os.Getenv("TERM_PROGRAM") == "ghostty":
+		d.mode = tuiKitty
+	case d.hasSixel():
+		d.mode = tuiSixel
+	default:
+		d.mode = tuiHalfBlock
+	}
+}
+
+// hasSixel asks for the primary device attributes, which list 4 for sixel.
+func (d *tuiDriver) hasSixel() bool {
+	d.write("\x1b[c")
+
+	var reply []byte
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for {
+		now := time.Now()
+		if !now.Before(deadline) {
+			return false
+		}
+		fds := []unix.PollFd{{Fd: int32(d.tty), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, millisecondsUntil(now, deadline))
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || n == 0 {
+			return false
+		}
+		var buf [64]byte
+		n, err = unix.Read(d.tty, buf[:])
+		if err != nil || n == 0 {
+			return false
+		}
+		reply = append(reply, buf[:n]...)
+
+		// ESC [ ? attrs c
+		if i := bytes.Index(reply, []byte("\x1b[?")); i >= 0 {
+			if j := bytes.IndexByte(reply[i:], 'c'); j >= 0 {
+				attrs := strings.Split(string(reply[i+3:i+j]), ";")
+				// keep input typed meanwhile
+				d.input = append(d.input, reply[:i]...)
+				d.input = append(d.input, reply[i+j+1:]...)
+				for _, a := range attrs[1:] {
+					if a == "4" {
+						return true
+					}
+				}
+				return false
+			}
+		}
+	}
+}
+
+// resize reads the terminal size and makes the framebuffer for it.
+func (d *tuiDriver) resize() error {
+	ws, err := unix.IoctlGetWinsize(d.tty, unix.TIOCGWINSZ)
+	if err != nil {
+		return fmt.Errorf("tui: TIOCGWINSZ: %v", err)
+	}
+	if ws.Col == 0 || ws.Row == 0 {
+		return errors.New("tui: the terminal has no size")
+	}
+	d.cols, d.rows = int(ws.Col), int(ws.Row)
+
+	mode := d.mode
+	if mode != tuiHalfBlock && (ws.Xpixel == 0 || ws.Ypixel == 0) {
+		// the cell size is unknown
+		if !d.forced {
+			d.mode = tuiHalfBlock
+		}
+		mode = tuiHalfBlock
+		d.cellW, d.cellH = 8, 16
+	} else {
+		d.cellW, d.cellH = int(ws.Xpixel)/d.cols, int(ws.Ypixel)/d.rows
+	}
+
+	var width, height int
+	switch mode {
+	case tuiHalfBlock:
+		width, height = d.cols, d.rows*2
+	case tuiSixel:
+		// the last row would scroll the screen
+		width, height = d.cols*d.cellW, (d.rows-1)*d.cellH
+	case tuiKitty:
+		width, height = d.cols*d.cellW, d.rows*d.cellH
+	}
+	d.mode = mode
+
+	if d.image == nil || d.image.Rect.Dx() != width || d.image.Rect.Dy() != height {
+		d.image = image.NewRGBA(image.Rect(0, 0, width, height))
+		d.events = append(d.events, sizeEvent{width: int32(width), height: int32(height)})
+	}
+	d.shown = nil
+	d.write("\x1b[2J")
+	d.events = append(d.events, exposeEvent{})
+	return nil
+}
+
+func (d *tuiDriver) close() {
+	if d.winch != nil {
+		signal.Stop(d.winch)
+		close(d.winch)
+		<-d.winched
+		d.winch = nil
+	}
+	if d.saved != nil {
+		if d.mode == tuiKitty && d.imageID != 0 {
+			d.write("\x1b_Ga=d,d=A,q=2\x1b\\")
+		}
+		d.write("\x1b[?1006l\x1b[?1003l\x1b[?25h\x1b[?1049l")
+		unix.IoctlSetTermios(d.tty, unix.TCSETS, d.saved)
+		d.saved = nil
+	}
+	d.wakeup.close()
+	d.timer.close()
+	if d.tty >= 0 {
+		unix.Close(d.tty)
+		d.tty = -1
+		d.res.release(resTerminal)
+	}
+	if d.owner {
+		tuiMu.Lock()
+		tuiInUse = false
+		tuiMu.Unlock()
+		d.owner = false
+	}
+}
+
+func (d *tuiDriver) setResources(res *resources) {
+	d.res = res
+}
+
+func (d *tuiDriver) handle() uintptr {
+	return 0
+}
+
+func (d *tuiDriver) write(s string) {
+	b := []byte(s)
+	for len(b) > 0 {
+		n, err := unix.Write(d.tty, b)
+		if err == unix.EINTR || err == unix.EAGAIN {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		b = b[n:]
+	}
+}
+
+func (d *tuiDriver) poll(timeout time.Duration) ([]interface{}, error) {
+	if err := d.timer.set(timeout); err != nil {
+		return nil, err
+	}
+	if len(d.input) > 0 {
+		d.parseInput()
+	}
+
+	for len(d.events) == 0 {
+		fds := []unix.PollFd{
+			{Fd: int32(d.tty), Events: unix.POLLIN},
+			d.wakeup.pollFd(),
+			d.timer.pollFd(),
+		}
+		fds = d.watches.pollFds(fds)
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Poll: %v", err)
+		}
+
+		d.events = append(d.events, d.watches.read(fds[3:])...)
+		woken := fds[1].Revents != 0
+		if woken {
+			d.wakeup.drain()
+			if atomic.SwapInt32(&d.resized, 0) != 0 {
+				if d.trace != nil {
+					d.trace(TraceLifecycle, "SIGWINCH", "")
+				}
+				if err := d.resize(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if fds[0].Revents&unix.POLLIN != 0 {
+			var buf [256]byte
+			n, err := unix.Read(d.tty, buf[:])
+			if err != nil && err != unix.EINTR && err != unix.EAGAIN {
+				return nil, fmt.Errorf("tui: read: %v", err)
+			}
+			if n > 0 {
+				d.input = append(d.input, buf[:n]...)
+				d.parseInput()
+			}
+		} else if fds[0].Revents&(unix.POLLHUP|unix.POLLERR) != 0 {
+			// the terminal is gone
+			d.events = append(d.events, closeEvent{})
+		}
+		if woken || d.timer.expired(fds[2]) {
+			break
+		}
+	}
+
+	events := d.events
+	d.events = nil
+	return events, nil
+}
+
+func (d *tuiDriver) wake() {
+	d.wakeup.wake()
+}
+
+func (d *tuiDriver) setWatches(ws *fdWatches) {
+	d.watches = ws
+}
+
+func (d *tuiDriver) framebuffer() *image.RGBA {
+	return d.image
+}
+
+func (d *tuiDriver) present(region []image.Rectangle) error {
+	if d.shown == nil || d.shown.Rect != d.image.Rect {
+		d.shown = nil
+		region = []image.Rectangle{d.image.Rect}
+	}
+
+	var out bytes.Buffer
+	switch d.mode {
+	case tuiHalfBlock:
+		for _, r := range region {
+			d.drawHalfBlocks(&out, r)
+		}
+	case tuiSixel:
+		for _, r := range d.changed(region) {
+			// sixels start at a cell
+			r = image.Rect(r.Min.X/d.cellW*d.cellW, r.Min.Y/d.cellH*d.cellH, r.Max.X, r.Max.Y)
+			fmt.Fprintf(&out, "\x1b[%d;%dH", r.Min.Y/d.cellH+1, r.Min.X/d.cellW+1)
+			encodeSixel(&out, d.image.SubImage(r).(*image.RGBA))
+		}
+	case tuiKitty:
+		if len(d.changed(region)) > 0 {
+			d.drawKitty(&out)
+		}
+	}
+	if out.Len() > 0 {
+		d.write(out.String())
+	}
+
+	if d.shown == nil {
+		d.shown = image.NewRGBA(d.image.Rect)
+	}
+	for _, r := range region {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i, end := d.image.PixOffset(r.Min.X, y), d.image.PixOffset(r.Max.X, y)
+			copy(d.shown.Pix[i:end], d.image.Pix[i:end])
+		}
+	}
+	return nil
+}
+
+// changed returns the rectangles of region which differ from the terminal.
+func (d *tuiDriver) changed(region []image.Rectangle) []image.Rectangle {
+	if d.shown == nil {
+		return region
+	}
+	var rects []image.Rectangle
+	for _, r := range region {
+		rects = append(rects, diffRects(d.shown, d.image, r)...)
+	}
+	return rects
+}
+
+// drawHalfBlocks writes the changed cells of r as upper half blocks,
+// the upper pixel in the foreground and the lower pixel in the background.
+func (d *tuiDriver) drawHalfBlocks(out *bytes.Buffer, r image.Rectangle) {
+	img := d.image
+	var fg, bg [3]byte
+	colors := false
+	next := image.Pt(-1, -1) // cursor position after the last cell
+
+	for row := r.Min.Y / 2; row < (r.Max.Y+1)/2; row++ {
+		for col := r.Min.X; col < r.Max.X; col++ {
+			top := img.Pix[img.PixOffset(col, row*2):]
+			bottom := img.Pix[img.PixOffset(col, row*2+1):]
+			if d.shown != nil {
+				oldTop := d.shown.Pix[d.shown.PixOffset(col, row*2):]
+				oldBottom := d.shown.Pix[d.shown.PixOffset(col, row*2+1):]
+				if bytes.Equal(top[:3], oldTop[:3]) && bytes.Equal(bottom[:3], oldBottom[:3]) {
+					continue
+				}
+			}
+
+			if next != image.Pt(col, row) {
+				fmt.Fprintf(out, "\x1b[%d;%dH", row+1, col+1)
+			}
+			t := [3]byte{top[0], top[1], top[2]}
+			b := [3]byte{bottom[0], bottom[1], bottom[2]}
+			if !colors || t != fg {
+				fmt.Fprintf(out, "\x1b[38;2;%d;%d;%dm", t[0], t[1], t[2])
+			}
+			if !colors || b != bg {
+				fmt.Fprintf(out, "\x1b[48;2;%d;%d;%dm", b[0], b[1], b[2])
+			}
+			fg, bg, colors = t, b, true
+			out.WriteString("▀")
+			next = image.Pt(col+1, row)
+		}
+	}
+	if colors {
+		out.WriteString("\x1b[0m")
+	}
+}
+
+// drawKitty sends the frame with the kitty graphics protocol. The new image
+// is placed before the old one is deleted to avoid flicker.
+func (d *tuiDriver) drawKitty(out *bytes.Buffer) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(d.image.Pix)
+	zw.Close()
+	data := base64.StdEncoding.EncodeToString(z.Bytes())
+
+	old := d.imageID
+	d.imageID = 3 - old // 1 and 2
+	if old == 0 {
+		d.imageID = 1
+	}
+
+	out.WriteString("\x1b[H")
+	const chunk = 4096
+	for i := 0; i < len(data); i += chunk {
+		end := i + chunk
+		more := 1
+		if end >= len(data) {
+			end, more = len(data), 0
+		}
+		if i == 0 {
+			fmt.Fprintf(out, "\x1b_Ga=T,f=32,o=z,s=%d,v=%d,i=%d,C=1,q=2,m=%d;%s\x1b\\",
+				d.image.Rect.Dx(), d.image.Rect.Dy(), d.imageID, more, data[i:end])
+		} else {
+			fmt.Fprintf(out, "\x1b_Gm=%d;%s\x1b\\", more, data[i:end])
+		}
+	}
+	if old != 0 {
+		fmt.Fprintf(out, "\x1b_Ga=d,d=I,i=%d,q=2\x1b\\", old)
+	}
+}
+
+// encodeSixel writes img as sixels with a 6x6x6 color cube.
+func encodeSixel(out *bytes.Buffer, img *image.RGBA) {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	index := make([]uint8, width*height)
+	var colors [216]bool
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			p := img.Pix[img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y):]
+			i := uint8((int(p[0])*5+127)/255*36 + (int(p[1])*5+127)/255*6 + (int(p[2])*5+127)/255)
+			index[y*width+x] = i
+			colors[i] = true
+		}
+	}
+
+	fmt.Fprintf(out, "\x1bPq\"1;1;%d;%d", width, height)
+	for i := range colors {
+		if colors[i] {
+			fmt.Fprintf(out, "#%d;2;%d;%d;%d", i, i/36*20, i/6%6*20, i%6*20)
+		}
+	}
+
+	for band := 0; band < height; band += 6 {
+		var used [216]bool
+		for y := band; y < band+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[index[y*width+x]] = true
+			}
+		}
+
+		first := true
+		for c := range used {
+			if !used[c] {
+				continue
+			}
+			if !first {
+				out.WriteByte('$')
+			}
+			first = false
+			fmt.Fprintf(out, "#%d", c)
+
+			var run byte
+			count := 0
+			flush := func() {
+				switch {
+				case count > 3:
+					fmt.Fprintf(out, "!%d%c", count, run)
+				default:
+					for i := 0; i < count; i++ {
+						out.WriteByte(run)
+					}
+				}
+			}
+			for x := 0; x < width; x++ {
+				bits := 0
+				for k := 0; k < 6 && band+k < height; k++ {
+					if index[(band+k)*width+x] == uint8(c) {
+						bits |= 1 << uint(k)
+					}
+				}
+				ch := byte(63 + bits)
+				if ch == run {
+					count++
+					continue
+				}
+				flush()
+				run, count = ch, 1
+			}
+			flush()
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\x1b\\")
+}
+
+// parseInput turns the terminal input into events.
+// An incomplete sequence at the end is kept for the next read.
+func (d *tuiDriver) parseInput() {
+	in := d.input
+	for len(in) > 0 {
+		n := d.parseOne(in)
+		if n == 0 {
+			break
+		}
+		if d.trace != nil {
+			d.trace(TraceInput, tuiSequenceName(in[:n]), fmt.Sprintf("seq=%q", in[:n]))
+		}
+		in = in[n:]
+	}
+	d.input = append(d.input[:0], in...)
+}
+
+// tuiSequenceName returns the name of a sequence sent by the terminal, e.g.
+// "CSI A" for an arrow key, "CSI <M" for a mouse report or "^C".
+func tuiSequenceName(seq []byte) string {
+	switch {
+	case len(seq) > 2 && seq[0] == 0x1b && seq[1] == '[':
+		name := "CSI "
+		if p := seq[2]; p == '<' || p == '?' || p == '>' || p == '=' {
+			name += string(p)
+		}
+		return name + string(seq[len(seq)-1])
+	case len(seq) == 3 && seq[0] == 0x1b && seq[1] == 'O':
+		return "SS3 " + string(seq[2])
+	case seq[0] == 0x1b:
+		return "ESC"
+	case seq[0] < 0x20:
+		return "^" + string(seq[0]+'@')
+	case seq[0] == 0x7f:
+		return "DEL"
+	}
+	return "text"
+}
+
+func (d *tuiDriver) setTrace(trace func(category TraceCategory, name string, detail string)) {
+	d.trace = trace
+}
+
+// shiftedSymbols are the symbols typed with Shift on the US layout.
+const shiftedSymbols = "~!@#$%^&*()_+{}|:\"<>?"
+
+// parseOne parses one key or mouse report and returns its length, or 0 if it is incomplete.
+func (d *tuiDriver) parseOne(in []byte) int {
+	c := in[0]
+	switch {
+	case c == 0x1b:
+		if len(in) == 1 {
+			d.key(KeyEscape, 0)
+			return 1
+		}
+		switch in[1] {
+		case '[':
+			return d.parseCSI(in)
+		case 'O':
+			if len(in) < 3 {
+				return 0
+			}
+			d.ss3(in[2], 0)
+			return 3
+		case 0x1b:
+			d.key(KeyEscape, 0)
+			return 1
+		}
+		// Alt+key
+		first := len(d.events)
+		n := d.parseOne(in[1:])
+		if n == 0 {
+			return 0
+		}
+		for _, e := range d.events[first:] {
+			if k, ok := e.(*KeyEvent); ok {
+				k.Mods |= ModAlt
+			}
+		}
+		return n + 1
+	case c == 0x03:
+		// Ctrl+C
+		d.events = append(d.events, closeEvent{})
+		return 1
+	case c == '\r' || c == '\n':
+		d.key(KeyEnter, 0)
+	case c == '\t':
+		d.key(KeyTab, 0)
+	case c == 0x7f || c == 0x08:
+		d.key(KeyBackspace, 0)
+	case c == 0:
+		d.key(KeySpace, ModCtrl)
+	case c >= 0x01 && c <= 0x1a:
+		d.key(KeyA+Key(c-1), ModCtrl)
+	case c < 0x80:
+		var mods Modifier
+		if (c >= 'A' && c <= 'Z') || strings.IndexByte(shiftedSymbols, c) >= 0 {
+			mods = ModShift
+		}
+		switch c {
+		case '{', '[', '}', ']', '\\', '|', ';', ':', '\'', '"', '`', '~':
+			d.key(KeyUnknown, mods)
+		default:
+			d.key(keyFromKeysym(uint32(c)), mods)
+		}
+	default:
+		// UTF-8 text has no key
+		n := 1
+		for n < len(in) && in[n]&0xc0 == 0x80 {
+			n++
+		}
+		return n
+	}
+	return 1
+}
+
+// csiKeys maps the final byte of CSI sequences to keys.
+var csiKeys = map[byte]Key{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+// tildeKeys maps the number of CSI n ~ sequences to keys.
+var tildeKeys = map[int]Key{
+	1:  KeyHome,
+	2:  KeyInsert,
+	3:  KeyDelete,
+	4:  KeyEnd,
+	5:  KeyPageUp,
+	6:  KeyPageDown,
+	7:  KeyHome,
+	8:  KeyEnd,
+	15: KeyF5,
+	17: KeyF6,
+	18: KeyF7,
+	19: KeyF8,
+	20: KeyF9,
+	21: KeyF10,
+	23: KeyF11,
+	24: KeyF12,
+}
+
+func (d *tuiDriver) ss3(final byte, mods Modifier) {
+	if key, ok := csiKeys[final]; ok {
+		d.key(key, mods)
+	}
+}
+
+// parseCSI parses ESC [ params final.
+func (d *tuiDriver) parseCSI(in []byte) int {
+	end := 2
+	for end < len(in) && (in[end] < 0x40 || in[end] > 0x7e) {
+		end++
+	}
+	if end == len(in) {
+		return 0
+	}
+	final := in[end]
+	params := string(in[2:end])
+
+	if strings.HasPrefix(params, "<") && (final == 'M' || final == 'm') {
+		d.mouse(params[1:], final == 'M')
+		return end + 1
+	}
+
+	var args []int
+	for _, p := range strings.Split(params, ";") {
+		n, _ := strconv.Atoi(p)
+		args = append(args, n)
+	}
+	// xterm modifiers: 1 + shift 1, alt 2, ctrl 4, meta 8
+	var mods Modifier
+	if len(args) >= 2 && args[1] > 1 {
+		m := args[1] - 1
+		if m&1 != 0 {
+			mods |= ModShift
+		}
+		if m&2 != 0 {
+			mods |= ModAlt
+		}
+		if m&4 != 0 {
+			mods |= ModCtrl
+		}
+		if m&8 != 0 {
+			mods |= ModSuper
+		}
+	}
+
+	if final == '~' {
+		if key, ok := tildeKeys[args[0]]; ok {
+			d.key(key, mods)
+		}
+	} else {
+		d.ss3(final, mods)
+	}
+	return end + 1
+}
+
+// mouse handles an SGR mouse report "b;x;y".
+func (d *tuiDriver) mouse(params string, press bool) {
+	parts := strings.Split(params, ";")
+	if len(parts) != 3 {
+		return
+	}
+	b, _ := strconv.Atoi(parts[0])
+	col, _ := strconv.Atoi(parts[1])
+	row, _ := strconv.Atoi(parts[2])
+
+	e := &MouseEvent{}
+	if d.mode == tuiHalfBlock {
+		e.X, e.Y = int32(col-1), int32((row-1)*2)
+	} else {
+		e.X, e.Y = int32((col-1)*d.cellW+d.cellW/2), int32((row-1)*d.cellH+d.cellH/2)
+	}
+	if b&4 != 0 {
+		e.Mods |= ModShift
+	}
+	if b&8 != 0 {
+		e.Mods |= ModAlt
+	}
+	if b&16 != 0 {
+		e.Mods |= ModCtrl
+	}
+
+	switch {
+	case b&64 != 0:
+		e.Action = MouseScroll
+		switch b & 3 {
+		case 0:
+			e.ScrollY = -1
+		case 1:
+			e.ScrollY = 1
+		case 2:
+			e.ScrollX = -1
+		case 3:
+			e.ScrollX = 1
+		}
+	case b&32 != 0:
+		e.Action = MouseMove
+	default:
+		e.Button = [...]MouseButton{ButtonLeft, ButtonMiddle, ButtonRight, ButtonNone}[b&3]
+		e.Action = MouseRelease
+		if press {
+			e.Action = MousePress
+		}
+	}
+	d.events = append(d.events, e)
+}
+
+// key sends a press and a release.
+func (d *tuiDriver) key(key Key, mods Modifier) {
+	d.events = append(d.events,
+		&KeyEvent{Key: key, Mods: mods, Down: true},
+		&KeyEvent{Key: key, Mods: mods, Down: false},
+	)
+}
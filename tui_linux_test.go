@@ -0,0 +1,218 @@
+package gui
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPty returns the master and the slave of a new pseudo terminal.
+func openPty(t *testing.T) (*os.File, *os.File) {
+	fd, err := unix.Open("/dev/ptmx", unix.O_RDWR|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Skipf("no pseudo terminals: %v", err)
+	}
+	master := os.NewFile(uintptr(fd), "/dev/ptmx")
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close()
+		t.Fatalf("TIOCSPTLCK: %v", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close()
+		t.Fatalf("TIOCGPTN: %v", err)
+	}
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		t.Skipf("no pseudo terminals: %v", err)
+	}
+	return master, slave
+}
+
+// runTUIChild runs a window of the tui backend on its controlling terminal
+// and prints the sizes of its frames until it is closed.
+func runTUIChild() {
+	app := NewApplication(WithBackend("tui"))
+	if err := app.Init(); err != nil {
+		fmt.Println("Init:", err)
+		os.Exit(1)
+	}
+	r := newTestRenderer(color.RGBA{0xff, 0, 0, 0xff})
+	errc := app.Loop("tui", 1, 1, r)
+	<-r.window
+	// the terminal has one window
+	fmt.Println("second:", <-app.Loop("second", 1, 1, newTestRenderer(color.RGBA{})))
+	go func() {
+		var last string
+		for f := range r.frames {
+			if size := fmt.Sprintf("%dx%d", f.Rect.Dx(), f.Rect.Dy()); size != last {
+				fmt.Println("frame", size)
+				last = size
+			}
+		}
+	}()
+	err := <-errc
+	app.Deinit()
+	fmt.Println("closed", err, CheckLeaks(app))
+	os.Exit(0)
+}
+
+// TestTUIPty runs itself in a process whose controlling terminal is a
+// pseudo terminal, resizes it and closes the window with Ctrl+C.
+func TestTUIPty(t *testing.T) {
+	if os.Getenv("GUI_TEST_TUI") != "" {
+		runTUIChild()
+	}
+
+	master, slave := openPty(t)
+	defer master.Close()
+	setSize := func(cols, rows uint16) {
+		if err := unix.IoctlSetWinsize(int(master.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Col: cols, Row: rows}); err != nil {
+			t.Fatalf("TIOCSWINSZ: %v", err)
+		}
+	}
+	setSize(16, 8)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTUIPty$")
+	cmd.Env = append(os.Environ(), "GUI_TEST_TUI=1", tuiModeEnv+"=halfblock")
+	cmd.Stdin = slave
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 0}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	slave.Close()
+
+	var mu sync.Mutex
+	var screen bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		buf := make([]byte, 4096)
+		for {
+			n, err := master.Read(buf)
+			mu.Lock()
+			screen.Write(buf[:n])
+			mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	lines := make(chan string, 16)
+	go func() {
+		s := bufio.NewScanner(stdout)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+		close(lines)
+	}()
+	expect := func(want string) {
+		select {
+		case line, ok := <-lines:
+			if !ok || line != want {
+				t.Fatalf("the child printed %q, want %q", line, want)
+			}
+		case <-testTimeout():
+			t.Fatalf("the child did not print %q", want)
+		}
+	}
+
+	expect(`second: gui: create window "second": tui: the terminal is already in use`)
+	expect("frame 16x16")
+	setSize(20, 10)
+	expect("frame 20x20")
+
+	// SIGWINCH while the window closes
+	for i := 0; i < 20; i++ {
+		setSize(20+uint16(i%2), 10)
+	}
+	master.Write([]byte{3}) // Ctrl+C
+	for {
+		line, ok := <-lines
+		if !ok {
+			t.Fatal("the child did not close the window")
+		}
+		if strings.HasPrefix(line, "closed") {
+			if line != "closed <nil> <nil>" {
+				t.Errorf("the child printed %q", line)
+			}
+			break
+		}
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("the child failed: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		cmd.Process.Kill()
+		t.Fatal("the child did not exit")
+	}
+	<-copied
+
+	mu.Lock()
+	out := screen.String()
+	mu.Unlock()
+	if !strings.Contains(out, "\x1b[?1049h") {
+		t.Errorf("the alternate screen was not entered: %q", out)
+	}
+	if !strings.HasSuffix(out, "\x1b[?1049l") {
+		if len(out) > 64 {
+			out = out[len(out)-64:]
+		}
+		t.Errorf("the terminal was not restored last: %q", out)
+	}
+}
+
+// TestTUITrace traces the sequences of the terminal by their names.
+func TestTUITrace(t *testing.T) {
+	type traced struct{ name, detail string }
+	var got []traced
+	d := &tuiDriver{}
+	d.setTrace(func(category TraceCategory, name string, detail string) {
+		if category != TraceInput {
+			t.Errorf("%s in %v", name, category)
+		}
+		got = append(got, traced{name, detail})
+	})
+	// the incomplete sequence at the end is traced when it is complete
+	d.input = []byte("\x1b[A\x1b[<0;3;4Mq\x1bOP\x7f\x1bx\x01\x1b[1;5")
+	d.parseInput()
+	want := []traced{
+		{"CSI A", `seq="\x1b[A"`},
+		{"CSI <M", `seq="\x1b[<0;3;4M"`},
+		{"text", `seq="q"`},
+		{"SS3 P", `seq="\x1bOP"`},
+		{"DEL", `seq="\x7f"`},
+		{"ESC", `seq="\x1bx"`},
+		{"^A", `seq="\x01"`},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("traced %q\nwant %q", got, want)
+	}
+	got = nil
+	d.input = append(d.input, 'C')
+	d.parseInput()
+	if len(got) != 1 || got[0].name != "CSI C" {
+		t.Errorf("traced %q, want CSI C", got)
+	}
+}
@@ -0,0 +1,952 @@
+// +build !windows
+
+package gui
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/draw"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// vncAddrEnv is the address of the first window, default 127.0.0.1:5900.
+// Later windows listen on the following ports.
+const vncAddrEnv = "GUI_VNC_ADDR"
+
+// RFB encodings
+const (
+	rfbEncodingRaw      = 0
+	rfbEncodingCopyRect = 1
+	rfbEncodingZlib     = 6
+)
+
+// RFB client messages
+const (
+	rfbSetPixelFormat           = 0
+	rfbSetEncodings             = 2
+	rfbFramebufferUpdateRequest = 3
+	rfbKeyEvent                 = 4
+	rfbPointerEvent             = 5
+	rfbClientCutText            = 6
+)
+
+// vncCapabilities are the features of the VNC backend.
+const vncCapabilities = CapMultiWindow | CapKeyboard | CapPointer | drawnMenuCapabilities | glCapabilities
+
+func init() {
+	registerBackend(&backend{
+		name:     "vnc",
+		priority: 200,
+		explicit: true,
+		caps:     vncCapabilities,
+		probe: func() error {
+			return nil
+		},
+		newDriver: func() (driver, error) {
+			return &vncDriver{}, nil
+		},
+	})
+}
+
+// vncDriver serves a window over the RFB protocol (RFC 6143) to any number
+// of viewers. Updates are sent with the raw, CopyRect or zlib encoding.
+type vncDriver struct {
+	name     string
+	listener net.Listener
+	image    *image.RGBA
+	events   chan interface{}
+	pending  []interface{}
+	done     chan struct{}
+	wakec    chan struct{}
+	res      *resources
+	trace    func(category TraceCategory, name string, detail string)
+
+	mu      sync.Mutex
+	frame   *image.RGBA // last presented frame, never modified
+	clients map[*vncClient]bool
+	wg      sync.WaitGroup
+}
+
+func (d *vncDriver) open(name string, width int32, height int32) error {
+	if width <= 0 || width > 0xffff || height <= 0 || height > 0xffff {
+		return fmt.Errorf("vnc: invalid size %dx%d", width, height)
+	}
+
+	addr := os.Getenv(vncAddrEnv)
+	if addr == "" {
+		addr = "127.0.0.1:5900"
+	}
+	ln, err := listenFrom(addr)
+	if err != nil {
+		return fmt.Errorf("vnc: %v", err)
+	}
+
+	d.name = name
+	d.res.acquire(resListener)
+	d.listener = ln
+	d.image = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	d.frame = image.NewRGBA(d.image.Rect)
+	d.events = make(chan interface{}, 256)
+	d.done = make(chan struct{})
+	d.wakec = make(chan struct{}, 1)
+	d.clients = make(map[*vncClient]bool)
+	d.pending = append(d.pending, sizeEvent{width: width, height: height}, exposeEvent{})
+
+	d.wg.Add(1)
+	go d.accept()
+	return nil
+}
+
+func (d *vncDriver) accept() {
+	defer d.wg.Done()
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := newVNCClient(d, conn)
+		d.mu.Lock()
+		d.clients[c] = true
+		d.mu.Unlock()
+
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			c.serve()
+
+			d.mu.Lock()
+			delete(d.clients, c)
+			d.mu.Unlock()
+		}()
+	}
+}
+
+func (d *vncDriver) close() {
+	if d.listener == nil {
+		return
+	}
+	close(d.done)
+	d.listener.Close()
+	d.mu.Lock()
+	for c := range d.clients {
+		c.conn.Close()
+	}
+	d.mu.Unlock()
+	d.wg.Wait()
+	d.listener = nil
+	d.res.release(resListener)
+}
+
+func (d *vncDriver) setResources(res *resources) {
+	d.res = res
+}
+
+func (d *vncDriver) handle() uintptr {
+	return 0
+}
+
+// post queues an input event of a client for the loop.
+func (d *vncDriver) post(e interface{}) {
+	stampEvent(e, time.Now())
+	select {
+	case d.events <- e:
+	case <-d.done:
+	}
+}
+
+func (d *vncDriver) setTrace(trace func(category TraceCategory, name string, detail string)) {
+	d.trace = trace
+}
+
+// traceMessage sends the native name of a message of a client to poll.
+func (d *vncDriver) traceMessage(category TraceCategory, name string, format string, args ...interface{}) {
+	if d.trace == nil {
+		return
+	}
+	select {
+	case d.events <- clientMessage{category, name, fmt.Sprintf(format, args...)}:
+	case <-d.done:
+	}
+}
+
+func (d *vncDriver) poll(timeout time.Duration) ([]interface{}, error) {
+	var events []interface{}
+	if len(d.pending) == 0 {
+		var timer <-chan time.Time
+		if timeout >= 0 {
+			t := time.NewTimer(timeout)
+			defer t.Stop()
+			timer = t.C
+		}
+		select {
+		case e := <-d.events:
+			events = append(events, e)
+		case <-d.wakec:
+		case <-timer:
+		}
+	}
+	for {
+		select {
+		case e := <-d.events:
+			events = append(events, e)
+			continue
+		default:
+		}
+		break
+	}
+
+	for _, e := range events {
+		if m, ok := e.(clientMessage); ok {
+			d.trace(m.category, m.name, m.detail)
+			continue
+		}
+		d.pending = append(d.pending, e)
+	}
+
+	events = d.pending
+	d.pending = nil
+	return events, nil
+}
+
+func (d *vncDriver) wake() {
+	select {
+	case d.wakec <- struct{}{}:
+	default:
+	}
+}
+
+func (d *vncDriver) framebuffer() *image.RGBA {
+	return d.image
+}
+
+func (d *vncDriver) present(region []image.Rectangle) error {
+	frame := image.NewRGBA(d.image.Rect)
+	copy(frame.Pix, d.image.Pix)
+
+	d.mu.Lock()
+	d.frame = frame
+	for c := range d.clients {
+		c.damage.addAll(region, frame.Rect)
+		c.wake()
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+// takeFrame returns the current frame and the damage of c since the last call.
+func (d *vncDriver) takeFrame(c *vncClient) (*image.RGBA, []image.Rectangle) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.frame, c.damage.take()
+}
+
+// addDamage gives back the damage which c has not sent.
+func (d *vncDriver) addDamage(c *vncClient, rects []image.Rectangle) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c.damage.addAll(rects, d.frame.Rect)
+}
+
+// rfbPixelFormat is the PIXEL_FORMAT of RFB.
+type rfbPixelFormat struct {
+	BitsPerPixel uint8
+	Depth        uint8
+	BigEndian    uint8
+	TrueColor    uint8
+	RedMax       uint16
+	GreenMax     uint16
+	BlueMax      uint16
+	RedShift     uint8
+	GreenShift   uint8
+	BlueShift    uint8
+	_            [3]byte
+}
+
+// rfbDefaultFormat is sent in ServerInit: 32 bits little endian XRGB.
+var rfbDefaultFormat = rfbPixelFormat{
+	BitsPerPixel: 32,
+	Depth:        24,
+	TrueColor:    1,
+	RedMax:       255,
+	GreenMax:     255,
+	BlueMax:      255,
+	RedShift:     16,
+	GreenShift:   8,
+	BlueShift:    0,
+}
+
+// vncClient is a connected viewer. Messages are read on serve and updates
+// are written on their own goroutine.
+type vncClient struct {
+	d    *vncDriver
+	conn net.Conn
+	r    *bufio.Reader
+	wake func()
+
+	mu          sync.Mutex
+	format      rfbPixelFormat
+	copyRect    bool
+	zlib        bool
+	requested   bool
+	incremental bool
+	region      image.Rectangle
+	signal      chan struct{}
+	closed      chan struct{}
+
+	// writer state
+	shown  *image.RGBA // frame the viewer has
+	damage damage      // changed since shown, guarded by d.mu
+	zbuf   bytes.Buffer
+	zw     *zlib.Writer
+
+	// input state
+	buttons uint8
+	x, y    int32
+	keys    map[uint32]bool
+	mods    Modifier
+}
+
+func newVNCClient(d *vncDriver, conn net.Conn) *vncClient {
+	c := &vncClient{
+		d:      d,
+		conn:   conn,
+		r:      bufio.NewReader(conn),
+		format: rfbDefaultFormat,
+		signal: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+		keys:   make(map[uint32]bool),
+	}
+	c.wake = func() {
+		select {
+		case c.signal <- struct{}{}:
+		default:
+		}
+	}
+	return c
+}
+
+func (c *vncClient) serve() {
+	defer c.conn.Close()
+	if err := c.handshake(); err != nil {
+		return
+	}
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		c.writeLoop()
+	}()
+	c.readLoop()
+	c.conn.Close()
+	close(c.closed)
+	<-writerDone
+}
+
+func (c *vncClient) handshake() error {
+	if _, err := io.WriteString(c.conn, "RFB 003.008\n"); err != nil {
+		return err
+	}
+	var version [12]byte
+	if _, err := io.ReadFull(c.r, version[:]); err != nil {
+		return err
+	}
+	var major, minor int
+	if _, err := fmt.Sscanf(string(version[:]), "RFB %03d.%03d\n", &major, &minor); err != nil || major != 3 {
+		return fmt.Errorf("vnc: unsupported version %q", version)
+	}
+
+	// security type None
+	if minor < 7 {
+		if err := binary.Write(c.conn, binary.BigEndian, uint32(1)); err != nil {
+			return err
+		}
+	} else {
+		if _, err := c.conn.Write([]byte{1, 1}); err != nil {
+			return err
+		}
+		selected, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if selected != 1 {
+			return fmt.Errorf("vnc: unsupported security type %d", selected)
+		}
+		if minor >= 8 {
+			if err := binary.Write(c.conn, binary.BigEndian, uint32(0)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// ClientInit: shared flag, all viewers share the window anyway
+	if _, err := c.r.ReadByte(); err != nil {
+		return err
+	}
+
+	b := c.d.image.Rect
+	var init bytes.Buffer
+	binary.Write(&init, binary.BigEndian, uint16(b.Dx()))
+	binary.Write(&init, binary.BigEndian, uint16(b.Dy()))
+	binary.Write(&init, binary.BigEndian, rfbDefaultFormat)
+	binary.Write(&init, binary.BigEndian, uint32(len(c.d.name)))
+	init.WriteString(c.d.name)
+	_, err := c.conn.Write(init.Bytes())
+	return err
+}
+
+func (c *vncClient) readLoop() {
+	for {
+		t, err := c.r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		switch t {
+		case rfbSetPixelFormat:
+			var msg struct {
+				_      [3]byte
+				Format rfbPixelFormat
+			}
+			if err := binary.Read(c.r, binary.BigEndian, &msg); err != nil {
+				return
+			}
+			if err := checkPixelFormat(&msg.Format); err != nil {
+				return
+			}
+			c.d.traceMessage(TraceOther, "SetPixelFormat", "bpp=%d", msg.Format.BitsPerPixel)
+			c.mu.Lock()
+			c.format = msg.Format
+			c.shown = nil // resend everything in the new format
+			c.mu.Unlock()
+		case rfbSetEncodings:
+			var msg struct {
+				_     byte
+				Count uint16
+			}
+			if err := binary.Read(c.r, binary.BigEndian, &msg); err != nil {
+				return
+			}
+			encodings := make([]int32, msg.Count)
+			if err := binary.Read(c.r, binary.BigEndian, encodings); err != nil {
+				return
+			}
+			c.d.traceMessage(TraceOther, "SetEncodings", "encodings=%v", encodings)
+			c.setEncodings(encodings)
+		case rfbFramebufferUpdateRequest:
+			var msg struct {
+				Incremental         uint8
+				X, Y, Width, Height uint16
+			}
+			if err := binary.Read(c.r, binary.BigEndian, &msg); err != nil {
+				return
+			}
+			c.d.traceMessage(TracePaint, "FramebufferUpdateRequest", "incremental=%d x=%d y=%d width=%d height=%d", msg.Incremental, msg.X, msg.Y, msg.Width, msg.Height)
+			c.mu.Lock()
+			region := image.Rect(int(msg.X), int(msg.Y), int(msg.X)+int(msg.Width), int(msg.Y)+int(msg.Height))
+			if c.requested && c.incremental == (msg.Incremental != 0) {
+				c.region = c.region.Union(region)
+			} else {
+				c.region = region
+			}
+			c.requested = true
+			c.incremental = msg.Incremental != 0
+			c.mu.Unlock()
+			c.wake()
+		case rfbKeyEvent:
+			var msg struct {
+				Down uint8
+				_    [2]byte
+				Key  uint32
+			}
+			if err := binary.Read(c.r, binary.BigEndian, &msg); err != nil {
+				return
+			}
+			c.d.traceMessage(TraceInput, "KeyEvent", "down=%d key=0x%04x", msg.Down, msg.Key)
+			c.key(msg.Key, msg.Down != 0)
+		case rfbPointerEvent:
+			var msg struct {
+				Buttons uint8
+				X, Y    uint16
+			}
+			if err := binary.Read(c.r, binary.BigEndian, &msg); err != nil {
+				return
+			}
+			c.d.traceMessage(TraceInput, "PointerEvent", "buttons=0x%02x x=%d y=%d", msg.Buttons, msg.X, msg.Y)
+			c.pointer(msg.Buttons, int32(msg.X), int32(msg.Y))
+		case rfbClientCutText:
+			var msg struct {
+				_      [3]byte
+				Length uint32
+			}
+			if err := binary.Read(c.r, binary.BigEndian, &msg); err != nil {
+				return
+			}
+			c.d.traceMessage(TraceOther, "ClientCutText", "length=%d", msg.Length)
+			if _, err := io.CopyN(ioutil.Discard, c.r, int64(msg.Length)); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func checkPixelFormat(f *rfbPixelFormat) error {
+	switch f.BitsPerPixel {
+	case 8, 16, 32:
+	default:
+		return fmt.Errorf("vnc: %d bits per pixel is not supported", f.BitsPerPixel)
+	}
+	if f.TrueColor == 0 {
+		return errors.New("vnc: color maps are not supported")
+	}
+	return nil
+}
+
+func (c *vncClient) setEncodings(encodings []int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.copyRect, c.zlib = false, false
+	preferred := false
+	for _, e := range encodings {
+		switch e {
+		case rfbEncodingCopyRect:
+			c.copyRect = true
+		case rfbEncodingZlib:
+			if !preferred {
+				c.zlib = true
+			}
+			preferred = true
+		case rfbEncodingRaw:
+			preferred = true
+		}
+	}
+}
+
+// writeLoop sends an update whenever one is requested and the frame has changed.
+func (c *vncClient) writeLoop() {
+	for {
+		select {
+		case <-c.signal:
+		case <-c.closed:
+			return
+		}
+
+		if err := c.update(); err != nil {
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+// rfbRect is a rectangle of a FramebufferUpdate.
+type rfbRect struct {
+	rect     image.Rectangle
+	copyFrom image.Point // source of CopyRect
+	copy     bool
+}
+
+func (c *vncClient) update() error {
+	c.mu.Lock()
+	requested := c.requested
+	c.mu.Unlock()
+	if !requested {
+		return nil
+	}
+	frame, dirty := c.d.takeFrame(c)
+
+	c.mu.Lock()
+	region := c.region.Intersect(frame.Rect)
+	incremental := c.incremental && c.shown != nil
+	format, useCopy, useZlib := c.format, c.copyRect, c.zlib
+	shown := c.shown
+	c.mu.Unlock()
+
+	// only the damage in the requested region is sent
+	var changed, kept damage
+	for _, r := range dirty {
+		changed.add(r, region)
+		if !r.In(region) {
+			kept.add(r, frame.Rect)
+		}
+	}
+	if !kept.empty() {
+		c.d.addDamage(c, kept.rects)
+	}
+
+	var rects []rfbRect
+	base := shown
+	if !incremental {
+		if !region.Empty() {
+			rects = append(rects, rfbRect{rect: region})
+		}
+		if shown == nil {
+			// the viewer has nothing outside region, which is sent when
+			// it is requested
+			c.d.addDamage(c, []image.Rectangle{frame.Rect})
+		}
+	} else {
+		if useCopy && !changed.empty() {
+			if r, ok := detectScroll(shown, frame, changed.bounds()); ok {
+				rects = append(rects, r)
+				base = applyCopy(shown, r)
+				// the copy may move pixels out of the damage
+				changed.add(r.rect, frame.Rect)
+			}
+		}
+		for _, dr := range changed.rects {
+			for _, r := range diffRects(base, frame, dr) {
+				rects = append(rects, rfbRect{rect: r})
+			}
+		}
+		if len(rects) == 0 {
+			// wait for the next frame
+			return nil
+		}
+	}
+
+	// the viewer has the sent rectangles of frame and base elsewhere
+	next := image.NewRGBA(frame.Rect)
+	if base != nil {
+		copy(next.Pix, base.Pix)
+	}
+	for _, r := range rects {
+		if !r.copy {
+			draw.Draw(next, r.rect, frame, r.rect.Min, draw.Src)
+		}
+	}
+
+	c.mu.Lock()
+	c.requested = false
+	c.shown = next
+	c.mu.Unlock()
+
+	var msg bytes.Buffer
+	msg.Write([]byte{0, 0})
+	binary.Write(&msg, binary.BigEndian, uint16(len(rects)))
+	for _, r := range rects {
+		binary.Write(&msg, binary.BigEndian, [4]uint16{
+			uint16(r.rect.Min.X), uint16(r.rect.Min.Y),
+			uint16(r.rect.Dx()), uint16(r.rect.Dy()),
+		})
+		pixels := func() []byte {
+			return encodePixels(frame, r.rect, &format)
+		}
+		switch {
+		case r.copy:
+			binary.Write(&msg, binary.BigEndian, int32(rfbEncodingCopyRect))
+			binary.Write(&msg, binary.BigEndian, [2]uint16{uint16(r.copyFrom.X), uint16(r.copyFrom.Y)})
+		case useZlib:
+			data, err := c.compress(pixels())
+			if err != nil {
+				return err
+			}
+			binary.Write(&msg, binary.BigEndian, int32(rfbEncodingZlib))
+			binary.Write(&msg, binary.BigEndian, uint32(len(data)))
+			msg.Write(data)
+		default:
+			binary.Write(&msg, binary.BigEndian, int32(rfbEncodingRaw))
+			msg.Write(pixels())
+		}
+	}
+	_, err := c.conn.Write(msg.Bytes())
+	return err
+}
+
+// compress compresses data with the zlib stream of the connection,
+// which lasts for all rectangles.
+func (c *vncClient) compress(data []byte) ([]byte, error) {
+	c.zbuf.Reset()
+	if c.zw == nil {
+		c.zw = zlib.NewWriter(&c.zbuf)
+	}
+	if _, err := c.zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := c.zw.Flush(); err != nil {
+		return nil, err
+	}
+	return c.zbuf.Bytes(), nil
+}
+
+// encodePixels converts the pixels of r in img to the pixel format f.
+func encodePixels(img *image.RGBA, r image.Rectangle, f *rfbPixelFormat) []byte {
+	bpp := int(f.BitsPerPixel) / 8
+	out := make([]byte, 0, r.Dx()*r.Dy()*bpp)
+	fast := *f == rfbDefaultFormat
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(r.Min.X, y):img.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			if fast {
+				out = append(out, row[i+2], row[i+1], row[i+0], 0)
+				continue
+			}
+			p := uint32(row[i+0])*uint32(f.RedMax)/255<<f.RedShift |
+				uint32(row[i+1])*uint32(f.GreenMax)/255<<f.GreenShift |
+				uint32(row[i+2])*uint32(f.BlueMax)/255<<f.BlueShift
+			for b := 0; b < bpp; b++ {
+				shift := 8 * uint(b)
+				if f.BigEndian != 0 {
+					shift = 8 * uint(bpp-1-b)
+				}
+				out = append(out, byte(p>>shift))
+			}
+		}
+	}
+	return out
+}
+
+// diffRects returns the rectangles of region where cur differs from old.
+// Changed rows are grouped into bands, each narrowed to its changed columns.
+func diffRects(old, cur *image.RGBA, region image.Rectangle) []image.Rectangle {
+	var rects []image.Rectangle
+	band := image.Rectangle{}
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		x0, x1 := changedColumns(old, cur, y, region.Min.X, region.Max.X)
+		if x0 < x1 {
+			row := image.Rect(x0, y, x1, y+1)
+			// merge rows closer than 8 pixels
+			if !band.Empty() && y-band.Max.Y < 8 {
+				band = band.Union(row)
+			} else {
+				if !band.Empty() {
+					rects = append(rects, band)
+				}
+				band = row
+			}
+		}
+	}
+	if !band.Empty() {
+		rects = append(rects, band)
+	}
+	return rects
+}
+
+// changedColumns returns the range of columns in [x0, x1) which differ in row y.
+func changedColumns(old, cur *image.RGBA, y, x0, x1 int) (int, int) {
+	a := old.Pix[old.PixOffset(x0, y):old.PixOffset(x1, y)]
+	b := cur.Pix[cur.PixOffset(x0, y):cur.PixOffset(x1, y)]
+	if bytes.Equal(a, b) {
+		return 0, 0
+	}
+	first, last := 0, len(a)-4
+	for first < len(a) && bytes.Equal(a[first:first+4], b[first:first+4]) {
+		first += 4
+	}
+	for last > first && bytes.Equal(a[last:last+4], b[last:last+4]) {
+		last -= 4
+	}
+	return x0 + first/4, x0 + last/4 + 1
+}
+
+// minScrollRows is the smallest scrolled block sent as CopyRect.
+const minScrollRows = 8
+
+// detectScroll finds the largest block of full rows in region which moved vertically.
+func detectScroll(old, cur *image.RGBA, region image.Rectangle) (rfbRect, bool) {
+	x0, x1 := region.Min.X, region.Max.X
+	row := func(img *image.RGBA, y int) []byte {
+		return img.Pix[img.PixOffset(x0, y):img.PixOffset(x1, y)]
+	}
+
+	// rows of old by hash
+	h := fnv.New64a()
+	hashes := make(map[uint64][]int)
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		h.Reset()
+		h.Write(row(old, y))
+		hashes[h.Sum64()] = append(hashes[h.Sum64()], y)
+	}
+
+	var best rfbRect
+	bestRows := 0
+	for y := region.Min.Y; y < region.Max.Y; y += minScrollRows {
+		if bytes.Equal(row(old, y), row(cur, y)) {
+			continue
+		}
+		h.Reset()
+		h.Write(row(cur, y))
+		for _, src := range hashes[h.Sum64()] {
+			dy := src - y
+			if dy == 0 {
+				continue
+			}
+			// extend the block up and down
+			top, bottom := y, y
+			for top > region.Min.Y && top-1+dy >= region.Min.Y && bytes.Equal(row(cur, top-1), row(old, top-1+dy)) {
+				top--
+			}
+			for bottom < region.Max.Y && bottom+dy < region.Max.Y && bytes.Equal(row(cur, bottom), row(old, bottom+dy)) {
+				bottom++
+			}
+			if rows := bottom - top; rows > bestRows {
+				bestRows = rows
+				best = rfbRect{
+					rect:     image.Rect(x0, top, x1, bottom),
+					copyFrom: image.Pt(x0, top+dy),
+					copy:     true,
+				}
+			}
+		}
+	}
+	return best, bestRows >= minScrollRows
+}
+
+// applyCopy returns old with the CopyRect r applied, as the viewer will have it.
+func applyCopy(old *image.RGBA, r rfbRect) *image.RGBA {
+	img := image.NewRGBA(old.Rect)
+	copy(img.Pix, old.Pix)
+	for y := 0; y < r.rect.Dy(); y++ {
+		dst := img.Pix[img.PixOffset(r.rect.Min.X, r.rect.Min.Y+y):img.PixOffset(r.rect.Max.X, r.rect.Min.Y+y)]
+		src := old.Pix[old.PixOffset(r.copyFrom.X, r.copyFrom.Y+y):]
+		copy(dst, src[:len(dst)])
+	}
+	return img
+}
+
+func (c *vncClient) key(keysym uint32, down bool) {
+	key := keyFromKeysym(keysym)
+	repeat := down && c.keys[keysym]
+	c.keys[keysym] = down
+
+	if mod, ok := keysymModifiers[keysym]; ok {
+		if down {
+			c.mods |= mod
+		} else {
+			c.mods &^= mod
+			// the other key of the pair may still be down
+			for k, pressed := range c.keys {
+				if pressed && keysymModifiers[k] == mod {
+					c.mods |= mod
+				}
+			}
+		}
+	}
+
+	c.d.post(&KeyEvent{Key: key, Mods: c.mods, Down: down, Repeat: repeat})
+}
+
+// VNC button mask bits
+var vncButtons = [...]MouseButton{ButtonLeft, ButtonMiddle, ButtonRight}
+
+func (c *vncClient) pointer(buttons uint8, x, y int32) {
+	if x != c.x || y != c.y {
+		c.x, c.y = x, y
+		c.d.post(&MouseEvent{Action: MouseMove, X: x, Y: y, Mods: c.mods})
+	}
+
+	changed := buttons ^ c.buttons
+	c.buttons = buttons
+	for i, button := range vncButtons {
+		if changed&(1<<uint(i)) == 0 {
+			continue
+		}
+		action := MouseRelease
+		if buttons&(1<<uint(i)) != 0 {
+			action = MousePress
+		}
+		c.d.post(&MouseEvent{Action: action, Button: button, X: x, Y: y, Mods: c.mods})
+	}
+
+	// wheel: up, down, left, right as buttons 4 to 7
+	pressed := changed & buttons
+	scroll := [...]struct{ dx, dy float32 }{{0, -1}, {0, 1}, {-1, 0}, {1, 0}}
+	for i, s := range scroll {
+		if pressed&(1<<uint(3+i)) != 0 {
+			c.d.post(&MouseEvent{Action: MouseScroll, X: x, Y: y, Mods: c.mods, ScrollX: s.dx, ScrollY: s.dy})
+		}
+	}
+}
+
+// keysymModifiers maps the X keysyms of modifier keys to modifiers.
+var keysymModifiers = map[uint32]Modifier{
+	0xffe1: ModShift,
+	0xffe2: ModShift,
+	0xffe3: ModCtrl,
+	0xffe4: ModCtrl,
+	0xffe7: ModSuper, // Meta
+	0xffe8: ModSuper,
+	0xffe9: ModAlt,
+	0xffea: ModAlt,
+	0xffeb: ModSuper,
+	0xffec: ModSuper,
+}
+
+// keysymKeys maps X keysyms to keys. Shifted symbols are mapped to their
+// keys on the US layout.
+var keysymKeys = map[uint32]Key{
+	0xff0d: KeyEnter,
+	0xff8d: KeyEnter, // KP_Enter
+	0xff1b: KeyEscape,
+	0xff08: KeyBackspace,
+	0xff09: KeyTab,
+	0x0020: KeySpace,
+	0xff63: KeyInsert,
+	0xffff: KeyDelete,
+	0xff50: KeyHome,
+	0xff57: KeyEnd,
+	0xff55: KeyPageUp,
+	0xff56: KeyPageDown,
+	0xff51: KeyLeft,
+	0xff52: KeyUp,
+	0xff53: KeyRight,
+	0xff54: KeyDown,
+	'-':    KeyMinus,
+	'_':    KeyMinus,
+	'=':    KeyEqual,
+	'+':    KeyEqual,
+	',':    KeyComma,
+	'<':    KeyComma,
+	'.':    KeyPeriod,
+	'>':    KeyPeriod,
+	'/':    KeySlash,
+	'?':    KeySlash,
+	')':    Key0,
+	'!':    Key1,
+	'@':    Key2,
+	'#':    Key3,
+	'$':    Key4,
+	'%':    Key5,
+	'^':    Key6,
+	'&':    Key7,
+	'*':    Key8,
+	'(':    Key9,
+	0xffe1: KeyShift,
+	0xffe2: KeyShift,
+	0xffe3: KeyControl,
+	0xffe4: KeyControl,
+	0xffe7: KeySuper,
+	0xffe8: KeySuper,
+	0xffe9: KeyAlt,
+	0xffea: KeyAlt,
+	0xffeb: KeySuper,
+	0xffec: KeySuper,
+}
+
+func keyFromKeysym(keysym uint32) Key {
+	switch {
+	case keysym >= 'a' && keysym <= 'z':
+		return KeyA + Key(keysym-'a')
+	case keysym >= 'A' && keysym <= 'Z':
+		return KeyA + Key(keysym-'A')
+	case keysym >= '0' && keysym <= '9':
+		return Key0 + Key(keysym-'0')
+	case keysym >= 0xffbe && keysym <= 0xffc9:
+		return KeyF1 + Key(keysym-0xffbe)
+	}
+	return keysymKeys[keysym]
+}
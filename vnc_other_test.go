@@ -0,0 +1,268 @@
+// +build !windows
+
+package gui
+
+import (
+	"bufio"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// rfbClient is a minimal RFB viewer with the raw encoding.
+type rfbClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	fb   *image.RGBA
+}
+
+func dialRFB(t *testing.T, addr string) *rfbClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &rfbClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	var version [12]byte
+	c.read(&version)
+	if string(version[:]) != "RFB 003.008\n" {
+		t.Fatalf("version %q", version)
+	}
+	c.write([]byte("RFB 003.008\n"))
+	var types [2]byte
+	c.read(&types)
+	if types != [2]byte{1, 1} {
+		t.Fatalf("security types % x, want None", types)
+	}
+	c.write([]byte{1})
+	var result uint32
+	c.read(&result)
+	if result != 0 {
+		t.Fatalf("security result %d", result)
+	}
+
+	c.write([]byte{1}) // shared
+	var init struct {
+		Width, Height uint16
+		Format        rfbPixelFormat
+		NameLength    uint32
+	}
+	c.read(&init)
+	name := make([]byte, init.NameLength)
+	c.read(name)
+	c.fb = image.NewRGBA(image.Rect(0, 0, int(init.Width), int(init.Height)))
+
+	// raw only
+	c.write([]byte{rfbSetEncodings, 0})
+	c.write(uint16(1))
+	c.write(int32(rfbEncodingRaw))
+	return c
+}
+
+func (c *rfbClient) read(v interface{}) {
+	if b, ok := v.([]byte); ok {
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			c.t.Fatal(err)
+		}
+		return
+	}
+	if err := binary.Read(c.r, binary.BigEndian, v); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *rfbClient) write(v interface{}) {
+	if err := binary.Write(c.conn, binary.BigEndian, v); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// update requests r and applies the FramebufferUpdate to fb. It returns
+// the rectangles received.
+func (c *rfbClient) update(incremental bool, r image.Rectangle) []image.Rectangle {
+	inc := byte(0)
+	if incremental {
+		inc = 1
+	}
+	c.write([]byte{rfbFramebufferUpdateRequest, inc})
+	c.write([4]uint16{uint16(r.Min.X), uint16(r.Min.Y), uint16(r.Dx()), uint16(r.Dy())})
+
+	var hdr struct {
+		Type  uint8
+		_     uint8
+		Count uint16
+	}
+	c.read(&hdr)
+	if hdr.Type != 0 {
+		c.t.Fatalf("message type %d", hdr.Type)
+	}
+	var rects []image.Rectangle
+	for i := 0; i < int(hdr.Count); i++ {
+		var rh struct {
+			X, Y, Width, Height uint16
+			Encoding            int32
+		}
+		c.read(&rh)
+		if rh.Encoding != rfbEncodingRaw {
+			c.t.Fatalf("encoding %d", rh.Encoding)
+		}
+		rect := image.Rect(int(rh.X), int(rh.Y), int(rh.X+rh.Width), int(rh.Y+rh.Height))
+		pix := make([]byte, rect.Dx()*rect.Dy()*4)
+		c.read(pix)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				p := pix[((y-rect.Min.Y)*rect.Dx()+x-rect.Min.X)*4:]
+				c.fb.SetRGBA(x, y, color.RGBA{p[2], p[1], p[0], 0xff})
+			}
+		}
+		rects = append(rects, rect)
+	}
+	return rects
+}
+
+// check fails unless all pixels of fb in r are want.
+func (c *rfbClient) check(r image.Rectangle, want color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if got := c.fb.RGBAAt(x, y); got != want {
+				c.t.Fatalf("viewer pixel %d,%d = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestVNCPartialUpdates(t *testing.T) {
+	setenv(t, vncAddrEnv, "127.0.0.1:0")
+	app := NewApplication(WithBackend("vnc"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+
+	red, blue := color.RGBA{0xff, 0, 0, 0xff}, color.RGBA{0, 0, 0xff, 0xff}
+	r := newTestRenderer(red)
+	errc := app.Loop("vnc", 40, 20, r)
+	w := (<-r.window).(*window)
+	r.nextFrame(t)
+	c := dialRFB(t, w.driver.(*vncDriver).listener.Addr().String())
+
+	top := image.Rect(0, 0, 40, 10)
+	if got := c.update(false, top); len(got) != 1 || got[0] != top {
+		t.Errorf("update of the top = %v", got)
+	}
+	c.check(top, red)
+
+	// the bottom was not sent, so it is sent when it is requested
+	c.update(true, c.fb.Rect)
+	c.check(c.fb.Rect, red)
+
+	// a frame sent for the left half only
+	left, right := image.Rect(0, 0, 20, 20), image.Rect(20, 0, 40, 20)
+	w.post(func() {
+		r.color = blue
+		w.Invalidate(w.size)
+	})
+	r.nextFrame(t)
+	c.update(true, left)
+	c.check(left, blue)
+	c.check(right, red)
+	got := c.update(true, c.fb.Rect)
+	for _, rect := range got {
+		if !rect.In(right) {
+			t.Errorf("sent %v again, only %v was missing", rect, right)
+		}
+	}
+	c.check(c.fb.Rect, blue)
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+}
+
+// TestVNCTrace traces the messages of a viewer by their RFB names.
+func TestVNCTrace(t *testing.T) {
+	setenv(t, vncAddrEnv, "127.0.0.1:0")
+	tr := newTraceRecorder()
+	app := NewApplication(WithBackend("vnc"), WithTracer(tr, 0))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("vnc", 40, 20, r)
+	w := (<-r.window).(*window)
+	c := dialRFB(t, w.driver.(*vncDriver).listener.Addr().String())
+
+	c.write([]byte{rfbKeyEvent, 1, 0, 0})
+	c.write(uint32(0xff0d))
+	c.write([]byte{rfbPointerEvent, 1})
+	c.write([2]uint16{5, 7})
+	for _, want := range []TraceEvent{
+		{Category: TraceOther, Name: "SetEncodings", Detail: "encodings=[0]"},
+		{Category: TraceInput, Name: "KeyEvent", Detail: "down=1 key=0xff0d"},
+		{Category: TraceInput, Name: "PointerEvent", Detail: "buttons=0x01 x=5 y=7"},
+	} {
+		e := tr.wait(t, "dispatch", want.Name)
+		if e.Backend != "vnc" || e.Window != "vnc" || e.Category != want.Category || e.Detail != want.Detail {
+			t.Errorf("traced %+v, want %+v", e, want)
+		}
+	}
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+}
+
+// TestVNCEventHeader checks the time and the position of the events of a
+// viewer, which RFB does not timestamp.
+func TestVNCEventHeader(t *testing.T) {
+	setenv(t, vncAddrEnv, "127.0.0.1:0")
+	app := NewApplication(WithBackend("vnc"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("vnc", 40, 20, r)
+	w := (<-r.window).(*window)
+	c := dialRFB(t, w.driver.(*vncDriver).listener.Addr().String())
+
+	sent := time.Now()
+	c.write([]byte{rfbPointerEvent, 0})
+	c.write([2]uint16{5, 7})
+	m := r.nextEvent(t, func(e Event) bool {
+		_, ok := e.(*MouseEvent)
+		return ok
+	}).(*MouseEvent)
+	if m.Action != MouseMove || m.X != 5 || m.Y != 7 || m.Pos != image.Pt(5, 7) {
+		t.Errorf("event %+v, want a move to (5, 7)", m)
+	}
+	if m.Time.Before(sent) || m.Time.After(time.Now()) {
+		t.Errorf("time %v, want the receipt after %v", m.Time, sent)
+	}
+
+	// the position of other events is the last one of the pointer
+	c.write([]byte{rfbKeyEvent, 1, 0, 0})
+	c.write(uint32(0xff0d))
+	k := r.nextEvent(t, func(e Event) bool {
+		_, ok := e.(*KeyEvent)
+		return ok
+	}).(*KeyEvent)
+	if k.Pos != image.Pt(5, 7) || k.Time.Before(m.Time) {
+		t.Errorf("key at %v %v, want (5, 7) after %v", k.Pos, k.Time, m.Time)
+	}
+
+	app.Quit(0)
+	if err := <-errc; err != nil {
+		t.Errorf("Loop: %v", err)
+	}
+}
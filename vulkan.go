@@ -0,0 +1,50 @@
+package gui
+
+// vulkanWindow is implemented by the windows of backends with Vulkan surfaces.
+type vulkanWindow interface {
+	vulkanExtensions() []string
+	createVulkanSurface(instance uintptr, allocator uintptr) (uint64, error)
+}
+
+// VulkanInstanceExtensions returns the instance extensions needed by
+// CreateVulkanSurface for w, or ErrNotSupported.
+func VulkanInstanceExtensions(w Window) ([]string, error) {
+	vw, ok := w.(vulkanWindow)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	exts := vw.vulkanExtensions()
+	if exts == nil {
+		return nil, ErrNotSupported
+	}
+	return exts, nil
+}
+
+// CreateVulkanSurface creates a VkSurfaceKHR of w. instance is a VkInstance
+// with the extensions of VulkanInstanceExtensions and allocator is a
+// VkAllocationCallbacks pointer or 0. The caller destroys the surface with
+// vkDestroySurfaceKHR before the loop ends.
+//
+// The surface lives as long as the window. Renderer.Update is called on
+// resize, and with zero size when the window is minimized, to recreate the
+// swapchain.
+//
+// Surfaces are win32 on Windows. With Linux, cgo and the vulkan build tag,
+// they are VK_KHR_xlib_surface for the "x11" backend, on a display of
+// libX11 opened for the window, and VK_EXT_headless_surface for the
+// "headless" backend, e.g. for lavapipe without a GPU. The framebuffer of
+// an X11 window is no longer presented once it has a surface.
+//
+// The other backends return ErrNotSupported. The Wayland backend speaks the
+// protocol in Go and has no wl_display of libwayland-client, which
+// VK_KHR_wayland_surface needs, and objects of its connection cannot be used
+// on another one. The fbdev, tui, vnc and web backends present a
+// framebuffer in memory, which no presentation engine can draw to; draw
+// offscreen and copy the image in SoftwareRenderer.DrawImage instead.
+func CreateVulkanSurface(w Window, instance uintptr, allocator uintptr) (uint64, error) {
+	vw, ok := w.(vulkanWindow)
+	if !ok {
+		return 0, ErrNotSupported
+	}
+	return vw.createVulkanSurface(instance, allocator)
+}
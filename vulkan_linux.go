@@ -0,0 +1,182 @@
+// +build linux,cgo,vulkan
+
+package gui
+
+/*
+#cgo LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stddef.h>
+
+// the types of vulkan.h, which is not needed to build
+
+typedef void *(*guiGetInstanceProcAddr)(uintptr_t instance, const char *name);
+
+typedef struct {
+	int32_t sType;
+	const void *pNext;
+	uint32_t flags;
+} guiHeadlessSurfaceCreateInfo;
+
+typedef int32_t (*guiCreateHeadlessSurface)(uintptr_t instance,
+	const guiHeadlessSurfaceCreateInfo *info, uintptr_t allocator, uint64_t *surface);
+
+typedef struct {
+	int32_t sType;
+	const void *pNext;
+	uint32_t flags;
+	void *dpy;
+	unsigned long window;
+} guiXlibSurfaceCreateInfo;
+
+typedef int32_t (*guiCreateXlibSurfaceFunc)(uintptr_t instance,
+	const guiXlibSurfaceCreateInfo *info, uintptr_t allocator, uint64_t *surface);
+
+// VK_STRUCTURE_TYPE_HEADLESS_SURFACE_CREATE_INFO_EXT
+#define GUI_HEADLESS_SURFACE_CREATE_INFO 1000256000
+// VK_STRUCTURE_TYPE_XLIB_SURFACE_CREATE_INFO_KHR
+#define GUI_XLIB_SURFACE_CREATE_INFO 1000004000
+// VK_ERROR_EXTENSION_NOT_PRESENT
+#define GUI_ERROR_EXTENSION_NOT_PRESENT -7
+
+static void *guiVulkan;
+
+static int guiLoadVulkan(void) {
+	if (!guiVulkan) {
+		guiVulkan = dlopen("libvulkan.so.1", RTLD_NOW | RTLD_LOCAL);
+	}
+	return guiVulkan != NULL;
+}
+
+static void *guiVulkanProc(uintptr_t instance, const char *name) {
+	guiGetInstanceProcAddr getProc = (guiGetInstanceProcAddr)dlsym(guiVulkan, "vkGetInstanceProcAddr");
+	return getProc ? getProc(instance, name) : NULL;
+}
+
+static int32_t guiCreateSurface(uintptr_t instance, uintptr_t allocator, uint64_t *surface) {
+	guiCreateHeadlessSurface create = (guiCreateHeadlessSurface)guiVulkanProc(instance, "vkCreateHeadlessSurfaceEXT");
+	if (!create) {
+		return GUI_ERROR_EXTENSION_NOT_PRESENT;
+	}
+	guiHeadlessSurfaceCreateInfo info = {GUI_HEADLESS_SURFACE_CREATE_INFO, NULL, 0};
+	return create(instance, &info, allocator, surface);
+}
+
+static int32_t guiCreateXlibSurface(uintptr_t instance, void *display, uintptr_t window,
+	uintptr_t allocator, uint64_t *surface) {
+	guiCreateXlibSurfaceFunc create = (guiCreateXlibSurfaceFunc)guiVulkanProc(instance, "vkCreateXlibSurfaceKHR");
+	if (!create) {
+		return GUI_ERROR_EXTENSION_NOT_PRESENT;
+	}
+	guiXlibSurfaceCreateInfo info = {GUI_XLIB_SURFACE_CREATE_INFO, NULL, 0, display, window};
+	return create(instance, &info, allocator, surface);
+}
+
+typedef void *(*guiXOpenDisplayFunc)(const char *name);
+typedef int (*guiXCloseDisplayFunc)(void *display);
+
+// guiXlib returns a function of libX11, which is loaded at run time so
+// that the other backends do not need it.
+static void *guiXlib(const char *name) {
+	void *xlib = dlopen("libX11.so.6", RTLD_NOW | RTLD_GLOBAL);
+	return xlib ? dlsym(xlib, name) : NULL;
+}
+
+static void *guiOpenXlibDisplay(void) {
+	guiXOpenDisplayFunc open = (guiXOpenDisplayFunc)guiXlib("XOpenDisplay");
+	return open ? open(NULL) : NULL;
+}
+
+static void guiCloseXlibDisplay(void *display) {
+	guiXCloseDisplayFunc close = (guiXCloseDisplayFunc)guiXlib("XCloseDisplay");
+	if (close) {
+		close(display);
+	}
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+)
+
+// vulkanX11Driver is a driver of X11 whose window can have a Vulkan surface.
+type vulkanX11Driver interface {
+	windowSurfaceDriver
+	// onClose calls f when the window is closed.
+	onClose(f func())
+}
+
+// xlibDisplays are the displays of Xlib of the windows with Vulkan surfaces.
+var (
+	xlibMu       sync.Mutex
+	xlibDisplays = make(map[vulkanX11Driver]unsafe.Pointer)
+)
+
+func (w *window) vulkanExtensions() []string {
+	switch w.app.Backend() {
+	case "headless":
+		return []string{"VK_KHR_surface", "VK_EXT_headless_surface"}
+	case "x11":
+		return []string{"VK_KHR_surface", "VK_KHR_xlib_surface"}
+	}
+	return nil
+}
+
+func (w *window) createVulkanSurface(instance uintptr, allocator uintptr) (uint64, error) {
+	if w.vulkanExtensions() == nil || C.guiLoadVulkan() == 0 {
+		return 0, ErrNotSupported
+	}
+	if d, ok := w.driver.(vulkanX11Driver); ok {
+		return w.createXlibSurface(d, instance, allocator)
+	}
+	var surface C.uint64_t
+	r := C.guiCreateSurface(C.uintptr_t(instance), C.uintptr_t(allocator), &surface)
+	if r == C.GUI_ERROR_EXTENSION_NOT_PRESENT {
+		return 0, ErrNotSupported
+	}
+	if r != 0 {
+		return 0, fmt.Errorf("vkCreateHeadlessSurfaceEXT: VkResult %d", int32(r))
+	}
+	return uint64(surface), nil
+}
+
+// createXlibSurface creates a surface of the window of d on a display of
+// Xlib, which the X11 backend does not use. The display is opened for the
+// first surface and closed with the window.
+func (w *window) createXlibSurface(d vulkanX11Driver, instance uintptr, allocator uintptr) (uint64, error) {
+	xlibMu.Lock()
+	display := xlibDisplays[d]
+	xlibMu.Unlock()
+	if display == nil {
+		display = C.guiOpenXlibDisplay()
+		if display == nil {
+			return 0, errors.New("XOpenDisplay: no X11 display")
+		}
+		xlibMu.Lock()
+		xlibDisplays[d] = display
+		xlibMu.Unlock()
+		d.onClose(func() {
+			xlibMu.Lock()
+			delete(xlibDisplays, d)
+			xlibMu.Unlock()
+			C.guiCloseXlibDisplay(display)
+		})
+	}
+
+	window, _ := d.glWindow()
+	var surface C.uint64_t
+	r := C.guiCreateXlibSurface(C.uintptr_t(instance), display, C.uintptr_t(window), C.uintptr_t(allocator), &surface)
+	if r == C.GUI_ERROR_EXTENSION_NOT_PRESENT {
+		return 0, ErrNotSupported
+	}
+	if r != 0 {
+		return 0, fmt.Errorf("vkCreateXlibSurfaceKHR: VkResult %d", int32(r))
+	}
+	// the swapchain presents instead of present
+	d.setGLSurface(true)
+	return uint64(surface), nil
+}
@@ -0,0 +1,87 @@
+// +build linux,cgo,vulkan
+
+package gui
+
+import (
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/ysh86/gui/internal/vktest"
+)
+
+func TestVulkanNotSupported(t *testing.T) {
+	setenv(t, vncAddrEnv, "127.0.0.1:0")
+	app := NewApplication(WithBackend("vnc"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("vulkan", 16, 16, r)
+	w := <-r.window
+
+	if _, err := VulkanInstanceExtensions(w); err != ErrNotSupported {
+		t.Errorf("VulkanInstanceExtensions: %v, want ErrNotSupported", err)
+	}
+	if _, err := CreateVulkanSurface(w, 0, 0); err != ErrNotSupported {
+		t.Errorf("CreateVulkanSurface: %v, want ErrNotSupported", err)
+	}
+	app.Quit(0)
+	<-errc
+}
+
+// createTestSurface creates a surface of a window of the backend with
+// lavapipe, or any other driver of the Vulkan loader with the extensions.
+func createTestSurface(t *testing.T, backend string, want []string) {
+	app := NewApplication(WithBackend(backend))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Deinit()
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("vulkan", 16, 16, r)
+	w := <-r.window
+	defer func() {
+		app.Quit(0)
+		if err := <-errc; err != nil {
+			t.Errorf("Loop: %v", err)
+		}
+		if err := CheckLeaks(app); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	exts, err := VulkanInstanceExtensions(w)
+	if err != nil || !reflect.DeepEqual(exts, want) {
+		t.Fatalf("VulkanInstanceExtensions: %v %v, want %v", exts, err, want)
+	}
+	instance, destroy, err := vktest.Instance(exts)
+	if err != nil {
+		t.Skipf("no Vulkan driver with the extensions: %v", err)
+	}
+
+	var surface uint64
+	onLoop(t, w.(*window), func() {
+		surface, err = CreateVulkanSurface(w, instance, 0)
+	})
+	// before the loop ends
+	onLoop(t, w.(*window), func() { destroy(surface) })
+	if err != nil {
+		t.Fatalf("CreateVulkanSurface: %v", err)
+	}
+	if surface == 0 {
+		t.Error("CreateVulkanSurface returned a null surface")
+	}
+}
+
+func TestVulkanHeadless(t *testing.T) {
+	createTestSurface(t, "headless", []string{"VK_KHR_surface", "VK_EXT_headless_surface"})
+}
+
+// TestVulkanXlib creates a surface of a window of the fake X server, which
+// Mesa does not contact until a swapchain is created.
+func TestVulkanXlib(t *testing.T) {
+	startXServer(t)
+	createTestSurface(t, "x11", []string{"VK_KHR_surface", "VK_KHR_xlib_surface"})
+}
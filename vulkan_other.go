@@ -0,0 +1,12 @@
+// +build !windows
+// +build !linux !cgo !vulkan
+
+package gui
+
+func (w *window) vulkanExtensions() []string {
+	return nil
+}
+
+func (w *window) createVulkanSurface(instance uintptr, allocator uintptr) (uint64, error) {
+	return 0, ErrNotSupported
+}
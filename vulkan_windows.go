@@ -0,0 +1,58 @@
+package gui
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// the loader is optional
+var (
+	modvulkan                 = windows.NewLazySystemDLL("vulkan-1.dll")
+	procvkGetInstanceProcAddr = modvulkan.NewProc("vkGetInstanceProcAddr")
+)
+
+// VK_STRUCTURE_TYPE_WIN32_SURFACE_CREATE_INFO_KHR
+const vkStructureTypeWin32SurfaceCreateInfo = 1000009000
+
+// vkWin32SurfaceCreateInfo is VkWin32SurfaceCreateInfoKHR.
+type vkWin32SurfaceCreateInfo struct {
+	sType     uint32
+	next      uintptr
+	flags     uint32
+	hinstance windows.Handle
+	hwnd      windows.Handle
+}
+
+func (w *window) vulkanExtensions() []string {
+	return []string{"VK_KHR_surface", "VK_KHR_win32_surface"}
+}
+
+func (w *window) createVulkanSurface(instance uintptr, allocator uintptr) (uint64, error) {
+	if procvkGetInstanceProcAddr.Find() != nil {
+		return 0, ErrNotSupported
+	}
+	name, err := windows.BytePtrFromString("vkCreateWin32SurfaceKHR")
+	if err != nil {
+		return 0, err
+	}
+	create, _, _ := syscall.Syscall(procvkGetInstanceProcAddr.Addr(), 2, instance, uintptr(unsafe.Pointer(name)), 0)
+	if create == 0 {
+		// VK_KHR_win32_surface is not enabled
+		return 0, ErrNotSupported
+	}
+
+	info := &vkWin32SurfaceCreateInfo{
+		sType:     vkStructureTypeWin32SurfaceCreateInfo,
+		hinstance: w.app.instance,
+		hwnd:      w.handle,
+	}
+	var surface uint64
+	r, _, _ := syscall.Syscall6(create, 4, instance, uintptr(unsafe.Pointer(info)), allocator, uintptr(unsafe.Pointer(&surface)), 0, 0)
+	if int32(r) != 0 {
+		return 0, fmt.Errorf("vkCreateWin32SurfaceKHR: VkResult %d", int32(r))
+	}
+	return surface, nil
+}
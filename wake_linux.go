@@ -0,0 +1,52 @@
+package gui
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// wakePipe wakes a driver blocked in poll(2) from other goroutines.
+type wakePipe struct {
+	r, w int
+	ok   bool
+}
+
+func (p *wakePipe) open() error {
+	var fds [2]int
+	if err := unix.Pipe2(fds[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		return fmt.Errorf("Pipe2: %v", err)
+	}
+	p.r, p.w, p.ok = fds[0], fds[1], true
+	return nil
+}
+
+func (p *wakePipe) close() {
+	if p.ok {
+		unix.Close(p.r)
+		unix.Close(p.w)
+		p.ok = false
+	}
+}
+
+// wake makes the read end readable. A full pipe is already readable.
+func (p *wakePipe) wake() {
+	if p.ok {
+		unix.Write(p.w, []byte{0})
+	}
+}
+
+func (p *wakePipe) pollFd() unix.PollFd {
+	return unix.PollFd{Fd: int32(p.r), Events: unix.POLLIN}
+}
+
+// drain empties the pipe after poll.
+func (p *wakePipe) drain() {
+	var buf [64]byte
+	for {
+		n, err := unix.Read(p.r, buf[:])
+		if n <= 0 || err != nil {
+			return
+		}
+	}
+}
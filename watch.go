@@ -0,0 +1,180 @@
+package gui
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Readiness is a set of conditions of a watched file descriptor.
+type Readiness uint32
+
+// Readiness conditions
+const (
+	Readable Readiness = 1 << iota
+	Writable
+	Hangup // the other end is closed or an error occurred, always watched
+)
+
+var readinessNames = []string{
+	"Readable",
+	"Writable",
+	"Hangup",
+}
+
+func (r Readiness) String() string {
+	var names []string
+	for i, name := range readinessNames {
+		if r&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "None"
+	}
+	return strings.Join(names, "|")
+}
+
+// Watcher calls a function on the loop thread of its window,
+// see Window.Watch and Window.Drain.
+type Watcher struct {
+	w      *window
+	fd     uintptr
+	events Readiness
+	f      func(ready Readiness)
+	done   bool
+
+	// stopc stops the goroutine of Drain
+	stopc chan struct{}
+}
+
+// Stop stops x. The function is not called after Stop returns.
+// It must be called on the loop thread.
+func (x *Watcher) Stop() {
+	if x.done {
+		return
+	}
+	x.done = true
+	if x.stopc != nil {
+		close(x.stopc)
+		return
+	}
+	x.w.unwatch(x)
+}
+
+// fireWatch calls the function of x with the conditions which are ready.
+func (w *window) fireWatch(x *Watcher, ready Readiness) {
+	if x.done {
+		return
+	}
+	w.guard(PhaseWatch, func() error {
+		x.f(ready)
+		return nil
+	})
+}
+
+func (w *window) Drain(ch interface{}, max int, f func(v interface{})) *Watcher {
+	c := reflect.ValueOf(ch)
+	if c.Kind() != reflect.Chan || c.Type().ChanDir()&reflect.RecvDir == 0 {
+		panic("gui: Drain of a non-receivable channel")
+	}
+	if max <= 0 {
+		max = 1
+	}
+
+	x := &Watcher{w: w, stopc: make(chan struct{})}
+	ack := make(chan struct{}, 1)
+	w.app.res.acquire(resDrain)
+	w.app.running.Add(1)
+	go func() {
+		defer w.app.running.Done()
+		defer w.app.res.release(resDrain)
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: c},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(x.stopc)},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(w.donec)},
+		}
+		for {
+			chosen, v, ok := reflect.Select(cases)
+			if chosen != 0 {
+				return
+			}
+			// the loop receives the rest until max, then the next value
+			// is received here
+			if !w.post(func() {
+				w.drain(x, c, v, ok, max, f)
+				ack <- struct{}{}
+			}) {
+				return
+			}
+			select {
+			case <-ack:
+			case <-x.stopc:
+				return
+			case <-w.donec:
+				return
+			}
+		}
+	}()
+	return x
+}
+
+// drain calls f with v and up to max-1 more values of c which are ready.
+func (w *window) drain(x *Watcher, c reflect.Value, v reflect.Value, ok bool, max int, f func(v interface{})) {
+	for n := 1; ; n++ {
+		if x.done {
+			return
+		}
+		if !ok {
+			// closed
+			x.Stop()
+			return
+		}
+		w.guard(PhaseWatch, func() error {
+			f(v.Interface())
+			return nil
+		})
+		if n >= max {
+			return
+		}
+		if v, ok = c.TryRecv(); !v.IsValid() {
+			return
+		}
+	}
+}
+
+// post calls f on the loop thread from any goroutine. It returns false
+// after the loop has ended.
+func (w *window) post(f func()) bool {
+	w.postMu.Lock()
+	defer w.postMu.Unlock()
+	if w.closed {
+		return false
+	}
+	w.posted = append(w.posted, f)
+	w.wakeLoop()
+	return true
+}
+
+// runPosted calls the functions of post on the loop thread.
+func (w *window) runPosted() {
+	w.postMu.Lock()
+	posted := w.posted
+	w.posted = nil
+	w.postMu.Unlock()
+
+	for _, f := range posted {
+		f()
+	}
+}
+
+// closePosts ends post when the loop ends.
+func (w *window) closePosts() {
+	w.postMu.Lock()
+	defer w.postMu.Unlock()
+	if !w.closed {
+		w.closed = true
+		w.posted = nil
+		close(w.donec)
+	}
+}
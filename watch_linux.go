@@ -0,0 +1,180 @@
+package gui
+
+import (
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+// fdWatches are the file descriptors of Window.Watch. A watchDriver polls
+// them with its own in poll(2), otherwise an fdPoller does.
+type fdWatches struct {
+	watchers []*Watcher
+	polling  []*Watcher // of the driver, see read
+	poller   *fdPoller
+}
+
+func (w *window) Watch(fd uintptr, events Readiness, f func(ready Readiness)) (*Watcher, error) {
+	x := &Watcher{w: w, fd: fd, events: events, f: f}
+	ws := &w.watches
+	if _, ok := w.driver.(watchDriver); !ok && ws.poller == nil {
+		p := &fdPoller{}
+		if err := p.wakeup.open(); err != nil {
+			return nil, err
+		}
+		ws.poller = p
+		w.app.res.acquire(resPoller)
+		w.app.running.Add(1)
+		go p.run(w)
+	}
+	ws.watchers = append(ws.watchers, x)
+	ws.changed()
+	return x, nil
+}
+
+func (w *window) unwatch(x *Watcher) {
+	ws := &w.watches
+	for i, y := range ws.watchers {
+		if y == x {
+			ws.watchers = append(ws.watchers[:i], ws.watchers[i+1:]...)
+			break
+		}
+	}
+	ws.changed()
+}
+
+// changed passes the watches to the poller.
+func (ws *fdWatches) changed() {
+	if p := ws.poller; p != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.watchers = append([]*Watcher(nil), ws.watchers...)
+		p.wakeup.wake()
+	}
+}
+
+// close stops the poller when the loop ends.
+func (ws *fdWatches) close() {
+	ws.watchers = nil
+	if p := ws.poller; p != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.closed = true
+		p.wakeup.wake()
+	}
+}
+
+// pollFds appends the watched file descriptors to fds for the driver.
+func (ws *fdWatches) pollFds(fds []unix.PollFd) []unix.PollFd {
+	if ws == nil {
+		return fds
+	}
+	ws.polling = append(ws.polling[:0], ws.watchers...)
+	return appendWatchFds(fds, ws.polling)
+}
+
+// read returns a watchEvent for each watch which is ready in fds, the
+// part of pollFds.
+func (ws *fdWatches) read(fds []unix.PollFd) []interface{} {
+	if ws == nil {
+		return nil
+	}
+	return readWatches(ws.polling, fds)
+}
+
+func appendWatchFds(fds []unix.PollFd, watchers []*Watcher) []unix.PollFd {
+	for _, x := range watchers {
+		var events int16
+		if x.events&Readable != 0 {
+			events |= unix.POLLIN
+		}
+		if x.events&Writable != 0 {
+			events |= unix.POLLOUT
+		}
+		fds = append(fds, unix.PollFd{Fd: int32(x.fd), Events: events})
+	}
+	return fds
+}
+
+func readWatches(watchers []*Watcher, fds []unix.PollFd) []interface{} {
+	var events []interface{}
+	for i, x := range watchers {
+		var ready Readiness
+		revents := fds[i].Revents
+		if revents&unix.POLLIN != 0 {
+			ready |= Readable
+		}
+		if revents&unix.POLLOUT != 0 {
+			ready |= Writable
+		}
+		if revents&(unix.POLLHUP|unix.POLLERR|unix.POLLNVAL) != 0 {
+			ready |= Hangup
+		}
+		if ready != 0 {
+			invalid := revents&unix.POLLNVAL != 0
+			events = append(events, watchEvent{watcher: x, ready: ready, invalid: invalid})
+		}
+	}
+	return events
+}
+
+// fdPoller polls the watches on a goroutine for drivers which do not wait
+// in poll(2), and fires them on the loop thread.
+type fdPoller struct {
+	mu       sync.Mutex
+	watchers []*Watcher
+	closed   bool
+	wakeup   wakePipe
+}
+
+func (p *fdPoller) run(w *window) {
+	defer func() {
+		p.mu.Lock()
+		p.wakeup.close()
+		p.mu.Unlock()
+		w.app.res.release(resPoller)
+		w.app.running.Done()
+	}()
+
+	ack := make(chan struct{}, 1)
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return
+		}
+		watchers := p.watchers
+		fds := append(appendWatchFds(nil, watchers), p.wakeup.pollFd())
+		p.mu.Unlock()
+
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if fds[len(fds)-1].Revents != 0 {
+			p.wakeup.drain()
+		}
+		events := readWatches(watchers, fds[:len(fds)-1])
+		if len(events) == 0 {
+			continue
+		}
+
+		// poll again after the functions have run
+		if !w.post(func() {
+			for _, e := range events {
+				w.fireWatchEvent(e.(watchEvent))
+			}
+			ack <- struct{}{}
+		}) {
+			return
+		}
+		select {
+		case <-ack:
+		case <-w.donec:
+			return
+		}
+	}
+}
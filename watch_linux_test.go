@@ -0,0 +1,224 @@
+package gui
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// startWatchWindow runs a window of the backend, whose driver polls the
+// watches itself or leaves them to the fdPoller.
+func startWatchWindow(t *testing.T, backend string) *window {
+	if backend == "x11" {
+		startXServer(t)
+	}
+	app := NewApplication(WithBackend(backend))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRenderer(color.RGBA{})
+	errc := app.Loop("watch", 8, 8, r)
+	w := (<-r.window).(*window)
+	t.Cleanup(func() {
+		app.Quit(0)
+		if err := <-errc; err != nil {
+			t.Errorf("Loop: %v", err)
+		}
+		app.Deinit()
+		if err := CheckLeaks(app); err != nil {
+			t.Error(err)
+		}
+	})
+	return w
+}
+
+func testPipe(t *testing.T) (r, w int) {
+	var fds [2]int
+	if err := unix.Pipe2(fds[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		t.Fatal(err)
+	}
+	return fds[0], fds[1]
+}
+
+// readiness is a call of a watcher.
+type readiness struct {
+	ready Readiness
+	tid   int
+}
+
+func nextReadiness(t *testing.T, c <-chan readiness) readiness {
+	t.Helper()
+	select {
+	case r := <-c:
+		return r
+	case <-testTimeout():
+		t.Fatal("the watcher was not called")
+		return readiness{}
+	}
+}
+
+// noReadiness fails if c receives a call in a while.
+func noReadiness(t *testing.T, c <-chan readiness, when string) {
+	t.Helper()
+	select {
+	case r := <-c:
+		t.Errorf("%s: called with %v", when, r.ready)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatch(t *testing.T) {
+	for _, backend := range []string{"headless", "x11"} {
+		t.Run(backend, func(t *testing.T) {
+			w := startWatchWindow(t, backend)
+			var loopTid int
+			onLoop(t, w, func() { loopTid = unix.Gettid() })
+
+			rfd, wfd := testPipe(t)
+			defer unix.Close(wfd)
+			calls := make(chan readiness, 64)
+			var x *Watcher
+			onLoop(t, w, func() {
+				var err error
+				x, err = w.Watch(uintptr(rfd), Readable, func(ready Readiness) {
+					calls <- readiness{ready, unix.Gettid()}
+					if ready&Readable != 0 {
+						var buf [16]byte
+						unix.Read(rfd, buf[:])
+					}
+				})
+				if err != nil {
+					t.Error(err)
+				}
+			})
+
+			// readable on the loop thread
+			noReadiness(t, calls, "empty")
+			unix.Write(wfd, []byte("x"))
+			if r := nextReadiness(t, calls); r.ready != Readable || r.tid != loopTid {
+				t.Errorf("written: %v on thread %d, want Readable on %d", r.ready, r.tid, loopTid)
+			}
+			noReadiness(t, calls, "read")
+
+			// stopped
+			onLoop(t, w, x.Stop)
+			unix.Write(wfd, []byte("x"))
+			noReadiness(t, calls, "stopped")
+			unix.Close(rfd)
+
+			// hangup of the other end
+			rfd, wfd2 := testPipe(t)
+			onLoop(t, w, func() {
+				x, _ = w.Watch(uintptr(rfd), Readable, func(ready Readiness) {
+					calls <- readiness{ready, unix.Gettid()}
+					if ready&Hangup != 0 {
+						x.Stop()
+					}
+				})
+			})
+			unix.Close(wfd2)
+			if r := nextReadiness(t, calls); r.ready&Hangup == 0 {
+				t.Errorf("closed: %v, want Hangup", r.ready)
+			}
+			noReadiness(t, calls, "hung up")
+			unix.Close(rfd)
+		})
+	}
+}
+
+// TestWatchClosed closes a watched fd without Stop, which poll(2) reports
+// with POLLNVAL on every call until the watcher is dropped.
+func TestWatchClosed(t *testing.T) {
+	for _, backend := range []string{"headless", "x11"} {
+		t.Run(backend, func(t *testing.T) {
+			w := startWatchWindow(t, backend)
+			rfd, wfd := testPipe(t)
+			defer unix.Close(wfd)
+			calls := make(chan readiness, 64)
+			var x *Watcher
+			onLoop(t, w, func() {
+				x, _ = w.Watch(uintptr(rfd), Readable, func(ready Readiness) {
+					select {
+					case calls <- readiness{ready: ready}:
+					default:
+						// spinning
+					}
+				})
+			})
+			onLoop(t, w, func() { unix.Close(rfd) })
+			// the poll before the close is woken by the change of the watches
+			onLoop(t, w, func() { w.watches.changed() })
+
+			if r := nextReadiness(t, calls); r.ready != Hangup {
+				t.Errorf("closed: %v, want Hangup", r.ready)
+			}
+			noReadiness(t, calls, "dropped")
+			onLoop(t, w, func() {
+				if !x.done || len(w.watches.watchers) != 0 {
+					t.Errorf("the watcher was not dropped")
+				}
+			})
+		})
+	}
+}
+
+func TestDrain(t *testing.T) {
+	w := startWatchWindow(t, "headless")
+	var loopTid int
+	onLoop(t, w, func() { loopTid = unix.Gettid() })
+
+	ch := make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		ch <- i
+	}
+	values := make(chan int, 20)
+	var x *Watcher
+	onLoop(t, w, func() {
+		x = w.Drain(ch, 3, func(v interface{}) {
+			if tid := unix.Gettid(); tid != loopTid {
+				t.Errorf("called on thread %d, want %d", tid, loopTid)
+			}
+			values <- v.(int)
+		})
+	})
+	for want := 0; want < 10; want++ {
+		select {
+		case v := <-values:
+			if v != want {
+				t.Errorf("value %d, want %d", v, want)
+			}
+		case <-testTimeout():
+			t.Fatal("Drain did not receive")
+		}
+	}
+
+	// the watcher stops when the channel is closed
+	close(ch)
+	deadline := time.Now().Add(time.Second)
+	for {
+		var done bool
+		onLoop(t, w, func() { done = x.done })
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Drain did not stop at the close")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// no value after Stop
+	ch2 := make(chan int, 1)
+	onLoop(t, w, func() {
+		x = w.Drain(ch2, 1, func(v interface{}) { values <- v.(int) })
+		x.Stop()
+	})
+	ch2 <- 1
+	select {
+	case v := <-values:
+		t.Errorf("stopped: received %d", v)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
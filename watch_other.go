@@ -0,0 +1,14 @@
+// +build !windows,!linux
+
+package gui
+
+// fdWatches are not supported without poll(2) of Linux.
+type fdWatches struct{}
+
+func (w *window) Watch(fd uintptr, events Readiness, f func(ready Readiness)) (*Watcher, error) {
+	return nil, ErrNotSupported
+}
+
+func (w *window) unwatch(x *Watcher) {}
+
+func (ws *fdWatches) close() {}
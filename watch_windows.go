@@ -0,0 +1,74 @@
+package gui
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+func (w *window) Watch(fd uintptr, events Readiness, f func(ready Readiness)) (*Watcher, error) {
+	// one wait object is for the messages
+	if len(w.watchers) >= MAXIMUM_WAIT_OBJECTS-1 {
+		return nil, fmt.Errorf("Watch: more than %d handles", MAXIMUM_WAIT_OBJECTS-1)
+	}
+	x := &Watcher{w: w, fd: fd, events: events, f: f}
+	w.watchers = append(w.watchers, x)
+	return x, nil
+}
+
+func (w *window) unwatch(x *Watcher) {
+	for i, y := range w.watchers {
+		if y == x {
+			w.watchers = append(w.watchers[:i], w.watchers[i+1:]...)
+			return
+		}
+	}
+}
+
+// wait calls the watchers of signaled handles until a message is in the
+// queue, so that GetMessage does not block them.
+func (w *window) wait() error {
+	for len(w.watchers) > 0 {
+		watchers := append([]*Watcher(nil), w.watchers...)
+		handles := make([]windows.Handle, len(watchers))
+		for i, x := range watchers {
+			handles[i] = windows.Handle(x.fd)
+		}
+		n := uint32(len(handles))
+
+		r, err := MsgWaitForMultipleObjectsEx(n, &handles[0], INFINITE, QS_ALLINPUT, MWMO_INPUTAVAILABLE)
+		if err != nil {
+			return fmt.Errorf("MsgWaitForMultipleObjectsEx: %v", err)
+		}
+		switch {
+		case r < WAIT_OBJECT_0+n:
+			w.fireWatch(watchers[r-WAIT_OBJECT_0], Readable)
+		case r >= WAIT_ABANDONED_0 && r < WAIT_ABANDONED_0+n:
+			// a mutex of an ended thread
+			w.fireWatch(watchers[r-WAIT_ABANDONED_0], Readable|Hangup)
+		}
+
+		// looking at the queue after each handle keeps a handle which
+		// stays signaled from starving the messages. PeekMessage also
+		// handles sent messages.
+		var msg Msg
+		if PeekMessage(&msg, 0, 0, 0, PM_NOREMOVE) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// closeWatches stops the watchers when the window is destroyed.
+func (w *window) closeWatches() {
+	for _, x := range w.watchers {
+		x.done = true
+	}
+	w.watchers = nil
+	w.closePosts()
+}
+
+// wakeLoop makes the loop run the functions of post.
+func (w *window) wakeLoop() {
+	PostMessage(w.handle, postMessage, 0, 0)
+}
@@ -0,0 +1,792 @@
+package gui
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ysh86/gui/internal/wayland"
+)
+
+// requests and events of the protocol objects used by waylandDriver
+const (
+	wlRegistryBind = 0
+
+	wlRegistryGlobal = 0
+
+	wlCompositorCreateSurface = 0
+
+	wlSurfaceAttach       = 1
+	wlSurfaceDamage       = 2
+	wlSurfaceCommit       = 6
+	wlSurfaceDamageBuffer = 9
+
+	wlShmCreatePool = 0
+
+	wlShmFormat = 0
+
+	wlShmPoolCreateBuffer = 0
+	wlShmPoolDestroy      = 1
+
+	wlBufferDestroy = 0
+
+	wlBufferRelease = 0
+
+	wlSeatGetPointer  = 0
+	wlSeatGetKeyboard = 1
+
+	wlSeatCapabilities = 0
+
+	wlPointerEnter  = 0
+	wlPointerLeave  = 1
+	wlPointerMotion = 2
+	wlPointerButton = 3
+	wlPointerAxis   = 4
+
+	wlKeyboardKeymap     = 0
+	wlKeyboardLeave      = 2
+	wlKeyboardKey        = 3
+	wlKeyboardModifiers  = 4
+	wlKeyboardRepeatInfo = 5
+
+	xdgWmBaseGetXdgSurface = 2
+	xdgWmBasePong          = 3
+
+	xdgWmBasePing = 0
+
+	xdgSurfaceGetToplevel  = 1
+	xdgSurfaceAckConfigure = 4
+
+	xdgSurfaceConfigure = 0
+
+	xdgToplevelSetTitle = 2
+	xdgToplevelSetAppID = 3
+
+	xdgToplevelConfigure = 0
+	xdgToplevelClose     = 1
+
+	wpFractionalScaleManagerGetFractionalScale = 1
+
+	wpFractionalScalePreferredScale = 0
+
+	wpViewporterGetViewport = 1
+
+	wpViewportSetDestination = 2
+)
+
+// waylandEvents are the event names of the interfaces in opcode order.
+var waylandEvents = map[string][]string{
+	"wl_registry":            {"global", "global_remove"},
+	"wl_surface":             {"enter", "leave", "preferred_buffer_scale", "preferred_buffer_transform"},
+	"wl_shm":                 {"format"},
+	"wl_buffer":              {"release"},
+	"wl_seat":                {"capabilities", "name"},
+	"wl_pointer":             {"enter", "leave", "motion", "button", "axis", "frame", "axis_source", "axis_stop", "axis_discrete", "axis_value120", "axis_relative_direction"},
+	"wl_keyboard":            {"keymap", "enter", "leave", "key", "modifiers", "repeat_info"},
+	"xdg_wm_base":            {"ping"},
+	"xdg_surface":            {"configure"},
+	"xdg_toplevel":           {"configure", "close", "configure_bounds", "wm_capabilities"},
+	"wp_fractional_scale_v1": {"preferred_scale"},
+}
+
+// waylandCategories are the trace categories of the interfaces, other by default.
+var waylandCategories = map[string]TraceCategory{
+	"wl_surface":             TraceLifecycle,
+	"wl_buffer":              TracePaint,
+	"wl_pointer":             TraceInput,
+	"wl_keyboard":            TraceInput,
+	"xdg_surface":            TraceLifecycle,
+	"xdg_toplevel":           TraceLifecycle,
+	"wp_fractional_scale_v1": TraceLifecycle,
+}
+
+// wl_shm formats
+const (
+	shmFormatXRGB8888 = 1
+	shmFormatXBGR8888 = 0x34324258 // byte order of image.RGBA
+)
+
+// wl_seat capabilities
+const (
+	seatPointer  = 1
+	seatKeyboard = 2
+)
+
+// modifier masks of the default xkb keymap
+const (
+	xkbShift = 1 << 0
+	xkbCtrl  = 1 << 2
+	xkbMod1  = 1 << 3 // Alt
+	xkbMod4  = 1 << 6 // Super
+)
+
+// fractional scale denominator
+const scaleBase = 120
+
+// waylandCapabilities are the features of the Wayland backend.
+const waylandCapabilities = CapMultiWindow | CapDPI | CapKeyboard | CapPointer | drawnMenuCapabilities | glCapabilities
+
+func init() {
+	registerBackend(&backend{
+		name:     "wayland",
+		priority: 10,
+		caps:     waylandCapabilities,
+		probe:    probeWayland,
+		newDriver: func() (driver, error) {
+			return &waylandDriver{}, nil
+		},
+	})
+}
+
+// probeWayland checks that the compositor accepts connections.
+func probeWayland() error {
+	if os.Getenv("WAYLAND_SOCKET") != "" {
+		// the socket can be used only once
+		return nil
+	}
+	conn, err := wayland.Dial()
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// waylandDriver shows a window as an xdg_toplevel with wl_shm buffers.
+type waylandDriver struct {
+	conn    *wayland.Conn
+	res     *resources
+	wakeup  wakePipe
+	timer   timerFd // of the timeout of poll
+	watches *fdWatches
+
+	// globals
+	registry          uint32
+	compositor        uint32
+	compositorVersion uint32
+	shm               uint32
+	xbgr              bool
+	wmBase            uint32
+	seat              uint32
+	fractionalManager uint32
+	viewporter        uint32
+
+	// window
+	surface         uint32
+	xdgSurface      uint32
+	toplevel        uint32
+	fractionalScale uint32
+	viewport        uint32
+
+	width, height int32  // surface size
+	pendingWidth  int32  // from xdg_toplevel.configure
+	pendingHeight int32  // from xdg_toplevel.configure
+	scale         uint32 // preferred scale * scaleBase
+	configured    bool
+
+	image   *image.RGBA
+	buffers []*waylandBuffer
+	waiting bool // present was skipped since all buffers were busy
+
+	// input
+	pointer     uint32
+	pointerX    int32 // last position in pixels
+	pointerY    int32
+	keyboard    uint32
+	mods        Modifier
+	repeatRate  int32 // keys per second, 0 to disable
+	repeatDelay int32 // milliseconds
+	repeatCode  uint32
+	repeatAt    time.Time
+
+	events []interface{}
+	trace  func(category TraceCategory, name string, detail string)
+	native func(m *NativeMessage) bool
+}
+
+// waylandBuffer is a wl_buffer in its own shared memory.
+type waylandBuffer struct {
+	id            uint32
+	data          []byte
+	width, height int32
+	busy          bool   // attached until the compositor releases it
+	damage        damage // changed since the buffer was written
+}
+
+func (d *waylandDriver) open(name string, width int32, height int32) error {
+	if err := d.wakeup.open(); err != nil {
+		return err
+	}
+	if err := d.timer.open(); err != nil {
+		return err
+	}
+	conn, err := wayland.Dial()
+	if err != nil {
+		return err
+	}
+	d.conn = conn
+	d.res.acquire(resDisplay)
+	d.width, d.height = width, height
+	d.scale = scaleBase
+	d.repeatRate, d.repeatDelay = 25, 600
+
+	d.registry, err = conn.GetRegistry(d.handler("wl_registry", d.handleRegistry))
+	if err != nil {
+		return err
+	}
+	// globals, then the events of the bound globals
+	if err := conn.Roundtrip(); err != nil {
+		return err
+	}
+	if d.compositor == 0 || d.shm == 0 || d.wmBase == 0 {
+		return errors.New("wayland: wl_compositor, wl_shm or xdg_wm_base is not available")
+	}
+	if err := conn.Roundtrip(); err != nil {
+		return err
+	}
+
+	d.surface = conn.NewID(d.handler("wl_surface", nil))
+	conn.Request(d.compositor, wlCompositorCreateSurface, d.surface)
+	d.xdgSurface = conn.NewID(d.handler("xdg_surface", d.handleXdgSurface))
+	conn.Request(d.wmBase, xdgWmBaseGetXdgSurface, d.xdgSurface, d.surface)
+	d.toplevel = conn.NewID(d.handler("xdg_toplevel", d.handleToplevel))
+	conn.Request(d.xdgSurface, xdgSurfaceGetToplevel, d.toplevel)
+	conn.Request(d.toplevel, xdgToplevelSetTitle, name)
+	conn.Request(d.toplevel, xdgToplevelSetAppID, filepath.Base(os.Args[0]))
+
+	// fractional scaling draws a larger buffer scaled down by the viewport
+	if d.fractionalManager != 0 && d.viewporter != 0 {
+		d.viewport = conn.NewID(nil)
+		conn.Request(d.viewporter, wpViewporterGetViewport, d.viewport, d.surface)
+		d.fractionalScale = conn.NewID(d.handler("wp_fractional_scale_v1", d.handleFractionalScale))
+		conn.Request(d.fractionalManager, wpFractionalScaleManagerGetFractionalScale, d.fractionalScale, d.surface)
+	}
+
+	// the initial commit without a buffer asks for the first configure
+	if err := conn.Request(d.surface, wlSurfaceCommit); err != nil {
+		return err
+	}
+	return conn.Roundtrip()
+}
+
+func (d *waylandDriver) close() {
+	for _, b := range d.buffers {
+		unix.Munmap(b.data)
+	}
+	d.buffers = nil
+	if d.conn != nil {
+		d.conn.Close()
+		d.conn = nil
+		d.res.release(resDisplay)
+	}
+	d.wakeup.close()
+	d.timer.close()
+}
+
+func (d *waylandDriver) setResources(res *resources) {
+	d.res = res
+}
+
+func (d *waylandDriver) handle() uintptr {
+	return uintptr(d.surface)
+}
+
+func (d *waylandDriver) poll(timeout time.Duration) ([]interface{}, error) {
+	if err := d.timer.set(timeout); err != nil {
+		return nil, err
+	}
+
+	for len(d.events) == 0 {
+		now := time.Now()
+		if d.repeatCode != 0 && !now.Before(d.repeatAt) {
+			d.repeat(now)
+			continue
+		}
+
+		ms := -1
+		if d.repeatCode != 0 {
+			ms = millisecondsUntil(now, d.repeatAt)
+		}
+
+		fds := []unix.PollFd{{Fd: int32(d.conn.Fd()), Events: unix.POLLIN}, d.wakeup.pollFd(), d.timer.pollFd()}
+		fds = d.watches.pollFds(fds)
+		_, err := unix.Poll(fds, ms)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Poll: %v", err)
+		}
+		if fds[0].Revents != 0 {
+			if err := d.conn.Dispatch(false); err != nil {
+				return nil, err
+			}
+		}
+		d.events = append(d.events, d.watches.read(fds[3:])...)
+		if fds[1].Revents != 0 {
+			d.wakeup.drain()
+			break
+		}
+		if d.timer.expired(fds[2]) {
+			break
+		}
+	}
+
+	events := d.events
+	d.events = nil
+	return events, nil
+}
+
+// millisecondsUntil rounds the time from now to t up to milliseconds.
+func millisecondsUntil(now, t time.Time) int {
+	d := t.Sub(now)
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+func (d *waylandDriver) wake() {
+	d.wakeup.wake()
+}
+
+func (d *waylandDriver) setWatches(ws *fdWatches) {
+	d.watches = ws
+}
+
+func (d *waylandDriver) framebuffer() *image.RGBA {
+	return d.image
+}
+
+func (d *waylandDriver) present(region []image.Rectangle) error {
+	if !d.configured || d.image == nil {
+		return nil
+	}
+
+	bounds := d.image.Rect
+	for _, b := range d.buffers {
+		b.damage.addAll(region, bounds)
+	}
+	width, height := int32(bounds.Dx()), int32(bounds.Dy())
+	b, err := d.freeBuffer(width, height)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		// redrawn when a buffer is released
+		d.waiting = true
+		return nil
+	}
+
+	rects := b.damage.take()
+	for _, r := range rects {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			i, end := d.image.PixOffset(r.Min.X, y), d.image.PixOffset(r.Max.X, y)
+			src, dst := d.image.Pix[i:end], b.data[i:end]
+			if d.xbgr {
+				copy(dst, src)
+				continue
+			}
+			for j := 0; j < len(src); j += 4 {
+				dst[j+0] = src[j+2]
+				dst[j+1] = src[j+1]
+				dst[j+2] = src[j+0]
+				dst[j+3] = 0xff
+			}
+		}
+	}
+
+	d.conn.Request(d.surface, wlSurfaceAttach, b.id, int32(0), int32(0))
+	if d.compositorVersion >= 4 {
+		for _, r := range rects {
+			d.conn.Request(d.surface, wlSurfaceDamageBuffer, int32(r.Min.X), int32(r.Min.Y), int32(r.Dx()), int32(r.Dy()))
+		}
+	} else {
+		d.conn.Request(d.surface, wlSurfaceDamage, int32(0), int32(0), d.width, d.height)
+	}
+	b.busy = true
+	return d.conn.Request(d.surface, wlSurfaceCommit)
+}
+
+// freeBuffer returns a buffer of the size which is not used by the compositor,
+// or nil if all buffers are busy.
+func (d *waylandDriver) freeBuffer(width, height int32) (*waylandBuffer, error) {
+	buffers := d.buffers[:0]
+	for _, b := range d.buffers {
+		if b.busy || (b.width == width && b.height == height) {
+			buffers = append(buffers, b)
+		} else {
+			d.destroyBuffer(b)
+		}
+	}
+	d.buffers = buffers
+
+	for _, b := range d.buffers {
+		if !b.busy {
+			return b, nil
+		}
+	}
+	// double buffering
+	if len(d.buffers) >= 2 {
+		return nil, nil
+	}
+
+	b, err := d.createBuffer(width, height)
+	if err != nil {
+		return nil, err
+	}
+	d.buffers = append(d.buffers, b)
+	return b, nil
+}
+
+func (d *waylandDriver) createBuffer(width, height int32) (*waylandBuffer, error) {
+	stride := width * 4
+	size := int(stride) * int(height)
+
+	fd, err := unix.MemfdCreate("gui-wayland", unix.MFD_CLOEXEC)
+	if err != nil {
+		return nil, fmt.Errorf("MemfdCreate: %v", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Ftruncate(fd, int64(size)); err != nil {
+		return nil, fmt.Errorf("Ftruncate: %v", err)
+	}
+	data, err := unix.Mmap(fd, 0, size, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("Mmap: %v", err)
+	}
+
+	format := uint32(shmFormatXRGB8888)
+	if d.xbgr {
+		format = shmFormatXBGR8888
+	}
+	b := &waylandBuffer{
+		data:   data,
+		width:  width,
+		height: height,
+	}
+	// a new buffer has no contents
+	full := image.Rect(0, 0, int(width), int(height))
+	b.damage.add(full, full)
+	pool := d.conn.NewID(nil)
+	d.conn.Request(d.shm, wlShmCreatePool, pool, wayland.Fd(fd), int32(size))
+	b.id = d.conn.NewID(d.handler("wl_buffer", func(opcode uint16, e *wayland.Event) {
+		if opcode == wlBufferRelease {
+			d.release(b)
+		}
+	}))
+	d.conn.Request(pool, wlShmPoolCreateBuffer, b.id, int32(0), width, height, stride, format)
+	// the buffer keeps the memory of the pool
+	if err := d.conn.Request(pool, wlShmPoolDestroy); err != nil {
+		unix.Munmap(data)
+		return nil, err
+	}
+	return b, nil
+}
+
+func (d *waylandDriver) destroyBuffer(b *waylandBuffer) {
+	d.conn.Request(b.id, wlBufferDestroy)
+	unix.Munmap(b.data)
+}
+
+func (d *waylandDriver) release(b *waylandBuffer) {
+	b.busy = false
+	if d.waiting {
+		d.waiting = false
+		d.events = append(d.events, exposeEvent{})
+	}
+}
+
+// pixelSize returns the buffer size for the surface size and scale.
+func (d *waylandDriver) pixelSize() (int32, int32) {
+	width := int32(math.Round(float64(d.width) * float64(d.scale) / scaleBase))
+	height := int32(math.Round(float64(d.height) * float64(d.scale) / scaleBase))
+	return width, height
+}
+
+// resize recreates the framebuffer for the current size and scale.
+func (d *waylandDriver) resize() {
+	width, height := d.pixelSize()
+	if d.image == nil || int32(d.image.Rect.Dx()) != width || int32(d.image.Rect.Dy()) != height {
+		d.image = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+		d.events = append(d.events, sizeEvent{width: width, height: height})
+	}
+	if d.viewport != 0 {
+		d.conn.Request(d.viewport, wpViewportSetDestination, d.width, d.height)
+	}
+	d.events = append(d.events, exposeEvent{})
+}
+
+func (d *waylandDriver) bind(name uint32, iface string, version uint32, h wayland.Handler) uint32 {
+	id := d.conn.NewID(d.handler(iface, h))
+	d.conn.Request(d.registry, wlRegistryBind, name, iface, version, id)
+	return id
+}
+
+func (d *waylandDriver) setTrace(trace func(category TraceCategory, name string, detail string)) {
+	d.trace = trace
+}
+
+func (d *waylandDriver) setNative(native func(m *NativeMessage) bool) {
+	d.native = native
+}
+
+// handler returns a handler of the events of iface, which traces them and
+// passes them to the native handler before h, if any.
+func (d *waylandDriver) handler(iface string, h wayland.Handler) wayland.Handler {
+	return func(opcode uint16, e *wayland.Event) {
+		if d.trace == nil && d.native == nil {
+			if h != nil {
+				h(opcode, e)
+			}
+			return
+		}
+
+		name := fmt.Sprintf("%s.%d", iface, opcode)
+		if names := waylandEvents[iface]; int(opcode) < len(names) {
+			name = iface + "." + names[opcode]
+		}
+		if d.trace != nil {
+			category, ok := waylandCategories[iface]
+			if !ok {
+				category = TraceOther
+			}
+			d.trace(category, name, "")
+		}
+		if d.native != nil {
+			m := &NativeMessage{Object: e.Object(), Opcode: opcode, Name: name, Args: e.Data()}
+			// the fd must be taken by h
+			if d.native(m) && name != "wl_keyboard.keymap" {
+				return
+			}
+		}
+		if h != nil {
+			h(opcode, e)
+		}
+	}
+}
+
+func (d *waylandDriver) handleRegistry(opcode uint16, e *wayland.Event) {
+	if opcode != wlRegistryGlobal {
+		return
+	}
+	name, iface, version := e.Uint(), e.String(), e.Uint()
+
+	switch iface {
+	case "wl_compositor":
+		// wl_surface.damage_buffer since version 4
+		d.compositorVersion = minUint32(version, 4)
+		d.compositor = d.bind(name, iface, d.compositorVersion, nil)
+	case "wl_shm":
+		d.shm = d.bind(name, iface, 1, d.handleShm)
+	case "xdg_wm_base":
+		d.wmBase = d.bind(name, iface, 1, d.handleWmBase)
+	case "wl_seat":
+		if d.seat == 0 {
+			// wl_keyboard.repeat_info since version 4
+			d.seat = d.bind(name, iface, minUint32(version, 4), d.handleSeat)
+		}
+	case "wp_fractional_scale_manager_v1":
+		d.fractionalManager = d.bind(name, iface, 1, nil)
+	case "wp_viewporter":
+		d.viewporter = d.bind(name, iface, 1, nil)
+	}
+}
+
+func minUint32(a, b uint32) uint32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (d *waylandDriver) handleShm(opcode uint16, e *wayland.Event) {
+	if opcode == wlShmFormat && e.Uint() == shmFormatXBGR8888 {
+		d.xbgr = true
+	}
+}
+
+func (d *waylandDriver) handleWmBase(opcode uint16, e *wayland.Event) {
+	if opcode == xdgWmBasePing {
+		d.conn.Request(d.wmBase, xdgWmBasePong, e.Uint())
+	}
+}
+
+func (d *waylandDriver) handleXdgSurface(opcode uint16, e *wayland.Event) {
+	if opcode != xdgSurfaceConfigure {
+		return
+	}
+	d.conn.Request(d.xdgSurface, xdgSurfaceAckConfigure, e.Uint())
+
+	// zero means the size is up to us
+	if d.pendingWidth > 0 && d.pendingHeight > 0 {
+		d.width, d.height = d.pendingWidth, d.pendingHeight
+	}
+	d.configured = true
+	d.resize()
+}
+
+func (d *waylandDriver) handleToplevel(opcode uint16, e *wayland.Event) {
+	switch opcode {
+	case xdgToplevelConfigure:
+		d.pendingWidth, d.pendingHeight = e.Int(), e.Int()
+	case xdgToplevelClose:
+		d.events = append(d.events, closeEvent{})
+	}
+}
+
+func (d *waylandDriver) handleFractionalScale(opcode uint16, e *wayland.Event) {
+	if opcode != wpFractionalScalePreferredScale {
+		return
+	}
+	if scale := e.Uint(); scale != 0 && scale != d.scale {
+		d.scale = scale
+		if d.configured {
+			d.resize()
+		}
+	}
+}
+
+func (d *waylandDriver) handleSeat(opcode uint16, e *wayland.Event) {
+	if opcode != wlSeatCapabilities {
+		return
+	}
+	caps := e.Uint()
+
+	if caps&seatPointer != 0 && d.pointer == 0 {
+		d.pointer = d.conn.NewID(d.handler("wl_pointer", d.handlePointer))
+		d.conn.Request(d.seat, wlSeatGetPointer, d.pointer)
+	}
+	if caps&seatKeyboard != 0 && d.keyboard == 0 {
+		d.keyboard = d.conn.NewID(d.handler("wl_keyboard", d.handleKeyboard))
+		d.conn.Request(d.seat, wlSeatGetKeyboard, d.keyboard)
+	}
+}
+
+// toPixels converts surface coordinates to buffer pixels.
+func (d *waylandDriver) toPixels(x, y wayland.Fixed) (int32, int32) {
+	s := float64(d.scale) / scaleBase
+	return int32(math.Floor(x.Float() * s)), int32(math.Floor(y.Float() * s))
+}
+
+func (d *waylandDriver) handlePointer(opcode uint16, e *wayland.Event) {
+	m := &MouseEvent{Mods: d.mods}
+	m.Time = time.Now()
+
+	switch opcode {
+	case wlPointerEnter:
+		e.Uint() // serial
+		e.Uint() // surface
+		m.Action = MouseEnter
+		d.pointerX, d.pointerY = d.toPixels(e.Fixed(), e.Fixed())
+	case wlPointerLeave:
+		m.Action = MouseLeave
+	case wlPointerMotion:
+		m.Time = monotonicTime(e.Uint())
+		m.Action = MouseMove
+		d.pointerX, d.pointerY = d.toPixels(e.Fixed(), e.Fixed())
+	case wlPointerButton:
+		e.Uint() // serial
+		m.Time = monotonicTime(e.Uint())
+		m.Button = buttonFromEvdev(e.Uint())
+		m.Action = MouseRelease
+		if e.Uint() == 1 {
+			m.Action = MousePress
+		}
+	case wlPointerAxis:
+		m.Time = monotonicTime(e.Uint())
+		axis := e.Uint()
+		// a wheel notch is 10 units in most compositors
+		v := float32(e.Fixed().Float() / 10)
+		m.Action = MouseScroll
+		if axis == 0 {
+			m.ScrollY = v
+		} else {
+			m.ScrollX = v
+		}
+	default:
+		return
+	}
+	// the position is sent only on enter and motion
+	m.X, m.Y = d.pointerX, d.pointerY
+	d.events = append(d.events, m)
+}
+
+func (d *waylandDriver) handleKeyboard(opcode uint16, e *wayland.Event) {
+	switch opcode {
+	case wlKeyboardKeymap:
+		// keys are mapped from their evdev codes without the keymap
+		e.Uint() // format
+		if fd := e.Fd(); fd >= 0 {
+			unix.Close(fd)
+		}
+	case wlKeyboardLeave:
+		d.repeatCode = 0
+		d.mods = 0
+	case wlKeyboardKey:
+		e.Uint() // serial
+		t := monotonicTime(e.Uint())
+		code := e.Uint()
+		down := e.Uint() == 1
+		key := keyFromEvdev(code)
+		d.events = append(d.events, &KeyEvent{EventHeader: EventHeader{Time: t}, Key: key, Mods: d.mods, Down: down})
+
+		switch {
+		case down && d.repeatRate > 0 && !isModifierKey(key):
+			d.repeatCode = code
+			d.repeatAt = time.Now().Add(time.Duration(d.repeatDelay) * time.Millisecond)
+		case !down && code == d.repeatCode:
+			d.repeatCode = 0
+		}
+	case wlKeyboardModifiers:
+		e.Uint()                    // serial
+		mask := e.Uint() | e.Uint() // depressed, latched
+		d.mods = 0
+		if mask&xkbShift != 0 {
+			d.mods |= ModShift
+		}
+		if mask&xkbCtrl != 0 {
+			d.mods |= ModCtrl
+		}
+		if mask&xkbMod1 != 0 {
+			d.mods |= ModAlt
+		}
+		if mask&xkbMod4 != 0 {
+			d.mods |= ModSuper
+		}
+	case wlKeyboardRepeatInfo:
+		d.repeatRate, d.repeatDelay = e.Int(), e.Int()
+		if d.repeatRate <= 0 {
+			d.repeatCode = 0
+		}
+	}
+}
+
+// repeat sends the repeated key, which the compositor leaves to clients.
+func (d *waylandDriver) repeat(now time.Time) {
+	d.events = append(d.events, &KeyEvent{
+		EventHeader: EventHeader{Time: now},
+		Key:         keyFromEvdev(d.repeatCode),
+		Mods:        d.mods,
+		Down:        true,
+		Repeat:      true,
+	})
+	interval := time.Second / time.Duration(d.repeatRate)
+	d.repeatAt = d.repeatAt.Add(interval)
+	if d.repeatAt.Before(now) {
+		d.repeatAt = now.Add(interval)
+	}
+}
+
+func isModifierKey(key Key) bool {
+	switch key {
+	case KeyShift, KeyControl, KeyAlt, KeySuper:
+		return true
+	}
+	return false
+}
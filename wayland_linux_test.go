@@ -0,0 +1,93 @@
+package gui
+
+import (
+	"image/color"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startWeston starts a headless weston for the test and points
+// WAYLAND_DISPLAY at it. It skips the test without weston.
+func startWeston(t *testing.T) {
+	path, err := exec.LookPath("weston")
+	if err != nil {
+		t.Skip("weston is not installed")
+	}
+	dir, err := ioutil.TempDir("", "gui-weston")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	cmd := exec.Command(path, "--backend=headless-backend.so", "--socket=gui-test", "--idle-time=0")
+	cmd.Env = append(os.Environ(), "XDG_RUNTIME_DIR="+dir)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("weston: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		<-exited
+	})
+
+	socket := filepath.Join(dir, "gui-test")
+	for {
+		if _, err := os.Stat(socket); err == nil {
+			break
+		}
+		select {
+		case <-exited:
+			t.Skip("weston exited, the headless backend may be missing")
+		case <-time.After(10 * time.Millisecond):
+		case <-testTimeout():
+			t.Fatal("weston did not create its socket")
+		}
+	}
+	setenv(t, "XDG_RUNTIME_DIR", dir)
+	setenv(t, "WAYLAND_DISPLAY", "gui-test")
+	os.Unsetenv("WAYLAND_SOCKET")
+}
+
+func TestWaylandWeston(t *testing.T) {
+	startWeston(t)
+
+	app := NewApplication(WithBackend("wayland"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if app.Backend() != "wayland" {
+		t.Fatalf("backend %q", app.Backend())
+	}
+
+	r := newTestRenderer(color.RGBA{0x20, 0x40, 0x60, 0xff})
+	errc := app.Loop("weston", 64, 48, r)
+	frame := r.nextFrame(t)
+	if b := frame.Bounds(); b.Dx() < 64 || b.Dy() < 48 {
+		t.Errorf("frame %v, want at least 64x48", b)
+	}
+	if c := frame.RGBAAt(10, 10); c != r.color {
+		t.Errorf("pixel %v, want %v", c, r.color)
+	}
+
+	app.Quit(0)
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Loop: %v", err)
+		}
+	case <-testTimeout():
+		t.Fatal("Loop did not quit")
+	}
+	app.Deinit()
+	if err := CheckLeaks(app); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,627 @@
+// +build !windows
+
+package gui
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ysh86/gui/internal/websocket"
+)
+
+// webAddrEnv is the HTTP address of the first window, default 127.0.0.1:8080.
+// Later windows listen on the following ports.
+const webAddrEnv = "GUI_WEB_ADDR"
+
+// webOriginsEnv lists the origins, separated by commas, of other sites whose
+// pages may connect, e.g. "https://example.com". Pages served by the window
+// itself are always allowed.
+const webOriginsEnv = "GUI_WEB_ORIGINS"
+
+// maxWebSize limits the size requested by browsers.
+const maxWebSize = 8192
+
+// webCapabilities are the features of the web backend.
+const webCapabilities = CapMultiWindow | CapKeyboard | CapPointer | drawnMenuCapabilities | glCapabilities
+
+func init() {
+	registerBackend(&backend{
+		name:     "web",
+		priority: 200,
+		explicit: true,
+		caps:     webCapabilities,
+		probe: func() error {
+			return nil
+		},
+		newDriver: func() (driver, error) {
+			return &webDriver{}, nil
+		},
+	})
+}
+
+// webDriver serves a window to browsers. The page at / connects to /ws,
+// receives the frame as PNG or JPEG patches of the changed rectangles,
+// and sends input and its size back as JSON.
+//
+// Add ?format=jpeg&quality=75 to the URL for JPEG patches.
+type webDriver struct {
+	name     string
+	origins  []string
+	listener net.Listener
+	server   *http.Server
+	image    *image.RGBA
+	events   chan interface{}
+	pending  []interface{}
+	done     chan struct{}
+	wakec    chan struct{}
+	res      *resources
+	trace    func(category TraceCategory, name string, detail string)
+
+	mu      sync.Mutex
+	frame   *image.RGBA // last presented frame, never modified
+	clients map[*webClient]bool
+	wg      sync.WaitGroup
+}
+
+// webResize is posted when a browser reports its size.
+type webResize struct {
+	width, height int32
+}
+
+func (d *webDriver) open(name string, width int32, height int32) error {
+	addr := os.Getenv(webAddrEnv)
+	if addr == "" {
+		addr = "127.0.0.1:8080"
+	}
+	ln, err := listenFrom(addr)
+	if err != nil {
+		return fmt.Errorf("web: %v", err)
+	}
+
+	d.name = name
+	d.res.acquire(resListener)
+	d.origins = nil
+	for _, o := range strings.Split(os.Getenv(webOriginsEnv), ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			d.origins = append(d.origins, o)
+		}
+	}
+	d.listener = ln
+	d.image = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	d.frame = image.NewRGBA(d.image.Rect)
+	d.events = make(chan interface{}, 256)
+	d.done = make(chan struct{})
+	d.wakec = make(chan struct{}, 1)
+	d.clients = make(map[*webClient]bool)
+	d.pending = append(d.pending, sizeEvent{width: width, height: height}, exposeEvent{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.servePage)
+	mux.HandleFunc("/ws", d.serveWebSocket)
+	d.server = &http.Server{Handler: mux}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.server.Serve(ln)
+	}()
+	return nil
+}
+
+func (d *webDriver) close() {
+	if d.server == nil {
+		return
+	}
+	d.mu.Lock()
+	close(d.done)
+	for c := range d.clients {
+		c.conn.Close()
+	}
+	d.mu.Unlock()
+	d.server.Close() // hijacked connections are not closed
+	d.wg.Wait()
+	d.server = nil
+	d.res.release(resListener)
+}
+
+func (d *webDriver) setResources(res *resources) {
+	d.res = res
+}
+
+func (d *webDriver) handle() uintptr {
+	return 0
+}
+
+func (d *webDriver) post(e interface{}) {
+	stampEvent(e, time.Now())
+	select {
+	case d.events <- e:
+	case <-d.done:
+	}
+}
+
+func (d *webDriver) setTrace(trace func(category TraceCategory, name string, detail string)) {
+	d.trace = trace
+}
+
+// traceMessage sends the native name of a message of a client to poll.
+func (d *webDriver) traceMessage(category TraceCategory, name string, format string, args ...interface{}) {
+	if d.trace == nil {
+		return
+	}
+	select {
+	case d.events <- clientMessage{category, name, fmt.Sprintf(format, args...)}:
+	case <-d.done:
+	}
+}
+
+func (d *webDriver) poll(timeout time.Duration) ([]interface{}, error) {
+	var events []interface{}
+	if len(d.pending) == 0 {
+		var timer <-chan time.Time
+		if timeout >= 0 {
+			t := time.NewTimer(timeout)
+			defer t.Stop()
+			timer = t.C
+		}
+		select {
+		case e := <-d.events:
+			events = append(events, e)
+		case <-d.wakec:
+		case <-timer:
+		}
+	}
+	for {
+		select {
+		case e := <-d.events:
+			events = append(events, e)
+			continue
+		default:
+		}
+		break
+	}
+
+	for _, e := range events {
+		if m, ok := e.(clientMessage); ok {
+			d.trace(m.category, m.name, m.detail)
+			continue
+		}
+		r, ok := e.(webResize)
+		if !ok {
+			d.pending = append(d.pending, e)
+			continue
+		}
+		if int(r.width) == d.image.Rect.Dx() && int(r.height) == d.image.Rect.Dy() {
+			continue
+		}
+		d.image = image.NewRGBA(image.Rect(0, 0, int(r.width), int(r.height)))
+		d.pending = append(d.pending, sizeEvent{width: r.width, height: r.height}, exposeEvent{})
+	}
+
+	events = d.pending
+	d.pending = nil
+	return events, nil
+}
+
+func (d *webDriver) wake() {
+	select {
+	case d.wakec <- struct{}{}:
+	default:
+	}
+}
+
+func (d *webDriver) framebuffer() *image.RGBA {
+	return d.image
+}
+
+func (d *webDriver) present(region []image.Rectangle) error {
+	frame := image.NewRGBA(d.image.Rect)
+	copy(frame.Pix, d.image.Pix)
+
+	d.mu.Lock()
+	d.frame = frame
+	for c := range d.clients {
+		c.damage.addAll(region, frame.Rect)
+		c.wake()
+	}
+	d.mu.Unlock()
+	return nil
+}
+
+// takeFrame returns the current frame and the damage of c since the last call.
+func (d *webDriver) takeFrame(c *webClient) (*image.RGBA, []image.Rectangle) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.frame, c.damage.take()
+}
+
+func (d *webDriver) servePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	webPage.Execute(w, d.name)
+}
+
+func (d *webDriver) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r, d.origins)
+	if err != nil {
+		return
+	}
+
+	c := &webClient{
+		d:      d,
+		conn:   conn,
+		signal: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+	if r.URL.Query().Get("format") == "jpeg" {
+		c.jpeg = true
+		c.quality = jpeg.DefaultQuality
+		if q, err := strconv.Atoi(r.URL.Query().Get("quality")); err == nil && q > 0 && q <= 100 {
+			c.quality = q
+		}
+	}
+
+	d.mu.Lock()
+	select {
+	case <-d.done:
+		d.mu.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	d.clients[c] = true
+	d.wg.Add(1)
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.clients, c)
+		d.mu.Unlock()
+		d.wg.Done()
+	}()
+
+	c.serve()
+}
+
+// webClient is a connected browser.
+type webClient struct {
+	d       *webDriver
+	conn    *websocket.Conn
+	jpeg    bool
+	quality int
+	signal  chan struct{}
+	closed  chan struct{}
+
+	shown  *image.RGBA // frame the browser has
+	damage damage      // changed since shown, guarded by d.mu
+}
+
+func (c *webClient) wake() {
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (c *webClient) serve() {
+	defer c.conn.Close()
+
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		c.writeLoop()
+	}()
+	c.wake()
+	c.readLoop()
+	c.conn.Close()
+	close(c.closed)
+	<-writerDone
+}
+
+// webMessage is a message from the browser.
+type webMessage struct {
+	Type string // "key", "mouse" or "resize"
+
+	// key
+	Code   string // KeyboardEvent.code
+	Down   bool
+	Repeat bool
+	Mods   Modifier
+
+	// mouse
+	Action string // "move", "down", "up", "wheel", "enter" or "leave"
+	Button int    // MouseEvent.button
+	X, Y   int32
+	DX, DY float32
+
+	// resize
+	Width, Height int32
+}
+
+func (c *webClient) readLoop() {
+	for {
+		t, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if t != websocket.TextMessage {
+			continue
+		}
+		var m webMessage
+		if err := json.Unmarshal(data, &m); err != nil {
+			continue
+		}
+
+		if c.d.trace != nil {
+			c.traceMessage(&m)
+		}
+		switch m.Type {
+		case "key":
+			c.d.post(&KeyEvent{Key: keyFromDOMCode(m.Code), Mods: m.Mods, Down: m.Down, Repeat: m.Repeat})
+		case "mouse":
+			if e := c.mouse(&m); e != nil {
+				c.d.post(e)
+			}
+		case "resize":
+			if m.Width > 0 && m.Width <= maxWebSize && m.Height > 0 && m.Height <= maxWebSize {
+				c.d.post(webResize{width: m.Width, height: m.Height})
+			}
+		}
+	}
+}
+
+// traceMessage traces m with the name of its DOM event.
+func (c *webClient) traceMessage(m *webMessage) {
+	switch m.Type {
+	case "key":
+		name := "keyup"
+		if m.Down {
+			name = "keydown"
+		}
+		c.d.traceMessage(TraceInput, name, "code=%s mods=0x%x repeat=%v", m.Code, m.Mods, m.Repeat)
+	case "mouse":
+		name := "mouse" + m.Action
+		if m.Action == "wheel" {
+			name = "wheel"
+		}
+		c.d.traceMessage(TraceInput, name, "x=%d y=%d button=%d", m.X, m.Y, m.Button)
+	case "resize":
+		c.d.traceMessage(TraceLifecycle, "resize", "width=%d height=%d", m.Width, m.Height)
+	}
+}
+
+var domButtons = map[int]MouseButton{
+	0: ButtonLeft,
+	1: ButtonMiddle,
+	2: ButtonRight,
+}
+
+func (c *webClient) mouse(m *webMessage) *MouseEvent {
+	e := &MouseEvent{X: m.X, Y: m.Y, Mods: m.Mods}
+	switch m.Action {
+	case "move":
+		e.Action = MouseMove
+	case "down":
+		e.Action = MousePress
+		e.Button = domButtons[m.Button]
+	case "up":
+		e.Action = MouseRelease
+		e.Button = domButtons[m.Button]
+	case "wheel":
+		e.Action = MouseScroll
+		e.ScrollX, e.ScrollY = m.DX, m.DY
+	case "enter":
+		e.Action = MouseEnter
+	case "leave":
+		e.Action = MouseLeave
+	default:
+		return nil
+	}
+	return e
+}
+
+func (c *webClient) writeLoop() {
+	for {
+		select {
+		case <-c.signal:
+		case <-c.closed:
+			return
+		}
+
+		if err := c.update(); err != nil {
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+// update sends the size if it has changed and the changed rectangles of the frame.
+func (c *webClient) update() error {
+	frame, dirty := c.d.takeFrame(c)
+
+	var rects []image.Rectangle
+	if c.shown == nil || c.shown.Rect != frame.Rect {
+		size, _ := json.Marshal(map[string]interface{}{
+			"type":   "size",
+			"width":  frame.Rect.Dx(),
+			"height": frame.Rect.Dy(),
+		})
+		if err := c.conn.WriteMessage(websocket.TextMessage, size); err != nil {
+			return err
+		}
+		rects = append(rects, frame.Rect)
+	} else {
+		for _, r := range dirty {
+			rects = append(rects, diffRects(c.shown, frame, r)...)
+		}
+	}
+	c.shown = frame
+
+	for _, r := range rects {
+		if r.Empty() {
+			continue
+		}
+		msg, err := c.encode(frame.SubImage(r), r.Min)
+		if err != nil {
+			return err
+		}
+		if err := c.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encode makes a patch: x and y as big endian uint16, the format (0 PNG, 1 JPEG) and the image.
+func (c *webClient) encode(img image.Image, at image.Point) ([]byte, error) {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, [2]uint16{uint16(at.X), uint16(at.Y)})
+	if c.jpeg {
+		buf.WriteByte(1)
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: c.quality}); err != nil {
+			return nil, err
+		}
+	} else {
+		buf.WriteByte(0)
+		enc := png.Encoder{CompressionLevel: png.BestSpeed}
+		if err := enc.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// domCodes maps KeyboardEvent.code values to keys.
+var domCodes = map[string]Key{
+	"Enter":        KeyEnter,
+	"NumpadEnter":  KeyEnter,
+	"Escape":       KeyEscape,
+	"Backspace":    KeyBackspace,
+	"Tab":          KeyTab,
+	"Space":        KeySpace,
+	"Insert":       KeyInsert,
+	"Delete":       KeyDelete,
+	"Home":         KeyHome,
+	"End":          KeyEnd,
+	"PageUp":       KeyPageUp,
+	"PageDown":     KeyPageDown,
+	"ArrowLeft":    KeyLeft,
+	"ArrowRight":   KeyRight,
+	"ArrowUp":      KeyUp,
+	"ArrowDown":    KeyDown,
+	"Minus":        KeyMinus,
+	"Equal":        KeyEqual,
+	"Comma":        KeyComma,
+	"Period":       KeyPeriod,
+	"Slash":        KeySlash,
+	"ShiftLeft":    KeyShift,
+	"ShiftRight":   KeyShift,
+	"ControlLeft":  KeyControl,
+	"ControlRight": KeyControl,
+	"AltLeft":      KeyAlt,
+	"AltRight":     KeyAlt,
+	"MetaLeft":     KeySuper,
+	"MetaRight":    KeySuper,
+}
+
+func keyFromDOMCode(code string) Key {
+	switch {
+	case len(code) == 4 && code[:3] == "Key" && code[3] >= 'A' && code[3] <= 'Z':
+		return KeyA + Key(code[3]-'A')
+	case len(code) == 6 && code[:5] == "Digit" && code[5] >= '0' && code[5] <= '9':
+		return Key0 + Key(code[5]-'0')
+	case len(code) >= 2 && code[0] == 'F':
+		if n, err := strconv.Atoi(code[1:]); err == nil && n >= 1 && n <= 12 {
+			return KeyF1 + Key(n-1)
+		}
+	}
+	return domCodes[code]
+}
+
+// webPage is the client. Modifier bits in messages are those of Modifier.
+var webPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<style>
+html, body { margin: 0; height: 100%; overflow: hidden; background: #000; }
+canvas { display: block; outline: none; }
+</style>
+</head>
+<body>
+<canvas id="screen" tabindex="0"></canvas>
+<script>
+"use strict";
+const canvas = document.getElementById("screen");
+const ctx = canvas.getContext("2d");
+const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host + "/ws" + location.search);
+ws.binaryType = "arraybuffer";
+
+const dpr = () => window.devicePixelRatio || 1;
+const send = m => { if (ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify(m)); };
+const mods = e => (e.shiftKey ? 1 : 0) | (e.ctrlKey ? 2 : 0) | (e.altKey ? 4 : 0) | (e.metaKey ? 8 : 0);
+const pos = e => {
+	const r = canvas.getBoundingClientRect();
+	return {x: Math.floor((e.clientX - r.left) * canvas.width / r.width), y: Math.floor((e.clientY - r.top) * canvas.height / r.height)};
+};
+
+const resize = () => send({type: "resize", width: Math.round(innerWidth * dpr()), height: Math.round(innerHeight * dpr())});
+ws.onopen = resize;
+addEventListener("resize", resize);
+
+// patches are drawn in order
+let queue = Promise.resolve();
+ws.onmessage = ev => {
+	const data = ev.data;
+	queue = queue.then(async () => {
+		if (typeof data === "string") {
+			const m = JSON.parse(data);
+			if (m.type === "size") {
+				canvas.width = m.width;
+				canvas.height = m.height;
+				canvas.style.width = (m.width / dpr()) + "px";
+				canvas.style.height = (m.height / dpr()) + "px";
+			}
+			return;
+		}
+		const v = new DataView(data);
+		const type = v.getUint8(4) ? "image/jpeg" : "image/png";
+		const bitmap = await createImageBitmap(new Blob([new Uint8Array(data, 5)], {type}));
+		ctx.drawImage(bitmap, v.getUint16(0), v.getUint16(2));
+		bitmap.close();
+	});
+};
+
+const mouse = (action, e, extra) => send(Object.assign({type: "mouse", action, mods: mods(e)}, pos(e), extra));
+canvas.addEventListener("mousemove", e => mouse("move", e));
+canvas.addEventListener("mousedown", e => { canvas.focus(); mouse("down", e, {button: e.button}); e.preventDefault(); });
+canvas.addEventListener("mouseup", e => mouse("up", e, {button: e.button}));
+canvas.addEventListener("mouseenter", e => mouse("enter", e));
+canvas.addEventListener("mouseleave", e => mouse("leave", e));
+canvas.addEventListener("wheel", e => { mouse("wheel", e, {dx: Math.sign(e.deltaX), dy: Math.sign(e.deltaY)}); e.preventDefault(); }, {passive: false});
+canvas.addEventListener("contextmenu", e => e.preventDefault());
+
+const key = (down, e) => { send({type: "key", code: e.code, down, repeat: e.repeat, mods: mods(e)}); e.preventDefault(); };
+canvas.addEventListener("keydown", e => key(true, e));
+canvas.addEventListener("keyup", e => key(false, e));
+canvas.focus();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,222 @@
+// +build !windows
+
+package gui
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal WebSocket client of the web backend.
+type wsClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialWS opens /ws of addr with the Origin header origin, if not empty,
+// and returns the status of the handshake.
+func dialWS(t *testing.T, addr, origin string) (*wsClient, int) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	req, _ := http.NewRequest(http.MethodGet, "http://"+addr+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, resp.StatusCode
+	}
+	h := sha1.New()
+	h.Write([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	if got, want := resp.Header.Get("Sec-WebSocket-Accept"), base64.StdEncoding.EncodeToString(h.Sum(nil)); got != want {
+		t.Fatalf("Sec-WebSocket-Accept %q, want %q", got, want)
+	}
+	return &wsClient{t: t, conn: conn, r: r}, resp.StatusCode
+}
+
+// read returns the next message, which the server sends in one frame.
+func (c *wsClient) read() (int, []byte) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	if hdr[0]&0x80 == 0 || hdr[1]&0x80 != 0 {
+		c.t.Fatalf("frame header % x", hdr)
+	}
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext uint16
+		binary.Read(c.r, binary.BigEndian, &ext)
+		n = uint64(ext)
+	case 127:
+		binary.Read(c.r, binary.BigEndian, &n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		c.t.Fatal(err)
+	}
+	return int(hdr[0] & 0x0f), data
+}
+
+// send writes v as a masked text message.
+func (c *wsClient) send(v interface{}) {
+	data, _ := json.Marshal(v)
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x81, 0x80 | byte(len(data))}
+	frame = append(frame, mask[:]...)
+	for i, b := range data {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func startWeb(t *testing.T, origins string, opts ...Option) (*testRenderer, string, func()) {
+	setenv(t, webAddrEnv, "127.0.0.1:0")
+	setenv(t, webOriginsEnv, origins)
+	app := NewApplication(append([]Option{WithBackend("web")}, opts...)...)
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRenderer(color.RGBA{0, 0xff, 0, 0xff})
+	errc := app.Loop("web", 16, 8, r)
+	w := (<-r.window).(*window)
+	r.nextFrame(t)
+	return r, w.driver.(*webDriver).listener.Addr().String(), func() {
+		app.Quit(0)
+		if err := <-errc; err != nil {
+			t.Errorf("Loop: %v", err)
+		}
+		app.Deinit()
+	}
+}
+
+func TestWebClient(t *testing.T) {
+	r, addr, stop := startWeb(t, "")
+	defer stop()
+
+	c, status := dialWS(t, addr, "")
+	if c == nil {
+		t.Fatalf("handshake status %d", status)
+	}
+	typ, data := c.read()
+	var size struct {
+		Type          string
+		Width, Height int
+	}
+	if err := json.Unmarshal(data, &size); typ != 1 || err != nil || size.Type != "size" || size.Width != 16 || size.Height != 8 {
+		t.Fatalf("first message %d %s", typ, data)
+	}
+	typ, data = c.read()
+	if typ != 2 || len(data) < 5 || data[4] != 0 {
+		t.Fatalf("patch message %d % x", typ, data)
+	}
+	if x, y := binary.BigEndian.Uint16(data), binary.BigEndian.Uint16(data[2:]); x != 0 || y != 0 {
+		t.Errorf("patch at %d,%d", x, y)
+	}
+	img, err := png.Decode(bytes.NewReader(data[5:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 16, 8) {
+		t.Errorf("patch bounds %v", img.Bounds())
+	}
+	if got := color.RGBAModel.Convert(img.At(3, 3)); got != r.color {
+		t.Errorf("patch color %v, want %v", got, r.color)
+	}
+
+	c.send(map[string]interface{}{"type": "key", "code": "KeyQ", "down": true})
+	for {
+		select {
+		case e := <-r.events:
+			if k, ok := e.(*KeyEvent); ok {
+				if k.Key != KeyQ || !k.Down {
+					t.Errorf("key event %+v", k)
+				}
+				return
+			}
+		case <-testTimeout():
+			t.Fatal("no key event")
+		}
+	}
+}
+
+func TestWebOrigin(t *testing.T) {
+	_, addr, stop := startWeb(t, "https://allowed.example, http://other.example:8000")
+	defer stop()
+
+	for _, test := range []struct {
+		origin string
+		status int
+	}{
+		{"", http.StatusSwitchingProtocols},
+		{"http://" + addr, http.StatusSwitchingProtocols},
+		{"https://allowed.example", http.StatusSwitchingProtocols},
+		{"http://other.example:8000", http.StatusSwitchingProtocols},
+		{"http://evil.example", http.StatusForbidden},
+		{"http://other.example", http.StatusForbidden},
+		{"https://127.0.0.1:1", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	} {
+		if _, status := dialWS(t, addr, test.origin); status != test.status {
+			t.Errorf("Origin %q: status %d, want %d", test.origin, status, test.status)
+		}
+	}
+}
+
+// TestWebTrace traces the messages of a browser by their DOM event names.
+func TestWebTrace(t *testing.T) {
+	tr := newTraceRecorder()
+	_, addr, stop := startWeb(t, "", WithTracer(tr, 0))
+	defer stop()
+
+	c, status := dialWS(t, addr, "")
+	if c == nil {
+		t.Fatalf("handshake status %d", status)
+	}
+	c.send(map[string]interface{}{"type": "key", "code": "KeyQ", "down": true})
+	c.send(map[string]interface{}{"type": "mouse", "action": "down", "x": 3, "y": 4, "button": 2})
+	c.send(map[string]interface{}{"type": "resize", "width": 20, "height": 10})
+	for _, want := range []TraceEvent{
+		{Category: TraceInput, Name: "keydown", Detail: "code=KeyQ mods=0x0 repeat=false"},
+		{Category: TraceInput, Name: "mousedown", Detail: "x=3 y=4 button=2"},
+		{Category: TraceLifecycle, Name: "resize", Detail: "width=20 height=10"},
+	} {
+		e := tr.wait(t, "dispatch", want.Name)
+		if e.Backend != "web" || e.Category != want.Category || e.Detail != want.Detail {
+			t.Errorf("traced %+v, want %+v", e, want)
+		}
+	}
+}
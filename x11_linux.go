@@ -0,0 +1,593 @@
+package gui
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/ysh86/gui/internal/x11"
+)
+
+// core requests used by x11Driver
+const (
+	xCreateWindow   = 1
+	xDestroyWindow  = 4
+	xMapWindow      = 8
+	xInternAtom     = 16
+	xChangeProperty = 18
+	xCreateGC       = 55
+	xFreeGC         = 60
+	xPutImage       = 72
+)
+
+// core events
+const (
+	xKeyPress        = 2
+	xKeyRelease      = 3
+	xButtonPress     = 4
+	xButtonRelease   = 5
+	xMotionNotify    = 6
+	xEnterNotify     = 7
+	xLeaveNotify     = 8
+	xFocusOut        = 10
+	xExpose          = 12
+	xConfigureNotify = 22
+	xClientMessage   = 33
+)
+
+// x11Events are the names of the core events by code.
+var x11Events = []string{
+	2: "KeyPress", "KeyRelease", "ButtonPress", "ButtonRelease", "MotionNotify",
+	"EnterNotify", "LeaveNotify", "FocusIn", "FocusOut", "KeymapNotify",
+	"Expose", "GraphicsExposure", "NoExposure", "VisibilityNotify",
+	"CreateNotify", "DestroyNotify", "UnmapNotify", "MapNotify",
+	"MapRequest", "ReparentNotify", "ConfigureNotify", "ConfigureRequest",
+	"GravityNotify", "ResizeRequest", "CirculateNotify", "CirculateRequest",
+	"PropertyNotify", "SelectionClear", "SelectionRequest", "SelectionNotify",
+	"ColormapNotify", "ClientMessage", "MappingNotify",
+}
+
+// x11Category returns the trace category of the core event code.
+func x11Category(code uint8) TraceCategory {
+	switch {
+	case code >= xKeyPress && code <= xLeaveNotify:
+		return TraceInput
+	case code == xExpose:
+		return TracePaint
+	case code >= 9 && code <= xClientMessage:
+		return TraceLifecycle
+	}
+	return TraceOther
+}
+
+// CreateWindow values
+const (
+	xInputOutput = 1
+
+	xCWBackPixel  = 1 << 1
+	xCWBitGravity = 1 << 4
+	xCWEventMask  = 1 << 11
+
+	xNorthWestGravity = 1
+
+	xGCGraphicsExposures = 1 << 16
+
+	xZPixmap = 2
+)
+
+// event masks
+const (
+	xKeyPressMask        = 1 << 0
+	xKeyReleaseMask      = 1 << 1
+	xButtonPressMask     = 1 << 2
+	xButtonReleaseMask   = 1 << 3
+	xEnterWindowMask     = 1 << 4
+	xLeaveWindowMask     = 1 << 5
+	xPointerMotionMask   = 1 << 6
+	xExposureMask        = 1 << 15
+	xStructureNotifyMask = 1 << 17
+	xFocusChangeMask     = 1 << 21
+)
+
+// predefined atoms
+const (
+	xAtomAtom    = 4
+	xAtomString  = 31
+	xAtomWMName  = 39
+	xAtomWMClass = 67
+)
+
+// x11Capabilities are the features of the X11 backend.
+const x11Capabilities = CapMultiWindow | CapKeyboard | CapPointer | drawnMenuCapabilities | glCapabilities
+
+func init() {
+	registerBackend(&backend{
+		name:     "x11",
+		priority: 20,
+		caps:     x11Capabilities,
+		probe:    probeX11,
+		newDriver: func() (driver, error) {
+			return &x11Driver{}, nil
+		},
+	})
+}
+
+// probeX11 checks that the X server accepts connections.
+func probeX11() error {
+	conn, err := x11.Dial()
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// x11Driver shows a window of the X server with PutImage.
+type x11Driver struct {
+	conn    *x11.Conn
+	res     *resources
+	wakeup  wakePipe
+	timer   timerFd // of the timeout of poll
+	watches *fdWatches
+
+	window uint32
+	gc     uint32
+	atoms  struct {
+		wmProtocols    uint32
+		wmDeleteWindow uint32
+		netWMName      uint32
+		utf8String     uint32
+	}
+
+	// pixel format of the root visual
+	redShift, greenShift, blueShift uint
+	byteOrder                       binary.ByteOrder
+
+	width, height int32
+	image         *image.RGBA
+	pixels        []byte // a strip of PutImage
+	direct        bool   // a GL or Vulkan surface draws to the window
+	closers       []func()
+
+	// release is a KeyRelease held back until the next event, which is the
+	// KeyPress of the same time if the server repeats the key.
+	release     *KeyEvent
+	releaseCode uint8
+	releaseTime uint32
+	keys        [256]bool // pressed keycodes
+
+	events []interface{}
+	trace  func(category TraceCategory, name string, detail string)
+	native func(m *NativeMessage) bool
+}
+
+func (d *x11Driver) open(name string, width int32, height int32) error {
+	if err := d.wakeup.open(); err != nil {
+		return err
+	}
+	if err := d.timer.open(); err != nil {
+		return err
+	}
+	conn, err := x11.Dial()
+	if err != nil {
+		return err
+	}
+	d.conn = conn
+	d.res.acquire(resDisplay)
+	conn.SetHandler(d.handleEvent)
+
+	s := &conn.Screen
+	v := &s.RootVisual
+	if s.BitsPerPixel != 32 || bits.OnesCount32(v.RedMask) != 8 || bits.OnesCount32(v.GreenMask) != 8 || bits.OnesCount32(v.BlueMask) != 8 {
+		return fmt.Errorf("x11: unsupported visual of depth %d and %d bits per pixel", s.RootDepth, s.BitsPerPixel)
+	}
+	d.redShift = uint(bits.TrailingZeros32(v.RedMask))
+	d.greenShift = uint(bits.TrailingZeros32(v.GreenMask))
+	d.blueShift = uint(bits.TrailingZeros32(v.BlueMask))
+	d.byteOrder = binary.LittleEndian
+	if conn.ImageByteOrder == x11.MSBFirst {
+		d.byteOrder = binary.BigEndian
+	}
+
+	for _, a := range []struct {
+		name string
+		atom *uint32
+	}{
+		{"WM_PROTOCOLS", &d.atoms.wmProtocols},
+		{"WM_DELETE_WINDOW", &d.atoms.wmDeleteWindow},
+		{"_NET_WM_NAME", &d.atoms.netWMName},
+		{"UTF8_STRING", &d.atoms.utf8String},
+	} {
+		if *a.atom, err = d.internAtom(a.name); err != nil {
+			return err
+		}
+	}
+
+	d.width, d.height = width, height
+	d.window = conn.NewID()
+	mask := uint32(xKeyPressMask | xKeyReleaseMask | xButtonPressMask | xButtonReleaseMask |
+		xEnterWindowMask | xLeaveWindowMask | xPointerMotionMask |
+		xExposureMask | xStructureNotifyMask | xFocusChangeMask)
+	conn.Request(xCreateWindow, s.RootDepth, d.window, s.Root,
+		int16(0), int16(0), uint16(width), uint16(height), uint16(0),
+		uint16(xInputOutput), v.ID, uint32(xCWBackPixel|xCWBitGravity|xCWEventMask),
+		s.BlackPixel, uint32(xNorthWestGravity), mask)
+
+	class := filepath.Base(os.Args[0])
+	d.changeProperty(xAtomWMName, xAtomString, 8, []byte(name))
+	d.changeProperty(d.atoms.netWMName, d.atoms.utf8String, 8, []byte(name))
+	d.changeProperty(xAtomWMClass, xAtomString, 8, []byte(class+"\x00"+class+"\x00"))
+	protocols := make([]byte, 4)
+	binary.LittleEndian.PutUint32(protocols, d.atoms.wmDeleteWindow)
+	d.changeProperty(d.atoms.wmProtocols, xAtomAtom, 32, protocols)
+
+	d.gc = conn.NewID()
+	conn.Request(xCreateGC, 0, d.gc, d.window, uint32(xGCGraphicsExposures), uint32(0))
+	conn.Request(xMapWindow, 0, d.window)
+
+	d.image = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+	d.events = append(d.events, sizeEvent{width: width, height: height})
+	// the first Expose follows the mapping
+	return conn.Sync()
+}
+
+func (d *x11Driver) internAtom(name string) (uint32, error) {
+	seq, err := d.conn.Request(xInternAtom, 0, uint16(len(name)), uint16(0), name)
+	if err != nil {
+		return 0, err
+	}
+	r, err := d.conn.Reply(seq)
+	if err != nil {
+		return 0, err
+	}
+	return r.Uint32(), nil
+}
+
+func (d *x11Driver) changeProperty(property, typ uint32, format uint8, data []byte) error {
+	_, err := d.conn.Request(xChangeProperty, 0, d.window, property, typ,
+		format, uint8(0), uint16(0), uint32(len(data)*8/int(format)), data)
+	return err
+}
+
+func (d *x11Driver) close() {
+	for _, f := range d.closers {
+		f()
+	}
+	d.closers = nil
+	if d.conn != nil {
+		if d.gc != 0 {
+			d.conn.Request(xFreeGC, 0, d.gc)
+		}
+		if d.window != 0 {
+			d.conn.Request(xDestroyWindow, 0, d.window)
+		}
+		d.conn.Close()
+		d.conn = nil
+		d.res.release(resDisplay)
+	}
+	d.wakeup.close()
+	d.timer.close()
+}
+
+func (d *x11Driver) setResources(res *resources) {
+	d.res = res
+}
+
+func (d *x11Driver) handle() uintptr {
+	return uintptr(d.window)
+}
+
+func (d *x11Driver) poll(timeout time.Duration) ([]interface{}, error) {
+	if err := d.timer.set(timeout); err != nil {
+		return nil, err
+	}
+
+	for len(d.events) == 0 {
+		fds := []unix.PollFd{{Fd: int32(d.conn.Fd()), Events: unix.POLLIN}, d.wakeup.pollFd(), d.timer.pollFd()}
+		fds = d.watches.pollFds(fds)
+		_, err := unix.Poll(fds, -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Poll: %v", err)
+		}
+		if fds[0].Revents != 0 {
+			if err := d.conn.Dispatch(false); err != nil {
+				return nil, err
+			}
+			// a repeated key is sent in the same read
+			d.flushRelease()
+		}
+		d.events = append(d.events, d.watches.read(fds[3:])...)
+		if fds[1].Revents != 0 {
+			d.wakeup.drain()
+			break
+		}
+		if d.timer.expired(fds[2]) {
+			break
+		}
+	}
+
+	events := d.events
+	d.events = nil
+	return events, nil
+}
+
+func (d *x11Driver) wake() {
+	d.wakeup.wake()
+}
+
+func (d *x11Driver) setWatches(ws *fdWatches) {
+	d.watches = ws
+}
+
+func (d *x11Driver) framebuffer() *image.RGBA {
+	if d.direct {
+		return nil
+	}
+	return d.image
+}
+
+// windowSurfaceDriver is a driver of X11 whose window can have an EGL
+// window surface or a Vulkan surface, which eglSwapBuffers or the swapchain
+// presents instead of present.
+type windowSurfaceDriver interface {
+	// glWindow returns the window and its visual.
+	glWindow() (window uintptr, visual uint32)
+	// setGLSurface stops presenting the framebuffer while on is true.
+	setGLSurface(on bool)
+}
+
+// glWindow and setGLSurface are used by the EGL and Vulkan surfaces.
+func (d *x11Driver) glWindow() (uintptr, uint32) {
+	return uintptr(d.window), d.conn.Screen.RootVisual.ID
+}
+
+func (d *x11Driver) setGLSurface(on bool) {
+	d.direct = on
+	if !on {
+		d.events = append(d.events, exposeEvent{})
+	}
+}
+
+// onClose calls f when the window is closed, e.g. to close the display of
+// Xlib of the Vulkan surfaces.
+func (d *x11Driver) onClose(f func()) {
+	d.closers = append(d.closers, f)
+}
+
+// present puts the rows of the region in strips which fit in a request.
+func (d *x11Driver) present(region []image.Rectangle) error {
+	if d.direct {
+		return nil
+	}
+	const header = 24 // of PutImage
+	for _, r := range region {
+		r = r.Intersect(d.image.Rect)
+		if r.Empty() {
+			continue
+		}
+		stride := 4 * r.Dx()
+		rows := (d.conn.MaxRequestLength - header) / stride
+		if rows < 1 {
+			return errors.New("x11: the window is too wide for PutImage")
+		}
+		for y := r.Min.Y; y < r.Max.Y; y += rows {
+			n := r.Max.Y - y
+			if n > rows {
+				n = rows
+			}
+			if err := d.putImage(image.Rect(r.Min.X, y, r.Max.X, y+n)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *x11Driver) putImage(r image.Rectangle) error {
+	size := 4 * r.Dx() * r.Dy()
+	if cap(d.pixels) < size {
+		d.pixels = make([]byte, size)
+	}
+	dst := d.pixels[:size]
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		src := d.image.Pix[d.image.PixOffset(r.Min.X, y):d.image.PixOffset(r.Max.X, y)]
+		for i := 0; i < len(src); i += 4 {
+			p := uint32(src[i])<<d.redShift | uint32(src[i+1])<<d.greenShift | uint32(src[i+2])<<d.blueShift
+			d.byteOrder.PutUint32(dst, p)
+			dst = dst[4:]
+		}
+	}
+	_, err := d.conn.Request(xPutImage, xZPixmap, d.window, d.gc,
+		uint16(r.Dx()), uint16(r.Dy()), int16(r.Min.X), int16(r.Min.Y),
+		uint8(0), d.conn.Screen.RootDepth, uint16(0), d.pixels[:size])
+	return err
+}
+
+func (d *x11Driver) setTrace(trace func(category TraceCategory, name string, detail string)) {
+	d.trace = trace
+}
+
+func (d *x11Driver) setNative(native func(m *NativeMessage) bool) {
+	d.native = native
+}
+
+// handleEvent traces an event and passes it to the native handler before
+// handling it.
+func (d *x11Driver) handleEvent(e *x11.Message) {
+	if d.trace != nil || d.native != nil {
+		name := fmt.Sprintf("event %d", e.Code)
+		if int(e.Code) < len(x11Events) && x11Events[e.Code] != "" {
+			name = x11Events[e.Code]
+		}
+		if d.trace != nil {
+			d.trace(x11Category(e.Code), name, "")
+		}
+		if d.native != nil {
+			// events of the window have it at the same offset except the
+			// input events, which have the time first
+			object := binary.LittleEndian.Uint32(e.Data())
+			if e.Code >= xKeyPress && e.Code <= xLeaveNotify {
+				object = binary.LittleEndian.Uint32(e.Data()[8:])
+			}
+			m := &NativeMessage{Object: object, Opcode: uint16(e.Code), Name: name, Args: e.Data()}
+			if d.native(m) {
+				return
+			}
+		}
+	}
+
+	if d.release != nil && e.Code != xKeyPress {
+		d.flushRelease()
+	}
+	switch e.Code {
+	case xKeyPress, xKeyRelease:
+		d.handleKey(e)
+	case xButtonPress, xButtonRelease, xMotionNotify, xEnterNotify, xLeaveNotify:
+		d.handlePointer(e)
+	case xFocusOut:
+		d.keys = [256]bool{}
+	case xExpose:
+		e.Skip(12) // window, x, y, width, height
+		if e.Uint16() == 0 {
+			// the last of a series
+			d.events = append(d.events, exposeEvent{})
+		}
+	case xConfigureNotify:
+		e.Skip(16) // event, window, above sibling, x, y
+		width, height := int32(e.Uint16()), int32(e.Uint16())
+		if width != d.width || height != d.height {
+			d.width, d.height = width, height
+			d.image = image.NewRGBA(image.Rect(0, 0, int(width), int(height)))
+			d.events = append(d.events, sizeEvent{width: width, height: height}, exposeEvent{})
+		}
+	case xClientMessage:
+		e.Skip(4) // window
+		if e.Uint32() == d.atoms.wmProtocols && e.Uint32() == d.atoms.wmDeleteWindow {
+			d.events = append(d.events, closeEvent{})
+		}
+	}
+}
+
+// x11Mods converts the state of an input event, whose masks are those of xkb.
+func x11Mods(state uint16) Modifier {
+	var mods Modifier
+	if state&xkbShift != 0 {
+		mods |= ModShift
+	}
+	if state&xkbCtrl != 0 {
+		mods |= ModCtrl
+	}
+	if state&xkbMod1 != 0 {
+		mods |= ModAlt
+	}
+	if state&xkbMod4 != 0 {
+		mods |= ModSuper
+	}
+	return mods
+}
+
+// x11Input reads the fields of a key, button, motion or crossing event.
+// The server time is CLOCK_MONOTONIC milliseconds on Linux.
+func x11Input(e *x11.Message) (ms uint32, x, y int32, mods Modifier) {
+	ms = e.Uint32()
+	e.Skip(16) // root, event, child, root x, root y
+	x, y = int32(e.Int16()), int32(e.Int16())
+	mods = x11Mods(e.Uint16())
+	return
+}
+
+// handleKey maps the keycodes of the server from evdev codes like the
+// X servers using xkb do, without the keyboard mapping.
+func (d *x11Driver) handleKey(e *x11.Message) {
+	code := e.Detail
+	ms, _, _, mods := x11Input(e)
+	k := &KeyEvent{
+		EventHeader: EventHeader{Time: monotonicTime(ms)},
+		Key:         keyFromEvdev(uint32(code) - 8),
+		Mods:        mods,
+		Down:        e.Code == xKeyPress,
+	}
+
+	if !k.Down {
+		d.keys[code] = false
+		d.release, d.releaseCode, d.releaseTime = k, code, ms
+		return
+	}
+	if r := d.release; r != nil {
+		d.release = nil
+		if d.releaseCode == code && d.releaseTime == ms {
+			k.Repeat = true
+		} else {
+			d.events = append(d.events, r)
+		}
+	}
+	// without detectable auto repeat, a pressed key may be pressed again
+	k.Repeat = k.Repeat || d.keys[code]
+	d.keys[code] = true
+	d.events = append(d.events, k)
+}
+
+// flushRelease sends the KeyRelease held back.
+func (d *x11Driver) flushRelease() {
+	if d.release != nil {
+		d.events = append(d.events, d.release)
+		d.release = nil
+	}
+}
+
+func (d *x11Driver) handlePointer(e *x11.Message) {
+	button := e.Detail
+	ms, x, y, mods := x11Input(e)
+	m := &MouseEvent{X: x, Y: y, Mods: mods}
+	m.Time = monotonicTime(ms)
+
+	switch e.Code {
+	case xMotionNotify:
+		m.Action = MouseMove
+	case xEnterNotify:
+		m.Action = MouseEnter
+	case xLeaveNotify:
+		m.Action = MouseLeave
+	case xButtonPress, xButtonRelease:
+		m.Action = MouseRelease
+		if e.Code == xButtonPress {
+			m.Action = MousePress
+		}
+		switch button {
+		case 1:
+			m.Button = ButtonLeft
+		case 2:
+			m.Button = ButtonMiddle
+		case 3:
+			m.Button = ButtonRight
+		case 4, 5, 6, 7:
+			// wheel notches are pressed and released at once
+			if e.Code == xButtonRelease {
+				return
+			}
+			m.Action = MouseScroll
+			switch button {
+			case 4:
+				m.ScrollY = -1
+			case 5:
+				m.ScrollY = 1
+			case 6:
+				m.ScrollX = -1
+			case 7:
+				m.ScrollX = 1
+			}
+		default:
+			return
+		}
+	}
+	d.events = append(d.events, m)
+}
@@ -0,0 +1,137 @@
+package gui
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// monotonicMillis returns the time of CLOCK_MONOTONIC like the server time of X11.
+func monotonicMillis(t *testing.T) uint32 {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		t.Fatal(err)
+	}
+	return uint32(ts.Nano() / int64(time.Millisecond))
+}
+
+func TestX11FakeServer(t *testing.T) {
+	s := startXServer(t)
+
+	app := NewApplication(WithBackend("x11"))
+	if err := app.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if app.Backend() != "x11" {
+		t.Fatalf("backend %q", app.Backend())
+	}
+
+	r := newTestRenderer(color.RGBA{0x20, 0x40, 0x60, 0xff})
+	errc := app.Loop("fake x", 64, 48, r)
+	win := (<-r.window).NativeHandle()
+
+	// the frame is split in strips of PutImage
+	w := s.window(t, func(w *fakeXWindow) bool {
+		return w.image.RGBAAt(63, 47) == r.color
+	})
+	s.mu.Lock()
+	if c := w.image.RGBAAt(0, 0); c != r.color {
+		t.Errorf("pixel %v, want %v", c, r.color)
+	}
+	if w.puts < 2 {
+		t.Errorf("%d PutImage requests, want strips", w.puts)
+	}
+	if name := string(w.props[xAtomWMName]); name != "fake x" {
+		t.Errorf("WM_NAME %q", name)
+	}
+	if p := w.props[s.atoms["WM_PROTOCOLS"]]; len(p) != 4 || uint32(p[0]) != s.atoms["WM_DELETE_WINDOW"] {
+		t.Errorf("WM_PROTOCOLS %v", p)
+	}
+	s.mu.Unlock()
+
+	// the button press happened 20ms ago at its position
+	now := monotonicMillis(t)
+	s.send(xButtonPress, 1, now-20, uint32(fakeXRoot), uint32(win), uint32(0),
+		int16(105), int16(107), int16(5), int16(7), uint16(xkbCtrl), uint8(1))
+	e := r.nextEvent(t, func(e Event) bool {
+		m, ok := e.(*MouseEvent)
+		return ok && m.Action == MousePress
+	}).(*MouseEvent)
+	if e.Button != ButtonLeft || e.X != 5 || e.Y != 7 || e.Mods != ModCtrl {
+		t.Errorf("press %+v", e)
+	}
+	if e.Pos != image.Pt(5, 7) {
+		t.Errorf("position %v, want (5,7)", e.Pos)
+	}
+	if age := time.Since(e.Time); age < 20*time.Millisecond || age > time.Second {
+		t.Errorf("press of %v ago, want 20ms", age)
+	}
+
+	// the server repeats a key with a release and a press of the same time
+	const keyA = 30 + 8
+	key := func(code uint8, ms uint32) {
+		s.send(code, keyA, ms, uint32(fakeXRoot), uint32(win), uint32(0),
+			int16(0), int16(0), int16(5), int16(7), uint16(0), uint8(1))
+	}
+	now = monotonicMillis(t)
+	key(xKeyPress, now)
+	s.batch(func() {
+		key(xKeyRelease, now+1)
+		key(xKeyPress, now+1)
+	})
+	key(xKeyRelease, now+2)
+	for _, want := range []KeyEvent{
+		{Key: KeyA, Down: true},
+		{Key: KeyA, Down: true, Repeat: true},
+		{Key: KeyA},
+	} {
+		k := r.nextEvent(t, func(e Event) bool {
+			_, ok := e.(*KeyEvent)
+			return ok
+		}).(*KeyEvent)
+		if k.Key != want.Key || k.Down != want.Down || k.Repeat != want.Repeat {
+			t.Errorf("key %v down %v repeat %v, want down %v repeat %v", k.Key, k.Down, k.Repeat, want.Down, want.Repeat)
+		}
+	}
+
+	// resizing draws a frame of the new size
+	s.resize(uint32(win), 80, 60)
+	s.window(t, func(w *fakeXWindow) bool {
+		return w.image.Bounds().Dx() == 80 && w.image.RGBAAt(79, 59) == r.color
+	})
+
+	// closed by the window manager
+	s.mu.Lock()
+	protocols, deleteWindow := s.atoms["WM_PROTOCOLS"], s.atoms["WM_DELETE_WINDOW"]
+	s.mu.Unlock()
+	s.send(xClientMessage, 32, uint32(win), protocols, deleteWindow, uint32(0))
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("Loop: %v", err)
+		}
+	case <-testTimeout():
+		t.Fatal("Loop did not return")
+	}
+	app.Deinit()
+	if err := CheckLeaks(app); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestX11Refused(t *testing.T) {
+	startXServer(t)
+	setenv(t, "XAUTHORITY", "/nonexistent")
+
+	app := NewApplication(WithBackend("x11"))
+	err := app.Init()
+	if !errors.Is(err, ErrNoBackend) || !strings.Contains(err.Error(), "Invalid MIT-MAGIC-COOKIE-1") {
+		t.Errorf("Init without the cookie: %v", err)
+	}
+	app.Deinit()
+}
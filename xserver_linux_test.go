@@ -0,0 +1,371 @@
+package gui
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fake X server setup
+const (
+	fakeXRoot       = 0x100
+	fakeXVisual     = 0x21
+	fakeXMaxRequest = 1024 // in 4 byte units, so that PutImage is split
+	fakeXCookie     = "0123456789abcdef"
+)
+
+// fakeXServer is an X server for the tests which keeps the contents of
+// the windows in images, in the little endian byte order only.
+type fakeXServer struct {
+	t     *testing.T
+	ln    net.Listener
+	conns sync.WaitGroup
+
+	mu      sync.Mutex
+	atoms   map[string]uint32
+	windows map[uint32]*fakeXWindow
+	client  *fakeXClient // of the last window
+	changed chan struct{}
+}
+
+type fakeXWindow struct {
+	image *image.RGBA
+	props map[uint32][]byte
+	puts  int
+}
+
+type fakeXClient struct {
+	conn  net.Conn
+	mu    sync.Mutex
+	seq   uint16
+	batch []byte // events held by batch
+}
+
+// startXServer starts a fake X server on a free display number and points
+// DISPLAY and XAUTHORITY at it. It listens on the abstract socket of the
+// display, which clients on Linux try first.
+func startXServer(t *testing.T) *fakeXServer {
+	dir, err := ioutil.TempDir("", "gui-x11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	s := &fakeXServer{
+		t:       t,
+		atoms:   make(map[string]uint32),
+		windows: make(map[uint32]*fakeXWindow),
+		changed: make(chan struct{}, 1),
+	}
+	number := 90
+	for ; ; number++ {
+		s.ln, err = net.Listen("unix", "@/tmp/.X11-unix/X"+strconv.Itoa(number))
+		if err == nil {
+			break
+		}
+		if number == 199 {
+			t.Fatal(err)
+		}
+	}
+	clients := make(map[net.Conn]bool)
+	t.Cleanup(func() {
+		s.ln.Close()
+		s.mu.Lock()
+		for conn := range clients {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		s.conns.Wait()
+	})
+	s.conns.Add(1)
+	go s.serve(clients)
+
+	// an entry of any address with the cookie
+	var auth []byte
+	for _, field := range []string{"", strconv.Itoa(number), "MIT-MAGIC-COOKIE-1", fakeXCookie} {
+		auth = append(auth, byte(len(field)>>8), byte(len(field)))
+		auth = append(auth, field...)
+	}
+	auth = append([]byte{0xff, 0xff}, auth...)
+	xauth := filepath.Join(dir, "Xauthority")
+	if err := ioutil.WriteFile(xauth, auth, 0600); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "DISPLAY", ":"+strconv.Itoa(number))
+	setenv(t, "XAUTHORITY", xauth)
+	return s
+}
+
+// serve serves the clients until the listener is closed. The connections
+// are kept in clients to be closed with it.
+func (s *fakeXServer) serve(clients map[net.Conn]bool) {
+	defer s.conns.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		clients[conn] = true
+		s.mu.Unlock()
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			s.serveClient(&fakeXClient{conn: conn})
+		}()
+	}
+}
+
+func (s *fakeXServer) serveClient(c *fakeXClient) {
+	defer c.conn.Close()
+	if !s.setup(c) {
+		return
+	}
+	for {
+		head := make([]byte, 4)
+		if _, err := io.ReadFull(c.conn, head); err != nil {
+			return
+		}
+		req := make([]byte, 4*int(binary.LittleEndian.Uint16(head[2:])))
+		if len(req) < 4 {
+			return
+		}
+		copy(req, head)
+		if _, err := io.ReadFull(c.conn, req[4:]); err != nil {
+			return
+		}
+		c.mu.Lock()
+		c.seq++
+		c.mu.Unlock()
+		s.request(c, req)
+	}
+}
+
+func (s *fakeXServer) setup(c *fakeXClient) bool {
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(c.conn, head); err != nil {
+		return false
+	}
+	nameLen, dataLen := int(binary.LittleEndian.Uint16(head[6:])), int(binary.LittleEndian.Uint16(head[8:]))
+	auth := make([]byte, (nameLen+3)&^3+(dataLen+3)&^3)
+	if _, err := io.ReadFull(c.conn, auth); err != nil {
+		return false
+	}
+	name, data := string(auth[:nameLen]), string(auth[(nameLen+3)&^3:][:dataLen])
+	if head[0] != 'l' || name != "MIT-MAGIC-COOKIE-1" || data != fakeXCookie {
+		reason := "Invalid MIT-MAGIC-COOKIE-1 key"
+		msg := []byte{0, byte(len(reason)), 11, 0, 0, 0, 0, 0}
+		msg = fakeXPad(append(msg, reason...))
+		binary.LittleEndian.PutUint16(msg[6:], uint16(len(msg)/4-2))
+		c.conn.Write(msg)
+		return false
+	}
+
+	vendor := "gui test"
+	var b []byte
+	b = append(b, 1, 0)
+	b = fakeXAppend(b, uint16(11), uint16(0), uint16(0))
+	b = fakeXAppend(b, uint32(0), uint32(0x200000), uint32(0x1fffff), uint32(0))
+	b = fakeXAppend(b, uint16(len(vendor)), uint16(fakeXMaxRequest))
+	b = append(b, 1, 1, 0, 0, 32, 32, 8, 255, 0, 0, 0, 0) // screens, formats, LSBFirst, bitmaps, keycodes
+	b = fakeXPad(append(b, vendor...))
+	b = append(b, 24, 32, 32, 0, 0, 0, 0, 0) // depth 24 in 32 bits per pixel
+	b = fakeXAppend(b, uint32(fakeXRoot), uint32(0x20), uint32(0xffffff), uint32(0), uint32(0))
+	b = fakeXAppend(b, uint16(1024), uint16(768), uint16(270), uint16(203), uint16(1), uint16(1))
+	b = fakeXAppend(b, uint32(fakeXVisual))
+	b = append(b, 0, 0, 24, 1) // backing stores, save unders, root depth, depths
+	b = append(b, 24, 0)
+	b = fakeXAppend(b, uint16(1), uint32(0))
+	b = fakeXAppend(b, uint32(fakeXVisual))
+	b = append(b, 4, 8) // TrueColor
+	b = fakeXAppend(b, uint16(256), uint32(0xff0000), uint32(0xff00), uint32(0xff), uint32(0))
+	binary.LittleEndian.PutUint16(b[6:], uint16(len(b)/4-2))
+	_, err := c.conn.Write(b)
+	return err == nil
+}
+
+func (s *fakeXServer) request(c *fakeXClient, req []byte) {
+	le := binary.LittleEndian
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req[0] {
+	case xCreateWindow:
+		id := le.Uint32(req[4:])
+		width, height := int(le.Uint16(req[16:])), int(le.Uint16(req[18:]))
+		s.windows[id] = &fakeXWindow{
+			image: image.NewRGBA(image.Rect(0, 0, width, height)),
+			props: make(map[uint32][]byte),
+		}
+		s.client = c
+	case xMapWindow:
+		c.event(xExpose, 0, le.Uint32(req[4:]), uint32(0), uint32(0), uint16(0))
+	case xChangeProperty:
+		if w := s.windows[le.Uint32(req[4:])]; w != nil {
+			n := int(le.Uint32(req[20:])) * int(req[16]) / 8
+			w.props[le.Uint32(req[8:])] = append([]byte(nil), req[24:24+n]...)
+		}
+	case xInternAtom:
+		name := string(req[8:][:le.Uint16(req[4:])])
+		atom, ok := s.atoms[name]
+		if !ok {
+			atom = uint32(100 + len(s.atoms))
+			s.atoms[name] = atom
+		}
+		c.reply(0, atom)
+	case 43: // GetInputFocus
+		c.reply(0, uint32(fakeXRoot))
+	case xPutImage:
+		w := s.windows[le.Uint32(req[4:])]
+		if w == nil || req[1] != xZPixmap || req[21] != 24 {
+			c.error(2, 0, req[0]) // BadValue
+			return
+		}
+		width, height := int(le.Uint16(req[12:])), int(le.Uint16(req[14:]))
+		x, y := int(int16(le.Uint16(req[16:]))), int(int16(le.Uint16(req[18:])))
+		data := req[24:]
+		for j := 0; j < height; j++ {
+			for i := 0; i < width; i++ {
+				p := data[4*(j*width+i):]
+				w.image.SetRGBA(x+i, y+j, color.RGBA{p[2], p[1], p[0], 0xff})
+			}
+		}
+		w.puts++
+		select {
+		case s.changed <- struct{}{}:
+		default:
+		}
+	case xDestroyWindow:
+		delete(s.windows, le.Uint32(req[4:]))
+	case 98: // QueryExtension: no extensions
+		c.reply(0, uint8(0))
+	case 20: // GetProperty: no properties of the root window
+		c.reply(0, uint32(0), uint32(0), uint32(0))
+	case 14: // GetGeometry
+		r := image.Rect(0, 0, 1024, 768)
+		if w := s.windows[le.Uint32(req[4:])]; w != nil {
+			r = w.image.Bounds()
+		}
+		c.reply(24, uint32(fakeXRoot), int16(0), int16(0), uint16(r.Dx()), uint16(r.Dy()), uint16(0))
+	case xCreateGC, xFreeGC:
+	default:
+		s.t.Logf("fake X server: request %d of %d bytes", req[0], len(req))
+		c.error(1, 0, req[0]) // BadRequest
+	}
+}
+
+// send sends an event to the client of the last window.
+func (s *fakeXServer) send(code, detail uint8, fields ...interface{}) {
+	s.mu.Lock()
+	c := s.client
+	s.mu.Unlock()
+	if c == nil {
+		s.t.Fatal("no window to send events to")
+	}
+	c.event(code, detail, fields...)
+}
+
+// batch sends the events sent by f in one write, like a server sending
+// the release and the press of a repeated key.
+func (s *fakeXServer) batch(f func()) {
+	s.mu.Lock()
+	c := s.client
+	s.mu.Unlock()
+	c.mu.Lock()
+	c.batch = []byte{}
+	c.mu.Unlock()
+	f()
+	c.mu.Lock()
+	c.conn.Write(c.batch)
+	c.batch = nil
+	c.mu.Unlock()
+}
+
+// resize resizes the window and sends ConfigureNotify.
+func (s *fakeXServer) resize(id uint32, width, height int) {
+	s.mu.Lock()
+	if w := s.windows[id]; w != nil {
+		w.image = image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	s.mu.Unlock()
+	s.send(xConfigureNotify, 0, id, id, uint32(0), int16(0), int16(0), uint16(width), uint16(height), uint16(0))
+}
+
+// window returns the last window, waiting for a PutImage after wait.
+func (s *fakeXServer) window(t *testing.T, wait func(w *fakeXWindow) bool) *fakeXWindow {
+	for {
+		s.mu.Lock()
+		for _, w := range s.windows {
+			if wait(w) {
+				s.mu.Unlock()
+				return w
+			}
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.changed:
+		case <-testTimeout():
+			t.Fatal("the window was not drawn")
+		}
+	}
+}
+
+func (c *fakeXClient) write(msg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	binary.LittleEndian.PutUint16(msg[2:], c.seq)
+	if c.batch != nil {
+		c.batch = append(c.batch, msg...)
+		return
+	}
+	c.conn.Write(msg)
+}
+
+func (c *fakeXClient) reply(detail uint8, fields ...interface{}) {
+	msg := fakeXAppend([]byte{1, detail, 0, 0, 0, 0, 0, 0}, fields...)
+	c.write(append(msg, make([]byte, 32-len(msg))...))
+}
+
+func (c *fakeXClient) error(code uint8, value uint32, major uint8) {
+	msg := fakeXAppend([]byte{0, code, 0, 0}, value, uint16(0), major)
+	c.write(append(msg, make([]byte, 32-len(msg))...))
+}
+
+func (c *fakeXClient) event(code, detail uint8, fields ...interface{}) {
+	msg := fakeXAppend([]byte{code, detail, 0, 0}, fields...)
+	c.write(append(msg, make([]byte, 32-len(msg))...))
+}
+
+// fakeXAppend appends the little endian fields to b.
+func fakeXAppend(b []byte, fields ...interface{}) []byte {
+	for _, f := range fields {
+		switch v := f.(type) {
+		case uint8:
+			b = append(b, v)
+		case uint16:
+			b = append(b, byte(v), byte(v>>8))
+		case int16:
+			b = append(b, byte(v), byte(v>>8))
+		case uint32:
+			b = append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+		default:
+			panic("fakeXAppend: unsupported field")
+		}
+	}
+	return b
+}
+
+func fakeXPad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
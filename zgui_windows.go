@@ -40,27 +40,85 @@ var (
 	modkernel32 = windows.NewLazySystemDLL("kernel32.dll")
 	modole32    = windows.NewLazySystemDLL("ole32.dll")
 	moduser32   = windows.NewLazySystemDLL("user32.dll")
+	modgdi32    = windows.NewLazySystemDLL("gdi32.dll")
+	modcomdlg32 = windows.NewLazySystemDLL("comdlg32.dll")
+	modshell32  = windows.NewLazySystemDLL("shell32.dll")
+	modopengl32 = windows.NewLazySystemDLL("opengl32.dll")
 
-	procGetModuleHandleW  = modkernel32.NewProc("GetModuleHandleW")
-	procCoInitializeEx    = modole32.NewProc("CoInitializeEx")
-	procCoUninitialize    = modole32.NewProc("CoUninitialize")
-	procMessageBoxExW     = moduser32.NewProc("MessageBoxExW")
-	procLoadIconW         = moduser32.NewProc("LoadIconW")
-	procLoadCursorW       = moduser32.NewProc("LoadCursorW")
-	procRegisterClassExW  = moduser32.NewProc("RegisterClassExW")
-	procCreateWindowExW   = moduser32.NewProc("CreateWindowExW")
-	procShowWindow        = moduser32.NewProc("ShowWindow")
-	procUpdateWindow      = moduser32.NewProc("UpdateWindow")
-	procDefWindowProcW    = moduser32.NewProc("DefWindowProcW")
-	procGetMessageW       = moduser32.NewProc("GetMessageW")
-	procTranslateMessage  = moduser32.NewProc("TranslateMessage")
-	procDispatchMessageW  = moduser32.NewProc("DispatchMessageW")
-	procPostQuitMessage   = moduser32.NewProc("PostQuitMessage")
-	procSetWindowLongPtrW = moduser32.NewProc("SetWindowLongPtrW")
-	procGetWindowLongPtrW = moduser32.NewProc("GetWindowLongPtrW")
-	procGetClientRect     = moduser32.NewProc("GetClientRect")
-	procValidateRect      = moduser32.NewProc("ValidateRect")
-	procInvalidateRect    = moduser32.NewProc("InvalidateRect")
+	procGetModuleHandleW            = modkernel32.NewProc("GetModuleHandleW")
+	procCoInitializeEx              = modole32.NewProc("CoInitializeEx")
+	procCoUninitialize              = modole32.NewProc("CoUninitialize")
+	procCoTaskMemFree               = modole32.NewProc("CoTaskMemFree")
+	procMessageBoxExW               = moduser32.NewProc("MessageBoxExW")
+	procLoadIconW                   = moduser32.NewProc("LoadIconW")
+	procLoadCursorW                 = moduser32.NewProc("LoadCursorW")
+	procRegisterClassExW            = moduser32.NewProc("RegisterClassExW")
+	procUnregisterClassW            = moduser32.NewProc("UnregisterClassW")
+	procCreateWindowExW             = moduser32.NewProc("CreateWindowExW")
+	procShowWindow                  = moduser32.NewProc("ShowWindow")
+	procUpdateWindow                = moduser32.NewProc("UpdateWindow")
+	procDefWindowProcW              = moduser32.NewProc("DefWindowProcW")
+	procGetMessageW                 = moduser32.NewProc("GetMessageW")
+	procTranslateMessage            = moduser32.NewProc("TranslateMessage")
+	procDispatchMessageW            = moduser32.NewProc("DispatchMessageW")
+	procPostQuitMessage             = moduser32.NewProc("PostQuitMessage")
+	procSetWindowLongPtrW           = moduser32.NewProc("SetWindowLongPtrW")
+	procGetWindowLongPtrW           = moduser32.NewProc("GetWindowLongPtrW")
+	procGetClientRect               = moduser32.NewProc("GetClientRect")
+	procValidateRect                = moduser32.NewProc("ValidateRect")
+	procInvalidateRect              = moduser32.NewProc("InvalidateRect")
+	procGetUpdateRgn                = moduser32.NewProc("GetUpdateRgn")
+	procCreateRectRgn               = modgdi32.NewProc("CreateRectRgn")
+	procGetRegionData               = modgdi32.NewProc("GetRegionData")
+	procGetOpenFileNameW            = modcomdlg32.NewProc("GetOpenFileNameW")
+	procGetSaveFileNameW            = modcomdlg32.NewProc("GetSaveFileNameW")
+	procChooseColorW                = modcomdlg32.NewProc("ChooseColorW")
+	procCommDlgExtendedError        = modcomdlg32.NewProc("CommDlgExtendedError")
+	procSHBrowseForFolderW          = modshell32.NewProc("SHBrowseForFolderW")
+	procSHGetPathFromIDListW        = modshell32.NewProc("SHGetPathFromIDListW")
+	procPostMessageW                = moduser32.NewProc("PostMessageW")
+	procCreateMenu                  = moduser32.NewProc("CreateMenu")
+	procCreatePopupMenu             = moduser32.NewProc("CreatePopupMenu")
+	procDestroyMenu                 = moduser32.NewProc("DestroyMenu")
+	procAppendMenuW                 = moduser32.NewProc("AppendMenuW")
+	procCheckMenuRadioItem          = moduser32.NewProc("CheckMenuRadioItem")
+	procSetMenu                     = moduser32.NewProc("SetMenu")
+	procDrawMenuBar                 = moduser32.NewProc("DrawMenuBar")
+	procTrackPopupMenuEx            = moduser32.NewProc("TrackPopupMenuEx")
+	procGetCursorPos                = moduser32.NewProc("GetCursorPos")
+	procSetForegroundWindow         = moduser32.NewProc("SetForegroundWindow")
+	procGetKeyState                 = moduser32.NewProc("GetKeyState")
+	procDestroyWindow               = moduser32.NewProc("DestroyWindow")
+	procCreateIconIndirect          = moduser32.NewProc("CreateIconIndirect")
+	procDestroyIcon                 = moduser32.NewProc("DestroyIcon")
+	procCreateBitmap                = modgdi32.NewProc("CreateBitmap")
+	procDeleteObject                = modgdi32.NewProc("DeleteObject")
+	procBeginPaint                  = moduser32.NewProc("BeginPaint")
+	procEndPaint                    = moduser32.NewProc("EndPaint")
+	procCreateCompatibleDC          = modgdi32.NewProc("CreateCompatibleDC")
+	procDeleteDC                    = modgdi32.NewProc("DeleteDC")
+	procCreateDIBSection            = modgdi32.NewProc("CreateDIBSection")
+	procSelectObject                = modgdi32.NewProc("SelectObject")
+	procBitBlt                      = modgdi32.NewProc("BitBlt")
+	procGdiFlush                    = modgdi32.NewProc("GdiFlush")
+	procGetDC                       = moduser32.NewProc("GetDC")
+	procReleaseDC                   = moduser32.NewProc("ReleaseDC")
+	procChoosePixelFormat           = modgdi32.NewProc("ChoosePixelFormat")
+	procSetPixelFormat              = modgdi32.NewProc("SetPixelFormat")
+	procSwapBuffers                 = modgdi32.NewProc("SwapBuffers")
+	procwglCreateContext            = modopengl32.NewProc("wglCreateContext")
+	procwglDeleteContext            = modopengl32.NewProc("wglDeleteContext")
+	procwglMakeCurrent              = modopengl32.NewProc("wglMakeCurrent")
+	procwglGetProcAddress           = modopengl32.NewProc("wglGetProcAddress")
+	procShell_NotifyIconW           = modshell32.NewProc("Shell_NotifyIconW")
+	procGetMessageTime              = moduser32.NewProc("GetMessageTime")
+	procGetMessagePos               = moduser32.NewProc("GetMessagePos")
+	procGetTickCount                = modkernel32.NewProc("GetTickCount")
+	procScreenToClient              = moduser32.NewProc("ScreenToClient")
+	procSetTimer                    = moduser32.NewProc("SetTimer")
+	procKillTimer                   = moduser32.NewProc("KillTimer")
+	procMsgWaitForMultipleObjectsEx = moduser32.NewProc("MsgWaitForMultipleObjectsEx")
+	procPeekMessageW                = moduser32.NewProc("PeekMessageW")
 )
 
 func GetModuleHandle(modulename *uint16) (module windows.Handle, err error) {
@@ -76,14 +134,10 @@ func GetModuleHandle(modulename *uint16) (module windows.Handle, err error) {
 	return
 }
 
-func CoInitializeEx(reserved uintptr, coInit uint32) (err error) {
-	r1, _, e1 := syscall.Syscall(procCoInitializeEx.Addr(), 2, uintptr(reserved), uintptr(coInit), 0)
-	if r1 != 0 {
-		if e1 != 0 {
-			err = errnoErr(e1)
-		} else {
-			err = syscall.EINVAL
-		}
+func CoInitializeEx(reserved uintptr, coInit uint32) (ret error) {
+	r0, _, _ := syscall.Syscall(procCoInitializeEx.Addr(), 2, uintptr(reserved), uintptr(coInit), 0)
+	if r0 != 0 {
+		ret = syscall.Errno(r0)
 	}
 	return
 }
@@ -93,6 +147,11 @@ func CoUninitialize() {
 	return
 }
 
+func CoTaskMemFree(address uintptr) {
+	syscall.Syscall(procCoTaskMemFree.Addr(), 1, uintptr(address), 0, 0)
+	return
+}
+
 func MessageBoxEx(window windows.Handle, text *uint16, caption *uint16, style uint32, languageID uint16) (id int32, err error) {
 	r0, _, e1 := syscall.Syscall6(procMessageBoxExW.Addr(), 5, uintptr(window), uintptr(unsafe.Pointer(text)), uintptr(unsafe.Pointer(caption)), uintptr(style), uintptr(languageID), 0)
 	id = int32(r0)
@@ -145,6 +204,18 @@ func RegisterClassEx(class *WndClassEx) (atom Atom, err error) {
 	return
 }
 
+func UnregisterClass(className *uint16, instance windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procUnregisterClassW.Addr(), 2, uintptr(unsafe.Pointer(className)), uintptr(instance), 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
 func CreateWindowEx(exStyle uint32, classname *uint16, windowname *uint16, style uint32, x int32, y int32, width int32, height int32, parent windows.Handle, menu windows.Handle, instance windows.Handle, lparam uintptr) (window windows.Handle, err error) {
 	r0, _, e1 := syscall.Syscall12(procCreateWindowExW.Addr(), 12, uintptr(exStyle), uintptr(unsafe.Pointer(classname)), uintptr(unsafe.Pointer(windowname)), uintptr(style), uintptr(x), uintptr(y), uintptr(width), uintptr(height), uintptr(parent), uintptr(menu), uintptr(instance), uintptr(lparam))
 	window = windows.Handle(r0)
@@ -305,3 +376,533 @@ func InvalidateRect(window windows.Handle, rect *Rect, erase bool) (err error) {
 	}
 	return
 }
+
+func GetUpdateRgn(window windows.Handle, region windows.Handle, erase bool) (result int32) {
+	var _p0 uint32
+	if erase {
+		_p0 = 1
+	} else {
+		_p0 = 0
+	}
+	r0, _, _ := syscall.Syscall(procGetUpdateRgn.Addr(), 3, uintptr(window), uintptr(region), uintptr(_p0))
+	result = int32(r0)
+	return
+}
+
+func CreateRectRgn(left int32, top int32, right int32, bottom int32) (region windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall6(procCreateRectRgn.Addr(), 4, uintptr(left), uintptr(top), uintptr(right), uintptr(bottom), 0, 0)
+	region = windows.Handle(r0)
+	if region == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func GetRegionData(region windows.Handle, count uint32, data *byte) (result uint32) {
+	r0, _, _ := syscall.Syscall(procGetRegionData.Addr(), 3, uintptr(region), uintptr(count), uintptr(unsafe.Pointer(data)))
+	result = uint32(r0)
+	return
+}
+
+func GetOpenFileName(ofn *OpenFileName) (ok bool) {
+	r0, _, _ := syscall.Syscall(procGetOpenFileNameW.Addr(), 1, uintptr(unsafe.Pointer(ofn)), 0, 0)
+	ok = r0 != 0
+	return
+}
+
+func GetSaveFileName(ofn *OpenFileName) (ok bool) {
+	r0, _, _ := syscall.Syscall(procGetSaveFileNameW.Addr(), 1, uintptr(unsafe.Pointer(ofn)), 0, 0)
+	ok = r0 != 0
+	return
+}
+
+func ChooseColor(cc *ChooseColorInfo) (ok bool) {
+	r0, _, _ := syscall.Syscall(procChooseColorW.Addr(), 1, uintptr(unsafe.Pointer(cc)), 0, 0)
+	ok = r0 != 0
+	return
+}
+
+func CommDlgExtendedError() (code uint32) {
+	r0, _, _ := syscall.Syscall(procCommDlgExtendedError.Addr(), 0, 0, 0, 0)
+	code = uint32(r0)
+	return
+}
+
+func SHBrowseForFolder(bi *BrowseInfo) (idl uintptr) {
+	r0, _, _ := syscall.Syscall(procSHBrowseForFolderW.Addr(), 1, uintptr(unsafe.Pointer(bi)), 0, 0)
+	idl = uintptr(r0)
+	return
+}
+
+func SHGetPathFromIDList(idl uintptr, path *uint16) (ok bool) {
+	r0, _, _ := syscall.Syscall(procSHGetPathFromIDListW.Addr(), 2, uintptr(idl), uintptr(unsafe.Pointer(path)), 0)
+	ok = r0 != 0
+	return
+}
+
+func PostMessage(window windows.Handle, message uint32, wParam uintptr, lParam uintptr) (err error) {
+	r1, _, e1 := syscall.Syscall6(procPostMessageW.Addr(), 4, uintptr(window), uintptr(message), uintptr(wParam), uintptr(lParam), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func CreateMenu() (menu windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procCreateMenu.Addr(), 0, 0, 0, 0)
+	menu = windows.Handle(r0)
+	if menu == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func CreatePopupMenu() (menu windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procCreatePopupMenu.Addr(), 0, 0, 0, 0)
+	menu = windows.Handle(r0)
+	if menu == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func DestroyMenu(menu windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procDestroyMenu.Addr(), 1, uintptr(menu), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func AppendMenu(menu windows.Handle, flags uint32, idNewItem uintptr, newItem *uint16) (err error) {
+	r1, _, e1 := syscall.Syscall6(procAppendMenuW.Addr(), 4, uintptr(menu), uintptr(flags), uintptr(idNewItem), uintptr(unsafe.Pointer(newItem)), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func CheckMenuRadioItem(menu windows.Handle, first uint32, last uint32, check uint32, flags uint32) (err error) {
+	r1, _, e1 := syscall.Syscall6(procCheckMenuRadioItem.Addr(), 5, uintptr(menu), uintptr(first), uintptr(last), uintptr(check), uintptr(flags), 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func SetMenu(window windows.Handle, menu windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procSetMenu.Addr(), 2, uintptr(window), uintptr(menu), 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func DrawMenuBar(window windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procDrawMenuBar.Addr(), 1, uintptr(window), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func TrackPopupMenuEx(menu windows.Handle, flags uint32, x int32, y int32, window windows.Handle, params uintptr) (result int32, err error) {
+	r0, _, e1 := syscall.Syscall6(procTrackPopupMenuEx.Addr(), 6, uintptr(menu), uintptr(flags), uintptr(x), uintptr(y), uintptr(window), uintptr(params))
+	result = int32(r0)
+	if result == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func GetCursorPos(point *Point) (err error) {
+	r1, _, e1 := syscall.Syscall(procGetCursorPos.Addr(), 1, uintptr(unsafe.Pointer(point)), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func SetForegroundWindow(window windows.Handle) (ok bool) {
+	r0, _, _ := syscall.Syscall(procSetForegroundWindow.Addr(), 1, uintptr(window), 0, 0)
+	ok = r0 != 0
+	return
+}
+
+func GetKeyState(virtKey int32) (state int16) {
+	r0, _, _ := syscall.Syscall(procGetKeyState.Addr(), 1, uintptr(virtKey), 0, 0)
+	state = int16(r0)
+	return
+}
+
+func DestroyWindow(window windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procDestroyWindow.Addr(), 1, uintptr(window), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func CreateIconIndirect(info *IconCursorInfo) (icon windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procCreateIconIndirect.Addr(), 1, uintptr(unsafe.Pointer(info)), 0, 0)
+	icon = windows.Handle(r0)
+	if icon == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func DestroyIcon(icon windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procDestroyIcon.Addr(), 1, uintptr(icon), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func CreateBitmap(width int32, height int32, planes uint32, bitCount uint32, bits unsafe.Pointer) (bitmap windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall6(procCreateBitmap.Addr(), 5, uintptr(width), uintptr(height), uintptr(planes), uintptr(bitCount), uintptr(bits), 0)
+	bitmap = windows.Handle(r0)
+	if bitmap == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func DeleteObject(object windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procDeleteObject.Addr(), 1, uintptr(object), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func BeginPaint(window windows.Handle, paint *PaintStruct) (dc windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procBeginPaint.Addr(), 2, uintptr(window), uintptr(unsafe.Pointer(paint)), 0)
+	dc = windows.Handle(r0)
+	if dc == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func EndPaint(window windows.Handle, paint *PaintStruct) (ok bool) {
+	r0, _, _ := syscall.Syscall(procEndPaint.Addr(), 2, uintptr(window), uintptr(unsafe.Pointer(paint)), 0)
+	ok = r0 != 0
+	return
+}
+
+func CreateCompatibleDC(dc windows.Handle) (memDC windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procCreateCompatibleDC.Addr(), 1, uintptr(dc), 0, 0)
+	memDC = windows.Handle(r0)
+	if memDC == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func DeleteDC(dc windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procDeleteDC.Addr(), 1, uintptr(dc), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func CreateDIBSection(dc windows.Handle, info *BitmapInfoHeader, usage uint32, bits *unsafe.Pointer, section windows.Handle, offset uint32) (bitmap windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall6(procCreateDIBSection.Addr(), 6, uintptr(dc), uintptr(unsafe.Pointer(info)), uintptr(usage), uintptr(unsafe.Pointer(bits)), uintptr(section), uintptr(offset))
+	bitmap = windows.Handle(r0)
+	if bitmap == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func SelectObject(dc windows.Handle, object windows.Handle) (previous windows.Handle) {
+	r0, _, _ := syscall.Syscall(procSelectObject.Addr(), 2, uintptr(dc), uintptr(object), 0)
+	previous = windows.Handle(r0)
+	return
+}
+
+func BitBlt(dc windows.Handle, x int32, y int32, width int32, height int32, src windows.Handle, srcX int32, srcY int32, rop uint32) (err error) {
+	r1, _, e1 := syscall.Syscall9(procBitBlt.Addr(), 9, uintptr(dc), uintptr(x), uintptr(y), uintptr(width), uintptr(height), uintptr(src), uintptr(srcX), uintptr(srcY), uintptr(rop))
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func GdiFlush() (ok bool) {
+	r0, _, _ := syscall.Syscall(procGdiFlush.Addr(), 0, 0, 0, 0)
+	ok = r0 != 0
+	return
+}
+
+func GetDC(window windows.Handle) (dc windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procGetDC.Addr(), 1, uintptr(window), 0, 0)
+	dc = windows.Handle(r0)
+	if dc == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func ReleaseDC(window windows.Handle, dc windows.Handle) (released int32) {
+	r0, _, _ := syscall.Syscall(procReleaseDC.Addr(), 2, uintptr(window), uintptr(dc), 0)
+	released = int32(r0)
+	return
+}
+
+func ChoosePixelFormat(dc windows.Handle, pfd *PixelFormatDescriptor) (format int32, err error) {
+	r0, _, e1 := syscall.Syscall(procChoosePixelFormat.Addr(), 2, uintptr(dc), uintptr(unsafe.Pointer(pfd)), 0)
+	format = int32(r0)
+	if format == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func SetPixelFormat(dc windows.Handle, format int32, pfd *PixelFormatDescriptor) (err error) {
+	r1, _, e1 := syscall.Syscall(procSetPixelFormat.Addr(), 3, uintptr(dc), uintptr(format), uintptr(unsafe.Pointer(pfd)))
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func SwapBuffers(dc windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procSwapBuffers.Addr(), 1, uintptr(dc), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func WglCreateContext(dc windows.Handle) (context windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall(procwglCreateContext.Addr(), 1, uintptr(dc), 0, 0)
+	context = windows.Handle(r0)
+	if context == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func WglDeleteContext(context windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procwglDeleteContext.Addr(), 1, uintptr(context), 0, 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func WglMakeCurrent(dc windows.Handle, context windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procwglMakeCurrent.Addr(), 2, uintptr(dc), uintptr(context), 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func WglGetProcAddress(name *byte) (proc uintptr) {
+	r0, _, _ := syscall.Syscall(procwglGetProcAddress.Addr(), 1, uintptr(unsafe.Pointer(name)), 0, 0)
+	proc = uintptr(r0)
+	return
+}
+
+func Shell_NotifyIcon(message uint32, data *NotifyIconData) (err error) {
+	r1, _, e1 := syscall.Syscall(procShell_NotifyIconW.Addr(), 2, uintptr(message), uintptr(unsafe.Pointer(data)), 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func GetMessageTime() (time int32) {
+	r0, _, _ := syscall.Syscall(procGetMessageTime.Addr(), 0, 0, 0, 0)
+	time = int32(r0)
+	return
+}
+
+func GetMessagePos() (pos uint32) {
+	r0, _, _ := syscall.Syscall(procGetMessagePos.Addr(), 0, 0, 0, 0)
+	pos = uint32(r0)
+	return
+}
+
+func GetTickCount() (ticks uint32) {
+	r0, _, _ := syscall.Syscall(procGetTickCount.Addr(), 0, 0, 0, 0)
+	ticks = uint32(r0)
+	return
+}
+
+func ScreenToClient(window windows.Handle, point *Point) (err error) {
+	r1, _, e1 := syscall.Syscall(procScreenToClient.Addr(), 2, uintptr(window), uintptr(unsafe.Pointer(point)), 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func SetTimer(window windows.Handle, id uintptr, elapse uint32, timerFunc uintptr) (result uintptr, err error) {
+	r0, _, e1 := syscall.Syscall6(procSetTimer.Addr(), 4, uintptr(window), uintptr(id), uintptr(elapse), uintptr(timerFunc), 0, 0)
+	result = uintptr(r0)
+	if result == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func KillTimer(window windows.Handle, id uintptr) (err error) {
+	r1, _, e1 := syscall.Syscall(procKillTimer.Addr(), 2, uintptr(window), uintptr(id), 0)
+	if r1 == 0 {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func MsgWaitForMultipleObjectsEx(count uint32, handles *windows.Handle, milliseconds uint32, wakeMask uint32, flags uint32) (event uint32, err error) {
+	r0, _, e1 := syscall.Syscall6(procMsgWaitForMultipleObjectsEx.Addr(), 5, uintptr(count), uintptr(unsafe.Pointer(handles)), uintptr(milliseconds), uintptr(wakeMask), uintptr(flags), 0)
+	event = uint32(r0)
+	if event == 0xFFFFFFFF {
+		if e1 != 0 {
+			err = errnoErr(e1)
+		} else {
+			err = syscall.EINVAL
+		}
+	}
+	return
+}
+
+func PeekMessage(message *Msg, window windows.Handle, messageFilterMin uint32, messageFilterMax uint32, removeMessage uint32) (ok bool) {
+	r0, _, _ := syscall.Syscall6(procPeekMessageW.Addr(), 5, uintptr(unsafe.Pointer(message)), uintptr(window), uintptr(messageFilterMin), uintptr(messageFilterMax), uintptr(removeMessage), 0)
+	ok = r0 != 0
+	return
+}